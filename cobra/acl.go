@@ -0,0 +1,66 @@
+package cobra
+
+import (
+	"SimpleHttpServer/store"
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"text/tabwriter"
+)
+
+// aclCmd 目录访问规则管理（直接修改数据目录下的规则文件，服务运行中修改同样即时生效）
+var aclCmd = &cobra.Command{
+	Use:   "acl",
+	Short: "管理目录访问规则（按用户、用户组限制可访问的目录）",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		openACLStore()
+	},
+}
+
+var aclSetCmd = &cobra.Command{
+	Use:   "set <路径> <用户名|group:组名|*> <none|read|write>",
+	Short: "新增或修改规则（路径为上传目录内的相对路径，规则同时作用于子目录）",
+	Args:  cobra.ExactArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		access, err := store.ParseAccess(args[2])
+		exitOnUserError(err)
+		rule := store.ACLRule{Path: args[0], Subject: args[1], Access: access}
+		exitOnUserError(store.ACL.Set(rule))
+		fmt.Printf("已设置规则: %s %s %s\n", store.CleanACLPath(rule.Path), rule.Subject, rule.Access)
+	},
+}
+
+var aclDelCmd = &cobra.Command{
+	Use:   "del <路径> <用户名|group:组名|*>",
+	Short: "删除规则",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		exitOnUserError(store.ACL.Delete(args[0], args[1]))
+		fmt.Printf("已删除规则: %s %s\n", store.CleanACLPath(args[0]), args[1])
+	},
+}
+
+var aclListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "列出所有规则",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		rules, err := store.ACL.List()
+		exitOnUserError(err)
+		if len(rules) == 0 {
+			fmt.Println("未配置目录访问规则，所有用户按角色访问全部目录")
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "路径\t对象\t访问级别")
+		for _, rule := range rules {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", rule.Path, rule.Subject, rule.Access)
+		}
+		w.Flush()
+	},
+}
+
+func init() {
+	aclCmd.AddCommand(aclSetCmd, aclDelCmd, aclListCmd)
+	rootCmd.AddCommand(aclCmd)
+}
@@ -0,0 +1,54 @@
+package cobra
+
+import (
+	. "SimpleHttpServer/config"
+	. "SimpleHttpServer/middleware"
+	"SimpleHttpServer/utils"
+	"SimpleHttpServer/views"
+	"fmt"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"os"
+)
+
+var cleanupDryRun bool
+
+// cleanupCmd 单次清理过期上传（与服务内后台清理任务规则一致），可在服务停止时通过定时任务执行
+var cleanupCmd = &cobra.Command{
+	Use:   "cleanup",
+	Short: "清理空闲超过 --upload-ttl 的未完成上传及孤立临时文件",
+	Run: func(cmd *cobra.Command, args []string) {
+		if GlobalConfig.UploadTTL <= 0 {
+			fmt.Println("--upload-ttl 必须大于0")
+			os.Exit(1)
+		}
+		if _, err := os.Stat(GlobalConfig.UploadDir); err != nil {
+			fmt.Printf("上传目录不可用: %v\n", err)
+			os.Exit(1)
+		}
+
+		result, err := views.RunUploadCleanup(GlobalConfig.UploadTTL, cleanupDryRun)
+		if err != nil {
+			Logger.Error("清理过期上传失败", zap.Error(err))
+			os.Exit(1)
+		}
+		Logger.Info("过期上传清理完成",
+			zap.Int("sessions", result.Sessions),
+			zap.Int("partFiles", result.PartFiles),
+			zap.String("freed", utils.FormatSize(result.Bytes)),
+			zap.Bool("dryRun", cleanupDryRun),
+		)
+
+		action := "已清理"
+		if cleanupDryRun {
+			action = "将清理（试运行，未删除）"
+		}
+		fmt.Printf("%s: 上传会话%d个，孤立临时文件%d个，共%s\n",
+			action, result.Sessions, result.PartFiles, utils.FormatSize(result.Bytes))
+	},
+}
+
+func init() {
+	cleanupCmd.Flags().BoolVar(&cleanupDryRun, "dry-run", false, "只列出将被清理的内容，不做删除")
+	rootCmd.AddCommand(cleanupCmd)
+}
@@ -0,0 +1,612 @@
+package cobra
+
+import (
+	. "SimpleHttpServer/config"
+	"SimpleHttpServer/hooks"
+	. "SimpleHttpServer/middleware"
+	"SimpleHttpServer/store"
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+	"maps"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	configFileEnv      = "SHS_CONFIG"    // 配置文件路径环境变量（未指定 --config 时使用）
+	configEnvPrefix    = "SHS_"          // 配置项环境变量前缀，如 max-size 对应 SHS_MAX_SIZE
+	configPollInterval = 5 * time.Second // 检查配置文件是否修改的间隔
+)
+
+// 配置文件中除命令行参数外的配置段
+const (
+	configIconsKey    = "icons"     // 文件后缀 → 图标（Font Awesome 类名），在内置列表基础上追加或覆盖
+	configTextExtsKey = "text-exts" // 按文本文件处理的后缀，在内置列表基础上追加
+	configHooksKey    = "hooks"     // 文件事件钩子（上传完成、删除、重命名、登录时推送URL或执行命令）
+)
+
+// reloadableSettings 运行中可热加载的配置项，其余配置项修改后需重启服务才能生效
+var reloadableSettings = []string{
+	"max-size", "login-max-attempts", "login-window", "login-lockout", "login-lockout-max", "require-2fa", "log-level",
+}
+
+// secretSettings config print 中隐藏取值的配置项
+var secretSettings = []string{"password", "s3-key", "metrics-token"}
+
+// configValue 配置文件或环境变量中一个配置项的取值
+type configValue struct {
+	items  []string // 取值（列表中的每一项，单个值时只有一项）
+	list   bool     // 是否为列表
+	origin string   // 来源，用于错误提示和 config print
+}
+
+// configSources 合并后的配置文件和环境变量
+type configSources struct {
+	settings map[string]configValue // 配置项名称（与命令行参数名相同）→ 取值，环境变量覆盖配置文件
+	icons    map[string]string
+	textExts []string
+	hooks    []hooks.Hook
+}
+
+var (
+	rootFlags      *pflag.FlagSet         // 根命令的全局参数（在init中赋值，避免与rootCmd初始化循环引用）
+	loadedSettings map[string]configValue // 启动时加载的配置，重新加载时据此判断哪些需重启的配置项被修改
+	settingOrigins = map[string]string{}  // 配置项 → 实际生效值的来源（config print 使用）
+)
+
+// configCmd 配置文件相关命令
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "查看合并命令行参数、环境变量和配置文件后的实际配置",
+}
+
+var configPrintCmd = &cobra.Command{
+	Use:   "print",
+	Short: "以YAML格式输出实际生效的配置（可直接作为配置文件使用，密码和密钥已隐藏）",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		data, err := effectiveConfigYAML()
+		exitOnUserError(err)
+		fmt.Print(string(data))
+	},
+}
+
+// settingFlags 返回可通过配置文件和环境变量设置的命令行参数（配置项名称 → 参数）
+func settingFlags() map[string]*pflag.Flag {
+	flags := map[string]*pflag.Flag{}
+	rootFlags.VisitAll(func(f *pflag.Flag) {
+		name := strings.TrimLeft(f.Name, "-")
+		if name != "config" {
+			flags[name] = f
+		}
+	})
+	return flags
+}
+
+// settingEnv 返回配置项对应的环境变量名
+func settingEnv(name string) string {
+	return configEnvPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// initConfig 命令执行前加载配置文件和环境变量，未在命令行中指定的参数按 环境变量 > 配置文件 > 默认值 取值，
+// 配置无效时输出错误并退出
+func initConfig() {
+	if GlobalConfig.ConfigFile == "" {
+		GlobalConfig.ConfigFile = os.Getenv(configFileEnv)
+	}
+	sources, err := readConfigSources(GlobalConfig.ConfigFile)
+	if err != nil {
+		fmt.Printf("加载配置失败: %v\n", err)
+		os.Exit(1)
+	}
+	for name, f := range settingFlags() {
+		value, ok := sources.settings[name]
+		switch {
+		case f.Changed:
+			settingOrigins[name] = "命令行参数"
+		case ok:
+			if err := setFlag(f, value); err != nil {
+				fmt.Printf("加载配置失败: %s 中的配置项 %s 无效: %v\n", value.origin, name, err)
+				os.Exit(1)
+			}
+			settingOrigins[name] = value.origin
+		default:
+			settingOrigins[name] = "默认值"
+		}
+	}
+	loadedSettings = sources.settings
+	GlobalConfig.SetFileTypes(sources.icons, sources.textExts)
+	hooks.Set(sources.hooks)
+	if err := SetLogLevel(GlobalConfig.LogLevel); err != nil {
+		fmt.Printf("--log-level 无效: %v\n", err)
+		os.Exit(1)
+	}
+	if err := SetupLogger(LogOptions{
+		Dir:        GlobalConfig.LogDir,
+		Format:     GlobalConfig.LogFormat,
+		MaxSize:    GlobalConfig.LogMaxSize,
+		MaxBackups: GlobalConfig.LogMaxBackups,
+		MaxAge:     GlobalConfig.LogMaxAge,
+		AccessLog:  GlobalConfig.AccessLog,
+	}); err != nil {
+		fmt.Printf("日志配置无效: %v\n", err)
+		os.Exit(1)
+	}
+	if GlobalConfig.ConfigFile != "" {
+		Logger.Debug("配置文件加载完成", zap.String("file", GlobalConfig.ConfigFile), zap.Int("settings", len(sources.settings)))
+	}
+}
+
+// readConfigSources 读取配置文件（path为空时跳过）和 SHS_ 开头的环境变量
+func readConfigSources(path string) (configSources, error) {
+	sources := configSources{settings: map[string]configValue{}}
+	flags := settingFlags()
+	if path != "" {
+		if err := readConfigFile(path, flags, &sources); err != nil {
+			return sources, err
+		}
+	}
+	for name, f := range flags {
+		env := settingEnv(name)
+		raw, ok := os.LookupEnv(env)
+		if !ok {
+			continue
+		}
+		value := configValue{items: []string{raw}, origin: "环境变量 " + env}
+		if _, ok := f.Value.(pflag.SliceValue); ok {
+			// 列表类参数在环境变量中以逗号分隔
+			value.items, value.list = splitList(raw), true
+		}
+		sources.settings[name] = value
+	}
+	return sources, nil
+}
+
+// readConfigFile 按后缀解析YAML（.yaml/.yml）或TOML（.toml）配置文件，顶层键为命令行参数名（不带--），
+// 另可包含 icons、text-exts、hooks 三个配置段；存在未知配置项或取值类型不符时返回错误
+func readConfigFile(path string, flags map[string]*pflag.Flag, sources *configSources) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var raw map[string]any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return fmt.Errorf("不支持的配置文件格式: %s（支持 .yaml、.yml、.toml）", path)
+	}
+	if err != nil {
+		return fmt.Errorf("解析配置文件 %s 失败: %w", path, err)
+	}
+
+	origin := "配置文件 " + path
+	var unknown []string
+	for key, value := range raw {
+		switch key {
+		case configIconsKey:
+			icons, ok := value.(map[string]any)
+			if !ok {
+				return fmt.Errorf("%s 中的 %s 应为 后缀: 图标类名 的映射", origin, key)
+			}
+			sources.icons = map[string]string{}
+			for ext, icon := range icons {
+				class, ok := icon.(string)
+				if !ok || class == "" {
+					return fmt.Errorf("%s 中 %s.%s 的图标类名应为非空字符串", origin, key, ext)
+				}
+				sources.icons[normalizeExt(ext)] = class
+			}
+		case configTextExtsKey:
+			exts, ok := value.([]any)
+			if !ok {
+				return fmt.Errorf("%s 中的 %s 应为后缀列表", origin, key)
+			}
+			for _, ext := range exts {
+				s, ok := ext.(string)
+				if !ok || normalizeExt(s) == "" {
+					return fmt.Errorf("%s 中的 %s 包含无效的后缀: %v", origin, key, ext)
+				}
+				sources.textExts = append(sources.textExts, normalizeExt(s))
+			}
+		case configHooksKey:
+			sources.hooks, err = hooks.Parse(value)
+			if err != nil {
+				return fmt.Errorf("%s 中的 %s %v", origin, key, err)
+			}
+		default:
+			if _, ok := flags[key]; !ok {
+				unknown = append(unknown, key)
+				continue
+			}
+			items, list, err := configItems(value)
+			if err != nil {
+				return fmt.Errorf("%s 中的配置项 %s %v", origin, key, err)
+			}
+			sources.settings[key] = configValue{items: items, list: list, origin: origin}
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("%s 中存在未知配置项: %s（配置项名称与命令行参数名相同，如 port、max-size，执行 --help 查看全部参数）",
+			origin, strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// configItems 将配置文件中的取值转换为字符串，列表逐项转换
+func configItems(value any) ([]string, bool, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, false, errors.New("没有取值")
+	case map[string]any:
+		return nil, false, errors.New("应为单个值或列表，不能是映射")
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			switch item.(type) {
+			case map[string]any, []any, nil:
+				return nil, false, errors.New("列表中只能包含单个值")
+			}
+			items = append(items, fmt.Sprint(item))
+		}
+		return items, true, nil
+	default:
+		return []string{fmt.Sprint(v)}, false, nil
+	}
+}
+
+// setFlag 将配置值写入命令行参数（不标记为已在命令行中指定），列表类参数整体替换
+func setFlag(f *pflag.Flag, value configValue) error {
+	if slice, ok := f.Value.(pflag.SliceValue); ok {
+		items := value.items
+		if !value.list {
+			items = splitList(value.items[0])
+		}
+		return slice.Replace(items)
+	}
+	if value.list {
+		return errors.New("应为单个值，不能是列表")
+	}
+	return f.Value.Set(value.items[0])
+}
+
+// resetFlag 将命令行参数恢复为默认值（配置项从配置文件中删除后重新加载时使用）
+func resetFlag(f *pflag.Flag) error {
+	if slice, ok := f.Value.(pflag.SliceValue); ok {
+		return slice.Replace(splitList(strings.Trim(f.DefValue, "[]")))
+	}
+	return f.Value.Set(f.DefValue)
+}
+
+// splitList 按逗号拆分列表，忽略空项
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// normalizeExt 统一文件后缀格式（小写、不带.）
+func normalizeExt(ext string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+}
+
+// applyConfig 校验所有配置并计算派生值（服务启动时调用）
+func applyConfig() error {
+	GlobalConfig.ChunkSize = chunkSizeMB * 1024 * 1024
+	if chunkSizeMB <= 0 {
+		return errors.New("--chunk 必须大于0")
+	}
+	if fileToORMaxZize > 30*1024 || fileToORMaxZize <= 2048 {
+		return errors.New("强制限制最大转OR体积大于2KB，小于30KB!!!")
+	}
+	GlobalConfig.FileToORMaxZize = fileToORMaxZize
+	GlobalConfig.UserName = username
+	GlobalConfig.Password = password
+	GlobalConfig.S3Keys = map[string]S3Key{}
+	for _, item := range s3Keys {
+		parts := strings.Split(item, ":")
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			return fmt.Errorf("S3访问密钥格式错误: %s（应为 AccessKey:SecretKey:用户名）", item)
+		}
+		GlobalConfig.S3Keys[parts[0]] = S3Key{SecretKey: parts[1], User: parts[2]}
+	}
+	if GlobalConfig.S3Port > 0 && len(GlobalConfig.S3Keys) == 0 {
+		return errors.New("启用S3接口（--s3-port）时必须通过 --s3-key 配置至少一个访问密钥")
+	}
+	if (GlobalConfig.TLSCert == "") != (GlobalConfig.TLSKey == "") {
+		return errors.New("--tls-cert 和 --tls-key 必须同时指定")
+	}
+	if GlobalConfig.HTTPRedirectPort > 0 && !tlsEnabled() {
+		return errors.New("--http-redirect-port 需要启用HTTPS（--tls-cert/--tls-key 或 --tls-self-signed）")
+	}
+	if GlobalConfig.CookieSecure != "auto" && GlobalConfig.CookieSecure != "always" && GlobalConfig.CookieSecure != "never" {
+		return fmt.Errorf("--cookie-secure 无效: %s（可选 auto/always/never）", GlobalConfig.CookieSecure)
+	}
+	if GlobalConfig.SessionStore != "cookie" && GlobalConfig.SessionStore != "file" {
+		return fmt.Errorf("--session-store 无效: %s（可选 cookie/file）", GlobalConfig.SessionStore)
+	}
+	if minFreeSpaceMB < 0 {
+		return errors.New("--min-free-space 不能小于0")
+	}
+	GlobalConfig.MinFreeSpace = minFreeSpaceMB * 1024 * 1024
+	GlobalConfig.MetricsAllowNets = nil
+	for _, item := range GlobalConfig.MetricsAllow {
+		network, err := parseIPNet(item)
+		if err != nil {
+			return fmt.Errorf("--metrics-allow 无效: %v", err)
+		}
+		GlobalConfig.MetricsAllowNets = append(GlobalConfig.MetricsAllowNets, network)
+	}
+	for name, port := range map[string]int64{"--port": GlobalConfig.Port, "--s3-port": GlobalConfig.S3Port, "--http-redirect-port": GlobalConfig.HTTPRedirectPort} {
+		if port < 0 || port > 65535 || (name == "--port" && port == 0) {
+			return fmt.Errorf("%s 无效: %d（应为1~65535）", name, port)
+		}
+	}
+	return applyReloadable()
+}
+
+// parseIPNet 解析IP地址或CIDR网段，单个IP视为只包含该地址的网段
+func parseIPNet(s string) (*net.IPNet, error) {
+	if strings.Contains(s, "/") {
+		_, network, err := net.ParseCIDR(s)
+		return network, err
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("%s 不是合法的IP地址或CIDR网段", s)
+	}
+	bits := 8 * len(ip)
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 32
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+// applyReloadable 校验可热加载的配置项并计算派生值（启动时及重新加载时在 GlobalConfig.Reload 中调用）
+func applyReloadable() error {
+	if maxFileSizeGB <= 0 {
+		return errors.New("--max-size 必须大于0")
+	}
+	GlobalConfig.MaxFileSize = maxFileSizeGB * 1024 * 1024 * 1024
+	if GlobalConfig.LoginMaxAttempts > 0 && (GlobalConfig.LoginLockout <= 0 || GlobalConfig.LoginLockoutMax < GlobalConfig.LoginLockout) {
+		return errors.New("--login-lockout 必须大于0，且 --login-lockout-max 不能小于 --login-lockout")
+	}
+	for i, name := range GlobalConfig.TOTPRequiredRoles {
+		role, err := store.ParseRole(name)
+		if err != nil {
+			return fmt.Errorf("--require-2fa 无效: %v", err)
+		}
+		GlobalConfig.TOTPRequiredRoles[i] = string(role)
+	}
+	return SetLogLevel(GlobalConfig.LogLevel)
+}
+
+// lockoutPolicy 返回当前配置的登录失败锁定策略
+func lockoutPolicy() store.LockoutPolicy {
+	return store.LockoutPolicy{
+		MaxAttempts: GlobalConfig.LoginMaxAttempts,
+		Window:      GlobalConfig.LoginWindow,
+		BaseLockout: GlobalConfig.LoginLockout,
+		MaxLockout:  GlobalConfig.LoginLockoutMax,
+	}
+}
+
+// watchConfigFile 指定了配置文件时，收到SIGHUP或检测到配置文件修改后重新加载配置
+func watchConfigFile() {
+	if GlobalConfig.ConfigFile == "" {
+		return
+	}
+	var modTime time.Time
+	if info, err := os.Stat(GlobalConfig.ConfigFile); err == nil {
+		modTime = info.ModTime()
+	}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		ticker := time.NewTicker(configPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-hup:
+			case <-ticker.C:
+				info, err := os.Stat(GlobalConfig.ConfigFile)
+				if err != nil || info.ModTime().Equal(modTime) {
+					continue
+				}
+				modTime = info.ModTime()
+			}
+			reloadConfig()
+		}
+	}()
+	Logger.Info("已启用配置热加载", zap.String("file", GlobalConfig.ConfigFile), zap.Strings("reloadable", reloadableSettings))
+}
+
+// reloadConfig 重新读取配置文件和环境变量，应用可热加载的配置项（命令行中指定的参数保持不变）；
+// 配置无效时继续使用原配置，需重启才能生效的配置项被修改时记录警告
+func reloadConfig() {
+	sources, err := readConfigSources(GlobalConfig.ConfigFile)
+	if err != nil {
+		Logger.Error("重新加载配置失败，继续使用原配置", zap.String("file", GlobalConfig.ConfigFile), zap.Error(err))
+		return
+	}
+	flags := settingFlags()
+	var changed, restart []string
+	err = GlobalConfig.Reload(func() error {
+		previous := map[string]string{}
+		for _, name := range reloadableSettings {
+			previous[name] = flags[name].Value.String()
+		}
+		restore := func() {
+			for _, name := range reloadableSettings {
+				if slice, ok := flags[name].Value.(pflag.SliceValue); ok {
+					slice.Replace(splitList(strings.Trim(previous[name], "[]")))
+				} else {
+					flags[name].Value.Set(previous[name])
+				}
+			}
+			applyReloadable()
+		}
+		for _, name := range reloadableSettings {
+			f := flags[name]
+			if f.Changed {
+				continue
+			}
+			value, ok := sources.settings[name]
+			if !ok {
+				if err := resetFlag(f); err != nil {
+					restore()
+					return err
+				}
+			} else if err := setFlag(f, value); err != nil {
+				restore()
+				return fmt.Errorf("%s 中的配置项 %s 无效: %w", value.origin, name, err)
+			}
+		}
+		if err := applyReloadable(); err != nil {
+			restore()
+			return err
+		}
+		GlobalConfig.SetFileTypes(sources.icons, sources.textExts)
+		hooks.Set(sources.hooks)
+		for _, name := range reloadableSettings {
+			if flags[name].Value.String() != previous[name] {
+				changed = append(changed, name)
+				settingOrigins[name] = "默认值"
+				if value, ok := sources.settings[name]; ok {
+					settingOrigins[name] = value.origin
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		Logger.Error("重新加载配置失败，继续使用原配置", zap.String("file", GlobalConfig.ConfigFile), zap.Error(err))
+		return
+	}
+	store.Lockouts.SetPolicy(lockoutPolicy())
+
+	for name, f := range flags {
+		if f.Changed || slices.Contains(reloadableSettings, name) {
+			continue
+		}
+		before, after := loadedSettings[name], sources.settings[name]
+		if !slices.Equal(before.items, after.items) || before.list != after.list {
+			restart = append(restart, name)
+		}
+	}
+	sort.Strings(restart)
+	Logger.Info("配置已重新加载", zap.String("file", GlobalConfig.ConfigFile), zap.Strings("changed", changed), zap.Int("hooks", len(sources.hooks)))
+	if len(restart) > 0 {
+		Logger.Warn("以下配置项修改后需重启服务才能生效", zap.Strings("settings", restart))
+	}
+}
+
+// effectiveConfigYAML 生成实际生效的配置（YAML），每项以注释标明来源
+func effectiveConfigYAML() ([]byte, error) {
+	doc := &yaml.Node{Kind: yaml.MappingNode}
+	if GlobalConfig.ConfigFile != "" {
+		doc.HeadComment = "配置文件: " + GlobalConfig.ConfigFile
+	} else {
+		doc.HeadComment = "未使用配置文件"
+	}
+	flags := settingFlags()
+	names := slices.Sorted(maps.Keys(flags))
+	for _, name := range names {
+		f := flags[name]
+		value := &yaml.Node{Kind: yaml.ScalarNode, Value: f.Value.String(), LineComment: settingOrigins[name]}
+		if slice, ok := f.Value.(pflag.SliceValue); ok {
+			value = &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle, LineComment: settingOrigins[name]}
+			for _, item := range slice.GetSlice() {
+				if slices.Contains(secretSettings, name) {
+					accessKey, _, _ := strings.Cut(item, ":")
+					item = accessKey + ":******"
+				}
+				value.Content = append(value.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: item})
+			}
+		} else if f.Value.Type() == "string" {
+			value.Tag = "!!str"
+			if slices.Contains(secretSettings, name) {
+				value.Value = "******"
+			}
+		}
+		doc.Content = append(doc.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: name}, value)
+	}
+
+	var icons, textExts yaml.Node
+	if err := icons.Encode(GlobalConfig.FileIconMap); err != nil {
+		return nil, err
+	}
+	exts := slices.Sorted(maps.Keys(TextFileExts))
+	if err := textExts.Encode(exts); err != nil {
+		return nil, err
+	}
+	textExts.Style = yaml.FlowStyle
+	doc.Content = append(doc.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Value: configIconsKey, HeadComment: "内置列表与配置文件合并后的结果"}, &icons,
+		&yaml.Node{Kind: yaml.ScalarNode, Value: configTextExtsKey}, &textExts,
+	)
+	if current := hooks.Current(); len(current) > 0 {
+		var hookList yaml.Node
+		if err := hookList.Encode(hookSettings(current)); err != nil {
+			return nil, err
+		}
+		doc.Content = append(doc.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: configHooksKey}, &hookList)
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), encoder.Close()
+}
+
+// hookSettings 将钩子转换为配置文件格式（签名密钥已隐藏）
+func hookSettings(list []hooks.Hook) []map[string]any {
+	settings := make([]map[string]any, 0, len(list))
+	for _, hook := range list {
+		setting := map[string]any{"name": hook.Name, "events": hook.Events, "timeout": hook.Timeout.String()}
+		if len(hook.Paths) > 0 {
+			setting["paths"] = hook.Paths
+		}
+		if hook.URL != "" {
+			setting["url"] = hook.URL
+			setting["retries"] = hook.Retries
+			if hook.Secret != "" {
+				setting["secret"] = "******"
+			}
+		} else {
+			setting["command"] = hook.Command
+		}
+		settings = append(settings, setting)
+	}
+	return settings
+}
+
+func init() {
+	rootFlags = rootCmd.PersistentFlags()
+	cobra.OnInitialize(initConfig)
+	configCmd.AddCommand(configPrintCmd)
+	rootCmd.AddCommand(configCmd)
+}
@@ -0,0 +1,67 @@
+package cobra
+
+import (
+	"SimpleHttpServer/store"
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+var lockoutClearAll bool
+
+// lockoutCmd 登录锁定管理（直接修改数据目录下的登录失败记录，服务运行中修改同样即时生效）
+var lockoutCmd = &cobra.Command{
+	Use:   "lockout",
+	Short: "管理登录失败锁定（列出、解除）",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		openLockoutStore()
+	},
+}
+
+var lockoutListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "列出登录失败记录及锁定状态",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		attempts, err := store.Lockouts.List()
+		exitOnUserError(err)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "类型\tIP/用户名\t连续失败次数\t最后失败时间\t锁定至")
+		for _, attempt := range attempts {
+			kind, name, _ := strings.Cut(attempt.Key, ":")
+			lockedUntil := "-"
+			if attempt.Locked() {
+				lockedUntil = attempt.LockedUntil.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n",
+				kind, name, attempt.Failures, attempt.LastFailure.Format("2006-01-02 15:04:05"), lockedUntil)
+		}
+		w.Flush()
+	},
+}
+
+var lockoutClearCmd = &cobra.Command{
+	Use:   "clear [IP或用户名...]",
+	Short: "解除锁定并清除失败计数（--all 清除所有记录）",
+	Run: func(cmd *cobra.Command, args []string) {
+		if lockoutClearAll == (len(args) > 0) {
+			exitOnUserError(fmt.Errorf("请指定要解除锁定的IP或用户名，或使用 --all"))
+		}
+		var keys []string
+		for _, arg := range args {
+			// 同一个值既可能是IP也可能是用户名，两种记录一并清除
+			keys = append(keys, "ip:"+arg, "user:"+arg)
+		}
+		cleared, err := store.Lockouts.Clear(keys...)
+		exitOnUserError(err)
+		fmt.Printf("已清除登录失败记录: %d 条\n", cleared)
+	},
+}
+
+func init() {
+	lockoutClearCmd.Flags().BoolVar(&lockoutClearAll, "all", false, "清除所有登录失败记录")
+	lockoutCmd.AddCommand(lockoutListCmd, lockoutClearCmd)
+	rootCmd.AddCommand(lockoutCmd)
+}
@@ -4,10 +4,10 @@ import (
 	. "SimpleHttpServer/config"
 	. "SimpleHttpServer/middleware"
 	"SimpleHttpServer/serverRouter"
+	"SimpleHttpServer/store"
 	"SimpleHttpServer/utils"
+	"SimpleHttpServer/views"
 	"fmt"
-	"github.com/gin-contrib/sessions"
-	"github.com/gin-contrib/sessions/cookie"
 	ginzap "github.com/gin-contrib/zap"
 	"github.com/gin-gonic/gin"
 	"github.com/spf13/cobra"
@@ -15,6 +15,7 @@ import (
 	"html/template"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 )
@@ -25,6 +26,8 @@ var (
 	fileToORMaxZize int64
 	username        string
 	password        string
+	s3Keys          []string
+	minFreeSpaceMB  int64
 )
 
 var rootCmd = &cobra.Command{
@@ -32,16 +35,11 @@ var rootCmd = &cobra.Command{
 	Short: "文件上传服务",
 	Run: func(cmd *cobra.Command, args []string) {
 
-		// 1. 计算实际文件大小（带日志输出结构化参数）
-		GlobalConfig.MaxFileSize = maxFileSizeGB * 1024 * 1024 * 1024
-		GlobalConfig.ChunkSize = chunkSizeMB * 1024 * 1024
-		if fileToORMaxZize > 30*1024 || fileToORMaxZize <= 2048 {
-			fmt.Println("强制限制最大转OR体积大于2KB，小于30KB!!!")
+		// 1. 校验配置（命令行参数、环境变量和配置文件合并后）并计算实际文件大小（带日志输出结构化参数）
+		if err := applyConfig(); err != nil {
+			fmt.Printf("配置无效: %v\n", err)
 			os.Exit(1)
 		}
-		GlobalConfig.FileToORMaxZize = fileToORMaxZize
-		GlobalConfig.UserName = username
-		GlobalConfig.Password = password
 		Logger.Info("配置参数解析完成",
 			zap.Int64("port", GlobalConfig.Port),
 			zap.Int64("max_file_size_gb", maxFileSizeGB),
@@ -60,14 +58,49 @@ var rootCmd = &cobra.Command{
 		}
 		Logger.Debug("上传目录创建/检查成功", zap.String("dir", GlobalConfig.UploadDir))
 
+		// 2.1 打开用户存储、目录访问规则、API令牌和登录失败记录，首次启动（没有任何用户）时根据 --username/--password 创建初始管理员
+		openUserStore()
+		openACLStore()
+		openTokenStore()
+		openLockoutStore()
+		openAuditLog()
+		watchConfigFile()
+		if users, err := store.Users.List(); err != nil {
+			Logger.Fatal("读取用户列表失败", zap.Error(err))
+		} else if len(users) == 0 {
+			if _, err := store.Users.Add(GlobalConfig.UserName, GlobalConfig.Password, store.RoleAdmin); err != nil {
+				Logger.Fatal("创建初始管理员账号失败", zap.Error(err))
+			}
+			Logger.Info("已根据启动参数创建初始管理员账号", zap.String("username", GlobalConfig.UserName))
+		}
+
+		// 2.2 从元数据文件恢复未完成的上传会话（重启后续传依然精确）
+		restored, err := views.RestoreUploadSessions()
+		if err != nil {
+			Logger.Error("恢复上传会话失败", zap.Error(err))
+		}
+		Logger.Info("上传会话恢复完成", zap.Int("restored", restored))
+
+		// 2.3 启动过期上传清理任务（空闲超过 --upload-ttl 的会话及临时文件）
+		if GlobalConfig.UploadTTL > 0 {
+			views.StartUploadJanitor(GlobalConfig.UploadTTL)
+		}
+
+		// 2.4 监听上传目录的文件变化，推送给浏览页面实现目录实时更新（失败不影响其他功能）
+		if err := views.StartLiveEvents(); err != nil {
+			Logger.Warn("目录实时更新启动失败，页面需手动刷新", zap.Error(err))
+		}
+
 		// 3. 初始化Gin引擎（修复原代码混用r和router的问题）
 		r := gin.New() // 改用gin.New()，手动添加必要中间件，避免Default()的默认日志
 		setupSession(r)
-		// 核心中间件：恢复panic + zap日志 + 日志格式化
+		// 核心中间件：请求ID + 访问日志 + 恢复panic
 		r.Use(
-			gin.Recovery(), // 基础panic恢复（与RecoveryWithZap配合）
-			ginzap.Ginzap(Logger, time.RFC3339, true), // 请求日志
-			ginzap.RecoveryWithZap(Logger, true),      // panic恢复日志（带堆栈）
+			RequestID(),                          // 请求ID（写入响应头，业务日志通过Log(c)携带）
+			AccessLog(),                          // 访问日志（单独写入access.log）
+			HTTPMetrics("web"),                   // 请求数和耗时监控指标
+			gin.Recovery(),                       // 基础panic恢复（与RecoveryWithZap配合）
+			ginzap.RecoveryWithZap(Logger, true), // panic恢复日志（带堆栈）
 			//gin.Logger(),                              // 可选：保留gin默认访问日志（若需要）
 		)
 
@@ -80,7 +113,7 @@ var rootCmd = &cobra.Command{
 				parts := strings.Split(filename, ".")
 				if len(parts) > 1 {
 					ext := strings.ToLower(parts[len(parts)-1])
-					if icon, ok := GlobalConfig.FileIconMap[ext]; ok {
+					if icon, ok := GlobalConfig.FileIcon(ext); ok {
 						return icon
 					}
 				}
@@ -104,19 +137,47 @@ var rootCmd = &cobra.Command{
 		// 5. 加载模板 + 初始化路由（修复原代码路由未挂载的问题）
 		r.LoadHTMLGlob("templates/*")
 		serverRouter.RouterInit(r) // 路由挂载到实际启动的r引擎
+		// 5.1 健康检查需确认模板可用，诊断页输出实际生效的配置
+		views.SetHTMLRender(r.HTMLRender)
+		views.EffectiveConfig = func() (data []byte, err error) {
+			err = GlobalConfig.View(func() error {
+				data, err = effectiveConfigYAML()
+				return err
+			})
+			return data, err
+		}
 
 		// 6. 启动前日志（结构化输出）
-		serverAddr := fmt.Sprintf("http://0.0.0.0:%d", GlobalConfig.Port)
+		scheme := "http"
+		if tlsEnabled() {
+			scheme = "https"
+		}
+		serverAddr := fmt.Sprintf("%s://0.0.0.0:%d", scheme, GlobalConfig.Port)
 		Logger.Info("Gin服务启动中",
 			zap.String("server_addr", serverAddr),
 			zap.String("upload_dir", GlobalConfig.UploadDir),
 			zap.Int64("max_file_size", maxFileSizeGB),
 		)
 
-		// 7. 启动服务（带错误日志）
-		if err := r.Run(fmt.Sprintf(":%d", GlobalConfig.Port)); err != nil {
-			Logger.Fatal("服务器启动失败", zap.Error(err))
+		// 6.1 网页服务，启用HTTPS时证书在收到SIGHUP后热加载
+		servers := []namedServer{{name: "网页服务", Server: &http.Server{
+			Addr:    fmt.Sprintf(":%d", GlobalConfig.Port),
+			Handler: r.Handler(),
+		}}}
+		if tlsEnabled() {
+			servers[0].TLSConfig = setupTLS()
+		}
+		// 6.2 S3兼容接口（独立端口，与网页服务共用上传目录和上传会话）
+		if GlobalConfig.S3Port > 0 {
+			servers = append(servers, newS3Server())
+		}
+		// 6.3 HTTP→HTTPS重定向
+		if GlobalConfig.HTTPRedirectPort > 0 {
+			servers = append(servers, newHTTPRedirectServer())
 		}
+
+		// 7. 启动服务，收到退出信号后优雅关闭（等待进行中的上传、下载完成）
+		serve(servers)
 	},
 }
 
@@ -135,19 +196,84 @@ func Execute() {
 	}
 }
 
-// 初始化session中间件
-func setupSession(router *gin.Engine) {
-	store := cookie.NewStore([]byte("32-byte-secret-key-1234567890abcdef"))
-	router.Use(sessions.Sessions("upload-session", store))
-	store.Options(sessions.Options{
-		Path:     "/",                  // Cookie作用于全站路径
-		MaxAge:   86400 * 1,            // Cookie有效期7天（避免频繁登录）
-		HttpOnly: true,                 // 防止XSS攻击（必须开启）
-		SameSite: http.SameSiteLaxMode, // 兼容跨站请求（IP/域名都能携带Cookie）
-		// 关键：不设置Domain，让浏览器自动适配请求的Host（IP/域名）
-		// Domain: "",  // 留空，自动适配（127.0.0.1/192.168.1.100/your-domain.com）
-		// Secure: false // 先设为false，后续通过中间件动态调整
-	})
+// newS3Server 创建S3兼容接口服务（独立端口）
+func newS3Server() namedServer {
+	s3 := gin.New()
+	s3.Use(
+		RequestID(),
+		AccessLog(),
+		HTTPMetrics("s3"),
+		gin.Recovery(),
+		ginzap.RecoveryWithZap(Logger, true),
+	)
+	serverRouter.S3RouterInit(s3)
+	// 关联的用户不存在时该访问密钥的请求都会被拒绝，启动时提示（用户可能稍后创建，不作为错误）
+	for accessKey, key := range GlobalConfig.S3Keys {
+		if _, err := store.Users.GetByName(key.User); err != nil {
+			Logger.Warn("S3访问密钥关联的用户不存在", zap.String("accessKey", accessKey), zap.String("username", key.User))
+		}
+	}
+	Logger.Info("S3兼容接口启动中",
+		zap.String("server_addr", fmt.Sprintf("http://0.0.0.0:%d", GlobalConfig.S3Port)),
+		zap.Int("access_keys", len(GlobalConfig.S3Keys)),
+	)
+	return namedServer{name: "S3接口", Server: &http.Server{
+		Addr:    fmt.Sprintf(":%d", GlobalConfig.S3Port),
+		Handler: s3.Handler(),
+	}}
+}
+
+// openUserStore 打开数据目录下的用户文件（服务启动和 user 子命令共用）
+func openUserStore() {
+	path := filepath.Join(GlobalConfig.DataDir, "users.json")
+	users, err := store.OpenUserStore(path)
+	if err != nil {
+		Logger.Fatal("打开用户存储失败", zap.String("path", path), zap.Error(err))
+	}
+	store.Users = users
+}
+
+// openACLStore 打开数据目录下的目录访问规则文件（服务启动和 acl 子命令共用）
+func openACLStore() {
+	path := filepath.Join(GlobalConfig.DataDir, "acl.json")
+	acl, err := store.OpenACLStore(path)
+	if err != nil {
+		Logger.Fatal("打开目录访问规则失败", zap.String("path", path), zap.Error(err))
+	}
+	store.ACL = acl
+}
+
+// openTokenStore 打开数据目录下的API令牌文件（服务启动和 token 子命令共用）
+func openTokenStore() {
+	path := filepath.Join(GlobalConfig.DataDir, "tokens.json")
+	tokens, err := store.OpenTokenStore(path)
+	if err != nil {
+		Logger.Fatal("打开API令牌存储失败", zap.String("path", path), zap.Error(err))
+	}
+	store.Tokens = tokens
+}
+
+// openLockoutStore 打开数据目录下的登录失败记录文件（服务启动和 lockout 子命令共用）
+func openLockoutStore() {
+	path := filepath.Join(GlobalConfig.DataDir, "lockouts.json")
+	lockouts, err := store.OpenLockoutStore(path, lockoutPolicy())
+	if err != nil {
+		Logger.Fatal("打开登录失败记录失败", zap.String("path", path), zap.Error(err))
+	}
+	store.Lockouts = lockouts
+}
+
+// openAuditLog 打开审计日志文件（--audit-log，默认为数据目录下的audit.log）
+func openAuditLog() {
+	path := GlobalConfig.AuditLogFile
+	if path == "" {
+		path = filepath.Join(GlobalConfig.DataDir, "audit.log")
+	}
+	audit, err := store.OpenAuditLog(path)
+	if err != nil {
+		Logger.Fatal("打开审计日志失败", zap.String("path", path), zap.Error(err))
+	}
+	store.Audit = audit
 }
 
 // init 初始化：先初始化日志，再定义命令行参数
@@ -196,13 +322,191 @@ func init() {
 		&username,
 		"username", "u",
 		"admin",
-		"默认登陆用户名",
+		"初始管理员用户名（仅在用户文件中没有任何用户时用于创建账号），默认:admin",
 	)
 	rootCmd.PersistentFlags().StringVarP(
 		&password,
 		"password", "p",
 		"admin@123",
-		"默认登陆密码",
+		"初始管理员密码（仅在用户文件中没有任何用户时用于创建账号），默认:admin@123",
+	)
+	rootCmd.PersistentFlags().DurationVar(
+		&GlobalConfig.UploadTTL,
+		"upload-ttl",
+		24*time.Hour,
+		"上传会话空闲超时，超时未完成的上传会被清理（如 12h、30m，0表示不清理），默认:24h",
+	)
+	rootCmd.PersistentFlags().Int64Var(
+		&GlobalConfig.S3Port,
+		"s3-port",
+		0,
+		"S3兼容接口端口（桶对应上传目录下的一级目录），默认:0（不启用）",
+	)
+	rootCmd.PersistentFlags().StringArrayVar(
+		&s3Keys,
+		"s3-key",
+		nil,
+		"S3访问密钥，格式 AccessKey:SecretKey:用户名（按该用户的权限访问），可多次指定",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&GlobalConfig.DataDir,
+		"data-dir",
+		"data",
+		"数据目录（保存用户文件等服务端数据），默认:data",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&GlobalConfig.SessionSecretFile,
+		"session-secret-file",
+		"",
+		"会话密钥文件，不存在时自动生成（环境变量 "+sessionSecretEnv+" 优先），默认:<数据目录>/session.key",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&GlobalConfig.SessionStore,
+		"session-store",
+		"cookie",
+		"会话存储方式：cookie（保存在Cookie中）、file（保存在数据目录，退出登录、修改密码后会话在服务端失效），默认:cookie",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&GlobalConfig.CookieSecure,
+		"cookie-secure",
+		"auto",
+		"会话Cookie的Secure属性：auto（HTTPS请求时启用）、always、never，默认:auto",
+	)
+	rootCmd.PersistentFlags().IntVar(
+		&GlobalConfig.LoginMaxAttempts,
+		"login-max-attempts",
+		5,
+		"同一IP或用户名连续登录失败多少次后临时锁定（0表示不锁定），默认:5",
+	)
+	rootCmd.PersistentFlags().DurationVar(
+		&GlobalConfig.LoginWindow,
+		"login-window",
+		15*time.Minute,
+		"登录失败计数窗口，最后一次失败超过该时间后计数清零，默认:15m",
+	)
+	rootCmd.PersistentFlags().DurationVar(
+		&GlobalConfig.LoginLockout,
+		"login-lockout",
+		time.Minute,
+		"首次锁定时长，之后每多失败一次锁定时长翻倍，默认:1m",
+	)
+	rootCmd.PersistentFlags().DurationVar(
+		&GlobalConfig.LoginLockoutMax,
+		"login-lockout-max",
+		time.Hour,
+		"最长锁定时长，默认:1h",
+	)
+	rootCmd.PersistentFlags().StringSliceVar(
+		&GlobalConfig.TOTPRequiredRoles,
+		"require-2fa",
+		nil,
+		"必须启用两步验证的角色，逗号分隔（如 admin,editor），未启用的用户登录后需先完成设置，默认:不要求",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&GlobalConfig.TLSCert,
+		"tls-cert",
+		"",
+		"HTTPS证书文件（PEM，需同时指定 --tls-key），收到SIGHUP时重新加载，默认:不启用HTTPS",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&GlobalConfig.TLSKey,
+		"tls-key",
+		"",
+		"HTTPS私钥文件（PEM）",
+	)
+	rootCmd.PersistentFlags().BoolVar(
+		&GlobalConfig.TLSSelfSigned,
+		"tls-self-signed",
+		false,
+		"启用HTTPS，证书不存在时生成自签名证书并保存（未指定 --tls-cert 时保存在 <数据目录>/tls），供局域网使用",
+	)
+	rootCmd.PersistentFlags().Int64Var(
+		&GlobalConfig.HTTPRedirectPort,
+		"http-redirect-port",
+		0,
+		"启用HTTPS时额外监听的HTTP端口，请求重定向到HTTPS，默认:0（不启用）",
+	)
+	rootCmd.PersistentFlags().DurationVar(
+		&GlobalConfig.ShutdownTimeout,
+		"shutdown-timeout",
+		30*time.Second,
+		"收到退出信号后等待进行中的请求（上传、下载）完成的最长时间，超时后强制断开，默认:30s",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&GlobalConfig.ConfigFile,
+		"config",
+		"",
+		"配置文件（.yaml/.yml/.toml，键名与参数名相同；环境变量 "+configFileEnv+" 同样可指定），命令行参数 > 环境变量 > 配置文件 > 默认值",
+	)
+	defaultLogLevel := "info"
+	if os.Getenv("ENV") == "dev" {
+		defaultLogLevel = "debug"
+	}
+	rootCmd.PersistentFlags().StringVar(
+		&GlobalConfig.LogLevel,
+		"log-level",
+		defaultLogLevel,
+		"日志级别：debug、info、warn、error，默认:info（环境变量 ENV=dev 时为debug）",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&GlobalConfig.LogDir,
+		"log-dir",
+		"logs",
+		"日志目录（应用日志 http-server.log、访问日志 access.log），默认:logs",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&GlobalConfig.LogFormat,
+		"log-format",
+		"json",
+		"应用日志格式：json、console（便于人工阅读），默认:json",
+	)
+	rootCmd.PersistentFlags().IntVar(
+		&GlobalConfig.LogMaxSize,
+		"log-max-size",
+		100,
+		"单个日志文件最大大小(MB)，超过后切割并压缩，默认:100",
+	)
+	rootCmd.PersistentFlags().IntVar(
+		&GlobalConfig.LogMaxBackups,
+		"log-max-backups",
+		30,
+		"最多保留的切割日志文件数（0表示不限制），默认:30",
+	)
+	rootCmd.PersistentFlags().IntVar(
+		&GlobalConfig.LogMaxAge,
+		"log-max-age",
+		30,
+		"切割日志文件保留天数（0表示不限制），默认:30",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&GlobalConfig.AccessLog,
+		"access-log",
+		"combined",
+		"访问日志格式：combined（Apache/Nginx组合格式，末尾附请求ID）、json、off（不记录），写入日志目录下的access.log，默认:combined",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&GlobalConfig.AuditLogFile,
+		"audit-log",
+		"",
+		"审计日志文件（记录登录、上传、下载、预览、删除等操作，JSON Lines格式，只追加不切割），默认:<数据目录>/audit.log",
+	)
+	rootCmd.PersistentFlags().Int64Var(
+		&minFreeSpaceMB,
+		"min-free-space",
+		1024,
+		"上传目录所在磁盘的最小可用空间(MB)，低于该值时就绪检查（/readyz）失败，负载均衡不再转发请求，默认:1024",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&GlobalConfig.MetricsToken,
+		"metrics-token",
+		"",
+		"访问 /metrics 监控接口的令牌（请求头 Authorization: Bearer <令牌>），为空时仅允许 --metrics-allow 中的地址访问",
+	)
+	rootCmd.PersistentFlags().StringSliceVar(
+		&GlobalConfig.MetricsAllow,
+		"metrics-allow",
+		[]string{"127.0.0.1", "::1"},
+		"允许免令牌访问 /metrics 的IP或网段（CIDR，如 10.0.0.0/8），多个用逗号分隔，默认:127.0.0.1,::1",
 	)
 
 }
@@ -0,0 +1,90 @@
+package cobra
+
+import (
+	. "SimpleHttpServer/config"
+	"SimpleHttpServer/hooks"
+	. "SimpleHttpServer/middleware"
+	"SimpleHttpServer/views"
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// namedServer 带名称的HTTP服务（网页服务、S3接口、HTTP重定向），名称用于日志
+type namedServer struct {
+	name string
+	*http.Server
+}
+
+// serve 监听所有服务端口并阻塞运行，收到SIGINT/SIGTERM后优雅关闭：
+// 不再接受新连接，等待进行中的请求（分块写入、下载等）在 --shutdown-timeout 内完成，
+// 超时后强制断开；随后将未完成的上传会话写入元数据文件。日志缓冲区由Execute返回前刷新
+func serve(servers []namedServer) {
+	// 先绑定所有端口，全部成功后再通知systemd启动完成
+	listeners := make([]net.Listener, len(servers))
+	for i, server := range servers {
+		listener, err := net.Listen("tcp", server.Addr)
+		if err != nil {
+			Logger.Fatal("服务器启动失败", zap.String("server", server.name), zap.String("addr", server.Addr), zap.Error(err))
+		}
+		listeners[i] = listener
+	}
+	serveErrors := make(chan error, len(servers))
+	for i, server := range servers {
+		go func() {
+			var err error
+			if server.TLSConfig != nil {
+				err = server.ServeTLS(listeners[i], "", "")
+			} else {
+				err = server.Serve(listeners[i])
+			}
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				serveErrors <- fmt.Errorf("%s: %w", server.name, err)
+			}
+		}()
+	}
+	sdNotify("READY=1\nSTATUS=服务运行中")
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	select {
+	case <-ctx.Done():
+	case err := <-serveErrors:
+		Logger.Fatal("服务器运行失败", zap.Error(err))
+	}
+	// 恢复默认信号处理：关闭过程中再次按Ctrl+C可立即退出
+	stop()
+	sdNotify("STOPPING=1\nSTATUS=正在等待进行中的请求完成")
+	Logger.Info("收到退出信号，停止接受新连接并等待进行中的请求完成",
+		zap.Duration("shutdownTimeout", GlobalConfig.ShutdownTimeout),
+	)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), GlobalConfig.ShutdownTimeout)
+	defer cancel()
+	// 先结束目录实时更新的长连接，否则会一直等到超时
+	views.StopLiveEvents()
+	var wg sync.WaitGroup
+	for _, server := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := server.Shutdown(shutdownCtx); err != nil {
+				Logger.Warn("等待请求完成超时，强制断开连接", zap.String("server", server.name), zap.Error(err))
+				server.Close()
+			}
+		}()
+	}
+	wg.Wait()
+	if err := hooks.Wait(shutdownCtx); err != nil {
+		Logger.Warn("等待钩子执行完成超时，未完成的推送和命令已放弃", zap.Error(err))
+	}
+
+	flushed := views.FlushUploadSessions()
+	Logger.Info("服务已停止", zap.Int("uploadSessions", flushed))
+}
@@ -0,0 +1,133 @@
+package cobra
+
+import (
+	. "SimpleHttpServer/config"
+	. "SimpleHttpServer/middleware"
+	"SimpleHttpServer/store"
+	"SimpleHttpServer/utils"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// sessionSecretEnv 会话密钥环境变量（优先于密钥文件）
+const sessionSecretEnv = "SHS_SESSION_SECRET"
+
+// sessionMaxAge 会话有效期
+const sessionMaxAge = 86400 * 1
+
+// 初始化session中间件
+func setupSession(router *gin.Engine) {
+	hashKey, blockKey := loadSessionKeys()
+	options := sessions.Options{
+		Path:     "/",                  // Cookie作用于全站路径
+		MaxAge:   sessionMaxAge,        // Cookie有效期1天
+		HttpOnly: true,                 // 防止XSS攻击（必须开启）
+		SameSite: http.SameSiteLaxMode, // 兼容跨站请求（IP/域名都能携带Cookie）
+		// 关键：不设置Domain，让浏览器自动适配请求的Host（IP/域名）
+		// Secure 启用HTTPS时自动开启，否则由SecureCookie中间件按请求协议动态调整
+		Secure: GlobalConfig.CookieSecure == "always" || (GlobalConfig.CookieSecure == "auto" && tlsEnabled()),
+	}
+
+	var sessionStore sessions.Store
+	switch GlobalConfig.SessionStore {
+	case "cookie":
+		sessionStore = cookie.NewStore(hashKey, blockKey)
+	case "file":
+		dir := filepath.Join(GlobalConfig.DataDir, "sessions")
+		fileStore, err := store.NewFileSessionStore(dir, hashKey, blockKey)
+		if err != nil {
+			Logger.Fatal("创建会话目录失败", zap.String("dir", dir), zap.Error(err))
+		}
+		startSessionJanitor(fileStore)
+		sessionStore = fileStore
+	default:
+		Logger.Fatal("会话存储方式无效（可选 cookie/file）", zap.String("sessionStore", GlobalConfig.SessionStore))
+	}
+	sessionStore.Options(options)
+	router.Use(sessions.Sessions("upload-session", sessionStore))
+	router.Use(SecureCookie(GlobalConfig.CookieSecure, options))
+	Logger.Info("会话初始化完成",
+		zap.String("sessionStore", GlobalConfig.SessionStore),
+		zap.String("cookieSecure", GlobalConfig.CookieSecure),
+	)
+}
+
+// loadSessionKeys 加载会话密钥并派生Cookie签名密钥和加密密钥
+// 密钥来源优先级：环境变量 SHS_SESSION_SECRET > 密钥文件（--session-secret-file，默认数据目录下的session.key，不存在时自动生成并保存）
+func loadSessionKeys() (hashKey, blockKey []byte) {
+	secret := os.Getenv(sessionSecretEnv)
+	source := "env"
+	if secret == "" {
+		path := GlobalConfig.SessionSecretFile
+		if path == "" {
+			path = filepath.Join(GlobalConfig.DataDir, "session.key")
+		}
+		var err error
+		secret, err = readOrCreateSessionSecret(path)
+		if err != nil {
+			Logger.Fatal("加载会话密钥失败", zap.String("path", path), zap.Error(err))
+		}
+		source = path
+	}
+	if len(secret) < 16 {
+		Logger.Fatal("会话密钥过短（至少16个字符）", zap.String("source", source))
+	}
+	Logger.Info("会话密钥加载完成", zap.String("source", source))
+	sum := sha512.Sum512([]byte(secret))
+	return sum[:32], sum[32:]
+}
+
+// readOrCreateSessionSecret 读取密钥文件，不存在时生成32字节随机密钥并以0600权限保存
+func readOrCreateSessionSecret(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return strings.TrimSpace(string(data)), nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	secret := hex.EncodeToString(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", err
+	}
+	if err := utils.WriteFileAtomic(path, []byte(secret+"\n"), 0600); err != nil {
+		return "", err
+	}
+	Logger.Info("已生成会话密钥文件", zap.String("path", path))
+	return secret, nil
+}
+
+// startSessionJanitor 定期删除过期的服务端会话文件
+func startSessionJanitor(fileStore *store.FileSessionStore) {
+	sweep := func() {
+		removed, err := fileStore.Sweep(sessionMaxAge * time.Second)
+		if err != nil {
+			Logger.Warn("清理过期会话失败", zap.Error(err))
+			return
+		}
+		if removed > 0 {
+			Logger.Info("已清理过期会话", zap.Int("removed", removed))
+		}
+	}
+	sweep()
+	go func() {
+		for range time.Tick(time.Hour) {
+			sweep()
+		}
+	}()
+}
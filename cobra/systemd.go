@@ -0,0 +1,30 @@
+package cobra
+
+import (
+	. "SimpleHttpServer/middleware"
+	"go.uber.org/zap"
+	"net"
+	"os"
+)
+
+// sdNotify 向systemd发送状态通知（sd_notify协议），未由systemd以 Type=notify 启动（没有NOTIFY_SOCKET）时忽略
+// 常用状态：READY=1（启动完成）、STOPPING=1（开始退出）、STATUS=...（状态说明）
+func sdNotify(state string) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return
+	}
+	// 以@开头表示Linux抽象命名空间套接字
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		Logger.Warn("连接systemd通知套接字失败", zap.String("socket", os.Getenv("NOTIFY_SOCKET")), zap.Error(err))
+		return
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		Logger.Warn("发送systemd通知失败", zap.String("state", state), zap.Error(err))
+	}
+}
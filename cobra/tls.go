@@ -0,0 +1,203 @@
+package cobra
+
+import (
+	. "SimpleHttpServer/config"
+	. "SimpleHttpServer/middleware"
+	"SimpleHttpServer/utils"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// selfSignedValidity 自签名证书有效期
+const selfSignedValidity = 3 * 365 * 24 * time.Hour
+
+// tlsEnabled 是否启用HTTPS（指定了证书或使用自签名证书）
+func tlsEnabled() bool {
+	return GlobalConfig.TLSCert != "" || GlobalConfig.TLSSelfSigned
+}
+
+// tlsFiles 返回证书和私钥路径：未通过 --tls-cert/--tls-key 指定时，自签名证书保存在数据目录的tls子目录
+func tlsFiles() (certFile, keyFile string) {
+	certFile, keyFile = GlobalConfig.TLSCert, GlobalConfig.TLSKey
+	if certFile == "" {
+		certFile = filepath.Join(GlobalConfig.DataDir, "tls", "cert.pem")
+		keyFile = filepath.Join(GlobalConfig.DataDir, "tls", "key.pem")
+	}
+	return certFile, keyFile
+}
+
+// certReloader 持有当前证书，收到SIGHUP时重新从文件加载（续期证书无需重启服务）
+type certReloader struct {
+	certFile string
+	keyFile  string
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+}
+
+// reload 从文件加载证书，失败时保留原证书
+func (r *certReloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.cert = &cert
+	r.mu.Unlock()
+	return nil
+}
+
+// GetCertificate 供tls.Config使用，每次握手返回当前证书
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// setupTLS 加载证书（启用 --tls-self-signed 且证书不存在时生成并保存自签名证书），监听SIGHUP热加载证书
+func setupTLS() *tls.Config {
+	certFile, keyFile := tlsFiles()
+	if GlobalConfig.TLSSelfSigned {
+		if _, err := os.Stat(certFile); errors.Is(err, os.ErrNotExist) {
+			if err := generateSelfSignedCert(certFile, keyFile); err != nil {
+				Logger.Fatal("生成自签名证书失败", zap.String("cert", certFile), zap.Error(err))
+			}
+		}
+	}
+	reloader := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := reloader.reload(); err != nil {
+		Logger.Fatal("加载TLS证书失败", zap.String("cert", certFile), zap.String("key", keyFile), zap.Error(err))
+	}
+	logCertificate("TLS证书加载完成", reloader.cert)
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := reloader.reload(); err != nil {
+				Logger.Error("重新加载TLS证书失败，继续使用原证书", zap.String("cert", certFile), zap.Error(err))
+				continue
+			}
+			logCertificate("TLS证书已重新加载", reloader.cert)
+		}
+	}()
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+}
+
+// logCertificate 记录证书的域名、有效期和SHA-256指纹（自签名证书可据此在浏览器中核对）
+func logCertificate(msg string, cert *tls.Certificate) {
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		Logger.Warn(msg, zap.Error(err))
+		return
+	}
+	fingerprint := sha256.Sum256(leaf.Raw)
+	Logger.Info(msg,
+		zap.Strings("dnsNames", leaf.DNSNames),
+		zap.Int("ipAddresses", len(leaf.IPAddresses)),
+		zap.Time("notAfter", leaf.NotAfter),
+		zap.String("sha256", hex.EncodeToString(fingerprint[:])),
+	)
+}
+
+// generateSelfSignedCert 生成自签名证书（ECDSA P-256），包含localhost、主机名和本机所有IP地址，供局域网使用
+func generateSelfSignedCert(certFile, keyFile string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+	dnsNames := []string{"localhost"}
+	if hostname, err := os.Hostname(); err == nil && hostname != "localhost" {
+		dnsNames = append(dnsNames, hostname)
+	}
+	ips := []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() {
+				ips = append(ips, ipNet.IP)
+			}
+		}
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "SimpleHttpServer", Organization: []string{"SimpleHttpServer"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              dnsNames,
+		IPAddresses:           ips,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+	for _, dir := range []string{filepath.Dir(certFile), filepath.Dir(keyFile)} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+	}
+	if err := utils.WriteFileAtomic(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return err
+	}
+	if err := utils.WriteFileAtomic(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return err
+	}
+	Logger.Info("已生成自签名证书", zap.String("cert", certFile), zap.String("key", keyFile))
+	return nil
+}
+
+// newHTTPRedirectServer 创建 --http-redirect-port 上的HTTP服务，将所有请求重定向到HTTPS端口
+func newHTTPRedirectServer() namedServer {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = strings.Trim(r.Host, "[]") // 请求未携带端口
+		}
+		if GlobalConfig.Port != 443 {
+			host = net.JoinHostPort(host, fmt.Sprint(GlobalConfig.Port))
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]" // IPv6地址
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+	Logger.Info("HTTP重定向服务启动中",
+		zap.String("server_addr", fmt.Sprintf("http://0.0.0.0:%d", GlobalConfig.HTTPRedirectPort)),
+	)
+	return namedServer{name: "HTTP重定向", Server: &http.Server{
+		Addr:    fmt.Sprintf(":%d", GlobalConfig.HTTPRedirectPort),
+		Handler: handler,
+	}}
+}
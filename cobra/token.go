@@ -0,0 +1,107 @@
+package cobra
+
+import (
+	"SimpleHttpServer/store"
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+var (
+	tokenName    string
+	tokenScopes  string
+	tokenExpires time.Duration
+)
+
+// tokenCmd API令牌管理（直接修改数据目录下的令牌文件，服务运行中修改同样即时生效）
+var tokenCmd = &cobra.Command{
+	Use:   "token",
+	Short: "管理API令牌（创建、列出、吊销）",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		openUserStore()
+		openTokenStore()
+	},
+}
+
+var tokenCreateCmd = &cobra.Command{
+	Use:   "create <用户名>",
+	Short: "为用户创建API令牌（明文只输出一次）",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		user, err := store.Users.GetByName(args[0])
+		exitOnUserError(err)
+		scopes, err := store.ParseTokenScopes(tokenScopes)
+		exitOnUserError(err)
+		token, secret, err := store.Tokens.Create(user.ID, tokenName, scopes, tokenExpires)
+		exitOnUserError(err)
+		fmt.Printf("已创建令牌: %s（ID: %s）\n", token.Name, token.ID)
+		fmt.Println("请立即保存以下令牌，之后将无法再次查看：")
+		fmt.Println(secret)
+	},
+}
+
+var tokenListCmd = &cobra.Command{
+	Use:   "list [用户名]",
+	Short: "列出令牌（不指定用户名时列出所有用户的令牌）",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		users, err := store.Users.List()
+		exitOnUserError(err)
+		usernames := map[string]string{}
+		for _, user := range users {
+			usernames[user.ID] = user.Username
+		}
+		userID := ""
+		if len(args) > 0 {
+			user, err := store.Users.GetByName(args[0])
+			exitOnUserError(err)
+			userID = user.ID
+		}
+		tokens, err := store.Tokens.List(userID)
+		exitOnUserError(err)
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\t用户\t名称\t授权范围\t创建时间\t过期时间\t最后使用")
+		for _, token := range tokens {
+			scopes := make([]string, 0, len(token.Scopes))
+			for _, scope := range token.Scopes {
+				scopes = append(scopes, string(scope))
+			}
+			expires := "永不过期"
+			if !token.ExpiresAt.IsZero() {
+				expires = token.ExpiresAt.Format("2006-01-02 15:04:05")
+				if token.Expired() {
+					expires += "（已过期）"
+				}
+			}
+			lastUsed := "-"
+			if !token.LastUsed.IsZero() {
+				lastUsed = token.LastUsed.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", token.ID, usernames[token.UserID], token.Name,
+				strings.Join(scopes, ","), token.CreatedAt.Format("2006-01-02 15:04:05"), expires, lastUsed)
+		}
+		w.Flush()
+	},
+}
+
+var tokenRevokeCmd = &cobra.Command{
+	Use:   "revoke <令牌ID>",
+	Short: "吊销令牌",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		exitOnUserError(store.Tokens.Revoke(args[0], ""))
+		fmt.Printf("已吊销令牌: %s\n", args[0])
+	},
+}
+
+func init() {
+	tokenCreateCmd.Flags().StringVar(&tokenName, "name", "", "令牌名称（用途说明，必填）")
+	tokenCreateCmd.Flags().StringVar(&tokenScopes, "scope", "read", "授权范围，逗号分隔：read、upload、delete")
+	tokenCreateCmd.Flags().DurationVar(&tokenExpires, "expires", 0, "有效期（如 720h），0表示永不过期")
+	tokenCmd.AddCommand(tokenCreateCmd, tokenListCmd, tokenRevokeCmd)
+	rootCmd.AddCommand(tokenCmd)
+}
@@ -0,0 +1,180 @@
+package cobra
+
+import (
+	"SimpleHttpServer/store"
+	"bufio"
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+var userAddRole string
+
+// userCmd 用户管理（直接修改数据目录下的用户文件，服务运行中修改同样即时生效）
+var userCmd = &cobra.Command{
+	Use:   "user",
+	Short: "管理登录用户（新增、删除、修改密码、角色、用户组、列出、禁用/启用、重置两步验证）",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		openUserStore()
+	},
+}
+
+var userAddCmd = &cobra.Command{
+	Use:   "add <用户名> [密码]",
+	Short: "新增用户（未指定密码时从标准输入读取）",
+	Args:  cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		role, err := store.ParseRole(userAddRole)
+		exitOnUserError(err)
+		user, err := store.Users.Add(args[0], passwordArg(args, 1), role)
+		exitOnUserError(err)
+		fmt.Printf("已新增用户: %s（ID: %s，角色: %s）\n", user.Username, user.ID, user.Role)
+	},
+}
+
+var userDelCmd = &cobra.Command{
+	Use:   "del <用户名>",
+	Short: "删除用户（同时吊销该用户的API令牌）",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		user, err := store.Users.GetByName(args[0])
+		exitOnUserError(err)
+		exitOnUserError(store.Users.Delete(args[0]))
+		openTokenStore()
+		exitOnUserError(store.Tokens.RevokeUser(user.ID))
+		fmt.Printf("已删除用户: %s\n", args[0])
+	},
+}
+
+var userPasswdCmd = &cobra.Command{
+	Use:   "passwd <用户名> [新密码]",
+	Short: "修改用户密码（未指定密码时从标准输入读取）",
+	Args:  cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		exitOnUserError(store.Users.SetPassword(args[0], passwordArg(args, 1)))
+		fmt.Printf("已修改用户密码: %s\n", args[0])
+	},
+}
+
+var userRoleCmd = &cobra.Command{
+	Use:   "role <用户名> <viewer|uploader|editor|admin>",
+	Short: "修改用户角色",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		role, err := store.ParseRole(args[1])
+		exitOnUserError(err)
+		exitOnUserError(store.Users.SetRole(args[0], role))
+		fmt.Printf("已修改用户角色: %s → %s\n", args[0], role)
+	},
+}
+
+var userGroupsCmd = &cobra.Command{
+	Use:   "groups <用户名> [组名,组名...]",
+	Short: "设置用户所属的用户组（不指定组名表示清空）",
+	Args:  cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		var groups []string
+		if len(args) > 1 {
+			for _, group := range strings.Split(args[1], ",") {
+				if group = strings.TrimSpace(group); group != "" {
+					groups = append(groups, group)
+				}
+			}
+		}
+		exitOnUserError(store.Users.SetGroups(args[0], groups))
+		fmt.Printf("已设置用户组: %s → [%s]\n", args[0], strings.Join(groups, ","))
+	},
+}
+
+var userListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "列出所有用户",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		users, err := store.Users.List()
+		exitOnUserError(err)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\t用户名\t角色\t用户组\t状态\t两步验证\t创建时间\t最后登录")
+		for _, user := range users {
+			status := "正常"
+			if user.Disabled {
+				status = "已禁用"
+			}
+			lastLogin := "-"
+			if !user.LastLogin.IsZero() {
+				lastLogin = user.LastLogin.Format("2006-01-02 15:04:05")
+			}
+			groups := strings.Join(user.Groups, ",")
+			if groups == "" {
+				groups = "-"
+			}
+			totp := "-"
+			if user.TOTPEnabled() {
+				totp = fmt.Sprintf("已启用（剩余恢复码%d个）", len(user.RecoveryCodes))
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				user.ID, user.Username, user.Role, groups, status, totp, user.CreatedAt.Format("2006-01-02 15:04:05"), lastLogin)
+		}
+		w.Flush()
+	},
+}
+
+var userDisableCmd = &cobra.Command{
+	Use:   "disable <用户名>",
+	Short: "禁用用户（已登录的会话随即失效）",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		exitOnUserError(store.Users.SetDisabled(args[0], true))
+		fmt.Printf("已禁用用户: %s\n", args[0])
+	},
+}
+
+var userEnableCmd = &cobra.Command{
+	Use:   "enable <用户名>",
+	Short: "启用用户",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		exitOnUserError(store.Users.SetDisabled(args[0], false))
+		fmt.Printf("已启用用户: %s\n", args[0])
+	},
+}
+
+var userReset2FACmd = &cobra.Command{
+	Use:   "reset-2fa <用户名>",
+	Short: "关闭用户的两步验证（用户丢失身份验证器且恢复码用完时使用，用户可重新设置）",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		exitOnUserError(store.Users.DisableTOTP(args[0]))
+		fmt.Printf("已关闭用户的两步验证: %s\n", args[0])
+	},
+}
+
+// passwordArg 取位置参数中的密码，未指定时从标准输入读取一行（避免密码出现在shell历史中）
+func passwordArg(args []string, index int) string {
+	if len(args) > index {
+		return args[index]
+	}
+	fmt.Fprint(os.Stderr, "请输入密码: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		fmt.Println("读取密码失败:", err)
+		os.Exit(1)
+	}
+	return strings.TrimRight(line, "\r\n")
+}
+
+// exitOnUserError 用户操作失败时输出原因并退出
+func exitOnUserError(err error) {
+	if err != nil {
+		fmt.Printf("操作失败: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func init() {
+	userAddCmd.Flags().StringVar(&userAddRole, "role", string(store.RoleViewer), "用户角色：viewer（只读）、uploader（可上传）、editor（可上传、删除）、admin（全部权限）")
+	userCmd.AddCommand(userAddCmd, userDelCmd, userPasswdCmd, userRoleCmd, userGroupsCmd, userListCmd, userDisableCmd, userEnableCmd, userReset2FACmd)
+	rootCmd.AddCommand(userCmd)
+}
@@ -1,40 +1,268 @@
 package config
 
 import (
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"slices"
+	"sort"
 	"sync"
 	"time"
 )
 
-// UploadStatus 分块上传状态（导出类型，供其他包使用）
+// UploadStatus 分块上传会话状态（导出类型，供其他包使用）
+// 每个会话由初始化接口生成唯一ID，绑定目标路径、总大小、分块大小和所属用户；
+// 分块可乱序、并发写入，已接收的分块记录在位图中，读写位图与计数需持有mu。
+// 会话以JSON形式持久化到数据文件旁的隐藏元数据文件中，服务重启后据此精确恢复
 type UploadStatus struct {
-	TotalChunks    int       // 总分块数
-	ReceivedChunks int       // 已接收分块数
-	FilePath       string    // 文件存储路径
-	LastUpdated    time.Time // 最后更新时间
+	ID             string       `json:"id"`              // 上传会话ID（UploadStatusCache的键）
+	Owner          string       `json:"owner"`           // 创建会话的登录用户
+	FileName       string       `json:"file_name"`       // 文件名
+	FilePath       string       `json:"file_path"`       // 文件最终存储路径（上传过程中数据写入 FilePath.part）
+	Overwrite      bool         `json:"overwrite"`       // 完成时是否允许替换已存在的同名文件
+	FileChecksum   string       `json:"file_checksum"`   // 客户端声明的整文件校验值（算法:十六进制摘要），为空时完成后按sha256计算摘要
+	Tus            bool         `json:"tus"`             // 是否为tus协议创建的会话（按偏移顺序追加写入，不接受分块接口上传）
+	Multipart      bool         `json:"multipart"`       // 是否为S3分段上传会话（各段先写入PartsDir，完成时按段号拼接到临时文件）
+	Parts          []UploadPart `json:"parts,omitempty"` // S3分段上传已接收的段（按段号升序）
+	Offset         int64        `json:"offset"`          // tus协议下已连续写入的字节数
+	TotalSize      int64        `json:"total_size"`      // 文件总大小(B)
+	ChunkSize      int64        `json:"chunk_size"`      // 分块大小(B)，以初始化会话时的配置为准
+	TotalChunks    int          `json:"total_chunks"`    // 总分块数
+	ReceivedChunks int          `json:"received_chunks"` // 已接收分块数
+	ChunkBitmap    []uint64     `json:"chunk_bitmap"`    // 已接收分块位图（第i位为1表示第i块已写入）
+	ChunkChecksums []string     `json:"chunk_checksums"` // 各分块写入时计算的CRC32C（十六进制，未接收为空）
+	CreatedAt      time.Time    `json:"created_at"`      // 会话创建时间
+	LastUpdated    time.Time    `json:"last_updated"`    // 最后更新时间
+	mu             sync.Mutex   // 保护位图、校验和、计数和更新时间
+	journalMu      sync.Mutex   // 串行化元数据文件写入，保证后写入的总是较新的状态
+}
+
+// NewUploadStatus 创建上传会话，按总大小和分块大小计算总分块数（空文件也占1块）并分配位图
+func NewUploadStatus(id, owner, filePath string, totalSize, chunkSize int64) *UploadStatus {
+	totalChunks := int((totalSize + chunkSize - 1) / chunkSize)
+	if totalChunks == 0 {
+		totalChunks = 1
+	}
+	now := time.Now()
+	return &UploadStatus{
+		ID:             id,
+		Owner:          owner,
+		FileName:       filepath.Base(filePath),
+		FilePath:       filePath,
+		TotalSize:      totalSize,
+		ChunkSize:      chunkSize,
+		TotalChunks:    totalChunks,
+		ChunkBitmap:    make([]uint64, (totalChunks+63)/64),
+		ChunkChecksums: make([]string, totalChunks),
+		CreatedAt:      now,
+		LastUpdated:    now,
+	}
+}
+
+// PartsDir 返回S3分段上传暂存各段数据的隐藏目录（.<文件名>.<会话ID>.parts），每段保存为以段号命名的文件
+func (s *UploadStatus) PartsDir() string {
+	return filepath.Join(filepath.Dir(s.FilePath), "."+filepath.Base(s.FilePath)+"."+s.ID+".parts")
+}
+
+// SetPart 记录已接收的分段（同一段号重复上传时替换），保持按段号升序
+func (s *UploadStatus) SetPart(part UploadPart) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.LastUpdated = time.Now()
+	index := sort.Search(len(s.Parts), func(i int) bool { return s.Parts[i].Number >= part.Number })
+	if index < len(s.Parts) && s.Parts[index].Number == part.Number {
+		s.Parts[index] = part
+		return
+	}
+	s.Parts = slices.Insert(s.Parts, index, part)
+}
+
+// PartList 返回已接收分段的副本（并发安全）
+func (s *UploadStatus) PartList() []UploadPart {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.Parts)
+}
+
+// PartPath 返回上传过程中写入数据的临时文件路径，全部分块接收后再重命名为FilePath
+func (s *UploadStatus) PartPath() string {
+	return s.FilePath + ".part"
+}
+
+// ChunkLength 返回第index块的期望字节数（最后一块可能小于分块大小）
+func (s *UploadStatus) ChunkLength(index int) int64 {
+	remain := s.TotalSize - int64(index)*s.ChunkSize
+	if remain < s.ChunkSize {
+		return remain
+	}
+	return s.ChunkSize
+}
+
+// MarkChunk 标记分块已接收并记录其校验和，返回当前已接收分块数以及该分块是否为首次接收
+func (s *UploadStatus) MarkChunk(index int, checksum string) (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.LastUpdated = time.Now()
+	s.ChunkChecksums[index] = checksum
+	word, bit := index/64, uint(index%64)
+	if s.ChunkBitmap[word]&(1<<bit) != 0 {
+		return s.ReceivedChunks, false
+	}
+	s.ChunkBitmap[word] |= 1 << bit
+	s.ReceivedChunks++
+	return s.ReceivedChunks, true
+}
+
+// UnmarkChunk 取消分块的已接收标记（恢复会话时发现分块数据不完整）
+func (s *UploadStatus) UnmarkChunk(index int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ChunkChecksums[index] = ""
+	word, bit := index/64, uint(index%64)
+	if s.ChunkBitmap[word]&(1<<bit) != 0 {
+		s.ChunkBitmap[word] &^= 1 << bit
+		s.ReceivedChunks--
+	}
+}
+
+// WriteJournal 在持有锁的情况下序列化会话状态并交给write写入，保证并发写入时元数据文件不会回退到旧状态
+func (s *UploadStatus) WriteJournal(write func(data []byte) error) error {
+	s.journalMu.Lock()
+	defer s.journalMu.Unlock()
+	s.mu.Lock()
+	data, err := json.Marshal(s)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return write(data)
+}
+
+// Received 返回已接收分块数（并发安全）
+func (s *UploadStatus) Received() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ReceivedChunks
+}
+
+// LastActive 返回会话最后一次接收分块（或创建）的时间（并发安全），用于清理长时间无进展的上传
+func (s *UploadStatus) LastActive() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.LastUpdated
+}
+
+// CurrentOffset 返回tus会话已连续写入的字节数（并发安全）
+func (s *UploadStatus) CurrentOffset() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Offset
+}
+
+// AdvanceOffset tus会话追加写入n字节后推进偏移，并将已被完整覆盖的分块标记为已接收，返回新的偏移
+func (s *UploadStatus) AdvanceOffset(n int64) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Offset += n
+	s.LastUpdated = time.Now()
+	// 只需检查本次写入涉及的分块
+	for index := int((s.Offset - n) / s.ChunkSize); index < s.TotalChunks; index++ {
+		end := int64(index)*s.ChunkSize + s.ChunkLength(index)
+		if end > s.Offset {
+			break
+		}
+		word, bit := index/64, uint(index%64)
+		if s.ChunkBitmap[word]&(1<<bit) == 0 {
+			s.ChunkBitmap[word] |= 1 << bit
+			s.ReceivedChunks++
+		}
+	}
+	return s.Offset
+}
+
+// MissingChunks 返回尚未接收的分块索引（升序），供客户端补传
+func (s *UploadStatus) MissingChunks() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	missing := make([]int, 0, s.TotalChunks-s.ReceivedChunks)
+	for i := 0; i < s.TotalChunks; i++ {
+		if s.ChunkBitmap[i/64]&(1<<uint(i%64)) == 0 {
+			missing = append(missing, i)
+		}
+	}
+	return missing
+}
+
+// UploadPart S3分段上传中的一段
+type UploadPart struct {
+	Number       int       `json:"number"`        // 段号（1~10000）
+	Size         int64     `json:"size"`          // 段大小(B)
+	ETag         string    `json:"etag"`          // 段内容MD5（十六进制），完成上传时与客户端提交的ETag比对
+	LastModified time.Time `json:"last_modified"` // 接收时间
 }
 
 // ResumeInfo 断点续传信息（导出类型，JSON标签保留）
 type ResumeInfo struct {
-	FileName       string `json:"file_name"`       // 文件名
-	FileExists     bool   `json:"file_exists"`     // 文件是否已存在
-	UploadedBytes  int64  `json:"uploaded_bytes"`  // 已上传字节数
-	UploadedChunks int    `json:"uploaded_chunks"` // 已上传分块数
+	FileName       string `json:"file_name"`              // 文件名
+	FileExists     bool   `json:"file_exists"`            // 文件是否已存在
+	UploadedBytes  int64  `json:"uploaded_bytes"`         // 已上传字节数
+	UploadedChunks int    `json:"uploaded_chunks"`        // 已上传分块数
+	TotalChunks    int    `json:"total_chunks,omitempty"` // 总分块数（存在上传会话或客户端传入时返回）
+	MissingChunks  []int  `json:"missing_chunks"`         // 缺失的分块索引，客户端据此补传
+	UploadID       string `json:"upload_id,omitempty"`    // 可继续使用的上传会话ID
+	ChunkSize      int64  `json:"chunk_size,omitempty"`   // 该会话的分块大小(B)
+}
+
+// S3Key S3访问密钥：SigV4签名使用的SecretKey，以及以哪个用户的身份和权限执行S3操作
+type S3Key struct {
+	SecretKey string
+	User      string // 关联的用户名，请求按该用户的角色和目录访问规则授权
 }
 
 // ServerConfig 服务全局配置（导出类型）
 type ServerConfig struct {
-	Port            int64             // 服务端口
-	MaxFileSize     int64             // 最大文件大小(B)
-	UploadDir       string            // 文件上传目录
-	ChunkSize       int64             // 分块大小(B)
-	FileIconMap     map[string]string // 文件类型对应图标（修正字段名大写导出）
-	FileToORMaxZize int64             //可转二维码的最大尺寸
-	UserName        string
-	Password        string
+	Port              int64             // 服务端口
+	MaxFileSize       int64             // 最大文件大小(B)，可热加载，运行中通过 MaxUploadSize 读取
+	UploadDir         string            // 文件上传目录
+	ChunkSize         int64             // 分块大小(B)
+	FileIconMap       map[string]string // 文件类型对应图标（修正字段名大写导出），可热加载，运行中通过 FileIcon 读取
+	FileToORMaxZize   int64             //可转二维码的最大尺寸
+	UserName          string            // 初始管理员用户名（用户文件中没有任何用户时据此创建）
+	Password          string            // 初始管理员密码
+	DataDir           string            // 数据目录（用户文件等服务端数据）
+	UploadTTL         time.Duration     // 上传会话空闲超时，超过该时间无新分块的会话及其临时文件会被清理（0表示不清理）
+	S3Port            int64             // S3兼容接口监听端口（0表示不启用）
+	S3Keys            map[string]S3Key  // S3访问密钥（AccessKey → SecretKey及关联用户），用于校验SigV4签名和授权
+	SessionSecretFile string            // 会话密钥文件（为空时使用数据目录下的session.key，不存在时自动生成）
+	SessionStore      string            // 会话存储方式：cookie（会话数据保存在Cookie中）或 file（保存在服务端文件中）
+	CookieSecure      string            // 会话Cookie的Secure属性：auto（HTTPS请求时启用）、always、never
+	LoginMaxAttempts  int               // 同一IP或用户名连续登录失败多少次后临时锁定（0表示不锁定）
+	LoginWindow       time.Duration     // 登录失败计数窗口，最后一次失败超过该时间后计数清零
+	LoginLockout      time.Duration     // 首次锁定时长，之后每多失败一次锁定时长翻倍
+	LoginLockoutMax   time.Duration     // 最长锁定时长
+	TOTPRequiredRoles []string          // 必须启用两步验证的角色（未启用的用户登录后需先完成设置），可热加载，运行中通过 TOTPRoleRequired 读取
+	TLSCert           string            // HTTPS证书文件（PEM），为空且未启用自签名证书时使用HTTP
+	TLSKey            string            // HTTPS私钥文件（PEM）
+	TLSSelfSigned     bool              // 证书文件不存在时生成并保存自签名证书（局域网使用）
+	HTTPRedirectPort  int64             // 启用HTTPS时额外监听的HTTP端口，所有请求重定向到HTTPS（0表示不启用）
+	ShutdownTimeout   time.Duration     // 收到退出信号后等待进行中的请求完成的最长时间
+	ConfigFile        string            // 配置文件（YAML或TOML），修改后收到SIGHUP或检测到文件变化时重新加载可热加载的配置项
+	LogLevel          string            // 日志级别：debug、info、warn、error
+	LogDir            string            // 日志目录（应用日志 http-server.log、访问日志 access.log）
+	LogFormat         string            // 应用日志编码：json、console
+	LogMaxSize        int               // 单个日志文件最大(MB)，超过后切割
+	LogMaxBackups     int               // 最多保留的切割日志文件数（0表示不限制）
+	LogMaxAge         int               // 切割日志文件保留天数（0表示不限制）
+	AccessLog         string            // 访问日志格式：combined、json、off（不记录）
+	MetricsToken      string            // 访问 /metrics 的Bearer令牌（为空时仅允许 MetricsAllow 中的地址访问）
+	MetricsAllow      []string          // 允许免令牌访问 /metrics 的IP或网段（CIDR）
+	MetricsAllowNets  []*net.IPNet      // 由 MetricsAllow 解析出的网段
+	MinFreeSpace      int64             // 就绪检查要求上传目录所在磁盘至少保留的可用空间(B)
+	AuditLogFile      string            // 审计日志文件（为空时使用数据目录下的audit.log）
 }
 
-// 全局上传状态缓存
-var UploadStatusCache sync.Map // 键：文件唯一标识，值：*UploadStatus
+// 全局上传会话缓存
+var UploadStatusCache sync.Map // 键：上传会话ID，值：*UploadStatus
 
 // 全局配置单例（导出变量，所有包可访问）
 var GlobalConfig = &ServerConfig{
@@ -59,6 +287,8 @@ var GlobalConfig = &ServerConfig{
 		"txt":  "fa-file-text-o",
 	},
 }
+
+// TextFileExts 按文本文件处理（可在线预览）的后缀，可热加载，运行中通过 IsTextFileExt 读取
 var TextFileExts = map[string]bool{
 	// 通用文本
 	"txt":      true,
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestUploadStatusChunkBitmap(t *testing.T) {
+	// 130块跨越3个位图字，最后一块不足一个分块大小
+	status := NewUploadStatus("id", "alice", "/tmp/a.bin", 129*10+3, 10)
+	if status.TotalChunks != 130 || len(status.ChunkBitmap) != 3 {
+		t.Fatalf("TotalChunks=%d 位图长度=%d，期望130和3", status.TotalChunks, len(status.ChunkBitmap))
+	}
+	if got := status.ChunkLength(129); got != 3 {
+		t.Fatalf("最后一块长度=%d，期望3", got)
+	}
+
+	// 乱序接收，重复接收不重复计数
+	for _, index := range []int{129, 0, 64, 63, 0} {
+		status.MarkChunk(index, "crc")
+	}
+	received, first := status.MarkChunk(64, "crc2")
+	if received != 4 || first {
+		t.Fatalf("重复分块 received=%d first=%v，期望4和false", received, first)
+	}
+	if status.ChunkChecksums[64] != "crc2" {
+		t.Fatalf("重复分块应覆盖校验和，实际%q", status.ChunkChecksums[64])
+	}
+	missing := status.MissingChunks()
+	if len(missing) != 126 || slices.Contains(missing, 0) || slices.Contains(missing, 63) ||
+		slices.Contains(missing, 64) || slices.Contains(missing, 129) || missing[0] != 1 {
+		t.Fatalf("缺失分块不正确: %v", missing)
+	}
+
+	status.UnmarkChunk(63)
+	status.UnmarkChunk(63)
+	if status.Received() != 3 || !slices.Contains(status.MissingChunks(), 63) || status.ChunkChecksums[63] != "" {
+		t.Fatalf("取消标记后 received=%d，期望3且63缺失", status.Received())
+	}
+}
+
+func TestUploadStatusEmptyFile(t *testing.T) {
+	status := NewUploadStatus("id", "alice", "/tmp/empty", 0, 10)
+	if status.TotalChunks != 1 || status.ChunkLength(0) != 0 {
+		t.Fatalf("空文件 TotalChunks=%d ChunkLength=%d，期望1和0", status.TotalChunks, status.ChunkLength(0))
+	}
+	if received, _ := status.MarkChunk(0, ""); received != 1 || len(status.MissingChunks()) != 0 {
+		t.Fatalf("空文件接收唯一分块后应无缺失")
+	}
+}
+
+func TestUploadStatusAdvanceOffset(t *testing.T) {
+	status := NewUploadStatus("id", "alice", "/tmp/a.bin", 25, 10)
+	status.AdvanceOffset(15)
+	if status.Received() != 1 || !slices.Equal(status.MissingChunks(), []int{1, 2}) {
+		t.Fatalf("偏移15后缺失分块=%v，期望[1 2]", status.MissingChunks())
+	}
+	status.AdvanceOffset(10)
+	if status.CurrentOffset() != 25 || len(status.MissingChunks()) != 0 {
+		t.Fatalf("偏移25后缺失分块=%v，期望无", status.MissingChunks())
+	}
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"maps"
+	"slices"
+	"sync"
+)
+
+// reloadMu 保护运行中可热加载的配置项（最大文件大小、必须启用两步验证的角色、文件图标和文本文件后缀），
+// 处理请求时需通过下面的方法读取，配置重新加载时在 Reload 中持有写锁修改
+var reloadMu sync.RWMutex
+
+// 内置的文件图标和文本文件后缀，配置文件中的 icons、text-exts 在此基础上追加或覆盖
+var (
+	defaultFileIconMap  = maps.Clone(GlobalConfig.FileIconMap)
+	defaultTextFileExts = maps.Clone(TextFileExts)
+)
+
+// Reload 持有写锁执行fn修改可热加载的配置项，fn返回错误时由调用方负责恢复原值
+func (c *ServerConfig) Reload(fn func() error) error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	return fn()
+}
+
+// View 持有读锁执行fn，用于一次性读取多个可热加载的配置项（如输出完整配置）
+func (c *ServerConfig) View(fn func() error) error {
+	reloadMu.RLock()
+	defer reloadMu.RUnlock()
+	return fn()
+}
+
+// MaxUploadSize 返回当前允许上传的最大文件大小(B)
+func (c *ServerConfig) MaxUploadSize() int64 {
+	reloadMu.RLock()
+	defer reloadMu.RUnlock()
+	return c.MaxFileSize
+}
+
+// TOTPRoleRequired 角色是否被要求启用两步验证
+func (c *ServerConfig) TOTPRoleRequired(role string) bool {
+	reloadMu.RLock()
+	defer reloadMu.RUnlock()
+	return slices.Contains(c.TOTPRequiredRoles, role)
+}
+
+// FileIcon 返回文件后缀（小写，不带.）对应的图标
+func (c *ServerConfig) FileIcon(ext string) (string, bool) {
+	reloadMu.RLock()
+	defer reloadMu.RUnlock()
+	icon, ok := c.FileIconMap[ext]
+	return icon, ok
+}
+
+// IsTextFileExt 文件后缀（小写，不带.）是否按文本文件处理
+func IsTextFileExt(ext string) bool {
+	reloadMu.RLock()
+	defer reloadMu.RUnlock()
+	return TextFileExts[ext]
+}
+
+// SetFileTypes 在内置列表的基础上追加或覆盖文件图标和文本文件后缀（调用方需在 Reload 中执行）
+func (c *ServerConfig) SetFileTypes(icons map[string]string, textExts []string) {
+	c.FileIconMap = maps.Clone(defaultFileIconMap)
+	maps.Copy(c.FileIconMap, icons)
+	TextFileExts = maps.Clone(defaultTextFileExts)
+	for _, ext := range textExts {
+		TextFileExts[ext] = true
+	}
+}
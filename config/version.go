@@ -0,0 +1,4 @@
+package config
+
+// Version 程序版本，发布时通过 -ldflags "-X SimpleHttpServer/config.Version=v1.2.0" 指定
+var Version = "dev"
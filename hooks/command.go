@@ -0,0 +1,76 @@
+package hooks
+
+import (
+	. "SimpleHttpServer/config"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// commandOutputLimit 命令失败时错误信息中保留的输出长度
+const commandOutputLimit = 1024
+
+// exec 执行钩子命令（不经过shell），事件信息通过环境变量和标准输入（JSON）传入，退出码非0视为失败
+func (h Hook) exec(p Payload) error {
+	body, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), h.Timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, h.Command[0], h.Command[1:]...)
+	cmd.Env = append(os.Environ(), commandEnv(p)...)
+	cmd.Stdin = bytes.NewReader(body)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			err = fmt.Errorf("执行超时（%s）", h.Timeout)
+		}
+		if out := strings.TrimSpace(output.String()); out != "" {
+			if len(out) > commandOutputLimit {
+				out = out[:commandOutputLimit] + "..."
+			}
+			err = fmt.Errorf("%w，输出: %s", err, out)
+		}
+		return err
+	}
+	return nil
+}
+
+// commandEnv 传给命令的环境变量：SHS_EVENT、SHS_USER、SHS_IP、SHS_PATH（上传目录内的路径）、
+// SHS_FILE（文件的绝对路径）、SHS_TARGET/SHS_TARGET_FILE（重命名后的路径）、SHS_SIZE、SHS_HASH、SHS_PROTOCOL、SHS_REQUEST_ID
+func commandEnv(p Payload) []string {
+	return []string{
+		"SHS_EVENT=" + string(p.Event),
+		"SHS_USER=" + p.User,
+		"SHS_IP=" + p.IP,
+		"SHS_PATH=" + p.Path,
+		"SHS_FILE=" + uploadFilePath(p.Path),
+		"SHS_TARGET=" + p.Target,
+		"SHS_TARGET_FILE=" + uploadFilePath(p.Target),
+		"SHS_SIZE=" + strconv.FormatInt(p.Size, 10),
+		"SHS_HASH=" + p.Hash,
+		"SHS_PROTOCOL=" + p.Protocol,
+		"SHS_REQUEST_ID=" + p.RequestID,
+	}
+}
+
+// uploadFilePath 将上传目录内的路径转换为文件的绝对路径，路径为空时返回空
+func uploadFilePath(path string) string {
+	if path == "" {
+		return ""
+	}
+	root, err := filepath.Abs(GlobalConfig.UploadDir)
+	if err != nil {
+		root = GlobalConfig.UploadDir
+	}
+	return filepath.Join(root, filepath.FromSlash(path))
+}
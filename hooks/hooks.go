@@ -0,0 +1,256 @@
+package hooks
+
+import (
+	. "SimpleHttpServer/middleware"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 文件事件钩子：上传完成、删除、重命名、登录时异步向URL推送JSON（HMAC签名，失败重试）或执行本地命令，
+// 用于触发索引、格式转换等后续处理。钩子在配置文件的 hooks 段中定义，支持热加载
+
+// Event 触发钩子的事件
+type Event string
+
+const (
+	EventUpload Event = "upload" // 上传完成（分块接口、tus、S3、WebDAV）
+	EventDelete Event = "delete" // 删除文件或目录
+	EventRename Event = "rename" // 移动或重命名（WebDAV MOVE）
+	EventLogin  Event = "login"  // 网页登录成功
+)
+
+// Events 支持的事件
+var Events = []Event{EventUpload, EventDelete, EventRename, EventLogin}
+
+const (
+	defaultTimeout = 10 * time.Second // 单次推送或命令执行的默认超时
+	defaultRetries = 3                // 推送失败的默认重试次数
+	maxConcurrent  = 8                // 同时执行的推送和命令数上限，超出的排队等待
+)
+
+// Hook 一个钩子，URL和Command二选一
+type Hook struct {
+	Name    string        `json:"name"`    // 名称（日志中区分钩子），为空时按序号命名
+	Events  []Event       `json:"events"`  // 触发的事件
+	Paths   []string      `json:"paths"`   // 只对这些目录（上传目录内的路径，含子目录）下的文件触发，为空时不限制；登录事件不受限制
+	URL     string        `json:"url"`     // 推送地址：POST JSON
+	Secret  string        `json:"secret"`  // 推送签名密钥，设置后请求头 X-SHS-Signature 为 sha256=<请求体的HMAC-SHA256>
+	Retries int           `json:"retries"` // 推送失败（网络错误、5xx、429）的重试次数，间隔从1秒起逐次翻倍
+	Command []string      `json:"command"` // 本地命令及参数（不经过shell），事件信息通过 SHS_ 开头的环境变量和标准输入（JSON）传入
+	Timeout time.Duration `json:"-"`       // 单次推送或命令执行的超时
+}
+
+// hookConfig 配置文件中钩子的格式（超时为时长字符串，重试次数未填写时使用默认值）
+type hookConfig struct {
+	Hook
+	Timeout string `json:"timeout"`
+	Retries *int   `json:"retries"`
+}
+
+// Payload 推送的JSON及命令的标准输入
+type Payload struct {
+	Event     Event     `json:"event"`
+	Time      time.Time `json:"time"`
+	User      string    `json:"user,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	Path      string    `json:"path,omitempty"`     // 上传目录内的路径（/开头）
+	Target    string    `json:"target,omitempty"`   // 重命名后的路径
+	Size      int64     `json:"size,omitempty"`     // 文件大小(B)，上传完成时填写
+	Hash      string    `json:"hash,omitempty"`     // 文件摘要（算法:十六进制摘要），上传完成时填写
+	Protocol  string    `json:"protocol,omitempty"` // 访问方式：web、tus、s3、webdav
+	RequestID string    `json:"request_id,omitempty"`
+}
+
+var (
+	current  atomic.Pointer[[]Hook] // 当前生效的钩子（由Set设置）
+	running  sync.WaitGroup         // 执行中的推送和命令，退出时等待完成
+	slots    = make(chan struct{}, maxConcurrent)
+	stopping = make(chan struct{}) // 退出时关闭，中断重试等待
+	stopOnce sync.Once
+)
+
+// Parse 解析并校验配置文件中的 hooks 段（YAML、TOML解析得到的列表）
+func Parse(raw any) ([]Hook, error) {
+	items, ok := raw.([]any)
+	if !ok {
+		return nil, errors.New("应为钩子列表")
+	}
+	hooks := make([]Hook, 0, len(items))
+	for i, item := range items {
+		// 经JSON转换为结构体，统一YAML、TOML的数值类型并拒绝未知字段
+		data, err := json.Marshal(item)
+		if err != nil {
+			return nil, fmt.Errorf("第%d个钩子格式错误: %v", i+1, err)
+		}
+		var cfg hookConfig
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&cfg); err != nil {
+			return nil, fmt.Errorf("第%d个钩子格式错误: %v", i+1, err)
+		}
+		hook, err := cfg.hook(i)
+		if err != nil {
+			return nil, fmt.Errorf("钩子 %s 无效: %v", hook.Name, err)
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks, nil
+}
+
+// hook 校验配置并填写默认值
+func (cfg hookConfig) hook(index int) (Hook, error) {
+	hook := cfg.Hook
+	if hook.Name == "" {
+		hook.Name = fmt.Sprintf("hook-%d", index+1)
+	}
+	if len(hook.Events) == 0 {
+		return hook, errors.New("events 不能为空")
+	}
+	for _, event := range hook.Events {
+		if !slices.Contains(Events, event) {
+			return hook, fmt.Errorf("未知的事件: %s（可选 upload、delete、rename、login）", event)
+		}
+	}
+	if hook.Command != nil && len(hook.Command) == 0 {
+		return hook, errors.New("command 不能为空列表")
+	}
+	if (hook.URL == "") == (len(hook.Command) == 0) {
+		return hook, errors.New("url 和 command 必须且只能指定一个")
+	}
+	if hook.URL != "" {
+		u, err := url.Parse(hook.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return hook, fmt.Errorf("url 无效: %s（应为 http:// 或 https:// 地址）", hook.URL)
+		}
+	}
+	if len(hook.Command) > 0 && hook.Command[0] == "" {
+		return hook, errors.New("command 的第一项（程序路径）不能为空")
+	}
+	for i, p := range hook.Paths {
+		hook.Paths[i] = "/" + strings.Trim(p, "/")
+	}
+	hook.Timeout = defaultTimeout
+	if cfg.Timeout != "" {
+		timeout, err := time.ParseDuration(cfg.Timeout)
+		if err != nil || timeout <= 0 {
+			return hook, fmt.Errorf("timeout 无效: %s（如 10s、1m）", cfg.Timeout)
+		}
+		hook.Timeout = timeout
+	}
+	hook.Retries = defaultRetries
+	if cfg.Retries != nil {
+		if *cfg.Retries < 0 {
+			return hook, errors.New("retries 不能小于0")
+		}
+		hook.Retries = *cfg.Retries
+	}
+	return hook, nil
+}
+
+// Set 设置生效的钩子（启动及重新加载配置时调用），进行中的推送和命令不受影响
+func Set(hooks []Hook) {
+	current.Store(&hooks)
+}
+
+// Current 返回当前生效的钩子
+func Current() []Hook {
+	if hooks := current.Load(); hooks != nil {
+		return *hooks
+	}
+	return nil
+}
+
+// Fire 异步执行与事件匹配的钩子，不阻塞请求
+func Fire(p Payload) {
+	if p.Time.IsZero() {
+		p.Time = time.Now()
+	}
+	for _, hook := range Current() {
+		if !hook.match(p) {
+			continue
+		}
+		running.Add(1)
+		go func() {
+			defer running.Done()
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			case <-stopping:
+				return
+			}
+			hook.run(p)
+		}()
+	}
+}
+
+// Wait 等待执行中的钩子完成（服务退出时调用），ctx到期时中断重试等待并返回错误
+func Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		stopOnce.Do(func() { close(stopping) })
+		return ctx.Err()
+	}
+}
+
+// match 判断钩子是否订阅了该事件且文件位于限定的目录下（重命名时原路径或新路径任一满足即可）
+func (h Hook) match(p Payload) bool {
+	if !slices.Contains(h.Events, p.Event) {
+		return false
+	}
+	if len(h.Paths) == 0 || p.Event == EventLogin {
+		return true
+	}
+	for _, dir := range h.Paths {
+		if pathUnder(p.Path, dir) || pathUnder(p.Target, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// pathUnder 路径等于dir或位于dir目录下
+func pathUnder(path, dir string) bool {
+	if path == "" {
+		return false
+	}
+	return dir == "/" || path == dir || strings.HasPrefix(path, dir+"/")
+}
+
+// run 执行钩子并记录结果
+func (h Hook) run(p Payload) {
+	logger := Logger.With(
+		zap.String("hook", h.Name),
+		zap.String("event", string(p.Event)),
+		zap.String("path", p.Path),
+		zap.String("requestID", p.RequestID),
+	)
+	start := time.Now()
+	var err error
+	if h.URL != "" {
+		err = h.post(p, logger)
+	} else {
+		err = h.exec(p)
+	}
+	if err != nil {
+		logger.Error("执行钩子失败", zap.Duration("elapsed", time.Since(start)), zap.Error(err))
+		return
+	}
+	logger.Info("执行钩子完成", zap.Duration("elapsed", time.Since(start)))
+}
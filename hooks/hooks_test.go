@@ -0,0 +1,67 @@
+package hooks
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		item map[string]any
+		err  string // 期望的错误（包含该内容），为空表示解析成功
+	}{
+		{"推送", map[string]any{"events": []any{"upload"}, "url": "https://example.com/hook"}, ""},
+		{"命令", map[string]any{"events": []any{"delete"}, "command": []any{"/bin/true", "-x"}}, ""},
+		{"url和空命令列表", map[string]any{"events": []any{"upload"}, "url": "https://example.com/hook", "command": []any{}}, "command 不能为空列表"},
+		{"空命令列表", map[string]any{"events": []any{"upload"}, "command": []any{}}, "command 不能为空列表"},
+		{"程序路径为空", map[string]any{"events": []any{"upload"}, "command": []any{""}}, "程序路径"},
+		{"url和命令同时指定", map[string]any{"events": []any{"upload"}, "url": "https://example.com", "command": []any{"/bin/true"}}, "只能指定一个"},
+		{"url和命令都未指定", map[string]any{"events": []any{"upload"}}, "只能指定一个"},
+		{"url不是http地址", map[string]any{"events": []any{"upload"}, "url": "ftp://example.com"}, "url 无效"},
+		{"未指定事件", map[string]any{"url": "https://example.com"}, "events 不能为空"},
+		{"未知事件", map[string]any{"events": []any{"mkdir"}, "url": "https://example.com"}, "未知的事件"},
+		{"未知字段", map[string]any{"events": []any{"upload"}, "url": "https://example.com", "header": "x"}, "unknown field"},
+		{"超时格式错误", map[string]any{"events": []any{"upload"}, "url": "https://example.com", "timeout": "10"}, "timeout 无效"},
+		{"重试次数为负", map[string]any{"events": []any{"upload"}, "url": "https://example.com", "retries": -1}, "retries 不能小于0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hooks, err := Parse([]any{tt.item})
+			if tt.err == "" {
+				if err != nil || len(hooks) != 1 {
+					t.Fatalf("解析失败: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("错误=%v，期望包含%q", err, tt.err)
+			}
+		})
+	}
+
+	if _, err := Parse(map[string]any{}); err == nil {
+		t.Fatalf("hooks 不是列表时应返回错误")
+	}
+}
+
+// 未填写的名称、超时、重试次数使用默认值，限定目录统一为/开头
+func TestParseDefaults(t *testing.T) {
+	hooks, err := Parse([]any{
+		map[string]any{"events": []any{"upload"}, "url": "https://example.com", "paths": []any{"docs/", "/a/b"}},
+		map[string]any{"name": "index", "events": []any{"upload"}, "url": "https://example.com", "timeout": "30s", "retries": 0},
+	})
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	first, second := hooks[0], hooks[1]
+	if first.Name != "hook-1" || first.Timeout != defaultTimeout || first.Retries != defaultRetries ||
+		!slices.Equal(first.Paths, []string{"/docs", "/a/b"}) {
+		t.Fatalf("默认值错误: %+v", first)
+	}
+	if second.Name != "index" || second.Timeout != 30*time.Second || second.Retries != 0 {
+		t.Fatalf("配置值错误: %+v", second)
+	}
+}
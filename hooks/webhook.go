@@ -0,0 +1,116 @@
+package hooks
+
+import (
+	. "SimpleHttpServer/config"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"time"
+)
+
+// 推送请求头
+const (
+	EventHeader     = "X-SHS-Event"     // 事件
+	DeliveryHeader  = "X-SHS-Delivery"  // 推送ID，重试时不变，接收方可据此去重
+	SignatureHeader = "X-SHS-Signature" // 请求体签名：sha256=<HMAC-SHA256十六进制>
+)
+
+// 推送失败重试的等待时间：从retryBaseDelay起逐次翻倍，最长retryMaxDelay（测试中可调小）
+var (
+	retryBaseDelay = time.Second
+	retryMaxDelay  = time.Minute
+)
+
+// webhookClient 推送使用的HTTP客户端（超时按钩子配置在请求context中设置）
+var webhookClient = &http.Client{}
+
+// post 向钩子URL推送事件，网络错误、5xx及429时按退避间隔重试
+func (h Hook) post(p Payload, logger *zap.Logger) error {
+	body, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	delivery := newDeliveryID()
+	delay := retryBaseDelay
+	for attempt := 0; ; attempt++ {
+		err = h.postOnce(body, p.Event, delivery)
+		var permanent *permanentError
+		if err == nil || errors.As(err, &permanent) || attempt >= h.Retries {
+			return err
+		}
+		logger.Warn("推送钩子失败，稍后重试",
+			zap.String("delivery", delivery),
+			zap.Int("attempt", attempt+1),
+			zap.Duration("retryIn", delay),
+			zap.Error(err),
+		)
+		select {
+		case <-time.After(delay):
+		case <-stopping:
+			return fmt.Errorf("服务退出，放弃重试: %w", err)
+		}
+		delay = min(delay*2, retryMaxDelay)
+	}
+}
+
+// permanentError 重试也不会成功的推送错误（4xx，429除外）
+type permanentError struct {
+	status int
+}
+
+func (e *permanentError) Error() string {
+	return fmt.Sprintf("推送地址返回 %d", e.status)
+}
+
+// postOnce 推送一次，2xx视为成功
+func (h Hook) postOnce(body []byte, event Event, delivery string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), h.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "SimpleHttpServer/"+Version)
+	req.Header.Set(EventHeader, string(event))
+	req.Header.Set(DeliveryHeader, delivery)
+	if h.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(h.Secret, body))
+	}
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests:
+		return &permanentError{status: resp.StatusCode}
+	}
+	return fmt.Errorf("推送地址返回 %d", resp.StatusCode)
+}
+
+// Sign 计算请求体签名（接收方用相同密钥计算后与 X-SHS-Signature 比对）
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// newDeliveryID 生成推送ID
+func newDeliveryID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,161 @@
+package hooks
+
+import (
+	. "SimpleHttpServer/middleware"
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	Logger = zap.NewNop()
+	retryBaseDelay, retryMaxDelay = 10*time.Millisecond, 40*time.Millisecond
+	os.Exit(m.Run())
+}
+
+// delivery 接收方收到的一次推送
+type delivery struct {
+	header http.Header
+	body   []byte
+}
+
+// webhookReceiver 按statuses依次返回状态码（用完后返回200），记录收到的推送
+func webhookReceiver(t *testing.T, statuses ...int) (*httptest.Server, func() []delivery) {
+	t.Helper()
+	var mu sync.Mutex
+	var received []delivery
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		received = append(received, delivery{header: r.Header.Clone(), body: body})
+		status := http.StatusOK
+		if len(received) <= len(statuses) {
+			status = statuses[len(received)-1]
+		}
+		mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []delivery {
+		mu.Lock()
+		defer mu.Unlock()
+		return received
+	}
+}
+
+// fireAndWait 设置钩子并触发事件，等待推送（含重试）完成
+func fireAndWait(t *testing.T, hooks []Hook, p Payload) {
+	t.Helper()
+	Set(hooks)
+	t.Cleanup(func() { Set(nil) })
+	Fire(p)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := Wait(ctx); err != nil {
+		t.Fatalf("等待钩子完成超时: %v", err)
+	}
+}
+
+// HMAC-SHA256公开测试向量
+func TestSign(t *testing.T) {
+	got := Sign("key", []byte("The quick brown fox jumps over the lazy dog"))
+	want := "sha256=f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"
+	if got != want {
+		t.Fatalf("Sign=%s，期望%s", got, want)
+	}
+}
+
+// 推送请求体为事件JSON，签名可由接收方用相同密钥校验，重试时推送ID不变
+func TestWebhookSignedRetries(t *testing.T) {
+	srv, received := webhookReceiver(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	payload := Payload{Event: EventUpload, User: "alice", Path: "/docs/a.txt", Size: 3, Hash: "sha256:abc", Protocol: "web"}
+	fireAndWait(t, []Hook{{Name: "index", Events: []Event{EventUpload}, URL: srv.URL, Secret: "s3cret", Retries: 3, Timeout: time.Second}}, payload)
+
+	deliveries := received()
+	if len(deliveries) != 3 {
+		t.Fatalf("推送%d次，期望3次（503、429各重试一次后成功）", len(deliveries))
+	}
+	id := deliveries[0].header.Get(DeliveryHeader)
+	for i, d := range deliveries {
+		if d.header.Get(DeliveryHeader) != id || id == "" {
+			t.Fatalf("第%d次推送ID=%q，期望与首次相同且非空", i+1, d.header.Get(DeliveryHeader))
+		}
+		if d.header.Get(EventHeader) != "upload" || d.header.Get("Content-Type") != "application/json" {
+			t.Fatalf("第%d次推送请求头错误: %v", i+1, d.header)
+		}
+		if !hmac.Equal([]byte(d.header.Get(SignatureHeader)), []byte(Sign("s3cret", d.body))) {
+			t.Fatalf("第%d次推送签名不匹配: %s", i+1, d.header.Get(SignatureHeader))
+		}
+	}
+	var got Payload
+	if err := json.Unmarshal(deliveries[0].body, &got); err != nil {
+		t.Fatalf("解析推送内容失败: %v", err)
+	}
+	if got.Path != payload.Path || got.User != payload.User || got.Hash != payload.Hash || got.Time.IsZero() {
+		t.Fatalf("推送内容错误: %+v", got)
+	}
+}
+
+func TestWebhookRetryPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		retries  int
+		want     int
+	}{
+		{"成功不重试", nil, 3, 1},
+		{"4xx不重试", []int{http.StatusNotFound}, 3, 1},
+		{"5xx重试至成功", []int{500, 502}, 3, 3},
+		{"重试次数用完后放弃", []int{500, 500, 500, 500}, 2, 3},
+		{"不重试", []int{500}, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, received := webhookReceiver(t, tt.statuses...)
+			fireAndWait(t, []Hook{{Events: []Event{EventDelete}, URL: srv.URL, Retries: tt.retries, Timeout: time.Second}},
+				Payload{Event: EventDelete, Path: "/a"})
+			deliveries := received()
+			if len(deliveries) != tt.want {
+				t.Fatalf("推送%d次，期望%d次", len(deliveries), tt.want)
+			}
+			if deliveries[0].header.Get(SignatureHeader) != "" {
+				t.Fatalf("未设置密钥时不应签名")
+			}
+		})
+	}
+}
+
+// 只推送订阅了该事件且路径位于限定目录下的钩子
+func TestWebhookMatch(t *testing.T) {
+	srv, received := webhookReceiver(t)
+	hooks := []Hook{
+		{Name: "docs", Events: []Event{EventUpload, EventRename, EventLogin}, Paths: []string{"/docs"}, URL: srv.URL + "/docs", Timeout: time.Second},
+		{Name: "all", Events: []Event{EventDelete}, URL: srv.URL + "/all", Timeout: time.Second},
+	}
+	for _, p := range []Payload{
+		{Event: EventUpload, Path: "/docs/sub/a.txt"},           // docs
+		{Event: EventUpload, Path: "/docs2/a.txt"},              // 前缀相同但不是子目录
+		{Event: EventRename, Path: "/tmp/a", Target: "/docs/a"}, // docs（新路径）
+		{Event: EventLogin, User: "alice"},                      // docs（登录不受目录限制）
+		{Event: EventDelete, Path: "/docs/a.txt"},               // all
+	} {
+		fireAndWait(t, hooks, p)
+	}
+	counts := map[string]int{}
+	for _, d := range received() {
+		var p Payload
+		json.Unmarshal(d.body, &p)
+		counts[string(p.Event)]++
+	}
+	if counts["upload"] != 1 || counts["rename"] != 1 || counts["login"] != 1 || counts["delete"] != 1 {
+		t.Fatalf("推送的事件错误: %v", counts)
+	}
+}
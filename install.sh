@@ -20,6 +20,8 @@ PASSWORD="admin@123"        # 密码（-p/--password）
 MAX_SIZE="20"               # 最大上传文件大小(GB)（-M/--max-size）
 UPLOAD_DIR="uploads"        # 上传目录名（-d/--dir）可以为绝对路径，也可相对路径
 CHUNK_SIZE="5"              # 分块大小(MB)（-c/--chunk）
+SHUTDOWN_TIMEOUT="30"       # 停止服务时等待进行中的请求完成的最长时间(秒)（--shutdown-timeout）
+STOP_TIMEOUT_SEC=$((SHUTDOWN_TIMEOUT + 10))  # systemd等待服务退出的时间，超时后强制结束
 
 # ===================== 端口检测函数（优化版：区分自身/其他进程占用）=====================
 # 检测端口是否为合法数字（1-65535）
@@ -137,7 +139,9 @@ After=network.target
 Documentation=man:SimpleHttpServer(1)
 
 [Service]
-Type=simple
+# notify：服务监听端口后通过sd_notify通知systemd启动完成
+Type=notify
+NotifyAccess=main
 WorkingDirectory=${BIN_DIR}
 # 启动命令：引用配置变量，拼接所有参数
 ExecStart=${BIN_PATH} \
@@ -146,7 +150,12 @@ ExecStart=${BIN_PATH} \
   --password ${PASSWORD} \
   --max-size ${MAX_SIZE} \
   --dir ${UPLOAD_DIR} \
-  --chunk ${CHUNK_SIZE}
+  --chunk ${CHUNK_SIZE} \
+  --shutdown-timeout ${SHUTDOWN_TIMEOUT}s
+# systemctl reload：重新加载HTTPS证书
+ExecReload=/bin/kill -HUP \$MAINPID
+# 停止/重启时发送SIGTERM，服务等待进行中的上传、下载完成后退出（需大于 --shutdown-timeout）
+TimeoutStopSec=${STOP_TIMEOUT_SEC}
 Restart=on-failure  # 服务崩溃时自动重启
 RestartSec=5s       # 重启间隔5秒
 StandardOutput=journal+console  # 日志输出到journalctl
@@ -188,7 +197,7 @@ echo "密码：${PASSWORD}"
 echo "可执行文件路径：${BIN_PATH}"
 echo "上传目录（绝对路径）：${UPLOAD_FULL_PATH}"
 echo "日志文件路径：${BIN_DIR}/logs"
-echo "启动命令：${BIN_PATH} --port ${PORT} --username ${USERNAME} --password ${PASSWORD} --max-size ${MAX_SIZE} --dir ${UPLOAD_DIR} --chunk ${CHUNK_SIZE}"
+echo "启动命令：${BIN_PATH} --port ${PORT} --username ${USERNAME} --password ${PASSWORD} --max-size ${MAX_SIZE} --dir ${UPLOAD_DIR} --chunk ${CHUNK_SIZE} --shutdown-timeout ${SHUTDOWN_TIMEOUT}s"
 echo -e "\n常用命令："
 echo "  查看日志：journalctl -u ${SERVICE_NAME} -f"
 echo "  停止服务：systemctl stop ${SERVICE_NAME}"
@@ -0,0 +1,29 @@
+// Package testutil 各包测试共用的夹具
+package testutil
+
+import (
+	"SimpleHttpServer/store"
+	"path/filepath"
+	"testing"
+)
+
+// SetupStores 使用临时目录中的用户、目录访问规则和令牌存储（测试结束后恢复）
+func SetupStores(t testing.TB) {
+	t.Helper()
+	dir := t.TempDir()
+	users, err := store.OpenUserStore(filepath.Join(dir, "users.json"))
+	if err != nil {
+		t.Fatalf("打开用户存储失败: %v", err)
+	}
+	acl, err := store.OpenACLStore(filepath.Join(dir, "acl.json"))
+	if err != nil {
+		t.Fatalf("打开目录访问规则失败: %v", err)
+	}
+	tokens, err := store.OpenTokenStore(filepath.Join(dir, "tokens.json"))
+	if err != nil {
+		t.Fatalf("打开令牌存储失败: %v", err)
+	}
+	savedUsers, savedACL, savedTokens := store.Users, store.ACL, store.Tokens
+	store.Users, store.ACL, store.Tokens = users, acl, tokens
+	t.Cleanup(func() { store.Users, store.ACL, store.Tokens = savedUsers, savedACL, savedTokens })
+}
@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// output 输出单个指标的文本格式
+func output(c collector) string {
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	c.write(w)
+	w.Flush()
+	return buf.String()
+}
+
+func TestCounterVecLabels(t *testing.T) {
+	c := NewCounterVec("test_uploads_failed_total", "失败的上传数", "protocol", "reason")
+	c.Inc("tus", ReasonAborted)
+	c.Inc("chunk", ReasonChecksumMismatch)
+	c.Add(2, "chunk", ReasonChecksumMismatch)
+	c.Add(-1, "chunk", ReasonChecksumMismatch) // 负数忽略
+	c.Inc("webdav", `a"b\c`)                   // 标签值按Go字符串转义
+	want := `# HELP test_uploads_failed_total 失败的上传数
+# TYPE test_uploads_failed_total counter
+test_uploads_failed_total{protocol="chunk",reason="checksum_mismatch"} 3
+test_uploads_failed_total{protocol="tus",reason="aborted"} 1
+test_uploads_failed_total{protocol="webdav",reason="a\"b\\c"} 1
+`
+	if got := output(c); got != want {
+		t.Fatalf("输出:\n%s期望:\n%s", got, want)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("标签值数量不一致应panic")
+		}
+	}()
+	c.Inc("chunk")
+}
+
+// 无标签的计数器尚未累加时也输出0
+func TestCounterVecNoLabels(t *testing.T) {
+	c := NewCounterVec("test_chunks_total", "分块数")
+	if got := output(c); !strings.HasSuffix(got, "\ntest_chunks_total 0\n") {
+		t.Fatalf("输出:\n%s", got)
+	}
+	c.Inc()
+	if got := output(c); !strings.HasSuffix(got, "\ntest_chunks_total 1\n") {
+		t.Fatalf("输出:\n%s", got)
+	}
+}
+
+// 直方图分桶累计计数，边界值计入该分桶（le为小于等于）
+func TestHistogramVec(t *testing.T) {
+	h := NewHistogramVec("test_duration_seconds", "耗时", []float64{0.1, 1}, "route")
+	for _, v := range []float64{0.05, 0.1, 0.5, 3} {
+		h.Observe(v, "/download/*path")
+	}
+	want := `# HELP test_duration_seconds 耗时
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{route="/download/*path",le="0.1"} 2
+test_duration_seconds_bucket{route="/download/*path",le="1"} 3
+test_duration_seconds_bucket{route="/download/*path",le="+Inf"} 4
+test_duration_seconds_sum{route="/download/*path"} 3.65
+test_duration_seconds_count{route="/download/*path"} 4
+`
+	if got := output(h); got != want {
+		t.Fatalf("输出:\n%s期望:\n%s", got, want)
+	}
+}
+
+// 即时指标取值失败时只输出HELP和TYPE
+func TestGaugeFunc(t *testing.T) {
+	value, err := 42.0, error(nil)
+	g := NewGaugeFunc("test_free_bytes", "可用空间", func() (float64, error) { return value, err })
+	if got := output(g); !strings.HasSuffix(got, "\ntest_free_bytes 42\n") {
+		t.Fatalf("输出:\n%s", got)
+	}
+	err = errors.New("statfs失败")
+	if got := output(g); strings.Contains(got, "test_free_bytes 42") {
+		t.Fatalf("取值失败时不应输出取值:\n%s", got)
+	}
+
+	var all bytes.Buffer
+	WriteTo(&all)
+	if !strings.Contains(all.String(), "# TYPE shs_http_requests_total counter") ||
+		!strings.Contains(all.String(), "# TYPE test_free_bytes gauge") {
+		t.Fatalf("WriteTo应输出所有已注册的指标")
+	}
+}
@@ -0,0 +1,35 @@
+package metrics
+
+// 服务的监控指标（上传目录磁盘空间、活跃上传会话等即时指标由views注册）
+var (
+	HTTPRequests = NewCounterVec("shs_http_requests_total",
+		"HTTP请求数（route为路由模板，未匹配路由为unmatched）", "server", "method", "route", "status")
+	HTTPRequestDuration = NewHistogramVec("shs_http_request_duration_seconds",
+		"HTTP请求处理耗时（秒）", DefBuckets, "server", "method", "route")
+	UploadBytes = NewCounterVec("shs_upload_bytes_total",
+		"接收的上传数据字节数（protocol：chunk分块接口、tus、s3、webdav）", "protocol")
+	UploadChunks = NewCounterVec("shs_upload_chunks_total",
+		"分块接口成功接收的分块数")
+	UploadsCompleted = NewCounterVec("shs_uploads_completed_total",
+		"完成的上传数", "protocol")
+	UploadsFailed = NewCounterVec("shs_uploads_failed_total",
+		"失败的上传数（reason：too_large超过大小限制、checksum_mismatch整文件校验失败、target_exists目标文件已存在、write_error写入或合并失败、aborted客户端取消、expired过期清理）",
+		"protocol", "reason")
+	DownloadBytes = NewCounterVec("shs_download_bytes_total",
+		"下载发送的字节数（protocol：http、s3、webdav）", "protocol")
+	QRCodeGenerations = NewCounterVec("shs_qrcode_generations_total",
+		"文件转二维码次数", "result")
+	Logins = NewCounterVec("shs_logins_total",
+		"登录认证次数（method：form网页登录、basic HTTP Basic认证（每个请求计一次）；result：success、failure、locked锁定期内被拒绝）",
+		"method", "result")
+)
+
+// 上传失败原因（UploadsFailed的reason标签）
+const (
+	ReasonTooLarge         = "too_large"
+	ReasonChecksumMismatch = "checksum_mismatch"
+	ReasonTargetExists     = "target_exists"
+	ReasonWriteError       = "write_error"
+	ReasonAborted          = "aborted"
+	ReasonExpired          = "expired"
+)
@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// RequestIDHeader 请求ID请求头/响应头：客户端或反向代理传入的合法请求ID会被沿用，否则生成新的ID
+const RequestIDHeader = "X-Request-ID"
+
+const (
+	requestIDKey     = "requestID"     // 上下文中保存请求ID的键
+	requestLoggerKey = "requestLogger" // 上下文中保存带请求ID的日志记录器的键
+)
+
+// requestLoggerContextKey 请求的context中保存带请求ID的日志记录器的键（供WebDAV等只能拿到*http.Request的代码使用）
+type requestLoggerContextKey struct{}
+
+// validRequestID 沿用外部传入的请求ID时的格式限制，避免日志注入
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,64}$`)
+
+// 访问日志输出（由SetupLogger设置，accessLogWriter为nil时不记录）
+var (
+	accessLogFormat string
+	accessLogWriter io.Writer
+)
+
+// RequestID 为每个请求分配请求ID，写入响应头，并在上下文中保存带requestID字段的日志记录器（通过Log获取）
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			b := make([]byte, 8)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+		logger := Logger.With(zap.String("requestID", id))
+		c.Set(requestIDKey, id)
+		c.Set(requestLoggerKey, logger)
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), requestLoggerContextKey{}, logger))
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// RequestIDOf 返回当前请求的请求ID，未经过RequestID中间件时返回空
+func RequestIDOf(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+// Log 返回当前请求的日志记录器（每条日志带requestID字段），未经过RequestID中间件时返回全局Logger
+func Log(c *gin.Context) *zap.Logger {
+	if logger, ok := c.Get(requestLoggerKey); ok {
+		return logger.(*zap.Logger)
+	}
+	return Logger
+}
+
+// LogContext 返回请求context中带请求ID的日志记录器，不存在时返回全局Logger
+func LogContext(ctx context.Context) *zap.Logger {
+	if logger, ok := ctx.Value(requestLoggerContextKey{}).(*zap.Logger); ok {
+		return logger
+	}
+	return Logger
+}
+
+// accessLogEntry JSON格式访问日志的一行
+type accessLogEntry struct {
+	Time      string  `json:"time"`
+	RequestID string  `json:"requestID"`
+	ClientIP  string  `json:"clientIP"`
+	User      string  `json:"user,omitempty"`
+	Method    string  `json:"method"`
+	URI       string  `json:"uri"`
+	Proto     string  `json:"proto"`
+	Status    int     `json:"status"`
+	Bytes     int     `json:"bytes"`
+	LatencyMs float64 `json:"latencyMs"`
+	Referer   string  `json:"referer,omitempty"`
+	UserAgent string  `json:"userAgent,omitempty"`
+}
+
+// AccessLog 请求处理完成后将访问记录写入访问日志（需挂在RequestID之后），
+// combined格式在Apache/Nginx组合格式末尾追加请求ID
+func AccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		if accessLogWriter == nil {
+			return
+		}
+		req := c.Request
+		bytes := max(c.Writer.Size(), 0)
+		var line []byte
+		if accessLogFormat == "json" {
+			line, _ = json.Marshal(accessLogEntry{
+				Time:      start.Format(time.RFC3339),
+				RequestID: c.GetString(requestIDKey),
+				ClientIP:  c.ClientIP(),
+				User:      c.GetString("user"),
+				Method:    req.Method,
+				URI:       req.RequestURI,
+				Proto:     req.Proto,
+				Status:    c.Writer.Status(),
+				Bytes:     bytes,
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+				Referer:   req.Referer(),
+				UserAgent: req.UserAgent(),
+			})
+		} else {
+			line = fmt.Appendf(nil, "%s - %s [%s] %q %d %d %q %q %q",
+				c.ClientIP(), dashIfEmpty(c.GetString("user")), start.Format("02/Jan/2006:15:04:05 -0700"),
+				req.Method+" "+req.RequestURI+" "+req.Proto, c.Writer.Status(), bytes,
+				dashIfEmpty(req.Referer()), dashIfEmpty(req.UserAgent()), c.GetString(requestIDKey))
+		}
+		if _, err := accessLogWriter.Write(append(line, '\n')); err != nil {
+			Logger.Warn("写入访问日志失败", zap.Error(err))
+		}
+	}
+}
+
+// dashIfEmpty 组合格式中缺失的字段记为-
+func dashIfEmpty(s string) string {
+	if strings.TrimSpace(s) == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"SimpleHttpServer/config"
+	"SimpleHttpServer/store"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// auditEntryKey 上下文中保存待记录的审计日志（*store.AuditEntry）的键
+const auditEntryKey = "auditEntry"
+
+// Audit 审计中间件：请求处理完成后记录一条审计日志，结果按响应状态码判断（小于400为成功）；
+// 需挂在权限校验之前以便记录被拒绝的操作。处理函数可通过SetAuditFile补充实际操作的文件、大小和摘要，
+// 未设置时记录路由参数 *path
+func Audit(action store.AuditAction) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		StartAudit(c, action, "web")
+		c.Next()
+		FinishAudit(c)
+	}
+}
+
+// StartAudit 开始记录当前请求的审计日志，处理完成后需调用FinishAudit
+// （WebDAV、S3接口按请求方法确定操作，由处理函数直接调用）
+func StartAudit(c *gin.Context, action store.AuditAction, protocol string) {
+	c.Set(auditEntryKey, &store.AuditEntry{Action: action, Protocol: protocol})
+}
+
+// FinishAudit 写入当前请求的审计日志，未调用SetAuditFailure时按响应状态码判断结果，未调用StartAudit时忽略
+func FinishAudit(c *gin.Context) {
+	value, ok := c.Get(auditEntryKey)
+	if !ok {
+		return
+	}
+	c.Set(auditEntryKey, nil)
+	entry, ok := value.(*store.AuditEntry)
+	if !ok {
+		return
+	}
+	if entry.Path == "" {
+		entry.Path = AuditPath(c.Param("path"))
+	}
+	entry.Status = c.Writer.Status()
+	if entry.Result == "" {
+		entry.Result = store.AuditSuccess
+		if entry.Status >= http.StatusBadRequest {
+			entry.Result = store.AuditFailure
+		}
+	}
+	RecordAudit(c, *entry)
+}
+
+// SetAuditFile 补充当前请求审计日志中的文件路径（上传目录内的绝对或相对路径）、大小和摘要，未开始记录时忽略
+func SetAuditFile(c *gin.Context, path string, size int64, hash string) {
+	if entry, ok := pendingAudit(c); ok {
+		entry.Path, entry.Size, entry.Hash = AuditPath(path), size, hash
+	}
+}
+
+// SetAuditTarget 补充当前请求审计日志中移动、复制的目标路径，未开始记录时忽略
+func SetAuditTarget(c *gin.Context, path string) {
+	if entry, ok := pendingAudit(c); ok {
+		entry.Target = AuditPath(path)
+	}
+}
+
+// SetAuditDetail 补充当前请求审计日志的说明（如令牌ID），未开始记录时忽略
+func SetAuditDetail(c *gin.Context, detail string) {
+	if entry, ok := pendingAudit(c); ok {
+		entry.Detail = detail
+	}
+}
+
+// SetAuditFailure 将当前请求的审计日志记为失败并注明原因（用于响应成功但操作未完成的情况，如取消上传），未开始记录时忽略
+func SetAuditFailure(c *gin.Context, reason string) {
+	if entry, ok := pendingAudit(c); ok {
+		entry.Result, entry.Detail = store.AuditFailure, reason
+	}
+}
+
+// pendingAudit 返回当前请求待写入的审计日志
+func pendingAudit(c *gin.Context) (*store.AuditEntry, bool) {
+	value, _ := c.Get(auditEntryKey)
+	entry, ok := value.(*store.AuditEntry)
+	return entry, ok && entry != nil
+}
+
+// RecordAudit 立即记录一条审计日志，未填写的用户、IP、请求ID取自当前请求；
+// 跨多个请求的操作（分块上传、tus上传）和不按状态码判断结果的操作（登录）由处理函数直接调用
+func RecordAudit(c *gin.Context, entry store.AuditEntry) {
+	if store.Audit == nil {
+		return
+	}
+	if entry.User == "" {
+		entry.User = c.GetString("user")
+		if entry.User == "" {
+			user, _ := SessionUser(c)
+			entry.User = user.Username
+		}
+	}
+	if entry.IP == "" {
+		entry.IP = c.ClientIP()
+	}
+	if entry.RequestID == "" {
+		entry.RequestID = RequestIDOf(c)
+	}
+	if entry.Result == "" {
+		entry.Result = store.AuditSuccess
+	}
+	if err := store.Audit.Append(entry); err != nil {
+		Log(c).Error("写入审计日志失败", zap.String("action", string(entry.Action)), zap.String("path", entry.Path), zap.Error(err))
+	}
+}
+
+// AuditPath 将文件路径转换为审计日志中的路径：上传目录内的绝对路径转换为相对路径，统一为/开头、/分隔
+func AuditPath(path string) string {
+	if path == "" {
+		return ""
+	}
+	if filepath.IsAbs(path) {
+		if root, err := filepath.Abs(config.GlobalConfig.UploadDir); err == nil {
+			if rel, err := filepath.Rel(root, path); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				path = rel
+			}
+		}
+	}
+	return filepath.ToSlash(filepath.Clean("/" + path))
+}
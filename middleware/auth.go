@@ -1,19 +1,37 @@
 package middleware
 
 import (
+	"SimpleHttpServer/store"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 	"net/http"
+	"time"
 )
 
+// BasicAuthRealm HTTP Basic认证的realm（WebDAV客户端弹出的登录框中展示）
+const BasicAuthRealm = "SimpleHttpServer"
+
 // 登录中间件
 func AuthRequired() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		session := sessions.Default(c)
-		user := session.Get("user")
-		if user == nil {
-			// 如果是API请求，返回401
-			if c.Request.Header.Get("X-Requested-With") == "XMLHttpRequest" {
+		if !authenticate(c) {
+			// API令牌无效或已过期
+			if _, ok := bearerToken(c); ok {
+				c.Header("WWW-Authenticate", `Bearer realm="`+BasicAuthRealm+`", error="invalid_token"`)
+				c.JSON(http.StatusUnauthorized, gin.H{"status": "error", "message": "API令牌无效或已过期"})
+				c.Abort()
+				return
+			}
+			// 携带了Authorization头（Basic认证失败），按HTTP认证规范返回401
+			if c.Request.Header.Get("Authorization") != "" {
+				c.Header("WWW-Authenticate", `Basic realm="`+BasicAuthRealm+`", charset="UTF-8"`)
+				c.JSON(http.StatusUnauthorized, gin.H{"status": "error", "message": "用户名或密码错误"})
+				c.Abort()
+				return
+			}
+			// 如果是API请求（含tus客户端），返回401
+			if c.Request.Header.Get("X-Requested-With") == "XMLHttpRequest" || c.Request.Header.Get("Tus-Resumable") != "" {
 				c.JSON(http.StatusUnauthorized, gin.H{"status": "error", "message": "未登录"})
 				c.Abort()
 				return
@@ -26,3 +44,82 @@ func AuthRequired() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// BasicAuthRequired 与AuthRequired使用相同的认证方式（会话或HTTP Basic），
+// 认证失败时返回401及WWW-Authenticate质询而不是重定向到登录页，供WebDAV等不支持网页登录的客户端使用
+func BasicAuthRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !authenticate(c) {
+			c.Header("WWW-Authenticate", `Basic realm="`+BasicAuthRealm+`", charset="UTF-8"`)
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Next()
+	}
+}
+
+// authenticate 识别请求的登录用户：携带 Authorization: Bearer 时只校验API令牌，
+// 否则优先读取登录会话（会话中保存用户ID和登录时间），其次校验HTTP Basic认证（受登录失败锁定限制）；
+// 启用两步验证的用户不能使用HTTP Basic认证；用户被删除、禁用或修改密码后会话随即失效；认证成功时将用户名、用户ID写入上下文（键"user"、"userID"），供后续处理函数读取
+func authenticate(c *gin.Context) bool {
+	if secret, ok := bearerToken(c); ok {
+		return authenticateToken(c, secret)
+	}
+	if user, ok := SessionUser(c); ok {
+		SetCurrentUser(c, user)
+		return true
+	}
+	if username, password, ok := c.Request.BasicAuth(); ok {
+		// HTTP Basic认证与网页登录共用失败计数和锁定，避免绕过登录页暴力破解
+		if _, locked := LoginLocked(c, username); locked {
+			return false
+		}
+		user, err := store.Users.Authenticate(username, password)
+		if err != nil {
+			RecordLoginResult(c, username, err)
+			return false
+		}
+		// 仅凭密码的HTTP Basic认证会绕过两步验证，此类用户需改用API令牌（不清除失败计数）
+		if user.TOTPEnabled() || TOTPRequired(user) {
+			Log(c).Warn("启用两步验证的用户不能使用HTTP Basic认证，请改用API令牌",
+				zap.String("username", user.Username),
+				zap.String("client_ip", c.ClientIP()),
+			)
+			return false
+		}
+		RecordLoginResult(c, username, nil)
+		SetCurrentUser(c, user)
+		return true
+	}
+	return false
+}
+
+const (
+	SessionUserKey  = "user_id"  // 登录会话中保存用户ID的键
+	SessionLoginKey = "login_at" // 登录会话中保存登录时间（UnixNano）的键
+)
+
+// SessionUser 返回登录会话对应的用户；用户被删除、禁用，或会话早于修改密码、退出所有设备的时间时返回false
+func SessionUser(c *gin.Context) (store.User, bool) {
+	session := sessions.Default(c)
+	userID, _ := session.Get(SessionUserKey).(string)
+	if userID == "" {
+		return store.User{}, false
+	}
+	user, err := store.Users.Get(userID)
+	if err != nil {
+		return store.User{}, false
+	}
+	loginAt, _ := session.Get(SessionLoginKey).(int64)
+	if !user.SessionValid(time.Unix(0, loginAt)) {
+		return store.User{}, false
+	}
+	return user, true
+}
+
+// SetCurrentUser 将认证通过的用户写入上下文（用户名、用户ID及供授权中间件使用的完整用户信息）
+func SetCurrentUser(c *gin.Context, user store.User) {
+	c.Set("user", user.Username)
+	c.Set("userID", user.ID)
+	c.Set(currentUserKey, user)
+}
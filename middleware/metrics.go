@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"SimpleHttpServer/config"
+	"SimpleHttpServer/metrics"
+	"crypto/subtle"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// standardMethods 标准HTTP请求方法（未匹配路由的请求按此归并method标签）
+var standardMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true, http.MethodPatch: true,
+	http.MethodDelete: true, http.MethodConnect: true, http.MethodOptions: true, http.MethodTrace: true,
+}
+
+// HTTPMetrics 按路由模板统计请求数和处理耗时，server区分网页服务和S3接口
+func HTTPMetrics(server string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		route, method := c.FullPath(), c.Request.Method
+		if route == "" {
+			// 未匹配的请求方法由客户端任意指定，只保留标准方法，避免标签数量无限增长
+			route = "unmatched"
+			if !standardMethods[method] {
+				method = "other"
+			}
+		}
+		metrics.HTTPRequests.Inc(server, method, route, strconv.Itoa(c.Writer.Status()))
+		metrics.HTTPRequestDuration.Observe(time.Since(start).Seconds(), server, method, route)
+	}
+}
+
+// MetricsAccessRequired 监控接口访问控制：来源IP在 --metrics-allow 允许的网段内，
+// 或携带与 --metrics-token 一致的 Authorization: Bearer 令牌；
+// 来源IP取TCP连接的对端地址，不信任X-Forwarded-For，经反向代理访问时需使用令牌
+func MetricsAccessRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if ip := net.ParseIP(c.RemoteIP()); ip != nil {
+			for _, allowed := range config.GlobalConfig.MetricsAllowNets {
+				if allowed.Contains(ip) {
+					c.Next()
+					return
+				}
+			}
+		}
+		if expected := config.GlobalConfig.MetricsToken; expected != "" {
+			if token, ok := bearerToken(c); ok && subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1 {
+				c.Next()
+				return
+			}
+		}
+		Log(c).Warn("拒绝访问监控接口", zap.String("client_ip", c.RemoteIP()))
+		c.String(http.StatusForbidden, "forbidden\n")
+		c.Abort()
+	}
+}
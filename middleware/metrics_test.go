@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"SimpleHttpServer/config"
+	"SimpleHttpServer/metrics"
+	"bytes"
+	"github.com/gin-gonic/gin"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// metricLine 返回监控指标输出中以prefix开头的行（不存在时返回空）
+func metricLine(prefix string) string {
+	var buf bytes.Buffer
+	metrics.WriteTo(&buf)
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.HasPrefix(line, prefix) {
+			return line
+		}
+	}
+	return ""
+}
+
+// 请求数按路由模板统计（不按实际路径，避免标签数量随文件数增长），未匹配的路由统一为unmatched，
+// 其中非标准的请求方法统一为other
+func TestHTTPMetricsLabels(t *testing.T) {
+	r := gin.New()
+	r.Use(HTTPMetrics("labeltest"))
+	r.GET("/download/*path", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.DELETE("/delete/*path", func(c *gin.Context) { c.Status(http.StatusForbidden) })
+
+	doRequest(r, "GET", "/download/a.txt", nil)
+	doRequest(r, "GET", "/download/dir/b.txt", nil)
+	doRequest(r, "DELETE", "/delete/a.txt", nil)
+	doRequest(r, "GET", "/no/such/route", nil)
+	doRequest(r, "FOO1", "/no/such/route", nil)
+	doRequest(r, "FOO2", "/download/a.txt", nil)
+
+	for line, want := range map[string]string{
+		`shs_http_requests_total{server="labeltest",method="GET",route="/download/*path",status="200"}`:    "2",
+		`shs_http_requests_total{server="labeltest",method="DELETE",route="/delete/*path",status="403"}`:   "1",
+		`shs_http_requests_total{server="labeltest",method="GET",route="unmatched",status="404"}`:          "1",
+		`shs_http_requests_total{server="labeltest",method="other",route="unmatched",status="404"}`:        "2",
+		`shs_http_request_duration_seconds_count{server="labeltest",method="GET",route="/download/*path"}`: "2",
+	} {
+		if got := metricLine(line + " "); got != line+" "+want {
+			t.Errorf("指标%s=%q，期望%s", line, got, want)
+		}
+	}
+	if got := metricLine(`shs_http_requests_total{server="labeltest",method="GET",route="/download/a.txt"`); got != "" {
+		t.Errorf("不应按实际路径统计: %s", got)
+	}
+	if got := metricLine(`shs_http_requests_total{server="labeltest",method="FOO`); got != "" {
+		t.Errorf("未匹配路由不应按非标准方法统计: %s", got)
+	}
+}
+
+// 监控接口按来源IP或令牌授权，不信任X-Forwarded-For
+func TestMetricsAccessRequired(t *testing.T) {
+	saved := *config.GlobalConfig
+	t.Cleanup(func() { *config.GlobalConfig = saved })
+	_, allowed, _ := net.ParseCIDR("10.0.0.0/8")
+	config.GlobalConfig.MetricsAllowNets = []*net.IPNet{allowed}
+	config.GlobalConfig.MetricsToken = "secret-token"
+
+	r := gin.New()
+	r.GET("/metrics", MetricsAccessRequired(), func(c *gin.Context) { c.Status(http.StatusOK) })
+	tests := []struct {
+		name   string
+		remote string
+		header map[string]string
+		status int
+	}{
+		{"允许的网段", "10.1.2.3:5000", nil, http.StatusOK},
+		{"其他地址", "192.168.1.1:5000", nil, http.StatusForbidden},
+		{"伪造X-Forwarded-For", "192.168.1.1:5000", map[string]string{"X-Forwarded-For": "10.1.2.3"}, http.StatusForbidden},
+		{"正确的令牌", "192.168.1.1:5000", map[string]string{"Authorization": "Bearer secret-token"}, http.StatusOK},
+		{"错误的令牌", "192.168.1.1:5000", map[string]string{"Authorization": "Bearer wrong"}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			req.RemoteAddr = tt.remote
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Fatalf("返回%d，期望%d", w.Code, tt.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"SimpleHttpServer/store"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"strings"
+)
+
+// currentUserKey 上下文中保存当前用户（store.User）的键
+const currentUserKey = "currentUser"
+
+// PathResolver 从请求中解析权限校验的目标路径（上传目录内的相对路径）
+// 解析失败时需自行写入错误响应并返回false
+type PathResolver func(c *gin.Context) (string, bool)
+
+// PathParam 以路由参数 *path 作为目标路径
+func PathParam(c *gin.Context) (string, bool) {
+	return c.Param("path"), true
+}
+
+// CurrentUser 返回认证中间件识别出的当前用户
+func CurrentUser(c *gin.Context) (store.User, bool) {
+	user, ok := c.Get(currentUserKey)
+	if !ok {
+		return store.User{}, false
+	}
+	return user.(store.User), true
+}
+
+// Allowed 判断当前用户能否对上传目录内的相对路径执行操作（未认证时返回false）
+// 通过API令牌访问时还需在令牌的授权范围内
+func Allowed(c *gin.Context, relPath string, perm store.Permission) bool {
+	user, ok := CurrentUser(c)
+	if !ok {
+		return false
+	}
+	if token, ok := CurrentToken(c); ok && !token.Allows(perm) {
+		return false
+	}
+	return store.ACL.Authorize(user, relPath, perm)
+}
+
+// RequirePermission 授权中间件，需挂在AuthRequired之后：按用户角色和目录访问规则校验对目标路径的操作权限
+func RequirePermission(perm store.Permission, resolve PathResolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		target, ok := resolve(c)
+		if !ok {
+			c.Abort()
+			return
+		}
+		if Allowed(c, target, perm) {
+			c.Next()
+			return
+		}
+
+		user, _ := CurrentUser(c)
+		token, _ := CurrentToken(c)
+		Log(c).Warn("权限不足，拒绝访问",
+			zap.String("username", user.Username),
+			zap.String("tokenID", token.ID),
+			zap.String("role", string(user.Role)),
+			zap.String("permission", string(perm)),
+			zap.String("path", store.CleanACLPath(target)),
+			zap.String("client_ip", c.ClientIP()),
+		)
+		message := "没有权限执行该操作"
+		// 页面请求（目录浏览、文件预览）渲染错误页，接口请求返回JSON
+		if strings.Contains(c.GetHeader("Accept"), "text/html") {
+			c.HTML(http.StatusForbidden, "error.html", gin.H{"error": message})
+		} else {
+			c.JSON(http.StatusForbidden, gin.H{"status": "error", "message": message})
+		}
+		c.Abort()
+	}
+}
+
+// RequireRole 授权中间件，需挂在AuthRequired之后：只允许指定角色的用户访问（如管理员专用的诊断页）
+func RequireRole(role store.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, _ := CurrentUser(c)
+		if user.Role == role {
+			c.Next()
+			return
+		}
+		Log(c).Warn("角色不符，拒绝访问",
+			zap.String("username", user.Username),
+			zap.String("role", string(user.Role)),
+			zap.String("requiredRole", string(role)),
+			zap.String("path", c.Request.URL.Path),
+			zap.String("client_ip", c.ClientIP()),
+		)
+		message := "没有权限访问该页面"
+		if strings.Contains(c.GetHeader("Accept"), "text/html") {
+			c.HTML(http.StatusForbidden, "error.html", gin.H{"error": message})
+		} else {
+			c.JSON(http.StatusForbidden, gin.H{"status": "error", "message": message})
+		}
+		c.Abort()
+	}
+}
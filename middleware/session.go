@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// SecureCookie 按请求协议调整会话Cookie的Secure属性（需挂在sessions.Sessions之后）
+// mode为auto时HTTPS请求（直接TLS或反向代理设置 X-Forwarded-Proto: https）启用Secure，always始终启用，never不启用
+func SecureCookie(mode string, options sessions.Options) gin.HandlerFunc {
+	secureOptions := options
+	secureOptions.Secure = true
+	return func(c *gin.Context) {
+		if mode == "auto" && (c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https") {
+			sessions.Default(c).Options(secureOptions)
+		}
+		c.Next()
+	}
+}
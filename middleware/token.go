@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"SimpleHttpServer/store"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"strings"
+)
+
+// currentTokenKey 上下文中保存本次请求所用API令牌（store.Token）的键
+const currentTokenKey = "currentToken"
+
+// bearerToken 读取 Authorization: Bearer 请求头中的令牌
+func bearerToken(c *gin.Context) (string, bool) {
+	scheme, token, ok := strings.Cut(c.GetHeader("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+// authenticateToken 校验API令牌，成功时将令牌所属用户及令牌写入上下文，并记录令牌ID
+func authenticateToken(c *gin.Context, secret string) bool {
+	token, err := store.Tokens.Authenticate(secret)
+	if err != nil {
+		Log(c).Warn("API令牌认证失败",
+			zap.String("tokenID", token.ID),
+			zap.String("client_ip", c.ClientIP()),
+			zap.Error(err),
+		)
+		return false
+	}
+	user, err := store.Users.Get(token.UserID)
+	if err != nil || user.Disabled {
+		Log(c).Warn("API令牌所属用户不存在或已禁用",
+			zap.String("tokenID", token.ID),
+			zap.String("userID", token.UserID),
+			zap.String("client_ip", c.ClientIP()),
+		)
+		return false
+	}
+	SetCurrentUser(c, user)
+	c.Set(currentTokenKey, token)
+	Log(c).Info("API令牌访问",
+		zap.String("tokenID", token.ID),
+		zap.String("username", user.Username),
+		zap.String("method", c.Request.Method),
+		zap.String("path", c.Request.URL.Path),
+		zap.String("client_ip", c.ClientIP()),
+	)
+	return true
+}
+
+// CurrentToken 返回本次请求所用的API令牌（会话或Basic认证时返回false）
+func CurrentToken(c *gin.Context) (store.Token, bool) {
+	token, ok := c.Get(currentTokenKey)
+	if !ok {
+		return store.Token{}, false
+	}
+	return token.(store.Token), true
+}
+
+// RejectToken 禁止通过API令牌访问（令牌管理等接口需网页登录或Basic认证）
+func RejectToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := CurrentToken(c); ok {
+			c.JSON(http.StatusForbidden, gin.H{"status": "error", "message": "API令牌不能用于管理令牌，请登录后操作"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"SimpleHttpServer/internal/testutil"
+	"SimpleHttpServer/store"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// tokenEngine 与serverRouter一致：登录认证后按权限授权，/tokens禁止令牌访问
+func tokenEngine() *gin.Engine {
+	r := gin.New()
+	ok := func(c *gin.Context) { c.String(http.StatusOK, c.GetString("user")) }
+	protected := r.Group("/", AuthRequired())
+	protected.GET("/explore/*path", RequirePermission(store.PermList, PathParam), ok)
+	protected.GET("/preview/*path", RequirePermission(store.PermRead, PathParam), ok)
+	protected.POST("/uploads/*path", RequirePermission(store.PermUpload, PathParam), ok)
+	protected.DELETE("/delete/*path", RequirePermission(store.PermDelete, PathParam), ok)
+	protected.GET("/tokens", RejectToken(), ok)
+	return r
+}
+
+func TestTokenScopeEnforcement(t *testing.T) {
+	testutil.SetupStores(t)
+	editor, _ := store.Users.Add("editor", "pw", store.RoleEditor)
+	viewer, _ := store.Users.Add("viewer", "pw", store.RoleViewer)
+	disabled, _ := store.Users.Add("disabled", "pw", store.RoleEditor)
+	var last store.Token
+	create := func(user store.User, ttl time.Duration, scopes ...store.Permission) string {
+		token, secret, err := store.Tokens.Create(user.ID, "test", scopes, ttl)
+		if err != nil {
+			t.Fatalf("创建令牌失败: %v", err)
+		}
+		last = token
+		return secret
+	}
+	readToken := create(editor, 0, store.PermRead)
+	uploadToken := create(editor, 0, store.PermUpload)
+	allToken := create(editor, 0, store.PermRead, store.PermUpload, store.PermDelete)
+	viewerToken := create(viewer, 0, store.PermRead, store.PermUpload, store.PermDelete)
+	expiredToken := create(editor, time.Nanosecond, store.PermRead)
+	revokedToken := create(editor, 0, store.PermRead)
+	store.Tokens.Revoke(last.ID, editor.ID)
+	disabledToken := create(disabled, 0, store.PermRead)
+	store.Users.SetDisabled("disabled", true)
+	time.Sleep(time.Millisecond)
+
+	tests := []struct {
+		name   string
+		token  string
+		method string
+		target string
+		status int
+	}{
+		// 授权范围：read包含浏览目录
+		{"read范围浏览目录", readToken, "GET", "/explore/docs", http.StatusOK},
+		{"read范围预览", readToken, "GET", "/preview/a.txt", http.StatusOK},
+		{"read范围不能上传", readToken, "POST", "/uploads/docs", http.StatusForbidden},
+		{"upload范围上传", uploadToken, "POST", "/uploads/docs", http.StatusOK},
+		{"upload范围不能预览", uploadToken, "GET", "/preview/a.txt", http.StatusForbidden},
+		{"upload范围不能删除", uploadToken, "DELETE", "/delete/a.txt", http.StatusForbidden},
+		{"全部范围删除", allToken, "DELETE", "/delete/a.txt", http.StatusOK},
+
+		// 令牌权限不超过所属用户的角色
+		{"viewer的令牌不能上传", viewerToken, "POST", "/uploads/docs", http.StatusForbidden},
+		{"viewer的令牌可以预览", viewerToken, "GET", "/preview/a.txt", http.StatusOK},
+
+		// 令牌不能管理令牌
+		{"令牌不能访问令牌管理", allToken, "GET", "/tokens", http.StatusForbidden},
+
+		// 无效令牌返回401，不回退到会话或Basic认证
+		{"令牌已过期", expiredToken, "GET", "/preview/a.txt", http.StatusUnauthorized},
+		{"令牌已吊销", revokedToken, "GET", "/preview/a.txt", http.StatusUnauthorized},
+		{"用户已禁用", disabledToken, "GET", "/preview/a.txt", http.StatusUnauthorized},
+		{"令牌格式错误", "not-a-token", "GET", "/preview/a.txt", http.StatusUnauthorized},
+	}
+	r := tokenEngine()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := doRequest(r, tt.method, tt.target, map[string]string{"Authorization": "Bearer " + tt.token})
+			if w.Code != tt.status {
+				t.Fatalf("返回%d，期望%d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if tt.status == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Fatalf("无效令牌应返回WWW-Authenticate质询")
+			}
+		})
+	}
+}
+
+// 令牌访问时目录访问规则同样生效
+func TestTokenRespectsACL(t *testing.T) {
+	testutil.SetupStores(t)
+	user, _ := store.Users.Add("alice", "pw", store.RoleEditor)
+	store.ACL.Set(store.ACLRule{Path: "/shared", Subject: "*", Access: store.AccessRead})
+	_, secret, _ := store.Tokens.Create(user.ID, "ci", []store.Permission{store.PermRead, store.PermUpload}, 0)
+
+	r := tokenEngine()
+	header := map[string]string{"Authorization": "Bearer " + secret}
+	if w := doRequest(r, "GET", "/preview/shared/a.txt", header); w.Code != http.StatusOK || w.Body.String() != "alice" {
+		t.Fatalf("可读目录返回%d: %s", w.Code, w.Body.String())
+	}
+	if w := doRequest(r, "POST", "/uploads/shared", header); w.Code != http.StatusForbidden {
+		t.Fatalf("只读目录上传返回%d，期望403", w.Code)
+	}
+	if w := doRequest(r, "GET", "/preview/private/a.txt", header); w.Code != http.StatusForbidden {
+		t.Fatalf("没有规则的目录返回%d，期望403", w.Code)
+	}
+}
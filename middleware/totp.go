@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"SimpleHttpServer/config"
+	"SimpleHttpServer/store"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+)
+
+// TOTPSetupPath 两步验证设置页
+const TOTPSetupPath = "/2fa"
+
+// TOTPRequired 用户的角色是否被要求启用两步验证（--require-2fa）
+func TOTPRequired(user store.User) bool {
+	return config.GlobalConfig.TOTPRoleRequired(string(user.Role))
+}
+
+// TOTPEnrollmentRequired 需挂在AuthRequired之后：角色要求两步验证但尚未启用的用户只能访问两步验证设置页，
+// 页面请求重定向到设置页，接口请求返回403；通过API令牌访问不受限制
+func TOTPEnrollmentRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, _ := CurrentUser(c)
+		if _, ok := CurrentToken(c); ok || user.TOTPEnabled() || !TOTPRequired(user) {
+			c.Next()
+			return
+		}
+		if c.Request.Method == http.MethodGet && strings.Contains(c.GetHeader("Accept"), "text/html") {
+			c.Redirect(http.StatusFound, TOTPSetupPath)
+		} else {
+			c.JSON(http.StatusForbidden, gin.H{"status": "error", "message": "管理员要求启用两步验证，请先在网页中完成设置"})
+		}
+		c.Abort()
+	}
+}
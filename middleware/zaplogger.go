@@ -1,9 +1,13 @@
 package middleware
 
 import (
+	"fmt"
 	"gopkg.in/natefinch/lumberjack.v2"
+	"io"
 	"os"
+	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
@@ -11,65 +15,149 @@ import (
 )
 
 var (
-	loggerOnce sync.Once // 单例初始化锁
-	Logger     *zap.Logger
+	loggerOnce  sync.Once // 单例初始化锁
+	Logger      *zap.Logger
+	atomicLevel = zap.NewAtomicLevel() // 日志级别，运行中可通过SetLogLevel修改
+	logFiles    []*checkedWriter       // 当前使用的日志文件（应用日志、访问日志），供CheckLogFiles检查
 )
 
-// 初始化Zap日志（支持输出到文件和控制台，按大小切割日志）
+// 日志目录下的文件名
+const (
+	appLogFile    = "http-server.log" // 应用日志
+	accessLogFile = "access.log"      // 访问日志
+)
+
+// LogOptions 日志输出配置
+type LogOptions struct {
+	Dir        string // 日志目录
+	Format     string // 应用日志编码：json、console（便于人工阅读的文本格式）
+	MaxSize    int    // 单个日志文件最大(MB)，超过后切割
+	MaxBackups int    // 最多保留的切割文件数（0表示不限制）
+	MaxAge     int    // 切割文件保留天数（0表示不限制）
+	AccessLog  string // 访问日志格式：combined（Apache/Nginx组合格式）、json、off（不记录）
+}
+
+// 初始化Zap日志：启动阶段只输出到控制台，加载配置后由SetupLogger按配置输出到文件
 func InitZapLogger() {
 	loggerOnce.Do(func() {
-		// 日志文件配置（按大小切割，保留30天）
-		fileWriter := &lumberjack.Logger{
-			Filename:   "./logs/http-server.log", // 日志文件路径
-			MaxSize:    100,                      // 单个文件最大100MB
-			MaxBackups: 30,                       // 最多保留30个备份
-			MaxAge:     30,                       // 保留30天
-			Compress:   true,                     // 压缩旧日志
-		}
-		// 将fileWriter包装为zap可识别的WriteSyncer
-		fileSyncer := zapcore.AddSync(fileWriter)
-		//// 控制台输出
-		consoleSyncer := zapcore.AddSync(os.Stdout)
 		// 定义日志级别（生产环境用Info，开发环境用Debug）
-		atomicLevel := zap.NewAtomicLevel()
 		if os.Getenv("ENV") == "dev" {
 			atomicLevel.SetLevel(zap.DebugLevel)
 		} else {
 			atomicLevel.SetLevel(zap.InfoLevel)
 		}
+		Logger = newLogger(zapcore.NewJSONEncoder(newEncoderConfig()), zapcore.AddSync(os.Stdout))
+	})
+}
 
-		encoderConfig := zapcore.EncoderConfig{
-			TimeKey:        "time",
-			LevelKey:       "level",
-			NameKey:        "logger",
-			CallerKey:      "caller", // 显示调用文件和行号
-			MessageKey:     "msg",
-			StacktraceKey:  "stacktrace",
-			LineEnding:     zapcore.DefaultLineEnding,
-			EncodeLevel:    zapcore.CapitalLevelEncoder, // 级别大写（INFO/ERROR）
-			EncodeTime:     customTimeEncoder,           // 自定义时间格式
-			EncodeDuration: zapcore.SecondsDurationEncoder,
-			EncodeCaller:   zapcore.ShortCallerEncoder, // 短路径调用者（如pkg/server/api/views/template.go:23）
-		}
-		// 创建JSON编码器
-		encoder := zapcore.NewJSONEncoder(encoderConfig)
-		// 5. 构建Zap核心：绑定输出目标、级别、编码器
-		core := zapcore.NewCore(
-			encoder,
-			zapcore.NewMultiWriteSyncer(fileSyncer, consoleSyncer), // 同时输出到文件和控制台
-			atomicLevel,
-		)
-		// 6. 添加调用者信息和堆栈跟踪（开发环境用）
-		logger := zap.New(core)
-		if os.Getenv("ENV") == "dev" {
-			logger = logger.WithOptions(zap.AddCaller(), zap.AddStacktrace(zap.ErrorLevel))
-		} else {
-			// 生产环境只在错误级别添加堆栈跟踪
-			logger = logger.WithOptions(zap.AddCaller(), zap.AddStacktrace(zap.PanicLevel))
+// SetupLogger 按配置重建应用日志（同时输出到日志目录下的http-server.log和控制台，按大小切割）
+// 并打开访问日志（access.log），需在处理请求前调用
+func SetupLogger(opts LogOptions) error {
+	var encoder zapcore.Encoder
+	switch opts.Format {
+	case "json":
+		encoder = zapcore.NewJSONEncoder(newEncoderConfig())
+	case "console":
+		encoder = zapcore.NewConsoleEncoder(newEncoderConfig())
+	default:
+		return fmt.Errorf("日志格式无效: %s（可选 json/console）", opts.Format)
+	}
+	if opts.AccessLog != "combined" && opts.AccessLog != "json" && opts.AccessLog != "off" {
+		return fmt.Errorf("访问日志格式无效: %s（可选 combined/json/off）", opts.AccessLog)
+	}
+	if opts.MaxSize <= 0 || opts.MaxBackups < 0 || opts.MaxAge < 0 {
+		return fmt.Errorf("日志切割参数无效：单个文件大小必须大于0，保留个数和天数不能小于0")
+	}
+
+	// 将文件写入器包装为zap可识别的WriteSyncer，与控制台输出合并
+	appLog := opts.rotatingFile(appLogFile)
+	fileSyncer := zapcore.AddSync(appLog)
+	consoleSyncer := zapcore.AddSync(os.Stdout)
+	Logger = newLogger(encoder, zapcore.NewMultiWriteSyncer(fileSyncer, consoleSyncer))
+
+	logFiles = []*checkedWriter{appLog}
+	accessLogFormat, accessLogWriter = opts.AccessLog, nil
+	if opts.AccessLog != "off" {
+		accessLog := opts.rotatingFile(accessLogFile)
+		logFiles = append(logFiles, accessLog)
+		accessLogWriter = accessLog
+	}
+	return nil
+}
+
+// rotatingFile 返回日志目录下按大小切割的日志文件（首次写入时创建目录和文件，旧日志压缩保存）
+func (opts LogOptions) rotatingFile(name string) *checkedWriter {
+	return &checkedWriter{name: name, Writer: &lumberjack.Logger{
+		Filename:   filepath.Join(opts.Dir, name),
+		MaxSize:    opts.MaxSize,
+		MaxBackups: opts.MaxBackups,
+		MaxAge:     opts.MaxAge,
+		Compress:   true,
+	}}
+}
+
+// checkedWriter 记录日志文件最近一次写入的错误（写入成功后清除）
+type checkedWriter struct {
+	io.Writer
+	name    string
+	lastErr atomic.Pointer[error]
+}
+
+func (w *checkedWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	if err != nil {
+		w.lastErr.Store(&err)
+	} else if w.lastErr.Load() != nil {
+		w.lastErr.Store(nil)
+	}
+	return n, err
+}
+
+// CheckLogFiles 检查日志文件最近一次写入是否成功（用于健康检查），未调用SetupLogger时只输出到控制台，不做检查
+func CheckLogFiles() error {
+	for _, w := range logFiles {
+		if err := w.lastErr.Load(); err != nil {
+			return fmt.Errorf("写入日志文件%s失败: %v", w.name, *err)
 		}
+	}
+	return nil
+}
 
-		Logger = logger
-	})
+// newEncoderConfig 应用日志的字段名和格式
+func newEncoderConfig() zapcore.EncoderConfig {
+	return zapcore.EncoderConfig{
+		TimeKey:        "time",
+		LevelKey:       "level",
+		NameKey:        "logger",
+		CallerKey:      "caller", // 显示调用文件和行号
+		MessageKey:     "msg",
+		StacktraceKey:  "stacktrace",
+		LineEnding:     zapcore.DefaultLineEnding,
+		EncodeLevel:    zapcore.CapitalLevelEncoder, // 级别大写（INFO/ERROR）
+		EncodeTime:     customTimeEncoder,           // 自定义时间格式
+		EncodeDuration: zapcore.SecondsDurationEncoder,
+		EncodeCaller:   zapcore.ShortCallerEncoder, // 短路径调用者（如pkg/server/api/views/template.go:23）
+	}
+}
+
+// newLogger 构建Zap核心（绑定输出目标、级别、编码器），添加调用者信息和堆栈跟踪
+func newLogger(encoder zapcore.Encoder, output zapcore.WriteSyncer) *zap.Logger {
+	logger := zap.New(zapcore.NewCore(encoder, output, atomicLevel))
+	if os.Getenv("ENV") == "dev" {
+		return logger.WithOptions(zap.AddCaller(), zap.AddStacktrace(zap.ErrorLevel))
+	}
+	// 生产环境只在错误级别添加堆栈跟踪
+	return logger.WithOptions(zap.AddCaller(), zap.AddStacktrace(zap.PanicLevel))
+}
+
+// SetLogLevel 修改日志级别（debug、info、warn、error），立即生效
+func SetLogLevel(level string) error {
+	parsed, err := zapcore.ParseLevel(level)
+	if err != nil {
+		return fmt.Errorf("日志级别无效: %s（可选 debug/info/warn/error）", level)
+	}
+	atomicLevel.SetLevel(parsed)
+	return nil
 }
 
 // 自定义时间格式（如 2023-10-01 15:04:05.000）
@@ -2,6 +2,7 @@ package serverRouter
 
 import (
 	"SimpleHttpServer/middleware"
+	"SimpleHttpServer/store"
 	"SimpleHttpServer/views"
 	"github.com/gin-gonic/gin"
 )
@@ -13,27 +14,97 @@ func RouterInit(r *gin.Engine) {
 		// 登录接口（只有这个接口不用登录）
 		public.GET("/login", views.LoginHandler) // 你的登录处理函数（需要自己实现）
 		public.POST("/login", views.LoginHandler)
-		public.GET("/download/*path", views.DownloadHandler) // 文件下载
+		public.POST("/login/2fa", views.LoginTOTPHandler) // 登录第二步：两步验证码
+		public.OPTIONS("/files", views.TusOptionsHandler) // tus协议能力探测
+		public.OPTIONS("/files/:id", views.TusOptionsHandler)
+		public.GET("/healthz", views.HealthzHandler) // 存活检查（负载均衡、监控探测）
+		public.GET("/readyz", views.ReadyzHandler)   // 就绪检查（上传目录可写、磁盘空间充足）
 
 		// 静态资源（比如前端页面、css/js，不需要登录）
 		public.Static("/static", "./static")
 	}
 	// ========== 2. 受保护路由分组（必须登录） ==========
 	protected := r.Group("/")
-	protected.Use(middleware.AuthRequired()) // 加登录认证「安检门」
+	protected.Use(middleware.AuthRequired(), middleware.TOTPEnrollmentRequired()) // 加登录认证「安检门」，角色要求两步验证时需先完成设置
 	{
+		// 授权中间件：在登录认证之后按用户角色和目录访问规则校验操作权限
+		canUploadForm := middleware.RequirePermission(store.PermUpload, views.UploadFormTarget)
+		canUploadSession := middleware.RequirePermission(store.PermUpload, views.UploadSessionTarget)
+		canResume := middleware.RequirePermission(store.PermUpload, views.ResumeInfoTarget)
+		canTusCreate := middleware.RequirePermission(store.PermUpload, views.TusCreateTarget)
+		canDelete := middleware.RequirePermission(store.PermDelete, views.DeleteTarget)
+		canList := middleware.RequirePermission(store.PermList, middleware.PathParam)
+		canRead := middleware.RequirePermission(store.PermRead, middleware.PathParam)
+		canDownload := middleware.RequirePermission(store.PermRead, views.DownloadTarget)
+
 		// 你的核心业务接口（全部需要登录）
-		protected.GET("/", views.IndexHandler)                           // 首页
-		protected.POST("/upload", views.UploadHandler)                   // 根目录上传
-		protected.POST("/upload/*path", views.UploadHandler)             // 子目录上传
-		protected.GET("/get_resume_info", views.ResumeInfoHandler)       // 根目录续传
-		protected.GET("/get_resume_info/*path", views.ResumeInfoHandler) // 子目录续传
-		protected.DELETE("/delete/*path", views.DeleteHandler)           // 文件删除
-		protected.GET("/explore/*path", views.ExploreDir)                // 目录浏览
-		protected.GET("/preview/*path", views.PreviewFile)               // 文件预览
-		protected.GET("/qrcode/*path", views.HandleFileToQR)             // 生成二维码
+		protected.GET("/", middleware.Audit(store.AuditList), views.IndexHandler)                                    // 首页（列表按权限过滤）
+		protected.POST("/uploads", canUploadForm, views.InitUploadHandler)                                           // 根目录创建上传会话
+		protected.POST("/uploads/*path", canUploadForm, views.InitUploadHandler)                                     // 子目录创建上传会话
+		protected.PUT("/uploads/:id/chunks/:index", canUploadSession, views.UploadHandler)                           // 按会话ID上传分块
+		protected.GET("/uploads/:id", canUploadSession, views.UploadStatusHandler)                                   // 查询上传会话状态
+		protected.DELETE("/uploads/:id", canUploadSession, views.AbortUploadHandler)                                 // 取消上传会话
+		protected.GET("/get_resume_info", canResume, views.ResumeInfoHandler)                                        // 根目录续传
+		protected.GET("/get_resume_info/*path", canResume, views.ResumeInfoHandler)                                  // 子目录续传
+		protected.DELETE("/delete/*path", middleware.Audit(store.AuditDelete), canDelete, views.DeleteHandler)       // 文件删除
+		protected.GET("/explore/*path", middleware.Audit(store.AuditList), canList, views.ExploreDir)                // 目录浏览
+		protected.GET("/preview/*path", middleware.Audit(store.AuditPreview), canRead, views.PreviewFile)            // 文件预览
+		protected.GET("/download/*path", middleware.Audit(store.AuditDownload), canDownload, views.DownloadHandler)  // 文件下载
+		protected.HEAD("/download/*path", middleware.Audit(store.AuditDownload), canDownload, views.DownloadHandler) // 下载前探测（断点续传工具会先发HEAD）
+		protected.GET("/qrcode/*path", middleware.Audit(store.AuditQRCode), canRead, views.HandleFileToQR)           // 生成二维码
+		protected.GET("/events", canList, views.LiveEventsHandler)                                                   // 根目录实时更新（SSE）
+		protected.GET("/events/*path", canList, views.LiveEventsHandler)                                             // 子目录实时更新（SSE）
+
+		// tus 1.0 断点续传协议（供CI、脚本等标准tus客户端使用）
+		tus := protected.Group("/files", views.TusResumableRequired)
+		tus.POST("", canTusCreate, views.TusCreateHandler)           // 创建上传
+		tus.HEAD("/:id", canUploadSession, views.TusHeadHandler)     // 查询偏移
+		tus.PATCH("/:id", canUploadSession, views.TusPatchHandler)   // 追加数据
+		tus.DELETE("/:id", canUploadSession, views.TusDeleteHandler) // 终止上传
+
+		// API令牌管理（需网页登录或Basic认证，不能使用令牌本身操作）
+		tokens := protected.Group("/tokens", middleware.RejectToken())
+		tokens.GET("", views.TokensPage)                                                         // 令牌列表
+		tokens.POST("", middleware.Audit(store.AuditToken), views.CreateTokenHandler)            // 创建令牌
+		tokens.POST("/:id/revoke", middleware.Audit(store.AuditToken), views.RevokeTokenHandler) // 吊销令牌
+
+		// 诊断信息（仅管理员）
+		protected.GET("/debug/info", middleware.RequireRole(store.RoleAdmin), views.DebugInfoHandler)
+
+		// 审计日志查询和导出（仅管理员）
+		audit := protected.Group("/audit", middleware.RequireRole(store.RoleAdmin))
+		audit.GET("", views.AuditPage)                 // 按用户、路径、操作和时间范围查询
+		audit.GET("/export", views.AuditExportHandler) // 导出为CSV或JSON
+	}
+	// ========== 2.1 账号安全（需登录；角色要求两步验证但尚未启用的用户也可访问，以便完成设置或退出） ==========
+	account := r.Group("/")
+	account.Use(middleware.AuthRequired())
+	{
+		// 两步验证设置（不能使用API令牌操作）
+		totp := account.Group(middleware.TOTPSetupPath, middleware.RejectToken())
+		totp.GET("", views.TOTPPage)                                                                // 设置页（二维码、状态）
+		totp.POST("/enable", middleware.Audit(store.AuditTOTP), views.EnableTOTPHandler)            // 确认验证码后启用
+		totp.POST("/recovery-codes", middleware.Audit(store.AuditTOTP), views.RecoveryCodesHandler) // 重新生成恢复码
+		totp.POST("/disable", middleware.Audit(store.AuditTOTP), views.DisableTOTPHandler)          // 关闭两步验证
 
 		// 登出接口（必须登录后才能登出）
-		protected.GET("/logout", views.LogoutHandler) // 你的登出处理函数（需要自己实现）
+		account.GET("/logout", middleware.Audit(store.AuditLogout), views.LogoutHandler) // 你的登出处理函数（需要自己实现）
+	}
+	// ========== 3. WebDAV（与受保护路由相同的认证，未认证返回401质询以便文件管理器弹出登录框） ==========
+	dav := r.Group(views.WebDAVPrefix)
+	dav.Use(middleware.BasicAuthRequired())
+	{
+		for _, method := range views.WebDAVMethods {
+			dav.Handle(method, "", views.WebDAVHandler)
+			dav.Handle(method, "/*path", views.WebDAVHandler)
+		}
 	}
+	// ========== 4. 监控指标（Prometheus抓取，按来源IP或令牌授权，不使用登录会话） ==========
+	r.GET("/metrics", middleware.MetricsAccessRequired(), views.MetricsHandler)
+}
+
+// S3RouterInit 挂载S3兼容接口路由（独立端口，路径风格：/<bucket>/<key>，使用SigV4访问密钥认证）
+func S3RouterInit(r *gin.Engine) {
+	r.Use(views.S3AuthRequired)
+	r.Any("/*path", views.S3Handler)
 }
@@ -0,0 +1,294 @@
+package store
+
+import (
+	"SimpleHttpServer/utils"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Role 用户角色，决定用户可以执行哪些操作
+type Role string
+
+const (
+	RoleViewer   Role = "viewer"   // 浏览、预览、生成二维码
+	RoleUploader Role = "uploader" // viewer + 上传
+	RoleEditor   Role = "editor"   // uploader + 删除
+	RoleAdmin    Role = "admin"    // 全部操作，不受目录ACL限制
+)
+
+// Permission 操作权限
+type Permission string
+
+const (
+	PermList   Permission = "list"   // 浏览目录（有子目录读权限时允许进入上级目录）
+	PermRead   Permission = "read"   // 预览、生成二维码
+	PermUpload Permission = "upload" // 上传、新建目录
+	PermDelete Permission = "delete" // 删除、移动
+)
+
+var rolePermissions = map[Role][]Permission{
+	RoleViewer:   {PermList, PermRead},
+	RoleUploader: {PermList, PermRead, PermUpload},
+	RoleEditor:   {PermList, PermRead, PermUpload, PermDelete},
+	RoleAdmin:    {PermList, PermRead, PermUpload, PermDelete},
+}
+
+// ParseRole 解析角色名
+func ParseRole(name string) (Role, error) {
+	role := Role(strings.ToLower(strings.TrimSpace(name)))
+	if _, ok := rolePermissions[role]; !ok {
+		return "", fmt.Errorf("角色无效: %q（可选 viewer/uploader/editor/admin）", name)
+	}
+	return role, nil
+}
+
+// Allows 角色是否具备指定操作权限
+func (r Role) Allows(perm Permission) bool {
+	return slices.Contains(rolePermissions[r], perm)
+}
+
+// Access 目录访问级别
+type Access string
+
+const (
+	AccessNone  Access = "none"  // 不可见
+	AccessRead  Access = "read"  // 可浏览、预览
+	AccessWrite Access = "write" // 可浏览、上传、删除（删除仍需editor及以上角色）
+)
+
+var accessRank = map[Access]int{AccessNone: 0, AccessRead: 1, AccessWrite: 2}
+
+// ParseAccess 解析访问级别
+func ParseAccess(name string) (Access, error) {
+	access := Access(strings.ToLower(strings.TrimSpace(name)))
+	if _, ok := accessRank[access]; !ok {
+		return "", fmt.Errorf("访问级别无效: %q（可选 none/read/write）", name)
+	}
+	return access, nil
+}
+
+// ACLRule 目录访问规则：对象（用户、用户组或所有用户）对某目录及其子目录的访问级别
+type ACLRule struct {
+	Path    string `json:"path"`    // 上传目录内的相对路径（/开头，/表示整个上传目录）
+	Subject string `json:"subject"` // 用户名、group:<组名> 或 *（所有用户）
+	Access  Access `json:"access"`  // 访问级别
+}
+
+// ACLStore 目录访问规则存储，与用户文件一样在修改后自动重新加载。
+// 没有任何规则时不限制目录，仅按角色判断；配置规则后未被规则覆盖的目录对非管理员不可见
+type ACLStore struct {
+	path    string
+	mu      sync.Mutex
+	rules   []ACLRule
+	modTime time.Time
+}
+
+// ACL 全局目录访问规则存储（服务启动时由OpenACLStore初始化）
+var ACL *ACLStore
+
+// OpenACLStore 打开目录访问规则文件（不存在时在首次保存时创建）
+func OpenACLStore(path string) (*ACLStore, error) {
+	s := &ACLStore{path: path}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// reload 文件修改时间变化时重新加载规则（调用方需持有mu）
+func (s *ACLStore) reload() error {
+	info, err := os.Stat(s.path)
+	if os.IsNotExist(err) {
+		s.rules, s.modTime = nil, time.Time{}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.ModTime().Equal(s.modTime) {
+		return nil
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	var file struct {
+		Rules []ACLRule `json:"rules"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("解析目录权限文件%s失败: %w", s.path, err)
+	}
+	for i := range file.Rules {
+		file.Rules[i].Path = CleanACLPath(file.Rules[i].Path)
+	}
+	s.rules, s.modTime = file.Rules, info.ModTime()
+	return nil
+}
+
+// save 原子写入规则文件（调用方需持有mu）
+func (s *ACLStore) save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(struct {
+		Rules []ACLRule `json:"rules"`
+	}{s.rules}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := utils.WriteFileAtomic(s.path, data, 0600); err != nil {
+		return err
+	}
+	if info, err := os.Stat(s.path); err == nil {
+		s.modTime = info.ModTime()
+	}
+	return nil
+}
+
+// List 返回所有规则（按路径、对象排序）
+func (s *ACLStore) List() ([]ACLRule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	rules := slices.Clone(s.rules)
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Path != rules[j].Path {
+			return rules[i].Path < rules[j].Path
+		}
+		return rules[i].Subject < rules[j].Subject
+	})
+	return rules, nil
+}
+
+// Set 新增规则，同一路径、同一对象的规则已存在时替换访问级别
+func (s *ACLStore) Set(rule ACLRule) error {
+	rule.Path = CleanACLPath(rule.Path)
+	rule.Subject = strings.TrimSpace(rule.Subject)
+	if rule.Subject == "" || rule.Subject == "group:" {
+		return fmt.Errorf("规则对象不能为空（用户名、group:<组名> 或 *）")
+	}
+	if _, err := ParseAccess(string(rule.Access)); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return err
+	}
+	index := slices.IndexFunc(s.rules, func(r ACLRule) bool { return r.Path == rule.Path && r.Subject == rule.Subject })
+	if index >= 0 {
+		s.rules[index] = rule
+	} else {
+		s.rules = append(s.rules, rule)
+	}
+	return s.save()
+}
+
+// Delete 删除规则
+func (s *ACLStore) Delete(rulePath, subject string) error {
+	rulePath = CleanACLPath(rulePath)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return err
+	}
+	index := slices.IndexFunc(s.rules, func(r ACLRule) bool { return r.Path == rulePath && r.Subject == subject })
+	if index < 0 {
+		return fmt.Errorf("规则不存在: %s %s", rulePath, subject)
+	}
+	s.rules = slices.Delete(s.rules, index, index+1)
+	return s.save()
+}
+
+// Authorize 判断用户能否对上传目录内的相对路径执行操作：先按角色判断，再按目录规则判断（管理员不受规则限制）
+func (s *ACLStore) Authorize(user User, relPath string, perm Permission) bool {
+	if !user.Role.Allows(perm) {
+		return false
+	}
+	if user.Role == RoleAdmin {
+		return true
+	}
+	relPath = CleanACLPath(relPath)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		// 规则文件损坏时拒绝访问，避免规则失效导致越权
+		return false
+	}
+	if len(s.rules) == 0 {
+		return true
+	}
+
+	need := AccessWrite
+	if perm == PermList || perm == PermRead {
+		need = AccessRead
+	}
+	if accessRank[s.access(user, relPath)] >= accessRank[need] {
+		return true
+	}
+	// 子目录有读权限时允许进入上级目录（列表中只展示有权限的条目）
+	if perm == PermList {
+		for _, rule := range s.rules {
+			if rule.Access != AccessNone && subjectPriority(user, rule.Subject) > 0 && isSubPath(relPath, rule.Path) && rule.Path != relPath {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// access 计算用户对路径的访问级别（调用方需持有mu）：
+// 取路径最长（最具体）的匹配规则，同一路径上用户规则优先于用户组规则，用户组规则优先于*；没有匹配规则时为none
+func (s *ACLStore) access(user User, relPath string) Access {
+	access := AccessNone
+	bestLen, bestPriority := -1, 0
+	for _, rule := range s.rules {
+		priority := subjectPriority(user, rule.Subject)
+		if priority == 0 || !isSubPath(rule.Path, relPath) {
+			continue
+		}
+		switch {
+		case len(rule.Path) > bestLen, len(rule.Path) == bestLen && priority > bestPriority:
+			access, bestLen, bestPriority = rule.Access, len(rule.Path), priority
+		case len(rule.Path) == bestLen && priority == bestPriority && accessRank[rule.Access] > accessRank[access]:
+			// 用户属于多个组时取权限最大的组
+			access = rule.Access
+		}
+	}
+	return access
+}
+
+// subjectPriority 规则对象与用户的匹配优先级：用户名3，所属用户组2，*为1，不匹配为0
+func subjectPriority(user User, subject string) int {
+	switch {
+	case subject == user.Username:
+		return 3
+	case strings.HasPrefix(subject, "group:") && slices.Contains(user.Groups, strings.TrimPrefix(subject, "group:")):
+		return 2
+	case subject == "*":
+		return 1
+	}
+	return 0
+}
+
+// isSubPath 判断child是否为parent本身或其子路径（均为CleanACLPath规范化后的路径）
+func isSubPath(parent, child string) bool {
+	return parent == "/" || child == parent || strings.HasPrefix(child, parent+"/")
+}
+
+// CleanACLPath 将上传目录内的相对路径规范化为/开头、/分隔的形式
+func CleanACLPath(relPath string) string {
+	return path.Clean("/" + filepath.ToSlash(relPath))
+}
@@ -0,0 +1,110 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestACLAuthorize(t *testing.T) {
+	alice := User{Username: "alice", Role: RoleEditor, Groups: []string{"dev", "ops"}}
+	bob := User{Username: "bob", Role: RoleEditor, Groups: []string{"dev"}}
+	carol := User{Username: "carol", Role: RoleEditor}
+	viewer := User{Username: "vic", Role: RoleViewer, Groups: []string{"ops"}}
+	admin := User{Username: "root", Role: RoleAdmin}
+
+	rules := []ACLRule{
+		{Path: "/", Subject: "*", Access: AccessRead},
+		{Path: "/private", Subject: "*", Access: AccessNone},
+		{Path: "/private/shared", Subject: "*", Access: AccessRead},
+		{Path: "/team", Subject: "*", Access: AccessRead},
+		{Path: "/team", Subject: "group:dev", Access: AccessNone},
+		{Path: "/team", Subject: "group:ops", Access: AccessWrite},
+		{Path: "/team", Subject: "bob", Access: AccessWrite},
+		{Path: "/team/archive", Subject: "group:ops", Access: AccessRead},
+		{Path: "/hidden", Subject: "*", Access: AccessNone},
+		{Path: "/hidden/a/b", Subject: "carol", Access: AccessRead},
+	}
+	tests := []struct {
+		name  string
+		rules []ACLRule
+		user  User
+		path  string
+		perm  Permission
+		want  bool
+	}{
+		// 没有任何规则时只按角色判断
+		{"空规则按角色允许", nil, carol, "any/dir/file", PermDelete, true},
+		{"空规则按角色拒绝", nil, viewer, "any/file", PermUpload, false},
+
+		// 取路径最长的匹配规则
+		{"根目录可读", rules, carol, "readme.txt", PermRead, true},
+		{"根目录不可写", rules, carol, "readme.txt", PermUpload, false},
+		{"更具体的规则覆盖上级", rules, carol, "private/x.txt", PermRead, false},
+		{"更具体的规则重新放开", rules, carol, "private/shared/x.txt", PermRead, true},
+		{"前缀相同但不是子目录", rules, carol, "private-notes/x.txt", PermRead, true},
+
+		// 同一路径上用户规则优先于用户组规则，用户组规则优先于*
+		{"用户组规则优先于*", rules, User{Username: "dave", Role: RoleEditor, Groups: []string{"dev"}}, "team/x", PermRead, false},
+		{"用户规则优先于用户组规则", rules, bob, "team/x", PermUpload, true},
+		{"不匹配的对象取*", rules, carol, "team/x", PermRead, true},
+
+		// 属于多个用户组时取权限最大的组
+		{"多个用户组取最大权限", rules, alice, "team/x", PermUpload, true},
+		{"多个用户组时更具体的路径优先", rules, alice, "team/archive/x", PermUpload, false},
+		{"更具体路径的组规则仍可读", rules, alice, "team/archive/x", PermRead, true},
+
+		// 规则不能超出角色的权限，管理员不受规则限制
+		{"角色没有上传权限", rules, viewer, "team/x", PermUpload, false},
+		{"管理员不受规则限制", rules, admin, "private/x", PermDelete, true},
+
+		// 子目录有读权限时允许列出（进入）上级目录，但不能读取上级目录中的文件
+		{"可列出有可读子目录的上级", rules, carol, "hidden", PermList, true},
+		{"可列出中间目录", rules, carol, "hidden/a", PermList, true},
+		{"上级目录中的文件不可读", rules, carol, "hidden/a/x.txt", PermRead, false},
+		{"其他用户不能列出", rules, bob, "hidden", PermList, false},
+		{"子目录本身可读", rules, carol, "hidden/a/b/x.txt", PermRead, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := OpenACLStore(filepath.Join(t.TempDir(), "acl.json"))
+			if err != nil {
+				t.Fatalf("打开目录访问规则失败: %v", err)
+			}
+			for _, rule := range tt.rules {
+				if err := s.Set(rule); err != nil {
+					t.Fatalf("添加规则失败: %v", err)
+				}
+			}
+			if got := s.Authorize(tt.user, tt.path, tt.perm); got != tt.want {
+				t.Fatalf("Authorize(%s, %s, %s)=%v，期望%v", tt.user.Username, tt.path, tt.perm, got, tt.want)
+			}
+		})
+	}
+}
+
+// 同一路径、同一对象的规则替换访问级别，删除后恢复为上级规则
+func TestACLSetReplaceAndDelete(t *testing.T) {
+	s, err := OpenACLStore(filepath.Join(t.TempDir(), "acl.json"))
+	if err != nil {
+		t.Fatalf("打开目录访问规则失败: %v", err)
+	}
+	user := User{Username: "alice", Role: RoleEditor}
+	s.Set(ACLRule{Path: "/", Subject: "*", Access: AccessRead})
+	s.Set(ACLRule{Path: "docs/", Subject: "alice", Access: AccessRead})
+	s.Set(ACLRule{Path: "/docs", Subject: "alice", Access: AccessWrite})
+	if rules, _ := s.List(); len(rules) != 2 {
+		t.Fatalf("规则数=%d，期望2（同一路径和对象应替换）", len(rules))
+	}
+	if !s.Authorize(user, "docs/a", PermUpload) {
+		t.Fatalf("替换为write后应可上传")
+	}
+	if err := s.Delete("/docs", "alice"); err != nil {
+		t.Fatalf("删除规则失败: %v", err)
+	}
+	if s.Authorize(user, "docs/a", PermUpload) || !s.Authorize(user, "docs/a", PermRead) {
+		t.Fatalf("删除后应按根目录规则只读")
+	}
+	if err := s.Set(ACLRule{Path: "/", Subject: "group:", Access: AccessRead}); err == nil {
+		t.Fatalf("空的用户组对象应被拒绝")
+	}
+}
@@ -0,0 +1,185 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AuditAction 审计日志记录的操作
+type AuditAction string
+
+const (
+	AuditLogin    AuditAction = "login"    // 网页登录、两步验证、HTTP Basic认证失败
+	AuditLogout   AuditAction = "logout"   // 退出登录
+	AuditUpload   AuditAction = "upload"   // 上传完成、失败或取消（分块接口、tus、S3、WebDAV）
+	AuditDownload AuditAction = "download" // 下载文件
+	AuditPreview  AuditAction = "preview"  // 在线预览
+	AuditDelete   AuditAction = "delete"   // 删除文件或目录
+	AuditQRCode   AuditAction = "qrcode"   // 文件转二维码
+	AuditList     AuditAction = "list"     // 浏览目录
+	AuditMkdir    AuditAction = "mkdir"    // 创建目录（WebDAV、S3）
+	AuditMove     AuditAction = "move"     // 移动或重命名（WebDAV）
+	AuditCopy     AuditAction = "copy"     // 复制（WebDAV、S3）
+	AuditToken    AuditAction = "token"    // 创建、吊销API令牌
+	AuditTOTP     AuditAction = "totp"     // 启用、关闭两步验证，重新生成恢复码
+)
+
+// AuditActions 可查询的操作类型（审计页面的下拉选项）
+var AuditActions = []AuditAction{
+	AuditLogin, AuditLogout, AuditUpload, AuditDownload, AuditPreview, AuditDelete,
+	AuditQRCode, AuditList, AuditMkdir, AuditMove, AuditCopy, AuditToken, AuditTOTP,
+}
+
+// 审计日志的操作结果
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// AuditEntry 一条审计日志
+type AuditEntry struct {
+	Time      time.Time   `json:"time"`
+	User      string      `json:"user"`                 // 用户名（未登录为空）
+	IP        string      `json:"ip"`                   // 客户端IP
+	Action    AuditAction `json:"action"`               // 操作
+	Path      string      `json:"path,omitempty"`       // 上传目录内的路径（/开头），登录等操作为空
+	Target    string      `json:"target,omitempty"`     // 移动、复制的目标路径
+	Size      int64       `json:"size,omitempty"`       // 文件大小(B)
+	Hash      string      `json:"hash,omitempty"`       // 文件摘要（算法:十六进制摘要），上传时记录
+	Result    string      `json:"result"`               // success、failure
+	Status    int         `json:"status,omitempty"`     // HTTP响应状态码
+	Protocol  string      `json:"protocol,omitempty"`   // 访问方式：web、tus、s3、webdav
+	Detail    string      `json:"detail,omitempty"`     // 补充说明（失败原因、令牌ID等）
+	RequestID string      `json:"request_id,omitempty"` // 请求ID，可与应用日志、访问日志关联
+}
+
+// AuditFilter 审计日志查询条件，零值字段不作限制
+type AuditFilter struct {
+	User   string      // 用户名（精确匹配）
+	Path   string      // 路径：匹配该文件或该目录下的所有文件（含移动、复制的目标路径）
+	Action AuditAction // 操作
+	Since  time.Time   // 起始时间（含）
+	Until  time.Time   // 截止时间（不含）
+}
+
+// Match 判断审计日志是否满足查询条件
+func (f AuditFilter) Match(entry AuditEntry) bool {
+	if f.User != "" && entry.User != f.User {
+		return false
+	}
+	if f.Action != "" && entry.Action != f.Action {
+		return false
+	}
+	if !f.Since.IsZero() && entry.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !entry.Time.Before(f.Until) {
+		return false
+	}
+	if f.Path != "" {
+		prefix := "/" + strings.Trim(f.Path, "/")
+		if !auditPathUnder(entry.Path, prefix) && !auditPathUnder(entry.Target, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// auditPathUnder 路径等于prefix或位于prefix目录下
+func auditPathUnder(path, prefix string) bool {
+	if path == "" {
+		return false
+	}
+	return prefix == "/" || path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// AuditLog 审计日志，以JSON Lines格式只追加写入文件，与应用日志分开保存且不切割
+type AuditLog struct {
+	path string
+	mu   sync.Mutex
+	file *os.File
+}
+
+// Audit 全局审计日志（服务启动时由OpenAuditLog初始化，为nil时不记录）
+var Audit *AuditLog
+
+// OpenAuditLog 以追加方式打开审计日志文件（不存在时创建，权限0600）
+func OpenAuditLog(path string) (*AuditLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &AuditLog{path: path, file: f}, nil
+}
+
+// Append 追加一条审计日志（时间为空时使用当前时间）
+func (a *AuditLog) Append(entry AuditEntry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, err = a.file.Write(append(data, '\n'))
+	return err
+}
+
+// Each 按时间顺序遍历满足条件的审计日志，fn返回错误时停止遍历并返回该错误；无法解析的行（如写入中断）跳过
+func (a *AuditLog) Each(filter AuditFilter, fn func(AuditEntry) error) error {
+	f, err := os.Open(a.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if !filter.Match(entry) {
+			continue
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// Query 返回满足条件的最近limit条审计日志（最新的在前），以及满足条件的总条数
+func (a *AuditLog) Query(filter AuditFilter, limit int) ([]AuditEntry, int, error) {
+	var recent []AuditEntry
+	total := 0
+	err := a.Each(filter, func(entry AuditEntry) error {
+		total++
+		recent = append(recent, entry)
+		if len(recent) > limit {
+			recent = recent[1:]
+		}
+		return nil
+	})
+	for i, j := 0, len(recent)-1; i < j; i, j = i+1, j-1 {
+		recent[i], recent[j] = recent[j], recent[i]
+	}
+	return recent, total, err
+}
+
+// Close 关闭审计日志文件
+func (a *AuditLog) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.file.Close()
+}
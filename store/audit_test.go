@@ -0,0 +1,85 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAuditFilterMatch(t *testing.T) {
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	entry := AuditEntry{Time: base, User: "alice", Action: AuditMove, Path: "/docs/a/x.txt", Target: "/archive/x.txt"}
+	tests := []struct {
+		name   string
+		filter AuditFilter
+		want   bool
+	}{
+		{"零值不限制", AuditFilter{}, true},
+		{"用户精确匹配", AuditFilter{User: "alice"}, true},
+		{"用户不匹配", AuditFilter{User: "ali"}, false},
+		{"操作匹配", AuditFilter{Action: AuditMove}, true},
+		{"操作不匹配", AuditFilter{Action: AuditCopy}, false},
+		{"路径等于文件", AuditFilter{Path: "/docs/a/x.txt"}, true},
+		{"路径为上级目录", AuditFilter{Path: "docs"}, true},
+		{"路径为子目录（带末尾斜杠）", AuditFilter{Path: "/docs/a/"}, true},
+		{"前缀相同但不是子目录", AuditFilter{Path: "/doc"}, false},
+		{"匹配目标路径", AuditFilter{Path: "/archive"}, true},
+		{"根目录匹配全部", AuditFilter{Path: "/"}, true},
+		{"起始时间包含", AuditFilter{Since: base}, true},
+		{"起始时间之前", AuditFilter{Since: base.Add(time.Second)}, false},
+		{"截止时间不包含", AuditFilter{Until: base}, false},
+		{"截止时间之后", AuditFilter{Until: base.Add(time.Second)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(entry); got != tt.want {
+				t.Fatalf("Match(%+v)=%v，期望%v", tt.filter, got, tt.want)
+			}
+		})
+	}
+
+	// 没有路径的记录（如登录）不满足路径条件
+	if (AuditFilter{Path: "/"}).Match(AuditEntry{Action: AuditLogin}) {
+		t.Fatalf("没有路径的记录不应满足路径条件")
+	}
+}
+
+// 查询返回最近limit条（最新的在前）及总条数，跳过无法解析的行
+func TestAuditLogQuery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit", "audit.log")
+	a, err := OpenAuditLog(path)
+	if err != nil {
+		t.Fatalf("打开审计日志失败: %v", err)
+	}
+	defer a.Close()
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i, user := range []string{"alice", "bob", "alice", "alice"} {
+		if err := a.Append(AuditEntry{Time: base.Add(time.Duration(i) * time.Minute), User: user, Action: AuditUpload, Result: AuditSuccess}); err != nil {
+			t.Fatalf("写入审计日志失败: %v", err)
+		}
+	}
+	// 模拟写入中断的行
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	f.WriteString(`{"time":"2024-05-01T`)
+	f.WriteString("\n")
+	f.Close()
+	if err := a.Append(AuditEntry{User: "alice", Action: AuditLogout}); err != nil {
+		t.Fatalf("写入审计日志失败: %v", err)
+	}
+
+	entries, total, err := a.Query(AuditFilter{User: "alice"}, 2)
+	if err != nil {
+		t.Fatalf("查询审计日志失败: %v", err)
+	}
+	if total != 4 || len(entries) != 2 {
+		t.Fatalf("total=%d，返回%d条，期望4、2", total, len(entries))
+	}
+	if entries[0].Action != AuditLogout || entries[0].Time.IsZero() || !entries[1].Time.Equal(base.Add(3*time.Minute)) {
+		t.Fatalf("应按最新的在前返回: %+v", entries)
+	}
+
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("审计日志文件权限应为0600")
+	}
+}
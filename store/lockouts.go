@@ -0,0 +1,252 @@
+package store
+
+import (
+	"SimpleHttpServer/utils"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// LockoutPolicy 登录失败锁定策略
+type LockoutPolicy struct {
+	MaxAttempts int           // 连续失败多少次后锁定（0表示不锁定）
+	Window      time.Duration // 失败计数窗口，最后一次失败超过该时间后计数清零
+	BaseLockout time.Duration // 首次锁定时长，之后每多失败一次时长翻倍
+	MaxLockout  time.Duration // 最长锁定时长
+}
+
+// LoginAttempt 某个IP或用户名的登录失败记录
+type LoginAttempt struct {
+	Key         string    `json:"key"`                    // ip:<IP> 或 user:<用户名>
+	Failures    int       `json:"failures"`               // 连续失败次数
+	LastFailure time.Time `json:"last_failure"`           // 最后一次失败时间
+	LockedUntil time.Time `json:"locked_until,omitempty"` // 锁定截止时间
+}
+
+// Locked 是否处于锁定中
+func (a LoginAttempt) Locked() bool {
+	return a.lockedAt(time.Now())
+}
+
+// lockedAt 在指定时间是否处于锁定中
+func (a LoginAttempt) lockedAt(now time.Time) bool {
+	return now.Before(a.LockedUntil)
+}
+
+// LockoutKeys 返回登录请求对应的计数键（按IP和按用户名分别计数）
+func LockoutKeys(clientIP, username string) []string {
+	return []string{"ip:" + clientIP, "user:" + username}
+}
+
+// LockoutStore 登录失败记录，保存在文件中以便 lockout 子命令在服务运行时查看和解除锁定
+type LockoutStore struct {
+	path     string
+	policy   LockoutPolicy
+	mu       sync.Mutex
+	attempts map[string]*LoginAttempt
+	modTime  time.Time
+	now      func() time.Time // 当前时间（测试中替换以模拟时间流逝）
+}
+
+// Lockouts 全局登录失败记录（服务启动时由OpenLockoutStore初始化）
+var Lockouts *LockoutStore
+
+// OpenLockoutStore 打开登录失败记录文件（不存在时在首次保存时创建）
+func OpenLockoutStore(path string, policy LockoutPolicy) (*LockoutStore, error) {
+	s := &LockoutStore{path: path, policy: policy, attempts: map[string]*LoginAttempt{}, now: time.Now}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// SetPolicy 修改锁定策略（配置重新加载时调用），已有的锁定不受影响
+func (s *LockoutStore) SetPolicy(policy LockoutPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.policy = policy
+}
+
+// reload 文件修改时间变化时重新加载（调用方需持有mu）
+func (s *LockoutStore) reload() error {
+	info, err := os.Stat(s.path)
+	if os.IsNotExist(err) {
+		s.attempts, s.modTime = map[string]*LoginAttempt{}, time.Time{}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.ModTime().Equal(s.modTime) {
+		return nil
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	var file struct {
+		Attempts []*LoginAttempt `json:"attempts"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("解析登录失败记录%s失败: %w", s.path, err)
+	}
+	s.attempts = map[string]*LoginAttempt{}
+	for _, attempt := range file.Attempts {
+		s.attempts[attempt.Key] = attempt
+	}
+	s.modTime = info.ModTime()
+	return nil
+}
+
+// save 清理过期记录后原子写入文件（调用方需持有mu）
+func (s *LockoutStore) save() error {
+	attempts := make([]*LoginAttempt, 0, len(s.attempts))
+	for key, attempt := range s.attempts {
+		if s.expired(attempt) {
+			delete(s.attempts, key)
+			continue
+		}
+		attempts = append(attempts, attempt)
+	}
+	sort.Slice(attempts, func(i, j int) bool { return attempts[i].Key < attempts[j].Key })
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(struct {
+		Attempts []*LoginAttempt `json:"attempts"`
+	}{attempts}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := utils.WriteFileAtomic(s.path, data, 0600); err != nil {
+		return err
+	}
+	if info, err := os.Stat(s.path); err == nil {
+		s.modTime = info.ModTime()
+	}
+	return nil
+}
+
+// expired 记录是否已失效（未锁定且最后一次失败超出计数窗口）
+func (s *LockoutStore) expired(attempt *LoginAttempt) bool {
+	now := s.now()
+	return !attempt.lockedAt(now) && now.Sub(attempt.LastFailure) > s.policy.Window
+}
+
+// Locked 检查各计数键是否处于锁定中，返回最晚的锁定截止时间
+func (s *LockoutStore) Locked(keys ...string) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.policy.MaxAttempts <= 0 {
+		return time.Time{}, false
+	}
+	if err := s.reload(); err != nil {
+		return time.Time{}, false
+	}
+	var until time.Time
+	for _, key := range keys {
+		if attempt, ok := s.attempts[key]; ok && attempt.lockedAt(s.now()) && attempt.LockedUntil.After(until) {
+			until = attempt.LockedUntil
+		}
+	}
+	return until, !until.IsZero()
+}
+
+// RecordFailure 记录一次登录失败，失败次数达到上限时锁定（锁定时长按超出次数指数增长），返回最晚的锁定截止时间
+func (s *LockoutStore) RecordFailure(keys ...string) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.policy.MaxAttempts <= 0 {
+		return time.Time{}, nil
+	}
+	if err := s.reload(); err != nil {
+		return time.Time{}, err
+	}
+	now := s.now()
+	var until time.Time
+	for _, key := range keys {
+		attempt, ok := s.attempts[key]
+		if !ok || s.expired(attempt) {
+			attempt = &LoginAttempt{Key: key}
+			s.attempts[key] = attempt
+		}
+		attempt.Failures++
+		attempt.LastFailure = now
+		if over := attempt.Failures - s.policy.MaxAttempts; over >= 0 {
+			lockout := s.policy.BaseLockout
+			for i := 0; i < over && lockout < s.policy.MaxLockout; i++ {
+				lockout *= 2
+			}
+			lockout = min(lockout, s.policy.MaxLockout)
+			attempt.LockedUntil = now.Add(lockout)
+			if attempt.LockedUntil.After(until) {
+				until = attempt.LockedUntil
+			}
+		}
+	}
+	return until, s.save()
+}
+
+// Reset 登录成功后清除计数（没有记录时不写文件）
+func (s *LockoutStore) Reset(keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return err
+	}
+	changed := false
+	for _, key := range keys {
+		if _, ok := s.attempts[key]; ok {
+			delete(s.attempts, key)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return s.save()
+}
+
+// List 返回仍在计数窗口内或锁定中的记录（按键排序）
+func (s *LockoutStore) List() ([]LoginAttempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	attempts := []LoginAttempt{}
+	for _, attempt := range s.attempts {
+		if !s.expired(attempt) {
+			attempts = append(attempts, *attempt)
+		}
+	}
+	sort.Slice(attempts, func(i, j int) bool { return attempts[i].Key < attempts[j].Key })
+	return attempts, nil
+}
+
+// Clear 解除锁定并清除计数，keys为空时清除所有记录，返回清除的记录数
+func (s *LockoutStore) Clear(keys ...string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return 0, err
+	}
+	cleared := 0
+	if len(keys) == 0 {
+		cleared = len(s.attempts)
+		s.attempts = map[string]*LoginAttempt{}
+	}
+	for _, key := range keys {
+		if _, ok := s.attempts[key]; ok {
+			delete(s.attempts, key)
+			cleared++
+		}
+	}
+	return cleared, s.save()
+}
@@ -0,0 +1,133 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestLockoutStore 打开临时目录中的登录失败记录，当前时间由返回的指针控制
+func newTestLockoutStore(t *testing.T, policy LockoutPolicy) (*LockoutStore, *time.Time) {
+	t.Helper()
+	s, err := OpenLockoutStore(filepath.Join(t.TempDir(), "lockouts.json"), policy)
+	if err != nil {
+		t.Fatalf("打开登录失败记录失败: %v", err)
+	}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	return s, &now
+}
+
+var testLockoutPolicy = LockoutPolicy{MaxAttempts: 3, Window: 10 * time.Minute, BaseLockout: time.Minute, MaxLockout: 4 * time.Minute}
+
+// 达到失败次数上限后锁定，之后每多失败一次锁定时长翻倍，不超过最长锁定时长
+func TestLockoutThreshold(t *testing.T) {
+	s, now := newTestLockoutStore(t, testLockoutPolicy)
+	keys := LockoutKeys("10.0.0.1", "alice")
+	for i, want := range []time.Duration{0, 0, time.Minute, 2 * time.Minute, 4 * time.Minute, 4 * time.Minute} {
+		until, err := s.RecordFailure(keys...)
+		if err != nil {
+			t.Fatalf("记录失败: %v", err)
+		}
+		if got := until.Sub(*now); until.IsZero() && want != 0 || !until.IsZero() && got != want {
+			t.Fatalf("第%d次失败锁定%v，期望%v", i+1, got, want)
+		}
+		if _, locked := s.Locked(keys...); locked != (want > 0) {
+			t.Fatalf("第%d次失败后locked=%v", i+1, locked)
+		}
+	}
+
+	// 锁定到期后解除，但仍在计数窗口内，再失败一次立即按最长时长锁定
+	*now = now.Add(4*time.Minute + time.Second)
+	if _, locked := s.Locked(keys...); locked {
+		t.Fatalf("锁定到期后应解除")
+	}
+	if until, _ := s.RecordFailure(keys...); until.Sub(*now) != 4*time.Minute {
+		t.Fatalf("计数窗口内再次失败应立即锁定，实际%v", until.Sub(*now))
+	}
+
+	// 登录成功清除计数
+	if err := s.Reset(keys...); err != nil {
+		t.Fatalf("清除计数失败: %v", err)
+	}
+	if _, locked := s.Locked(keys...); locked {
+		t.Fatalf("清除计数后不应锁定")
+	}
+}
+
+// 最后一次失败超出计数窗口后重新计数
+func TestLockoutWindowExpiry(t *testing.T) {
+	s, now := newTestLockoutStore(t, testLockoutPolicy)
+	s.RecordFailure("user:alice")
+	s.RecordFailure("user:alice")
+	*now = now.Add(10*time.Minute + time.Second)
+	if attempts, _ := s.List(); len(attempts) != 0 {
+		t.Fatalf("超出计数窗口的记录不应列出: %+v", attempts)
+	}
+	if until, _ := s.RecordFailure("user:alice"); !until.IsZero() {
+		t.Fatalf("超出计数窗口后应重新计数，不应锁定")
+	}
+	attempts, _ := s.List()
+	if len(attempts) != 1 || attempts[0].Failures != 1 {
+		t.Fatalf("重新计数后失败次数应为1: %+v", attempts)
+	}
+
+	// 窗口内的失败持续累计
+	*now = now.Add(9 * time.Minute)
+	s.RecordFailure("user:alice")
+	if until, _ := s.RecordFailure("user:alice"); until.Sub(*now) != time.Minute {
+		t.Fatalf("窗口内累计3次失败应锁定1分钟")
+	}
+}
+
+// IP和用户名分别计数，返回最晚的锁定截止时间；锁定记录保存在文件中，其他进程打开后可见
+func TestLockoutKeys(t *testing.T) {
+	s, now := newTestLockoutStore(t, testLockoutPolicy)
+	for i := 0; i < 3; i++ {
+		s.RecordFailure(LockoutKeys("10.0.0.1", "alice")...)
+	}
+	// 同一IP尝试其他用户名：IP已锁定
+	if _, locked := s.Locked(LockoutKeys("10.0.0.1", "bob")...); !locked {
+		t.Fatalf("同一IP尝试其他用户名应被锁定")
+	}
+	// 其他IP尝试同一用户名：用户名已锁定
+	if _, locked := s.Locked(LockoutKeys("10.0.0.2", "alice")...); !locked {
+		t.Fatalf("其他IP尝试同一用户名应被锁定")
+	}
+	if _, locked := s.Locked(LockoutKeys("10.0.0.2", "bob")...); locked {
+		t.Fatalf("无关的IP和用户名不应被锁定")
+	}
+	s.RecordFailure("user:alice")
+	until, _ := s.Locked(LockoutKeys("10.0.0.1", "alice")...)
+	if until.Sub(*now) != 2*time.Minute {
+		t.Fatalf("应返回最晚的锁定截止时间，实际%v", until.Sub(*now))
+	}
+
+	other, err := OpenLockoutStore(s.path, testLockoutPolicy)
+	if err != nil {
+		t.Fatalf("重新打开登录失败记录失败: %v", err)
+	}
+	other.now = s.now
+	if _, locked := other.Locked("ip:10.0.0.1"); !locked {
+		t.Fatalf("重新打开后锁定应仍然有效")
+	}
+	if cleared, _ := other.Clear("ip:10.0.0.1"); cleared != 1 {
+		t.Fatalf("解除锁定的记录数=%d，期望1", cleared)
+	}
+	if _, locked := s.Locked("ip:10.0.0.1"); locked {
+		t.Fatalf("其他进程解除锁定后应重新加载")
+	}
+}
+
+// 失败次数上限为0时不锁定
+func TestLockoutDisabled(t *testing.T) {
+	s, _ := newTestLockoutStore(t, LockoutPolicy{})
+	for i := 0; i < 10; i++ {
+		if until, err := s.RecordFailure("user:alice"); err != nil || !until.IsZero() {
+			t.Fatalf("未启用锁定时不应锁定: until=%v err=%v", until, err)
+		}
+	}
+	if _, locked := s.Locked("user:alice"); locked {
+		t.Fatalf("未启用锁定时不应锁定")
+	}
+}
@@ -0,0 +1,53 @@
+package store
+
+import (
+	ginsessions "github.com/gin-contrib/sessions"
+	"github.com/gorilla/sessions"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileSessionStore 服务端会话存储：会话数据保存在目录下的文件中，Cookie中只保存签名加密后的会话ID，
+// 退出登录时删除会话文件，即使Cookie被复制也无法继续使用
+type FileSessionStore struct {
+	*sessions.FilesystemStore
+	dir string
+}
+
+// NewFileSessionStore 创建服务端会话存储，keyPairs为Cookie签名密钥和加密密钥
+func NewFileSessionStore(dir string, keyPairs ...[]byte) (*FileSessionStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileSessionStore{FilesystemStore: sessions.NewFilesystemStore(dir, keyPairs...), dir: dir}, nil
+}
+
+// Options 设置会话Cookie选项（实现gin-contrib/sessions的Store接口）
+func (s *FileSessionStore) Options(options ginsessions.Options) {
+	s.FilesystemStore.Options = options.ToGorillaOptions()
+}
+
+// Sweep 删除超过有效期未更新的会话文件，返回删除的文件数
+func (s *FileSessionStore) Sweep(maxAge time.Duration) (int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, err
+	}
+	deadline := time.Now().Add(-maxAge)
+	removed := 0
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), "session_") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(deadline) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.dir, entry.Name())); err == nil {
+			removed++
+		}
+	}
+	return removed, nil
+}
@@ -0,0 +1,267 @@
+package store
+
+import (
+	"SimpleHttpServer/utils"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Token 个人访问令牌，供脚本通过 Authorization: Bearer 访问接口
+type Token struct {
+	ID        string       `json:"id"`                   // 令牌ID（明文令牌中包含，日志中记录）
+	UserID    string       `json:"user_id"`              // 所属用户ID
+	Name      string       `json:"name"`                 // 令牌名称（用途说明）
+	Hash      string       `json:"hash"`                 // 令牌sha256摘要，明文只在创建时返回一次
+	Scopes    []Permission `json:"scopes"`               // 授权范围：read/upload/delete
+	CreatedAt time.Time    `json:"created_at"`           // 创建时间
+	ExpiresAt time.Time    `json:"expires_at,omitempty"` // 过期时间，零值表示永不过期
+	LastUsed  time.Time    `json:"last_used,omitempty"`  // 最后使用时间
+}
+
+// TokenScopes 令牌可选的授权范围
+var TokenScopes = []Permission{PermRead, PermUpload, PermDelete}
+
+// tokenPrefix 明文令牌前缀，格式：shs_<令牌ID>_<随机串>
+const tokenPrefix = "shs_"
+
+// tokenUseRecordInterval 最后使用时间的最小更新间隔，避免每个请求都写文件
+const tokenUseRecordInterval = time.Minute
+
+var (
+	ErrTokenInvalid = errors.New("API令牌无效")
+	ErrTokenExpired = errors.New("API令牌已过期")
+)
+
+// Allows 令牌授权范围是否包含指定操作（浏览目录属于read范围）
+func (t Token) Allows(perm Permission) bool {
+	if perm == PermList {
+		perm = PermRead
+	}
+	return slices.Contains(t.Scopes, perm)
+}
+
+// Expired 令牌是否已过期
+func (t Token) Expired() bool {
+	return !t.ExpiresAt.IsZero() && time.Now().After(t.ExpiresAt)
+}
+
+// ParseTokenScopes 解析逗号分隔的授权范围
+func ParseTokenScopes(value string) ([]Permission, error) {
+	var scopes []Permission
+	for _, item := range strings.Split(value, ",") {
+		scope := Permission(strings.ToLower(strings.TrimSpace(item)))
+		if scope == "" {
+			continue
+		}
+		if !slices.Contains(TokenScopes, scope) {
+			return nil, fmt.Errorf("授权范围无效: %q（可选 read/upload/delete）", item)
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	if len(scopes) == 0 {
+		return nil, errors.New("至少指定一个授权范围（read/upload/delete）")
+	}
+	return scopes, nil
+}
+
+// TokenStore 个人访问令牌存储，与用户文件一样在修改后自动重新加载
+type TokenStore struct {
+	path    string
+	mu      sync.Mutex
+	tokens  []*Token
+	modTime time.Time
+}
+
+// Tokens 全局令牌存储（服务启动时由OpenTokenStore初始化）
+var Tokens *TokenStore
+
+// OpenTokenStore 打开令牌文件（不存在时在首次保存时创建）
+func OpenTokenStore(path string) (*TokenStore, error) {
+	s := &TokenStore{path: path}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// reload 文件修改时间变化时重新加载令牌（调用方需持有mu）
+func (s *TokenStore) reload() error {
+	info, err := os.Stat(s.path)
+	if os.IsNotExist(err) {
+		s.tokens, s.modTime = nil, time.Time{}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.ModTime().Equal(s.modTime) {
+		return nil
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	var file struct {
+		Tokens []*Token `json:"tokens"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("解析令牌文件%s失败: %w", s.path, err)
+	}
+	s.tokens, s.modTime = file.Tokens, info.ModTime()
+	return nil
+}
+
+// save 原子写入令牌文件（调用方需持有mu）
+func (s *TokenStore) save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(struct {
+		Tokens []*Token `json:"tokens"`
+	}{s.tokens}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := utils.WriteFileAtomic(s.path, data, 0600); err != nil {
+		return err
+	}
+	if info, err := os.Stat(s.path); err == nil {
+		s.modTime = info.ModTime()
+	}
+	return nil
+}
+
+// Create 为用户创建令牌，返回令牌信息和明文令牌（明文不保存，只能在创建时获取）
+// ttl为0表示永不过期
+func (s *TokenStore) Create(userID, name string, scopes []Permission, ttl time.Duration) (Token, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return Token{}, "", errors.New("令牌名称不能为空")
+	}
+	if len(scopes) == 0 {
+		return Token{}, "", errors.New("至少指定一个授权范围（read/upload/delete）")
+	}
+	id := utils.NewUploadID()[:16]
+	secret := tokenPrefix + id + "_" + utils.NewUploadID()
+	token := &Token{
+		ID:        id,
+		UserID:    userID,
+		Name:      name,
+		Hash:      hashToken(secret),
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+	}
+	if ttl > 0 {
+		token.ExpiresAt = token.CreatedAt.Add(ttl)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return Token{}, "", err
+	}
+	s.tokens = append(s.tokens, token)
+	if err := s.save(); err != nil {
+		return Token{}, "", err
+	}
+	return *token, secret, nil
+}
+
+// List 返回令牌列表（按创建时间排序），userID为空时返回所有用户的令牌
+func (s *TokenStore) List(userID string) ([]Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	tokens := []Token{}
+	for _, token := range s.tokens {
+		if userID == "" || token.UserID == userID {
+			tokens = append(tokens, *token)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].CreatedAt.Before(tokens[j].CreatedAt) })
+	return tokens, nil
+}
+
+// Revoke 吊销令牌，userID不为空时只能吊销该用户的令牌
+func (s *TokenStore) Revoke(id, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return err
+	}
+	index := slices.IndexFunc(s.tokens, func(t *Token) bool {
+		return t.ID == id && (userID == "" || t.UserID == userID)
+	})
+	if index < 0 {
+		return fmt.Errorf("令牌不存在: %s", id)
+	}
+	s.tokens = slices.Delete(s.tokens, index, index+1)
+	return s.save()
+}
+
+// Authenticate 校验明文令牌，成功返回令牌信息并更新最后使用时间
+func (s *TokenStore) Authenticate(secret string) (Token, error) {
+	rest, ok := strings.CutPrefix(secret, tokenPrefix)
+	if !ok {
+		return Token{}, ErrTokenInvalid
+	}
+	id, _, ok := strings.Cut(rest, "_")
+	if !ok {
+		return Token{}, ErrTokenInvalid
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return Token{}, err
+	}
+	index := slices.IndexFunc(s.tokens, func(t *Token) bool { return t.ID == id })
+	if index < 0 {
+		return Token{}, ErrTokenInvalid
+	}
+	token := s.tokens[index]
+	if subtle.ConstantTimeCompare([]byte(token.Hash), []byte(hashToken(secret))) != 1 {
+		return Token{}, ErrTokenInvalid
+	}
+	if token.Expired() {
+		return *token, ErrTokenExpired
+	}
+	if now := time.Now(); now.Sub(token.LastUsed) >= tokenUseRecordInterval {
+		token.LastUsed = now
+		// 记录失败不影响本次认证
+		_ = s.save()
+	}
+	return *token, nil
+}
+
+// RevokeUser 删除用户的所有令牌（删除用户时调用）
+func (s *TokenStore) RevokeUser(userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return err
+	}
+	s.tokens = slices.DeleteFunc(s.tokens, func(t *Token) bool { return t.UserID == userID })
+	return s.save()
+}
+
+// hashToken 计算令牌摘要（令牌为高熵随机串，无需bcrypt等慢哈希）
+func hashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
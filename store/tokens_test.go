@@ -0,0 +1,84 @@
+package store
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenAuthenticate(t *testing.T) {
+	s, err := OpenTokenStore(filepath.Join(t.TempDir(), "tokens.json"))
+	if err != nil {
+		t.Fatalf("打开令牌存储失败: %v", err)
+	}
+	valid, validSecret, err := s.Create("u1", "ci", []Permission{PermRead}, 0)
+	if err != nil {
+		t.Fatalf("创建令牌失败: %v", err)
+	}
+	expired, expiredSecret, _ := s.Create("u1", "old", []Permission{PermRead}, time.Nanosecond)
+	revoked, revokedSecret, _ := s.Create("u1", "gone", []Permission{PermRead}, 0)
+	if err := s.Revoke(revoked.ID, "u2"); err == nil {
+		t.Fatalf("不能吊销其他用户的令牌")
+	}
+	if err := s.Revoke(revoked.ID, "u1"); err != nil {
+		t.Fatalf("吊销令牌失败: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+
+	// 令牌ID正确但随机部分错误：按ID能找到令牌，摘要不一致
+	_, random, _ := strings.Cut(strings.TrimPrefix(validSecret, tokenPrefix), "_")
+	wrongSecret := tokenPrefix + valid.ID + "_" + strings.Repeat("0", len(random))
+
+	tests := []struct {
+		name   string
+		secret string
+		wantID string
+		err    error
+	}{
+		{"有效令牌", validSecret, valid.ID, nil},
+		{"令牌ID正确但密钥错误", wrongSecret, "", ErrTokenInvalid},
+		{"令牌ID不存在", tokenPrefix + "nope_" + random, "", ErrTokenInvalid},
+		{"缺少前缀", strings.TrimPrefix(validSecret, tokenPrefix), "", ErrTokenInvalid},
+		{"缺少随机部分", tokenPrefix + valid.ID, "", ErrTokenInvalid},
+		{"已过期", expiredSecret, expired.ID, ErrTokenExpired},
+		{"已吊销", revokedSecret, "", ErrTokenInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := s.Authenticate(tt.secret)
+			if !errors.Is(err, tt.err) || token.ID != tt.wantID {
+				t.Fatalf("Authenticate返回令牌%q err=%v，期望%q err=%v", token.ID, err, tt.wantID, tt.err)
+			}
+		})
+	}
+
+	// 认证成功后记录最后使用时间，且只保存摘要不保存明文
+	tokens, _ := s.List("u1")
+	for _, token := range tokens {
+		if token.Hash == validSecret || strings.Contains(token.Hash, random) {
+			t.Fatalf("令牌文件中不应保存明文")
+		}
+		if token.ID == valid.ID && token.LastUsed.IsZero() {
+			t.Fatalf("认证成功后应记录最后使用时间")
+		}
+	}
+}
+
+func TestTokenAllows(t *testing.T) {
+	token := Token{Scopes: []Permission{PermRead, PermUpload}}
+	for perm, want := range map[Permission]bool{PermList: true, PermRead: true, PermUpload: true, PermDelete: false} {
+		if got := token.Allows(perm); got != want {
+			t.Errorf("Allows(%s)=%v，期望%v", perm, got, want)
+		}
+	}
+	if scopes, err := ParseTokenScopes("Read, upload,read"); err != nil || len(scopes) != 2 {
+		t.Fatalf("ParseTokenScopes=%v err=%v，期望[read upload]", scopes, err)
+	}
+	for _, value := range []string{"", "admin", "read,list"} {
+		if _, err := ParseTokenScopes(value); err == nil {
+			t.Errorf("ParseTokenScopes(%q)应返回错误", value)
+		}
+	}
+}
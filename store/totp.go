@@ -0,0 +1,213 @@
+package store
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// RFC 6238 TOTP参数（与主流身份验证器App的默认值一致）
+const (
+	totpPeriod = 30 // 时间步长（秒）
+	totpDigits = 6  // 验证码位数
+	totpSkew   = 1  // 允许前后偏差的时间步数（容忍客户端时钟误差）
+)
+
+// recoveryCodeCount 每次生成的恢复码数量
+const recoveryCodeCount = 10
+
+var (
+	ErrInvalidTOTP     = errors.New("两步验证码错误")
+	ErrTOTPNotEnabled  = errors.New("未启用两步验证")
+	ErrTOTPEnabled     = errors.New("已启用两步验证")
+	totpSecretEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+)
+
+// TOTPEnabled 用户是否已启用两步验证
+func (u User) TOTPEnabled() bool {
+	return u.TOTPSecret != ""
+}
+
+// NewTOTPSecret 生成两步验证密钥（160位随机数，base32编码）
+func NewTOTPSecret() string {
+	secret := make([]byte, 20)
+	rand.Read(secret)
+	return totpSecretEncoding.EncodeToString(secret)
+}
+
+// TOTPURI 生成身份验证器App扫码添加账号用的 otpauth:// 地址
+func TOTPURI(issuer, account, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(totpDigits))
+	query.Set("period", fmt.Sprint(totpPeriod))
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// totpCode 计算指定时间步的验证码（RFC 4226 HOTP，计数器为时间步）
+func totpCode(secret string, step int64) (string, error) {
+	key, err := totpSecretEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", fmt.Errorf("两步验证密钥无效: %w", err)
+	}
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%uint32(math.Pow10(totpDigits))), nil
+}
+
+// matchTOTP 校验验证码，返回匹配的时间步；只接受晚于lastStep的时间步，防止验证码被重放
+func matchTOTP(secret, code string, lastStep int64, now time.Time) (int64, bool) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != totpDigits {
+		return 0, false
+	}
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= lastStep {
+			continue
+		}
+		expected, err := totpCode(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// newRecoveryCodes 生成一组恢复码，返回明文（展示给用户）和摘要（保存）
+func newRecoveryCodes() ([]string, []string) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for range recoveryCodeCount {
+		raw := make([]byte, 5)
+		rand.Read(raw)
+		code := hex.EncodeToString(raw)
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	return codes, hashes
+}
+
+// hashRecoveryCode 计算恢复码摘要（忽略大小写、空格和连字符）
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// EnableTOTP 校验用户用新密钥生成的验证码后启用两步验证，返回恢复码明文（只在此时返回一次）；
+// 启用后该用户其他仅凭密码登录的会话随即失效
+func (s *UserStore) EnableTOTP(id, secret, code string) ([]string, error) {
+	codes, hashes := newRecoveryCodes()
+	err := s.update(func() error {
+		user := s.findByID(id)
+		if user == nil {
+			return ErrUserNotFound
+		}
+		if user.TOTPEnabled() {
+			return ErrTOTPEnabled
+		}
+		step, ok := matchTOTP(secret, code, 0, time.Now())
+		if !ok {
+			return ErrInvalidTOTP
+		}
+		user.TOTPSecret = secret
+		user.TOTPLastStep = step
+		user.RecoveryCodes = hashes
+		user.SessionsValidAfter = time.Now()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// VerifyTOTP 校验两步验证码或恢复码（恢复码使用后作废），返回是否使用了恢复码
+func (s *UserStore) VerifyTOTP(id, code string) (bool, error) {
+	usedRecovery := false
+	err := s.update(func() error {
+		user := s.findByID(id)
+		if user == nil {
+			return ErrUserNotFound
+		}
+		if !user.TOTPEnabled() {
+			return ErrTOTPNotEnabled
+		}
+		if step, ok := matchTOTP(user.TOTPSecret, code, user.TOTPLastStep, time.Now()); ok {
+			user.TOTPLastStep = step
+			return nil
+		}
+		hash := hashRecoveryCode(code)
+		index := slices.IndexFunc(user.RecoveryCodes, func(h string) bool {
+			return subtle.ConstantTimeCompare([]byte(h), []byte(hash)) == 1
+		})
+		if index < 0 {
+			return ErrInvalidTOTP
+		}
+		user.RecoveryCodes = slices.Delete(user.RecoveryCodes, index, index+1)
+		usedRecovery = true
+		return nil
+	})
+	return usedRecovery, err
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码（原有恢复码全部作废），返回新恢复码明文
+func (s *UserStore) RegenerateRecoveryCodes(id string) ([]string, error) {
+	codes, hashes := newRecoveryCodes()
+	err := s.update(func() error {
+		user := s.findByID(id)
+		if user == nil {
+			return ErrUserNotFound
+		}
+		if !user.TOTPEnabled() {
+			return ErrTOTPNotEnabled
+		}
+		user.RecoveryCodes = hashes
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableTOTP 关闭用户的两步验证（用户自行关闭，或丢失身份验证器时由管理员重置）
+func (s *UserStore) DisableTOTP(username string) error {
+	return s.update(func() error {
+		user := s.find(username)
+		if user == nil {
+			return ErrUserNotFound
+		}
+		if !user.TOTPEnabled() {
+			return ErrTOTPNotEnabled
+		}
+		user.TOTPSecret = ""
+		user.TOTPLastStep = 0
+		user.RecoveryCodes = nil
+		return nil
+	})
+}
@@ -0,0 +1,165 @@
+package store
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 6238附录B的SHA1测试向量（密钥为ASCII "12345678901234567890"），取8位验证码的后6位
+func TestTOTPCodeRFC6238(t *testing.T) {
+	secret := totpSecretEncoding.EncodeToString([]byte("12345678901234567890"))
+	for unix, want := range map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	} {
+		got, err := totpCode(secret, unix/totpPeriod)
+		if err != nil || got != want {
+			t.Errorf("T=%d 验证码=%s err=%v，期望%s", unix, got, err, want)
+		}
+	}
+	// 密钥不区分大小写
+	if got, _ := totpCode(strings.ToLower(secret), 59/totpPeriod); got != "287082" {
+		t.Errorf("小写密钥的验证码=%s，期望287082", got)
+	}
+	if _, err := totpCode("not base32!", 1); err == nil {
+		t.Errorf("无效密钥应返回错误")
+	}
+}
+
+// 接受前后各一个时间步内的验证码，只接受晚于上次使用的时间步
+func TestMatchTOTPWindow(t *testing.T) {
+	secret := totpSecretEncoding.EncodeToString([]byte("12345678901234567890"))
+	now := time.Unix(1700000000, 0)
+	current := now.Unix() / totpPeriod
+	code := func(step int64) string {
+		c, _ := totpCode(secret, step)
+		return c
+	}
+	tests := []struct {
+		name     string
+		code     string
+		lastStep int64
+		wantStep int64
+		ok       bool
+	}{
+		{"当前时间步", code(current), 0, current, true},
+		{"上一个时间步", code(current - 1), 0, current - 1, true},
+		{"下一个时间步", code(current + 1), 0, current + 1, true},
+		{"超出偏差的时间步", code(current - 2), 0, 0, false},
+		{"超出偏差的未来时间步", code(current + 2), 0, 0, false},
+		{"带空格的验证码", code(current)[:3] + " " + code(current)[3:], 0, current, true},
+		{"位数不对", code(current)[:5], 0, 0, false},
+		{"已使用的时间步不能重放", code(current), current, 0, false},
+		{"早于已使用时间步的验证码", code(current - 1), current, 0, false},
+		{"晚于已使用时间步", code(current + 1), current, current + 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := matchTOTP(secret, tt.code, tt.lastStep, now)
+			if ok != tt.ok || step != tt.wantStep {
+				t.Fatalf("matchTOTP=(%d, %v)，期望(%d, %v)", step, ok, tt.wantStep, tt.ok)
+			}
+		})
+	}
+}
+
+// newTestUser 在临时用户存储中创建用户
+func newTestUser(t *testing.T) (*UserStore, User) {
+	t.Helper()
+	s, err := OpenUserStore(filepath.Join(t.TempDir(), "users.json"))
+	if err != nil {
+		t.Fatalf("打开用户存储失败: %v", err)
+	}
+	user, err := s.Add("alice", "password", RoleEditor)
+	if err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	return s, user
+}
+
+// 启用两步验证后，同一验证码（TOTPLastStep之前的时间步）不能再次使用
+func TestVerifyTOTPReplay(t *testing.T) {
+	s, user := newTestUser(t)
+	secret := NewTOTPSecret()
+	step := time.Now().Unix() / totpPeriod
+	code, _ := totpCode(secret, step)
+	stale, _ := totpCode(secret, step-5)
+	if _, err := s.EnableTOTP(user.ID, secret, stale); !errors.Is(err, ErrInvalidTOTP) {
+		t.Fatalf("过期的验证码不能启用，err=%v", err)
+	}
+	if _, err := s.EnableTOTP(user.ID, secret, code); err != nil {
+		t.Fatalf("启用两步验证失败: %v", err)
+	}
+	if _, err := s.EnableTOTP(user.ID, secret, code); !errors.Is(err, ErrTOTPEnabled) {
+		t.Fatalf("重复启用应返回ErrTOTPEnabled，err=%v", err)
+	}
+
+	// 启用时使用的验证码不能用于登录
+	if _, err := s.VerifyTOTP(user.ID, code); !errors.Is(err, ErrInvalidTOTP) {
+		t.Fatalf("启用时使用的验证码被重放，err=%v", err)
+	}
+	next, _ := totpCode(secret, step+1)
+	if used, err := s.VerifyTOTP(user.ID, next); err != nil || used {
+		t.Fatalf("下一个时间步的验证码应通过，used=%v err=%v", used, err)
+	}
+	if _, err := s.VerifyTOTP(user.ID, next); !errors.Is(err, ErrInvalidTOTP) {
+		t.Fatalf("已使用的验证码被重放，err=%v", err)
+	}
+	if stored, _ := s.Get(user.ID); stored.TOTPLastStep != step+1 {
+		t.Fatalf("TOTPLastStep=%d，期望%d", stored.TOTPLastStep, step+1)
+	}
+
+	if err := s.DisableTOTP("alice"); err != nil {
+		t.Fatalf("关闭两步验证失败: %v", err)
+	}
+	if _, err := s.VerifyTOTP(user.ID, next); !errors.Is(err, ErrTOTPNotEnabled) {
+		t.Fatalf("关闭后校验应返回ErrTOTPNotEnabled，err=%v", err)
+	}
+}
+
+// 恢复码只能使用一次，忽略大小写和连字符；重新生成后原有恢复码作废
+func TestRecoveryCodesSingleUse(t *testing.T) {
+	s, user := newTestUser(t)
+	secret := NewTOTPSecret()
+	code, _ := totpCode(secret, time.Now().Unix()/totpPeriod)
+	codes, err := s.EnableTOTP(user.ID, secret, code)
+	if err != nil || len(codes) != recoveryCodeCount {
+		t.Fatalf("启用两步验证返回%d个恢复码 err=%v", len(codes), err)
+	}
+	if stored, _ := s.Get(user.ID); slices.Contains(stored.RecoveryCodes, codes[0]) {
+		t.Fatalf("恢复码不应明文保存")
+	}
+
+	if used, err := s.VerifyTOTP(user.ID, codes[0]); err != nil || !used {
+		t.Fatalf("恢复码应通过，used=%v err=%v", used, err)
+	}
+	if _, err := s.VerifyTOTP(user.ID, codes[0]); !errors.Is(err, ErrInvalidTOTP) {
+		t.Fatalf("恢复码不能重复使用，err=%v", err)
+	}
+	variant := " " + strings.ToUpper(strings.ReplaceAll(codes[1], "-", "")) + " "
+	if used, err := s.VerifyTOTP(user.ID, variant); err != nil || !used {
+		t.Fatalf("恢复码应忽略大小写、空格和连字符，used=%v err=%v", used, err)
+	}
+	if stored, _ := s.Get(user.ID); len(stored.RecoveryCodes) != recoveryCodeCount-2 {
+		t.Fatalf("剩余恢复码%d个，期望%d", len(stored.RecoveryCodes), recoveryCodeCount-2)
+	}
+
+	fresh, err := s.RegenerateRecoveryCodes(user.ID)
+	if err != nil {
+		t.Fatalf("重新生成恢复码失败: %v", err)
+	}
+	if _, err := s.VerifyTOTP(user.ID, codes[2]); !errors.Is(err, ErrInvalidTOTP) {
+		t.Fatalf("重新生成后原有恢复码应作废，err=%v", err)
+	}
+	if used, err := s.VerifyTOTP(user.ID, fresh[0]); err != nil || !used {
+		t.Fatalf("新恢复码应通过，used=%v err=%v", used, err)
+	}
+}
@@ -0,0 +1,384 @@
+package store
+
+import (
+	"SimpleHttpServer/utils"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// User 登录用户（导出类型，JSON标签保留）
+type User struct {
+	ID           string    `json:"id"`                   // 用户ID（会话中保存的是该ID）
+	Username     string    `json:"username"`             // 登录用户名（唯一）
+	PasswordHash string    `json:"password_hash"`        // bcrypt密码哈希
+	Role         Role      `json:"role"`                 // 角色（viewer/uploader/editor/admin）
+	Groups       []string  `json:"groups,omitempty"`     // 所属用户组（目录访问规则可按组授权）
+	Disabled     bool      `json:"disabled"`             // 是否已禁用（禁用后无法登录，已登录的会话随之失效）
+	CreatedAt    time.Time `json:"created_at"`           // 创建时间
+	LastLogin    time.Time `json:"last_login,omitempty"` // 最后一次网页登录时间
+	// SessionsValidAfter 早于该时间登录的会话均失效（修改密码、退出所有设备、启用两步验证时更新）
+	SessionsValidAfter time.Time `json:"sessions_valid_after,omitempty"`
+	TOTPSecret         string    `json:"totp_secret,omitempty"`    // 两步验证密钥（base32），为空表示未启用
+	TOTPLastStep       int64     `json:"totp_last_step,omitempty"` // 最近一次通过验证的时间步，同一验证码不能重复使用
+	RecoveryCodes      []string  `json:"recovery_codes,omitempty"` // 未使用的恢复码sha256摘要（每个只能使用一次）
+}
+
+// SessionValid 判断在loginAt登录的会话是否仍然有效
+func (u User) SessionValid(loginAt time.Time) bool {
+	return !u.Disabled && !loginAt.Before(u.SessionsValidAfter)
+}
+
+var (
+	ErrUserNotFound    = errors.New("用户不存在")
+	ErrUserExists      = errors.New("用户名已存在")
+	ErrUserDisabled    = errors.New("账号已被禁用")
+	ErrInvalidPassword = errors.New("用户名或密码错误")
+)
+
+// basicAuthCacheTTL HTTP Basic认证（WebDAV等客户端每个请求都携带密码）校验成功后的缓存时间，避免每次请求都计算bcrypt
+const basicAuthCacheTTL = 5 * time.Minute
+
+// UserStore 用户存储：用户列表以JSON保存在文件中，每次读取前检查文件是否被修改（如通过 user 子命令），
+// 被修改时重新加载，服务运行期间增删用户、修改密码无需重启
+type UserStore struct {
+	path     string
+	mu       sync.Mutex
+	users    []*User
+	modTime  time.Time
+	verified map[[32]byte]time.Time // 最近校验通过的 用户ID+密码哈希+密码 摘要 → 过期时间
+}
+
+// Users 全局用户存储（服务启动时由OpenUserStore初始化）
+var Users *UserStore
+
+// OpenUserStore 打开用户存储文件（不存在时在首次保存时创建）
+func OpenUserStore(path string) (*UserStore, error) {
+	s := &UserStore{path: path, verified: map[[32]byte]time.Time{}}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// reload 文件修改时间变化时重新加载用户列表（调用方需持有mu）
+func (s *UserStore) reload() error {
+	info, err := os.Stat(s.path)
+	if os.IsNotExist(err) {
+		s.users, s.modTime = nil, time.Time{}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.ModTime().Equal(s.modTime) && s.users != nil {
+		return nil
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	var file struct {
+		Users []*User `json:"users"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("解析用户文件%s失败: %w", s.path, err)
+	}
+	s.users, s.modTime = file.Users, info.ModTime()
+	if s.users == nil {
+		s.users = []*User{}
+	}
+	// 引入角色前创建的用户没有角色字段，保持其原有的全部权限
+	for _, user := range s.users {
+		if user.Role == "" {
+			user.Role = RoleAdmin
+		}
+	}
+	return nil
+}
+
+// save 原子写入用户文件（调用方需持有mu），权限0600避免其他系统用户读取密码哈希
+func (s *UserStore) save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(struct {
+		Users []*User `json:"users"`
+	}{s.users}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := utils.WriteFileAtomic(s.path, data, 0600); err != nil {
+		return err
+	}
+	if info, err := os.Stat(s.path); err == nil {
+		s.modTime = info.ModTime()
+	}
+	return nil
+}
+
+// find 按用户名查找用户（调用方需持有mu）
+func (s *UserStore) find(username string) *User {
+	for _, user := range s.users {
+		if user.Username == username {
+			return user
+		}
+	}
+	return nil
+}
+
+// findByID 按用户ID查找用户（调用方需持有mu）
+func (s *UserStore) findByID(id string) *User {
+	for _, user := range s.users {
+		if user.ID == id {
+			return user
+		}
+	}
+	return nil
+}
+
+// update 重新加载后修改用户列表并保存
+func (s *UserStore) update(fn func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		return err
+	}
+	return s.save()
+}
+
+// List 返回所有用户（按用户名排序的副本）
+func (s *UserStore) List() ([]User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	users := make([]User, 0, len(s.users))
+	for _, user := range s.users {
+		users = append(users, *user)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	return users, nil
+}
+
+// Get 按用户ID查找用户
+func (s *UserStore) Get(id string) (User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return User{}, err
+	}
+	for _, user := range s.users {
+		if user.ID == id {
+			return *user, nil
+		}
+	}
+	return User{}, ErrUserNotFound
+}
+
+// GetByName 按用户名查找用户
+func (s *UserStore) GetByName(username string) (User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return User{}, err
+	}
+	if user := s.find(username); user != nil {
+		return *user, nil
+	}
+	return User{}, ErrUserNotFound
+}
+
+// Add 新增用户
+func (s *UserStore) Add(username, password string, role Role) (User, error) {
+	username = strings.TrimSpace(username)
+	if username == "" || strings.ContainsAny(username, ":/\\") {
+		return User{}, fmt.Errorf("用户名无效: %q（不能为空，不能包含:/\\）", username)
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return User{}, err
+	}
+	user := &User{
+		ID:           utils.NewUploadID()[:16],
+		Username:     username,
+		PasswordHash: hash,
+		Role:         role,
+		CreatedAt:    time.Now(),
+	}
+	err = s.update(func() error {
+		if s.find(username) != nil {
+			return ErrUserExists
+		}
+		s.users = append(s.users, user)
+		return nil
+	})
+	if err != nil {
+		return User{}, err
+	}
+	return *user, nil
+}
+
+// Delete 删除用户
+func (s *UserStore) Delete(username string) error {
+	return s.update(func() error {
+		for i, user := range s.users {
+			if user.Username == username {
+				s.users = append(s.users[:i], s.users[i+1:]...)
+				return nil
+			}
+		}
+		return ErrUserNotFound
+	})
+}
+
+// SetPassword 修改用户密码，已登录的会话全部失效
+func (s *UserStore) SetPassword(username, password string) error {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	return s.update(func() error {
+		user := s.find(username)
+		if user == nil {
+			return ErrUserNotFound
+		}
+		user.PasswordHash = hash
+		user.SessionsValidAfter = time.Now()
+		return nil
+	})
+}
+
+// SetDisabled 禁用/启用用户
+func (s *UserStore) SetDisabled(username string, disabled bool) error {
+	return s.update(func() error {
+		user := s.find(username)
+		if user == nil {
+			return ErrUserNotFound
+		}
+		user.Disabled = disabled
+		return nil
+	})
+}
+
+// SetRole 修改用户角色
+func (s *UserStore) SetRole(username string, role Role) error {
+	return s.update(func() error {
+		user := s.find(username)
+		if user == nil {
+			return ErrUserNotFound
+		}
+		user.Role = role
+		return nil
+	})
+}
+
+// SetGroups 设置用户所属的用户组（为空表示不属于任何组）
+func (s *UserStore) SetGroups(username string, groups []string) error {
+	return s.update(func() error {
+		user := s.find(username)
+		if user == nil {
+			return ErrUserNotFound
+		}
+		user.Groups = groups
+		return nil
+	})
+}
+
+// RevokeSessions 使用户所有已登录的会话失效（退出所有设备）
+func (s *UserStore) RevokeSessions(id string) error {
+	return s.update(func() error {
+		for _, user := range s.users {
+			if user.ID == id {
+				user.SessionsValidAfter = time.Now()
+				return nil
+			}
+		}
+		return ErrUserNotFound
+	})
+}
+
+// RecordLogin 记录用户最后登录时间
+func (s *UserStore) RecordLogin(id string) error {
+	return s.update(func() error {
+		for _, user := range s.users {
+			if user.ID == id {
+				user.LastLogin = time.Now()
+				return nil
+			}
+		}
+		return ErrUserNotFound
+	})
+}
+
+// Authenticate 校验用户名和密码，成功返回用户；用户不存在时同样计算一次bcrypt，避免通过响应时间判断用户名是否存在。
+// 校验通过的结果缓存basicAuthCacheTTL，修改密码后缓存自然失效（缓存键包含密码哈希）
+func (s *UserStore) Authenticate(username, password string) (User, error) {
+	s.mu.Lock()
+	if err := s.reload(); err != nil {
+		s.mu.Unlock()
+		return User{}, err
+	}
+	var user User
+	if found := s.find(username); found != nil {
+		user = *found
+	}
+	key := sha256.Sum256([]byte(user.ID + "\x00" + user.PasswordHash + "\x00" + password))
+	expires, cached := s.verified[key]
+	s.mu.Unlock()
+
+	if user.ID == "" {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(password))
+		return User{}, ErrInvalidPassword
+	}
+	if !cached || time.Now().After(expires) {
+		if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+			return User{}, ErrInvalidPassword
+		}
+		s.mu.Lock()
+		now := time.Now()
+		for k, exp := range s.verified {
+			if now.After(exp) {
+				delete(s.verified, k)
+			}
+		}
+		s.verified[key] = now.Add(basicAuthCacheTTL)
+		s.mu.Unlock()
+	}
+	if user.Disabled {
+		return User{}, ErrUserDisabled
+	}
+	return user, nil
+}
+
+// dummyPasswordHash 用户不存在时用于比对的哈希（保证响应时间与用户存在时一致）
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("SimpleHttpServer"), bcrypt.DefaultCost)
+	return hash
+})
+
+// hashPassword 计算bcrypt密码哈希
+func hashPassword(password string) (string, error) {
+	if password == "" {
+		return "", errors.New("密码不能为空")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("计算密码哈希失败: %w", err)
+	}
+	return string(hash), nil
+}
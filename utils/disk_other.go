@@ -0,0 +1,13 @@
+//go:build !(linux || darwin || freebsd)
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+)
+
+// DiskUsage 当前平台不支持获取磁盘空间，返回的错误包装了errors.ErrUnsupported
+func DiskUsage(path string) (total, free uint64, err error) {
+	return 0, 0, fmt.Errorf("当前平台不支持获取磁盘空间: %w", errors.ErrUnsupported)
+}
@@ -3,7 +3,13 @@ package utils
 import (
 	"SimpleHttpServer/config"
 	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"hash"
+	"hash/crc32"
 	"io"
 	"os"
 	"path/filepath"
@@ -37,7 +43,7 @@ func IsTextFile(filename string) bool {
 	extLower := strings.ToLower(ext[1:])
 
 	// 3. 匹配文本后缀列表
-	return config.TextFileExts[extLower]
+	return config.IsTextFileExt(extLower)
 }
 
 // 计算文件的 MD5 值
@@ -55,3 +61,122 @@ func FileMD5(path string) (string, error) {
 
 	return fmt.Sprintf("%x", h.Sum(nil)), nil
 }
+
+// NewChecksumHash 按算法名创建哈希计算器，支持 md5、sha1、sha256、crc32c
+func NewChecksumHash(algo string) (hash.Hash, error) {
+	switch algo {
+	case "md5":
+		return md5.New(), nil
+	case "sha1":
+		return sha1.New(), nil
+	case "sha256":
+		return sha256.New(), nil
+	case "crc32c":
+		return crc32.New(crc32.MakeTable(crc32.Castagnoli)), nil
+	default:
+		return nil, fmt.Errorf("不支持的校验算法: %s（仅支持md5/sha1/sha256/crc32c）", algo)
+	}
+}
+
+// ParseChecksum 解析 "算法:十六进制摘要" 格式的校验值（如 sha256:9f86d0...），返回小写的算法名和摘要
+func ParseChecksum(value string) (algo, digest string, err error) {
+	algo, digest, ok := strings.Cut(strings.TrimSpace(value), ":")
+	if !ok || digest == "" {
+		return "", "", fmt.Errorf("校验值格式错误: %s（应为 算法:十六进制摘要）", value)
+	}
+	algo, digest = strings.ToLower(algo), strings.ToLower(digest)
+	h, err := NewChecksumHash(algo)
+	if err != nil {
+		return "", "", err
+	}
+	if raw, err := hex.DecodeString(digest); err != nil || len(raw) != h.Size() {
+		return "", "", fmt.Errorf("校验值摘要无效: %s（%s摘要应为%d位十六进制）", digest, algo, h.Size()*2)
+	}
+	return algo, digest, nil
+}
+
+// FileChecksum 按指定算法计算文件摘要（十六进制）
+func FileChecksum(path, algo string) (string, error) {
+	h, err := NewChecksumHash(algo)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// IsWithinDir 判断target是否为root目录本身或位于root目录内（两者均先规范化）
+// 按路径分隔符比较，避免 /data/uploads2 被误判为位于 /data/uploads 内
+func IsWithinDir(root, target string) bool {
+	root, target = filepath.Clean(root), filepath.Clean(target)
+	if target == root {
+		return true
+	}
+	return strings.HasPrefix(target, strings.TrimSuffix(root, string(filepath.Separator))+string(filepath.Separator))
+}
+
+// SafeJoin 将相对路径rel拼接到root目录下并规范化，结果不在root内时返回错误（防止../等路径遍历）
+func SafeJoin(root, rel string) (string, error) {
+	target := filepath.Clean(filepath.Join(root, rel))
+	if !IsWithinDir(root, target) {
+		return "", fmt.Errorf("路径非法，禁止访问上传目录外的文件: %s", rel)
+	}
+	return target, nil
+}
+
+// FileETag 根据文件大小和修改时间生成强ETag（无需读取文件内容，适合大文件）
+// 文件被覆盖或修改后大小/时间变化，ETag随之变化，保证断点续传不会拼接出错误内容
+func FileETag(info os.FileInfo) string {
+	return fmt.Sprintf("\"%x-%x\"", info.ModTime().UnixNano(), info.Size())
+}
+
+// NewUploadID 生成随机上传会话ID（32位十六进制）
+func NewUploadID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("生成随机ID失败: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
+
+// WriteFileAtomic 原子写入文件：先写同目录临时文件并落盘，再重命名覆盖目标文件，
+// 进程崩溃或断电时目标文件要么是旧内容要么是新内容，不会出现写了一半的文件
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// SyncDir 将目录项变更（创建、重命名、删除文件）落盘，保证重命名在断电后依然有效
+func SyncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
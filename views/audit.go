@@ -0,0 +1,288 @@
+package views
+
+import (
+	. "SimpleHttpServer/config"
+	"SimpleHttpServer/metrics"
+	. "SimpleHttpServer/middleware"
+	"SimpleHttpServer/store"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 审计页面默认及最多显示的条数
+const (
+	auditPageLimit    = 200
+	auditPageMaxLimit = 5000
+)
+
+// auditTimeLayouts 查询条件中起止时间支持的格式（页面的日期时间选择框为本地时间）
+var auditTimeLayouts = []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02"}
+
+// auditCSVHeader 导出CSV的表头，与auditCSVRecord的列一一对应
+var auditCSVHeader = []string{"time", "user", "ip", "action", "path", "target", "size", "hash", "result", "status", "protocol", "detail", "request_id"}
+
+// contentHash 计算文件内容的SHA-256摘要（审计日志格式：sha256:十六进制摘要）
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// auditUpload 记录一次上传的审计日志（上传完成、失败或取消），需在写出响应后调用；
+// 分块上传和tus跨多个请求，在完成、失败或取消的那个请求中记录。failReason为空表示上传成功
+func auditUpload(c *gin.Context, protocol, path string, size int64, hash, failReason string) {
+	if protocol == "chunk" {
+		protocol = "web"
+	}
+	entry := store.AuditEntry{
+		Action:   store.AuditUpload,
+		Path:     AuditPath(path),
+		Size:     size,
+		Hash:     hash,
+		Result:   store.AuditSuccess,
+		Status:   c.Writer.Status(),
+		Protocol: protocol,
+		Detail:   failReason,
+	}
+	if failReason != "" {
+		entry.Result = store.AuditFailure
+	}
+	RecordAudit(c, entry)
+}
+
+// auditExpiredUpload 记录清理过期上传会话的审计日志（不在请求中，用户为创建会话的用户）
+func auditExpiredUpload(status *UploadStatus) {
+	if store.Audit == nil {
+		return
+	}
+	protocol := uploadProtocol(status)
+	if protocol == "chunk" {
+		protocol = "web"
+	}
+	err := store.Audit.Append(store.AuditEntry{
+		User:     status.Owner,
+		Action:   store.AuditUpload,
+		Path:     AuditPath(status.FilePath),
+		Size:     status.TotalSize,
+		Result:   store.AuditFailure,
+		Protocol: protocol,
+		Detail:   metrics.ReasonExpired,
+	})
+	if err != nil {
+		Logger.Error("写入审计日志失败", zap.String("uploadID", status.ID), zap.Error(err))
+	}
+}
+
+// AuditPage 审计日志查询（GET /audit，仅管理员）：按用户、路径、操作及时间范围筛选，
+// 浏览器访问渲染页面，其他请求返回JSON（最新的在前）
+func AuditPage(c *gin.Context) {
+	filter, err := auditFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	limit := auditPageLimit
+	if value := c.Query("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > auditPageMaxLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": fmt.Sprintf("limit 须为1~%d的整数", auditPageMaxLimit)})
+			return
+		}
+	}
+	if store.Audit == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "error", "message": "审计日志未启用"})
+		return
+	}
+	entries, total, err := store.Audit.Query(filter, limit)
+	if err != nil {
+		Log(c).Error("查询审计日志失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "查询审计日志失败: " + err.Error()})
+		return
+	}
+	if entries == nil {
+		entries = []store.AuditEntry{}
+	}
+
+	if strings.Contains(c.GetHeader("Accept"), "text/html") {
+		c.HTML(http.StatusOK, "audit.html", gin.H{
+			"Username": currentUser(c),
+			"Entries":  entries,
+			"Total":    total,
+			"Actions":  store.AuditActions,
+			"Query": gin.H{
+				"user":   c.Query("user"),
+				"path":   c.Query("path"),
+				"action": c.Query("action"),
+				"since":  c.Query("since"),
+				"until":  c.Query("until"),
+			},
+			"ExportCSV":  auditExportURL(c, "csv"),
+			"ExportJSON": auditExportURL(c, "json"),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"entries": entries, "total": total})
+}
+
+// AuditExportHandler 导出审计日志（GET /audit/export?format=csv|json，仅管理员），
+// 查询条件同审计页面，按时间顺序导出全部满足条件的记录
+func AuditExportHandler(c *gin.Context) {
+	filter, err := auditFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "json" {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "format 仅支持 csv、json"})
+		return
+	}
+	if store.Audit == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "error", "message": "审计日志未启用"})
+		return
+	}
+
+	filename := fmt.Sprintf("audit-%s.%s", time.Now().Format("20060102-150405"), format)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	if format == "csv" {
+		err = exportAuditCSV(c, filter)
+	} else {
+		err = exportAuditJSON(c, filter)
+	}
+	if err != nil {
+		// 响应已开始写出，只能记录日志
+		Log(c).Error("导出审计日志失败", zap.String("format", format), zap.Error(err))
+	}
+}
+
+// auditExportURL 返回使用当前查询条件导出的链接
+func auditExportURL(c *gin.Context, format string) string {
+	query := c.Request.URL.Query()
+	query.Del("limit")
+	query.Set("format", format)
+	return "/audit/export?" + query.Encode()
+}
+
+// exportAuditCSV 以CSV格式写出审计日志
+func exportAuditCSV(c *gin.Context, filter store.AuditFilter) error {
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Status(http.StatusOK)
+	w := csv.NewWriter(c.Writer)
+	if err := w.Write(auditCSVHeader); err != nil {
+		return err
+	}
+	err := store.Audit.Each(filter, func(entry store.AuditEntry) error {
+		return w.Write(auditCSVRecord(entry))
+	})
+	w.Flush()
+	if err != nil {
+		return err
+	}
+	return w.Error()
+}
+
+// auditCSVRecord 将一条审计日志转换为CSV的一行
+func auditCSVRecord(entry store.AuditEntry) []string {
+	status := ""
+	if entry.Status != 0 {
+		status = strconv.Itoa(entry.Status)
+	}
+	record := []string{
+		entry.Time.Format(time.RFC3339), entry.User, entry.IP, string(entry.Action),
+		entry.Path, entry.Target, strconv.FormatInt(entry.Size, 10), entry.Hash,
+		entry.Result, status, entry.Protocol, entry.Detail, entry.RequestID,
+	}
+	for i, cell := range record {
+		record[i] = csvSafeCell(cell)
+	}
+	return record
+}
+
+// csvSafeCell 防止CSV公式注入：文件名、用户名等以=、+、-、@、制表符或回车开头时，
+// 在表格软件中打开会被当作公式执行，加'前缀使其按文本显示
+func csvSafeCell(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+// exportAuditJSON 以JSON数组格式写出审计日志（逐条写出，不在内存中汇总）
+func exportAuditJSON(c *gin.Context, filter store.AuditFilter) error {
+	c.Header("Content-Type", "application/json; charset=utf-8")
+	c.Status(http.StatusOK)
+	if _, err := c.Writer.WriteString("["); err != nil {
+		return err
+	}
+	first := true
+	err := store.Audit.Each(filter, func(entry store.AuditEntry) error {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		if !first {
+			data = append([]byte(",\n"), data...)
+		}
+		first = false
+		_, err = c.Writer.Write(data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	_, err = c.Writer.WriteString("]\n")
+	return err
+}
+
+// auditFilter 解析查询条件：user、path、action、since、until（RFC3339、2006-01-02T15:04或2006-01-02，后两种按本地时间）
+func auditFilter(c *gin.Context) (store.AuditFilter, error) {
+	filter := store.AuditFilter{
+		User:   strings.TrimSpace(c.Query("user")),
+		Path:   strings.TrimSpace(c.Query("path")),
+		Action: store.AuditAction(c.Query("action")),
+	}
+	if filter.Action != "" && !validAuditAction(filter.Action) {
+		return filter, fmt.Errorf("未知的操作类型: %s", filter.Action)
+	}
+	var err error
+	if filter.Since, err = parseAuditTime(c.Query("since")); err != nil {
+		return filter, fmt.Errorf("since 格式错误: %v", err)
+	}
+	if filter.Until, err = parseAuditTime(c.Query("until")); err != nil {
+		return filter, fmt.Errorf("until 格式错误: %v", err)
+	}
+	return filter, nil
+}
+
+// validAuditAction 判断是否为已知的操作类型
+func validAuditAction(action store.AuditAction) bool {
+	for _, a := range store.AuditActions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// parseAuditTime 解析查询条件中的时间，为空时返回零值（不限制）
+func parseAuditTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range auditTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("支持 RFC3339、2006-01-02T15:04、2006-01-02")
+}
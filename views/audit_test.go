@@ -0,0 +1,161 @@
+package views
+
+import (
+	"SimpleHttpServer/store"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setupAudit 使用临时审计日志并写入entries（测试结束后恢复）
+func setupAudit(t *testing.T, entries []store.AuditEntry) {
+	t.Helper()
+	a, err := store.OpenAuditLog(filepath.Join(t.TempDir(), "audit.log"))
+	if err != nil {
+		t.Fatalf("打开审计日志失败: %v", err)
+	}
+	saved := store.Audit
+	store.Audit = a
+	t.Cleanup(func() {
+		store.Audit = saved
+		a.Close()
+	})
+	for _, entry := range entries {
+		if err := a.Append(entry); err != nil {
+			t.Fatalf("写入审计日志失败: %v", err)
+		}
+	}
+}
+
+// 审计页面（JSON）及CSV、JSON导出按用户、路径（含子目录）、操作、时间范围筛选
+func TestAuditQueryAndExport(t *testing.T) {
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	setupAudit(t, []store.AuditEntry{
+		{Time: base, User: "alice", Action: store.AuditUpload, Path: "/docs/a.txt", Size: 3, Hash: "sha256:abc", Result: store.AuditSuccess, Status: 200, Protocol: "web"},
+		{Time: base.Add(time.Hour), User: "bob", Action: store.AuditDownload, Path: "/docs/sub/b.txt", Result: store.AuditSuccess, Status: 200},
+		{Time: base.Add(2 * time.Hour), User: "alice", Action: store.AuditMove, Path: "/tmp/c.txt", Target: "/docs/c.txt", Result: store.AuditSuccess},
+		{Time: base.Add(3 * time.Hour), User: "alice", Action: store.AuditLogin, Result: store.AuditFailure, Detail: "密码错误, 第1次"},
+		{Time: base.Add(4 * time.Hour), User: "bob", Action: store.AuditDelete, Path: "/docs2/d.txt", Result: store.AuditSuccess},
+	})
+	r := testEngine("admin")
+	r.GET("/audit", AuditPage)
+	r.GET("/audit/export", AuditExportHandler)
+
+	tests := []struct {
+		name  string
+		query url.Values
+		want  []string // 按时间顺序期望的路径（无路径时为操作）
+	}{
+		{"不限制", nil, []string{"/docs/a.txt", "/docs/sub/b.txt", "/tmp/c.txt", "login", "/docs2/d.txt"}},
+		{"按用户", url.Values{"user": {"bob"}}, []string{"/docs/sub/b.txt", "/docs2/d.txt"}},
+		{"按路径含子目录及目标路径", url.Values{"path": {"/docs"}}, []string{"/docs/a.txt", "/docs/sub/b.txt", "/tmp/c.txt"}},
+		{"按子目录", url.Values{"path": {"docs/sub/"}}, []string{"/docs/sub/b.txt"}},
+		{"按操作", url.Values{"action": {"login"}}, []string{"login"}},
+		{"按时间范围", url.Values{"since": {base.Add(time.Hour).Format(time.RFC3339)}, "until": {base.Add(3 * time.Hour).Format(time.RFC3339)}},
+			[]string{"/docs/sub/b.txt", "/tmp/c.txt"}},
+		{"组合条件", url.Values{"user": {"alice"}, "path": {"/docs"}, "action": {"upload"}}, []string{"/docs/a.txt"}},
+	}
+	key := func(entry store.AuditEntry) string {
+		if entry.Path == "" {
+			return string(entry.Action)
+		}
+		return entry.Path
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 审计页面：最新的在前
+			w := doRequest(r, http.MethodGet, "/audit?"+tt.query.Encode(), nil, nil)
+			var page struct {
+				Entries []store.AuditEntry `json:"entries"`
+				Total   int                `json:"total"`
+			}
+			decodeJSON(t, w, &page)
+			var got []string
+			for _, entry := range page.Entries {
+				got = append(got, key(entry))
+			}
+			slices.Reverse(got)
+			if w.Code != http.StatusOK || page.Total != len(tt.want) || !slices.Equal(got, tt.want) {
+				t.Fatalf("审计页面返回%d total=%d %v，期望%v", w.Code, page.Total, got, tt.want)
+			}
+
+			// JSON导出：按时间顺序
+			query := url.Values{"format": {"json"}}
+			for k, v := range tt.query {
+				query[k] = v
+			}
+			w = doRequest(r, http.MethodGet, "/audit/export?"+query.Encode(), nil, nil)
+			var exported []store.AuditEntry
+			if err := json.Unmarshal(w.Body.Bytes(), &exported); err != nil {
+				t.Fatalf("JSON导出无法解析: %v，响应: %s", err, w.Body.String())
+			}
+			got = got[:0]
+			for _, entry := range exported {
+				got = append(got, key(entry))
+			}
+			if !slices.Equal(got, tt.want) || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+				t.Fatalf("JSON导出%v，期望%v", got, tt.want)
+			}
+
+			// CSV导出：表头加按时间顺序的记录
+			query.Set("format", "csv")
+			w = doRequest(r, http.MethodGet, "/audit/export?"+query.Encode(), nil, nil)
+			records, err := csv.NewReader(strings.NewReader(w.Body.String())).ReadAll()
+			if err != nil {
+				t.Fatalf("CSV导出无法解析: %v", err)
+			}
+			if !slices.Equal(records[0], auditCSVHeader) || len(records)-1 != len(tt.want) {
+				t.Fatalf("CSV导出%d条，期望%d: %v", len(records)-1, len(tt.want), records)
+			}
+			if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") ||
+				!strings.Contains(w.Header().Get("Content-Disposition"), ".csv") {
+				t.Fatalf("CSV导出响应头错误: %v", w.Header())
+			}
+		})
+	}
+
+	// CSV各列与表头对应，含逗号的字段正确转义
+	w := doRequest(r, http.MethodGet, "/audit/export?format=csv", nil, nil)
+	records, _ := csv.NewReader(strings.NewReader(w.Body.String())).ReadAll()
+	upload := []string{base.Format(time.RFC3339), "alice", "", "upload", "/docs/a.txt", "", "3", "sha256:abc", "success", "200", "web", "", ""}
+	if !slices.Equal(records[1], upload) {
+		t.Fatalf("CSV记录=%q，期望%q", records[1], upload)
+	}
+	if records[4][11] != "密码错误, 第1次" || records[4][9] != "" {
+		t.Fatalf("CSV记录=%q", records[4])
+	}
+
+	for _, target := range []string{"/audit?action=unknown", "/audit?since=yesterday", "/audit?limit=0", "/audit/export?format=xml", "/audit/export?until=2024-13-01"} {
+		if w := doRequest(r, http.MethodGet, target, nil, nil); w.Code != http.StatusBadRequest {
+			t.Fatalf("%s返回%d，期望400", target, w.Code)
+		}
+	}
+	if w := doRequest(r, http.MethodGet, "/audit?limit=1", nil, nil); !strings.Contains(w.Body.String(), `"total":5`) ||
+		strings.Count(w.Body.String(), `"action"`) != 1 {
+		t.Fatalf("limit=1应只返回1条且total为5: %s", w.Body.String())
+	}
+}
+
+// 以公式字符开头的文件名、用户名、详情等在CSV中加'前缀，表格软件按文本显示
+func TestAuditCSVRecordFormula(t *testing.T) {
+	entry := store.AuditEntry{
+		Time: time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC), User: "@bob", Action: store.AuditMove,
+		Path: "/docs/=1+1.txt", Target: "=HYPERLINK(\"http://x\")", Size: 3, Detail: "-2+3", RequestID: "\tid",
+	}
+	record := auditCSVRecord(entry)
+	want := map[int]string{1: "'@bob", 4: "/docs/=1+1.txt", 5: "'=HYPERLINK(\"http://x\")", 6: "3", 11: "'-2+3", 12: "'\tid"}
+	for i, cell := range want {
+		if record[i] != cell {
+			t.Fatalf("%s列=%q，期望%q", auditCSVHeader[i], record[i], cell)
+		}
+	}
+	if got := csvSafeCell("\r=1"); got != "'\r=1" {
+		t.Fatalf("回车开头的字段=%q", got)
+	}
+}
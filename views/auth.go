@@ -1,19 +1,24 @@
 package views
 
 import (
-	. "SimpleHttpServer/config"
+	"SimpleHttpServer/hooks"
+	. "SimpleHttpServer/middleware"
+	"SimpleHttpServer/store"
+	"errors"
+	"fmt"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 // 登录处理
 func LoginHandler(c *gin.Context) {
 	if c.Request.Method == "GET" {
-		// 检查是否已登录
-		session := sessions.Default(c)
-		user := session.Get("user")
-		if user != nil {
+		// 检查是否已登录（会话中的用户仍存在、未被禁用且会话未失效）
+		if _, ok := SessionUser(c); ok {
 			c.Redirect(http.StatusFound, "/")
 			return
 		}
@@ -25,22 +30,171 @@ func LoginHandler(c *gin.Context) {
 	username := c.PostForm("username")
 	password := c.PostForm("password")
 
-	if username == GlobalConfig.UserName && password == GlobalConfig.Password {
-		session := sessions.Default(c)
-		session.Set("user", username)
-		session.Save()
-		c.Redirect(http.StatusFound, "/")
-	} else {
+	// 连续失败次数过多的IP或用户名在锁定期内不校验密码
+	if until, locked := LoginLocked(c, username); locked {
+		renderLoginLocked(c, until)
+		return
+	}
+
+	user, err := store.Users.Authenticate(username, password)
+	if err != nil {
+		lockedUntil := RecordLoginResult(c, username, err)
+		Log(c).Warn("登录失败",
+			zap.String("username", username),
+			zap.String("client_ip", c.ClientIP()),
+			zap.Error(err),
+		)
+		if !lockedUntil.IsZero() {
+			renderLoginLocked(c, lockedUntil)
+			return
+		}
+		message := "用户名或密码错误"
+		if errors.Is(err, store.ErrUserDisabled) {
+			message = "账号已被禁用，请联系管理员"
+		}
 		c.HTML(http.StatusOK, "login.html", gin.H{
-			"error": "用户名或密码错误",
+			"error": message,
 		})
+		return
+	}
+
+	// 已启用两步验证：密码正确后进入第二步，失败计数在两步都通过后才清除
+	if user.TOTPEnabled() {
+		session := sessions.Default(c)
+		session.Clear()
+		session.Set(pendingTOTPUserKey, user.ID)
+		session.Set(pendingTOTPAtKey, time.Now().UnixNano())
+		session.Save()
+		Log(c).Info("密码验证通过，等待两步验证",
+			zap.String("username", user.Username),
+			zap.String("client_ip", c.ClientIP()),
+		)
+		c.HTML(http.StatusOK, "login.html", gin.H{"totp": true})
+		return
+	}
+	RecordLoginResult(c, username, nil)
+	completeLogin(c, user)
+}
+
+const (
+	pendingTOTPUserKey = "totp_pending_user" // 密码验证通过、等待两步验证的用户ID
+	pendingTOTPAtKey   = "totp_pending_at"   // 密码验证通过的时间（UnixNano）
+)
+
+// pendingTOTPTimeout 密码验证通过后输入两步验证码的时限
+const pendingTOTPTimeout = 5 * time.Minute
+
+// LoginTOTPHandler 登录第二步（POST /login/2fa）：校验身份验证器中的验证码或恢复码
+func LoginTOTPHandler(c *gin.Context) {
+	session := sessions.Default(c)
+	userID, _ := session.Get(pendingTOTPUserKey).(string)
+	pendingAt, _ := session.Get(pendingTOTPAtKey).(int64)
+	user, err := store.Users.Get(userID)
+	// 超时，或密码验证通过后用户被禁用、修改了密码，需重新输入密码
+	if userID == "" || err != nil || time.Since(time.Unix(0, pendingAt)) > pendingTOTPTimeout ||
+		!user.SessionValid(time.Unix(0, pendingAt)) {
+		session.Clear()
+		session.Save()
+		c.HTML(http.StatusOK, "login.html", gin.H{"error": "登录已超时，请重新输入用户名和密码"})
+		return
+	}
+
+	if until, locked := LoginLocked(c, user.Username); locked {
+		renderLoginLocked(c, until)
+		return
+	}
+	usedRecovery, err := store.Users.VerifyTOTP(user.ID, c.PostForm("code"))
+	lockedUntil := RecordLoginResult(c, user.Username, err)
+	if err != nil {
+		Log(c).Warn("两步验证失败",
+			zap.String("username", user.Username),
+			zap.String("client_ip", c.ClientIP()),
+			zap.Error(err),
+		)
+		if !lockedUntil.IsZero() {
+			renderLoginLocked(c, lockedUntil)
+			return
+		}
+		c.HTML(http.StatusOK, "login.html", gin.H{"totp": true, "error": "验证码错误"})
+		return
+	}
+	if usedRecovery {
+		latest, _ := store.Users.Get(user.ID)
+		Log(c).Warn("使用恢复码完成两步验证",
+			zap.String("username", user.Username),
+			zap.Int("remainingCodes", len(latest.RecoveryCodes)),
+			zap.String("client_ip", c.ClientIP()),
+		)
+	}
+	completeLogin(c, user)
+}
+
+// completeLogin 登录成功：重建会话（防止会话固定）并记录登录时间
+func completeLogin(c *gin.Context, user store.User) {
+	session := sessions.Default(c)
+	session.Clear()
+	session.Set(SessionUserKey, user.ID)
+	session.Set(SessionLoginKey, time.Now().UnixNano())
+	session.Save()
+	if err := store.Users.RecordLogin(user.ID); err != nil {
+		Log(c).Warn("记录登录时间失败", zap.String("userID", user.ID), zap.Error(err))
+	}
+	Log(c).Info("用户登录成功",
+		zap.String("username", user.Username),
+		zap.String("userID", user.ID),
+		zap.String("client_ip", c.ClientIP()),
+	)
+	hooks.Fire(hooks.Payload{Event: hooks.EventLogin, User: user.Username, IP: c.ClientIP(), Protocol: "web", RequestID: RequestIDOf(c)})
+	c.Redirect(http.StatusFound, "/")
+}
+
+// renderLoginLocked 登录被临时锁定时返回429及Retry-After，页面提示剩余等待时间
+func renderLoginLocked(c *gin.Context, until time.Time) {
+	wait := max(time.Until(until).Round(time.Second), time.Second)
+	c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())))
+	c.HTML(http.StatusTooManyRequests, "login.html", gin.H{
+		"error": fmt.Sprintf("登录失败次数过多，请在%s后重试", formatWait(wait)),
+	})
+}
+
+// formatWait 将等待时长格式化为"X分Y秒"形式
+func formatWait(d time.Duration) string {
+	minutes, seconds := int(d/time.Minute), int(d%time.Minute/time.Second)
+	switch {
+	case minutes == 0:
+		return fmt.Sprintf("%d秒", seconds)
+	case seconds == 0:
+		return fmt.Sprintf("%d分钟", minutes)
+	default:
+		return fmt.Sprintf("%d分%d秒", minutes, seconds)
 	}
 }
 
-// 登出处理
+// 登出处理（/logout?all=1 同时使该用户在其他设备上的会话失效）
 func LogoutHandler(c *gin.Context) {
+	if c.Query("all") != "" {
+		if user, ok := CurrentUser(c); ok {
+			if err := store.Users.RevokeSessions(user.ID); err != nil {
+				Log(c).Error("退出所有设备失败", zap.String("userID", user.ID), zap.Error(err))
+			} else {
+				Log(c).Info("用户已退出所有设备", zap.String("username", user.Username))
+			}
+		}
+	}
+	// MaxAge为负数时删除Cookie，服务端会话存储同时删除会话文件
 	session := sessions.Default(c)
 	session.Clear()
+	session.Options(sessions.Options{Path: "/", MaxAge: -1})
 	session.Save()
 	c.Redirect(http.StatusFound, "/login")
 }
+
+// currentUser 返回当前登录用户名（未登录返回空字符串）
+// 认证中间件会将会话或HTTP Basic认证识别出的用户写入上下文，未经过认证中间件时按登录会话查找
+func currentUser(c *gin.Context) string {
+	if user := c.GetString("user"); user != "" {
+		return user
+	}
+	user, _ := SessionUser(c)
+	return user.Username
+}
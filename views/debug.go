@@ -0,0 +1,133 @@
+package views
+
+import (
+	. "SimpleHttpServer/config"
+	. "SimpleHttpServer/middleware"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+)
+
+// startedAt 服务启动时间（用于计算运行时长）
+var startedAt = time.Now()
+
+// EffectiveConfig 返回实际生效的配置（YAML，密码和密钥已隐藏），由cobra包在启动时设置
+var EffectiveConfig func() ([]byte, error)
+
+// buildInfo 版本及编译信息
+type buildInfo struct {
+	Version   string `json:"version"`
+	GoVersion string `json:"go_version"`
+	Revision  string `json:"revision,omitempty"`   // 编译时的Git提交
+	BuildTime string `json:"build_time,omitempty"` // 提交时间
+	Modified  bool   `json:"modified,omitempty"`   // 编译时工作区是否有未提交的修改
+}
+
+// uploadSessionInfo 诊断页中的一个未完成上传会话
+type uploadSessionInfo struct {
+	ID            string    `json:"id"`
+	Owner         string    `json:"owner"`
+	Protocol      string    `json:"protocol"`
+	FilePath      string    `json:"file_path"`
+	TotalSize     int64     `json:"total_size"`
+	UploadedBytes int64     `json:"uploaded_bytes"`
+	LastActive    time.Time `json:"last_active"`
+}
+
+// DebugInfoHandler 诊断信息（GET /debug/info，仅管理员）：版本、运行时长、协程数、内存、
+// 实际生效的配置及未完成的上传会话；浏览器访问渲染页面，其他请求返回JSON
+func DebugInfoHandler(c *gin.Context) {
+	var config string
+	if EffectiveConfig != nil {
+		data, err := EffectiveConfig()
+		if err != nil {
+			Log(c).Error("生成配置信息失败", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "生成配置信息失败: " + err.Error()})
+			return
+		}
+		config = string(data)
+	}
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	sessions := uploadSessions()
+	uptime := time.Since(startedAt).Round(time.Second)
+
+	info := gin.H{
+		"build":           readBuildInfo(),
+		"started_at":      startedAt,
+		"uptime":          uptime.String(),
+		"goroutines":      runtime.NumGoroutine(),
+		"heap_alloc":      int64(mem.HeapAlloc),
+		"sys_memory":      int64(mem.Sys),
+		"upload_sessions": sessions,
+		"config":          config,
+	}
+	if strings.Contains(c.GetHeader("Accept"), "text/html") {
+		info["Username"] = currentUser(c)
+		c.HTML(http.StatusOK, "debug.html", info)
+		return
+	}
+	c.JSON(http.StatusOK, info)
+}
+
+// readBuildInfo 读取程序版本及Go工具链写入的编译信息
+func readBuildInfo() buildInfo {
+	info := buildInfo{Version: Version, GoVersion: runtime.Version()}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	for _, setting := range bi.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			info.Revision = setting.Value
+		case "vcs.time":
+			info.BuildTime = setting.Value
+		case "vcs.modified":
+			info.Modified = setting.Value == "true"
+		}
+	}
+	return info
+}
+
+// uploadSessions 返回所有未完成的上传会话（最近活跃的在前）
+func uploadSessions() []uploadSessionInfo {
+	sessions := []uploadSessionInfo{}
+	UploadStatusCache.Range(func(_, value any) bool {
+		status := value.(*UploadStatus)
+		sessions = append(sessions, uploadSessionInfo{
+			ID:            status.ID,
+			Owner:         status.Owner,
+			Protocol:      uploadProtocol(status),
+			FilePath:      status.FilePath,
+			TotalSize:     status.TotalSize,
+			UploadedBytes: uploadedBytes(status),
+			LastActive:    status.LastActive(),
+		})
+		return true
+	})
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastActive.After(sessions[j].LastActive)
+	})
+	return sessions
+}
+
+// uploadedBytes 上传会话已接收的字节数（tus为当前偏移，S3分段上传为已接收各段之和）
+func uploadedBytes(status *UploadStatus) int64 {
+	switch {
+	case status.Tus:
+		return status.CurrentOffset()
+	case status.Multipart:
+		var total int64
+		for _, part := range status.PartList() {
+			total += part.Size
+		}
+		return total
+	}
+	return sessionInfo(status).UploadedBytes
+}
@@ -2,6 +2,9 @@ package views
 
 import (
 	. "SimpleHttpServer/config"
+	"SimpleHttpServer/hooks"
+	. "SimpleHttpServer/middleware"
+	. "SimpleHttpServer/utils"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"net/http"
@@ -14,24 +17,9 @@ import (
 // DeleteHandler 适配多级路径的文件删除接口
 // 路由建议：r.DELETE("/delete/*path", DeleteHandler)（*path匹配多级路径）
 func DeleteHandler(c *gin.Context) {
-	// 1. 获取URL中的编码后的完整路径参数（替换原filename）
-	encodedPath := c.Param("path")
-	if encodedPath == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "error",
-			"message": "未指定要删除的文件路径",
-		})
-		return
-	}
-
-	// 2. 去掉路径开头的/（避免拼接后出现//）+ URL解码（处理空格/中文/特殊字符）
-	encodedPath = strings.TrimPrefix(encodedPath, "/")
-	fileFullPath, err := url.QueryUnescape(encodedPath)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "error",
-			"message": fmt.Sprintf("路径解析失败：%v", err),
-		})
+	// 1-2. 获取并解码要删除的文件路径
+	fileFullPath, ok := deletePathParam(c)
+	if !ok {
 		return
 	}
 
@@ -39,7 +27,7 @@ func DeleteHandler(c *gin.Context) {
 	rootUploadDir := filepath.Clean(GlobalConfig.UploadDir) // 根目录清理
 	targetFilePath := filepath.Clean(filepath.Join(rootUploadDir, fileFullPath))
 	// 校验：目标文件必须在根上传目录内
-	if !strings.HasPrefix(targetFilePath, rootUploadDir) {
+	if !IsWithinDir(rootUploadDir, targetFilePath) {
 		c.JSON(http.StatusForbidden, gin.H{
 			"status":  "error",
 			"message": "禁止删除上传目录外的文件（路径遍历攻击）",
@@ -47,33 +35,92 @@ func DeleteHandler(c *gin.Context) {
 		return
 	}
 
+	var size int64
+	if info, err := os.Stat(targetFilePath); err == nil {
+		size = info.Size()
+		if info.IsDir() {
+			// 目录内上传会话的临时文件和元数据会使目录非空，先作废这些会话
+			dropUploadSessionsUnder(targetFilePath)
+		}
+	}
+	SetAuditFile(c, fileFullPath, size, "")
+
 	// 4. 拼接临时文件路径（保留原有逻辑）
 	tempFilePath := targetFilePath + ".part"
 
-	// 5. 删除主文件（保留原有逻辑，兼容文件不存在的情况）
-	if err := os.Remove(targetFilePath); err != nil && !os.IsNotExist(err) {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status":  "error",
-			"message": fmt.Sprintf("删除文件失败: %v", err),
-		})
-		return
+	// 5. 删除主文件（文件不存在时只清理临时文件）
+	removed := true
+	if err := os.Remove(targetFilePath); err != nil {
+		if !os.IsNotExist(err) {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status":  "error",
+				"message": fmt.Sprintf("删除文件失败: %v", err),
+			})
+			return
+		}
+		removed = false
 	}
 
 	// 6. 删除临时文件（保留原有逻辑）
-	if err := os.Remove(tempFilePath); err != nil && !os.IsNotExist(err) {
-		c.JSON(http.StatusInternalServerError, gin.H{
+	partRemoved := true
+	if err := os.Remove(tempFilePath); err != nil {
+		if !os.IsNotExist(err) {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status":  "error",
+				"message": fmt.Sprintf("删除临时文件失败: %v", err),
+			})
+			return
+		}
+		partRemoved = false
+	}
+
+	// 7. 删除写入该文件（或该目录下文件）的上传会话及元数据（会话按ID缓存，按目标路径匹配）
+	dropUploadSessionsUnder(targetFilePath)
+	os.Remove(journalPath(targetFilePath)) // 无会话缓存时残留的元数据文件，删除失败不影响结果
+
+	// 8. 文件和临时文件都不存在时返回404（审计日志记为失败，不触发钩子）
+	if !removed && !partRemoved {
+		c.JSON(http.StatusNotFound, gin.H{
 			"status":  "error",
-			"message": fmt.Sprintf("删除临时文件失败: %v", err),
+			"message": "文件不存在",
 		})
 		return
 	}
 
-	// 7. 删除上传状态缓存（key改为完整路径，和前端传递的一致）
-	UploadStatusCache.Delete(fileFullPath)
-
-	// 8. 返回成功（格式和前端JS匹配）
+	// 9. 返回成功（格式和前端JS匹配）
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "success",
 		"message": "文件已删除",
 	})
+
+	// 10. 只有文件确实被删除时触发删除事件钩子（只删除临时文件时不触发）
+	if removed {
+		fireHook(c, hooks.EventDelete, "web", fileFullPath, "", 0, "")
+	}
+}
+
+// deletePathParam 解析删除接口的文件路径（授权中间件与DeleteHandler共用，保证校验与删除的是同一路径）
+// 解析失败时已写入错误响应，ok为false
+func deletePathParam(c *gin.Context) (string, bool) {
+	// 1. 获取URL中的编码后的完整路径参数（替换原filename）
+	encodedPath := c.Param("path")
+	if encodedPath == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "未指定要删除的文件路径",
+		})
+		return "", false
+	}
+
+	// 2. 去掉路径开头的/（避免拼接后出现//）+ URL解码（处理空格/中文/特殊字符）
+	encodedPath = strings.TrimPrefix(encodedPath, "/")
+	fileFullPath, err := url.QueryUnescape(encodedPath)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": fmt.Sprintf("路径解析失败：%v", err),
+		})
+		return "", false
+	}
+	return fileFullPath, true
 }
@@ -0,0 +1,74 @@
+package views
+
+import (
+	. "SimpleHttpServer/config"
+	"SimpleHttpServer/hooks"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 只有确实删除了文件才返回成功并触发删除钩子；删除目录时一并作废其中的上传会话
+func TestDeleteHandler(t *testing.T) {
+	dir := setupUploadDir(t, 4)
+	var fired atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { fired.Add(1) }))
+	t.Cleanup(srv.Close)
+	hooks.Set([]hooks.Hook{{Events: []hooks.Event{hooks.EventDelete}, URL: srv.URL, Timeout: time.Second}})
+	t.Cleanup(func() { hooks.Set(nil) })
+
+	r := uploadEngine("alice")
+	r.DELETE("/delete/*path", DeleteHandler)
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644)
+	os.WriteFile(filepath.Join(dir, "b.txt.part"), []byte("b"), 0644)
+	os.Mkdir(filepath.Join(dir, "sub"), 0755)
+	w := postForm(r, "/uploads/sub", url.Values{"file_name": {"s.bin"}, "total_size": {"8"}, "action": {"new"}})
+	var session uploadSession
+	decodeJSON(t, w, &session)
+	if w.Code != http.StatusOK {
+		t.Fatalf("初始化上传会话返回%d: %s", w.Code, session.Message)
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		status int
+		hook   bool
+	}{
+		{"文件不存在", "/delete/missing.txt", http.StatusNotFound, false},
+		{"删除文件", "/delete/a.txt", http.StatusOK, true},
+		{"只有临时文件", "/delete/b.txt", http.StatusOK, false},
+		{"删除有上传会话的目录", "/delete/sub", http.StatusOK, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := fired.Load()
+			if w := doRequest(r, http.MethodDelete, tt.target, nil, nil); w.Code != tt.status {
+				t.Fatalf("返回%d，期望%d: %s", w.Code, tt.status, w.Body.String())
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			hooks.Wait(ctx)
+			want := int32(0)
+			if tt.hook {
+				want = 1
+			}
+			if got := fired.Load() - before; got != want {
+				t.Fatalf("触发删除钩子%d次，期望触发=%v", got, tt.hook)
+			}
+		})
+	}
+
+	if _, ok := UploadStatusCache.Load(session.UploadID); ok {
+		t.Fatalf("删除目录后其中的上传会话应被作废")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "sub")); !os.IsNotExist(err) {
+		t.Fatalf("目录应被删除")
+	}
+}
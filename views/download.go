@@ -2,6 +2,9 @@ package views
 
 import (
 	. "SimpleHttpServer/config"
+	"SimpleHttpServer/metrics"
+	. "SimpleHttpServer/middleware"
+	. "SimpleHttpServer/utils"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"mime"
@@ -14,14 +17,14 @@ import (
 	"strings"
 )
 
-// DownloadHandler 适配多级路径的文件下载接口
-// 路由建议：r.GET("/download/*path", DownloadHandler)（*path匹配多级路径）
-func DownloadHandler(c *gin.Context) {
+// downloadPathParam 解析下载接口的文件路径（授权中间件与DownloadHandler共用，保证校验与下载的是同一路径）
+// 解析失败时已写入错误响应，ok为false
+func downloadPathParam(c *gin.Context) (string, bool) {
 	// 1. 获取URL中的编码后的完整路径参数（替换原filename）
 	encodedPath := c.Param("path")
 	if encodedPath == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "未指定要下载的文件路径"})
-		return
+		return "", false
 	}
 
 	// 2. 去掉路径开头的/ + URL解码（处理空格/中文/特殊字符）
@@ -29,24 +32,59 @@ func DownloadHandler(c *gin.Context) {
 	fileFullPath, err := url.QueryUnescape(encodedPath)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("路径解析失败：%v", err)})
+		return "", false
+	}
+	return fileFullPath, true
+}
+
+// DownloadHandler 适配多级路径的文件下载接口（需登录，授权中间件按DownloadTarget校验读取权限）
+// 路由建议：protected.GET("/download/*path", canDownload, DownloadHandler)（*path匹配多级路径）
+func DownloadHandler(c *gin.Context) {
+	fileFullPath, ok := downloadPathParam(c)
+	if !ok {
 		return
 	}
 
+	SetAuditFile(c, fileFullPath, 0, "")
+
 	// 3. 路径安全校验（防止../../等路径遍历攻击）
 	rootUploadDir := filepath.Clean(GlobalConfig.UploadDir)
 	targetFilePath := filepath.Clean(filepath.Join(rootUploadDir, fileFullPath))
 	// 校验：目标文件必须在根上传目录内
-	if !strings.HasPrefix(targetFilePath, rootUploadDir) {
+	if !IsWithinDir(rootUploadDir, targetFilePath) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "禁止下载上传目录外的文件（路径遍历攻击）"})
 		return
 	}
 
-	// 4. 检查文件是否存在（保留原有逻辑）
-	if _, err := os.Stat(targetFilePath); os.IsNotExist(err) {
+	// 校验：未完成上传的临时文件（.part）和隐藏文件/目录（上传会话元数据、隔离目录等）与文件列表一致，不对外提供下载
+	if hiddenUploadPath(strings.TrimPrefix(targetFilePath, rootUploadDir)) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "文件不存在"})
 		return
 	}
 
+	// 4. 打开文件并获取文件信息（目录不允许下载）
+	f, err := os.Open(targetFilePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "文件不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("打开文件失败：%v", err)})
+		return
+	}
+	defer f.Close()
+	fileInfo, err := f.Stat()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取文件信息失败：%v", err)})
+		return
+	}
+	if fileInfo.IsDir() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "目标路径是目录，无法下载"})
+		return
+	}
+
+	SetAuditFile(c, fileFullPath, fileInfo.Size(), "")
+
 	// 5. 提取最终的文件名（关键：多级路径下只取最后一段作为下载文件名）
 	// 比如 fileFullPath = "xxx/yyy/a.txt" → fileName = "a.txt"
 	fileName := filepath.Base(fileFullPath)
@@ -64,11 +102,23 @@ func DownloadHandler(c *gin.Context) {
 	c.Header("Content-Type", mimeType)
 	// RFC 5987 标准：支持中文文件名，兼容各浏览器
 	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"; filename*=UTF-8''%s", fileName, encodedFileName))
-	// 可选：设置文件大小（提升下载体验）
-	if fileInfo, err := os.Stat(targetFilePath); err == nil {
-		c.Header("Content-Length", fmt.Sprintf("%d", fileInfo.Size()))
-	}
+	// 断点续传相关：声明支持按字节范围请求，ETag用于If-None-Match/If-Range校验
+	c.Header("Accept-Ranges", "bytes")
+	c.Header("ETag", FileETag(fileInfo))
 
-	// 8. 输出文件（保留原有逻辑）
-	c.File(targetFilePath)
+	// 8. 输出文件：由ServeContent统一处理Range（单段206/多段multipart/byteranges）、
+	// Content-Length、Last-Modified以及If-None-Match/If-Range/If-Modified-Since等条件请求
+	http.ServeContent(c.Writer, c.Request, fileName, fileInfo.ModTime(), f)
+	metrics.DownloadBytes.Add(float64(max(c.Writer.Size(), 0)), "http")
+}
+
+// hiddenUploadPath 判断上传目录内的相对路径是否为不对外展示的文件：
+// 任一级以.开头（隐藏文件/目录）或以.part结尾（未完成上传的临时文件）
+func hiddenUploadPath(relPath string) bool {
+	for _, name := range strings.Split(filepath.ToSlash(relPath), "/") {
+		if strings.HasPrefix(name, ".") || strings.HasSuffix(name, ".part") {
+			return true
+		}
+	}
+	return false
 }
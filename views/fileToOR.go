@@ -3,6 +3,7 @@ package views
 import (
 	"SimpleHttpServer/config"
 	// 点导入middleware包，直接调用包内函数（如Logger）
+	"SimpleHttpServer/metrics"
 	. "SimpleHttpServer/middleware"
 	"SimpleHttpServer/utils"
 	"encoding/base64"
@@ -30,21 +31,36 @@ type QRChunk struct {
 
 // 主要Gin视图函数
 func HandleFileToQR(c *gin.Context) {
+	defer func() {
+		result := "success"
+		if c.Writer.Status() >= http.StatusBadRequest {
+			result = "failure"
+		}
+		metrics.QRCodeGenerations.Inc(result)
+	}()
 	// 1. 获取上传的文件路径参数
 	path := c.Param("path")
 	path = strings.TrimPrefix(path, "/")
 	if path == "" {
-		Logger.Error("生成二维码失败：请求路径为空", zap.String("request_url", c.Request.URL.String()))
+		Log(c).Error("生成二维码失败：请求路径为空", zap.String("request_url", c.Request.URL.String()))
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "file path is required (e.g. /qrcode/run.sh)",
 		})
 		return
 	}
 
+	// 未完成上传的临时文件（.part）和隐藏文件/目录（上传会话元数据、隔离目录等）与文件列表一致，不生成二维码
+	if hiddenUploadPath(path) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "文件不存在",
+		})
+		return
+	}
+
 	// 获取上传目录绝对路径
 	dirAbs, err := filepath.Abs(config.GlobalConfig.UploadDir)
 	if err != nil {
-		Logger.Error("生成二维码失败：获取上传目录绝对路径失败", zap.Error(err), zap.String("upload_dir", config.GlobalConfig.UploadDir))
+		Log(c).Error("生成二维码失败：获取上传目录绝对路径失败", zap.Error(err), zap.String("upload_dir", config.GlobalConfig.UploadDir))
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":  "获取文件目录失败",
 			"detail": err.Error(),
@@ -58,7 +74,7 @@ func HandleFileToQR(c *gin.Context) {
 	// 校验文件是否存在、是否为文件、大小限制
 	fileInfo, err := os.Stat(fullPath)
 	if err != nil {
-		Logger.Error("生成二维码失败：打开文件失败", zap.String("full_path", fullPath), zap.Error(err))
+		Log(c).Error("生成二维码失败：打开文件失败", zap.String("full_path", fullPath), zap.Error(err))
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":  "打开文件失败",
 			"detail": err.Error(),
@@ -67,7 +83,7 @@ func HandleFileToQR(c *gin.Context) {
 	}
 
 	if fileInfo.IsDir() {
-		Logger.Warn("生成二维码失败：请求路径是目录而非文件", zap.String("full_path", fullPath))
+		Log(c).Warn("生成二维码失败：请求路径是目录而非文件", zap.String("full_path", fullPath))
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "请求必须是文件（不能是目录）",
 		})
@@ -75,7 +91,7 @@ func HandleFileToQR(c *gin.Context) {
 	}
 
 	if fileInfo.Size() == 0 {
-		Logger.Warn("生成二维码失败：请求文件为空", zap.String("full_path", fullPath))
+		Log(c).Warn("生成二维码失败：请求文件为空", zap.String("full_path", fullPath))
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "文件为空",
 		})
@@ -85,7 +101,7 @@ func HandleFileToQR(c *gin.Context) {
 	// 提前校验文件大小
 	maxSize := config.GlobalConfig.FileToORMaxZize // 10KB
 	if fileInfo.Size() >= int64(maxSize) {
-		Logger.Warn("生成二维码失败：文件大小超过限制", zap.String("full_path", fullPath), zap.Int64("file_size", fileInfo.Size()), zap.Int64("max_size", int64(maxSize)))
+		Log(c).Warn("生成二维码失败：文件大小超过限制", zap.String("full_path", fullPath), zap.Int64("file_size", fileInfo.Size()), zap.Int64("max_size", int64(maxSize)))
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":      "文件过大",
 			"detail":     fmt.Sprintf("文件大小: %d 字节, 最大支持: %d 字节", fileInfo.Size(), maxSize),
@@ -97,7 +113,7 @@ func HandleFileToQR(c *gin.Context) {
 	// 2. 读取文件内容（使用拼接后的完整路径）
 	fileBytes, err := os.ReadFile(fullPath)
 	if err != nil {
-		Logger.Error("生成二维码失败：读取文件失败", zap.String("full_path", fullPath), zap.Error(err))
+		Log(c).Error("生成二维码失败：读取文件失败", zap.String("full_path", fullPath), zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":  "读取文件失败",
 			"detail": err.Error(),
@@ -108,7 +124,8 @@ func HandleFileToQR(c *gin.Context) {
 	// 变量定义
 	fileName := fileInfo.Name()
 	fileSize := len(fileBytes)
-	Logger.Info("成功读取文件，开始分片处理", zap.String("file_name", fileName), zap.Int("file_size", fileSize))
+	SetAuditFile(c, fullPath, int64(fileSize), contentHash(fileBytes))
+	Log(c).Info("成功读取文件，开始分片处理", zap.String("file_name", fileName), zap.Int("file_size", fileSize))
 
 	// 3. 分片处理（新增错误返回）
 	chunkSize := 2048 // 每个分片2KB
@@ -124,7 +141,7 @@ func HandleFileToQR(c *gin.Context) {
 	}
 
 	if splitErr != nil {
-		Logger.Error("生成二维码失败：文件分片失败", zap.String("file_name", fileName), zap.Error(splitErr))
+		Log(c).Error("生成二维码失败：文件分片失败", zap.String("file_name", fileName), zap.Error(splitErr))
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":  "文件分片失败",
 			"detail": splitErr.Error(),
@@ -133,19 +150,19 @@ func HandleFileToQR(c *gin.Context) {
 	}
 
 	if len(chunks) == 0 {
-		Logger.Error("生成二维码失败：未生成任何分片", zap.String("file_name", fileName))
+		Log(c).Error("生成二维码失败：未生成任何分片", zap.String("file_name", fileName))
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "未生成任何分片",
 		})
 		return
 	}
 
-	Logger.Info("文件分片成功", zap.String("file_name", fileName), zap.Int("total_chunks", len(chunks)), zap.Bool("is_text_file", isText))
+	Log(c).Info("文件分片成功", zap.String("file_name", fileName), zap.Int("total_chunks", len(chunks)), zap.Bool("is_text_file", isText))
 
 	// 4. 生成二维码（新增错误返回）
 	qrChunks, qrErr := generateQRCodeForChunks(chunks)
 	if qrErr != nil {
-		Logger.Error("生成二维码失败：二维码生成失败", zap.String("file_name", fileName), zap.Error(qrErr))
+		Log(c).Error("生成二维码失败：二维码生成失败", zap.String("file_name", fileName), zap.Error(qrErr))
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":  "生成二维码失败",
 			"detail": qrErr.Error(),
@@ -154,7 +171,7 @@ func HandleFileToQR(c *gin.Context) {
 	}
 	md5, err := utils.FileMD5(fullPath)
 	if err != nil {
-		Logger.Error("md5计算失败", zap.String("file_name", fileName), zap.Error(err))
+		Log(c).Error("md5计算失败", zap.String("file_name", fileName), zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":  "md5计算失败",
 			"detail": err.Error(),
@@ -162,7 +179,7 @@ func HandleFileToQR(c *gin.Context) {
 		return
 	}
 	// 5. 返回JSON结果
-	Logger.Info("二维码生成成功", zap.String("file_name", fileName), zap.Int("total_qr_chunks", len(qrChunks)))
+	Log(c).Info("二维码生成成功", zap.String("file_name", fileName), zap.Int("total_qr_chunks", len(qrChunks)))
 	c.JSON(http.StatusOK, gin.H{
 		"fileName":    fileName,
 		"fileSize":    utils.FormatSize(int64(fileSize)),
@@ -0,0 +1,128 @@
+package views
+
+import (
+	. "SimpleHttpServer/config"
+	. "SimpleHttpServer/middleware"
+	"SimpleHttpServer/utils"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/render"
+	"go.uber.org/zap"
+	"net/http"
+	"os"
+)
+
+// requiredTemplates 页面依赖的模板，任一缺失或解析失败时健康检查失败
+var requiredTemplates = []string{"index.html", "login.html", "preview.html", "error.html", "tokens.html", "totp.html", "debug.html", "audit.html"}
+
+// htmlRender 网页服务加载的模板（由SetHTMLRender设置）
+var htmlRender render.HTMLRender
+
+// SetHTMLRender 设置网页服务加载的模板，供健康检查确认模板可用（加载模板后调用）
+func SetHTMLRender(r render.HTMLRender) {
+	htmlRender = r
+}
+
+// healthCheck 一项健康检查
+type healthCheck struct {
+	name  string
+	check func() error
+}
+
+var (
+	// livenessChecks 存活检查（/healthz）：失败说明进程本身异常，需要重启
+	livenessChecks = []healthCheck{
+		{"templates", checkTemplates},
+		{"log", CheckLogFiles},
+	}
+	// readinessChecks 就绪检查（/readyz）：在存活检查的基础上确认可以正常接收上传
+	readinessChecks = append(livenessChecks,
+		healthCheck{"upload_dir", checkUploadDir},
+		healthCheck{"disk_space", checkDiskSpace},
+	)
+)
+
+// HealthzHandler 存活检查（GET /healthz，无需登录），全部通过返回200，否则返回503及失败的检查项
+func HealthzHandler(c *gin.Context) {
+	respondHealth(c, livenessChecks)
+}
+
+// ReadyzHandler 就绪检查（GET /readyz，无需登录）：模板、日志、上传目录可写及磁盘剩余空间，
+// 全部通过返回200，否则返回503，负载均衡据此暂停转发请求
+func ReadyzHandler(c *gin.Context) {
+	respondHealth(c, readinessChecks)
+}
+
+// respondHealth 依次执行检查并返回各项结果（通过为ok，失败为failed）；
+// 接口无需登录，失败原因含上传目录路径等部署信息，只记录到日志
+func respondHealth(c *gin.Context, checks []healthCheck) {
+	results := gin.H{}
+	var failed []zap.Field
+	for _, hc := range checks {
+		if err := hc.check(); err != nil {
+			results[hc.name] = "failed"
+			failed = append(failed, zap.NamedError(hc.name, err))
+			continue
+		}
+		results[hc.name] = "ok"
+	}
+	if len(failed) > 0 {
+		Log(c).Warn("健康检查失败", append(failed, zap.String("path", c.Request.URL.Path))...)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "error", "checks": results})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "checks": results})
+}
+
+// checkTemplates 检查页面模板均已加载（调试模式下每次渲染都会重新解析模板文件，解析失败时gin直接panic）
+func checkTemplates() (err error) {
+	if htmlRender == nil {
+		return errors.New("页面模板未加载")
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("解析页面模板失败: %v", p)
+		}
+	}()
+	for _, name := range requiredTemplates {
+		html, ok := htmlRender.Instance(name, nil).(render.HTML)
+		if !ok || html.Template == nil || html.Template.Lookup(name) == nil {
+			return fmt.Errorf("缺少页面模板: %s", name)
+		}
+	}
+	return nil
+}
+
+// checkUploadDir 检查上传目录存在且可写（创建并删除一个隐藏的临时文件）
+func checkUploadDir() error {
+	info, err := os.Stat(GlobalConfig.UploadDir)
+	if err != nil {
+		return fmt.Errorf("上传目录不可用: %v", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("上传目录不是目录: %s", GlobalConfig.UploadDir)
+	}
+	f, err := os.CreateTemp(GlobalConfig.UploadDir, ".healthz-*")
+	if err != nil {
+		return fmt.Errorf("上传目录不可写: %v", err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// checkDiskSpace 检查上传目录所在磁盘的可用空间不低于 --min-free-space
+func checkDiskSpace() error {
+	_, free, err := utils.DiskUsage(GlobalConfig.UploadDir)
+	if err != nil {
+		if errors.Is(err, errors.ErrUnsupported) {
+			return nil
+		}
+		return fmt.Errorf("读取磁盘空间失败: %v", err)
+	}
+	if int64(free) < GlobalConfig.MinFreeSpace {
+		return fmt.Errorf("磁盘可用空间不足：剩余%s，要求至少%s",
+			utils.FormatSize(int64(free)), utils.FormatSize(GlobalConfig.MinFreeSpace))
+	}
+	return nil
+}
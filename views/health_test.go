@@ -0,0 +1,114 @@
+package views
+
+import (
+	. "SimpleHttpServer/config"
+	. "SimpleHttpServer/middleware"
+	"github.com/gin-gonic/gin/render"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"html/template"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testHTMLRender 只定义了names中模板的渲染器
+func testHTMLRender(names ...string) render.HTMLRender {
+	tmpl := template.New("")
+	for _, name := range names {
+		template.Must(tmpl.New(name).Parse("ok"))
+	}
+	return render.HTMLProduction{Template: tmpl}
+}
+
+// 就绪检查的各项失败分别返回503及失败的检查项（失败原因只记录到日志），存活检查不受上传目录和磁盘空间影响
+func TestReadyzFailureModes(t *testing.T) {
+	saved, savedLogger := htmlRender, Logger
+	t.Cleanup(func() { htmlRender, Logger = saved, savedLogger })
+
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, dir string)
+		failed  map[string]string // 失败的检查项 → 失败原因应包含的内容，为空表示全部通过
+		healthy bool              // 存活检查是否通过
+	}{
+		{"全部通过", nil, nil, true},
+		{"模板未加载", func(t *testing.T, dir string) { htmlRender = nil }, map[string]string{"templates": "页面模板未加载"}, false},
+		{"缺少模板", func(t *testing.T, dir string) {
+			htmlRender = testHTMLRender(requiredTemplates[:len(requiredTemplates)-1]...)
+		}, map[string]string{"templates": "缺少页面模板: " + requiredTemplates[len(requiredTemplates)-1]}, false},
+		{"上传目录不存在", func(t *testing.T, dir string) {
+			GlobalConfig.UploadDir = filepath.Join(dir, "missing")
+		}, map[string]string{"upload_dir": "上传目录不可用", "disk_space": "读取磁盘空间失败"}, true},
+		{"上传目录不是目录", func(t *testing.T, dir string) {
+			GlobalConfig.UploadDir = filepath.Join(dir, "file")
+			os.WriteFile(GlobalConfig.UploadDir, nil, 0644)
+		}, map[string]string{"upload_dir": "上传目录不是目录"}, true},
+		{"上传目录不可写", func(t *testing.T, dir string) {
+			if os.Geteuid() == 0 {
+				t.Skip("root用户不受目录权限限制")
+			}
+			os.Chmod(dir, 0555)
+			t.Cleanup(func() { os.Chmod(dir, 0755) })
+		}, map[string]string{"upload_dir": "上传目录不可写"}, true},
+		{"磁盘空间不足", func(t *testing.T, dir string) {
+			GlobalConfig.MinFreeSpace = math.MaxInt64
+		}, map[string]string{"disk_space": "磁盘可用空间不足"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := setupUploadDir(t, 1024)
+			GlobalConfig.MinFreeSpace = 0
+			htmlRender = testHTMLRender(requiredTemplates...)
+			if tt.setup != nil {
+				tt.setup(t, dir)
+			}
+			core, logs := observer.New(zap.WarnLevel)
+			Logger = zap.New(core)
+			r := testEngine("")
+			r.GET("/healthz", HealthzHandler)
+			r.GET("/readyz", ReadyzHandler)
+
+			w := doRequest(r, http.MethodGet, "/readyz", nil, nil)
+			var result struct {
+				Status string            `json:"status"`
+				Checks map[string]string `json:"checks"`
+			}
+			decodeJSON(t, w, &result)
+			if len(result.Checks) != len(readinessChecks) {
+				t.Fatalf("检查项%v，期望%d项", result.Checks, len(readinessChecks))
+			}
+			var logged map[string]any
+			if entries := logs.TakeAll(); len(entries) > 0 {
+				logged = entries[0].ContextMap()
+			}
+			for name, value := range result.Checks {
+				message, ok := tt.failed[name]
+				if !ok {
+					if value != "ok" {
+						t.Fatalf("%s=%q，期望ok", name, value)
+					}
+					continue
+				}
+				if value != "failed" {
+					t.Fatalf("%s=%q，期望failed（不对外返回失败原因）", name, value)
+				}
+				if reason, _ := logged[name].(string); !strings.Contains(reason, message) {
+					t.Fatalf("日志中%s=%q，期望包含%q", name, reason, message)
+				}
+			}
+			if want := map[bool]int{true: http.StatusOK, false: http.StatusServiceUnavailable}[len(tt.failed) == 0]; w.Code != want {
+				t.Fatalf("/readyz返回%d，期望%d", w.Code, want)
+			}
+			if w := doRequest(r, http.MethodGet, "/healthz", nil, nil); (w.Code == http.StatusOK) != tt.healthy {
+				t.Fatalf("/healthz返回%d: %s", w.Code, w.Body.String())
+			}
+			if entries, _ := os.ReadDir(dir); len(entries) > 1 {
+				t.Fatalf("就绪检查不应在上传目录留下临时文件: %v", entries)
+			}
+		})
+	}
+}
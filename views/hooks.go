@@ -0,0 +1,31 @@
+package views
+
+import (
+	"SimpleHttpServer/hooks"
+	. "SimpleHttpServer/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// fireHook 触发文件事件钩子（异步执行，不影响响应），path、target为上传目录内的绝对或相对路径，
+// 用户、IP、请求ID取自当前请求
+func fireHook(c *gin.Context, event hooks.Event, protocol, path, target string, size int64, hash string) {
+	if protocol == "chunk" {
+		protocol = "web"
+	}
+	payload := hooks.Payload{
+		Event:     event,
+		User:      currentUser(c),
+		IP:        c.ClientIP(),
+		Size:      size,
+		Hash:      hash,
+		Protocol:  protocol,
+		RequestID: RequestIDOf(c),
+	}
+	if path != "" {
+		payload.Path = AuditPath(path)
+	}
+	if target != "" {
+		payload.Target = AuditPath(target)
+	}
+	hooks.Fire(payload)
+}
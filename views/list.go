@@ -3,6 +3,7 @@ package views
 import (
 	. "SimpleHttpServer/config"
 	. "SimpleHttpServer/middleware"
+	"SimpleHttpServer/store"
 	. "SimpleHttpServer/utils"
 	"fmt"
 	"github.com/gin-gonic/gin"
@@ -30,6 +31,7 @@ type FileInfo struct {
 // IndexHandler 首页处理器，负责渲染文件列表首页
 // 核心逻辑：解析分页/搜索参数 → 获取文件列表 → 计算上传目录绝对路径 → 渲染前端模板
 func IndexHandler(c *gin.Context) {
+	SetAuditFile(c, "/", 0, "")
 	// 获取分页参数，默认值为第1页、每页10条数据
 	pageStr := c.DefaultQuery("page", "1")
 	pageSizeStr := c.DefaultQuery("pageSize", "10")
@@ -47,7 +49,7 @@ func IndexHandler(c *gin.Context) {
 	// 获取上传目录的绝对路径，用于前端展示当前目录位置
 	dirAbs, err := filepath.Abs(GlobalConfig.UploadDir)
 	if err != nil {
-		Logger.Error("获取上传目录绝对路径失败", zap.Error(err))
+		Log(c).Error("获取上传目录绝对路径失败", zap.Error(err))
 		c.HTML(http.StatusInternalServerError, "error.html", gin.H{
 			"error": "无法获取上传目录位置：" + err.Error(),
 		})
@@ -55,9 +57,9 @@ func IndexHandler(c *gin.Context) {
 	}
 
 	// 调用GetFileList获取根目录下的文件列表，空字符串表示根目录，传递搜索关键词和分页参数
-	fileList, total, totalPage, err := GetFileList(dirAbs, c.Query("search"), strconv.Itoa(page), strconv.Itoa(pageSize))
+	fileList, total, totalPage, err := GetFileList(dirAbs, c.Query("search"), strconv.Itoa(page), strconv.Itoa(pageSize), listFilter(c))
 	if err != nil {
-		Logger.Error("读取文件列表失败", zap.Error(err))
+		Log(c).Error("读取文件列表失败", zap.Error(err))
 		c.HTML(http.StatusInternalServerError, "error.html", gin.H{
 			"error": "无法读取文件列表：" + err.Error(),
 		})
@@ -68,8 +70,10 @@ func IndexHandler(c *gin.Context) {
 	c.HTML(http.StatusOK, "index.html", gin.H{
 		"Files":         fileList,
 		"Chunk_size":    GlobalConfig.ChunkSize,
-		"Max_file_size": GlobalConfig.MaxFileSize,
-		"Username":      GlobalConfig.UserName,
+		"Max_file_size": GlobalConfig.MaxUploadSize(),
+		"Username":      currentUser(c),
+		"CanUpload":     Allowed(c, "", store.PermUpload), // 是否展示上传区域
+		"CanDelete":     Allowed(c, "", store.PermDelete), // 是否展示删除按钮
 		"dirAbs":        dirAbs,
 		"dirRel":        "", // 当前目录相对路径，用于面包屑导航
 		// 分页参数：传递整数类型，确保前端模板可执行加减运算
@@ -88,8 +92,9 @@ func IndexHandler(c *gin.Context) {
 //	rootDir - 根上传目录，用于计算相对路径
 //	currentDir - 当前遍历的目录
 //	searchKey - 搜索关键词，用于过滤文件/目录
+//	visible - 可见性过滤函数（按用户权限隐藏无权访问的文件/目录，nil表示不过滤）
 //	result - 收集结果的切片指针，避免值拷贝提升性能
-func recursiveSearchFiles(rootDir, currentDir, searchKey string, result *[]FileInfo) {
+func recursiveSearchFiles(rootDir, currentDir, searchKey string, visible func(absPath string, isDir bool) bool, result *[]FileInfo) {
 	// 读取当前目录下的所有文件/目录条目
 	entries, err := os.ReadDir(currentDir)
 	if err != nil {
@@ -99,12 +104,16 @@ func recursiveSearchFiles(rootDir, currentDir, searchKey string, result *[]FileI
 
 	for _, entry := range entries {
 		// 跳过临时文件（.part后缀）和隐藏文件/目录（.开头），符合业务过滤规则
-		if strings.HasSuffix(entry.Name(), ".part") || strings.HasPrefix(entry.Name(), ".") {
+		if hiddenUploadPath(entry.Name()) {
 			continue
 		}
 
 		// 拼接当前条目绝对路径，用于后续获取文件信息和计算相对路径
 		entryAbsPath := path.Join(currentDir, entry.Name())
+		// 无权访问的条目不展示，无权浏览的目录也不再向下搜索
+		if visible != nil && !visible(entryAbsPath, entry.IsDir()) {
+			continue
+		}
 		// 计算当前条目相对于根上传目录的相对路径，用于前端展示完整路径
 		entryRelPath, err := filepath.Rel(rootDir, entryAbsPath)
 		if err != nil {
@@ -123,7 +132,7 @@ func recursiveSearchFiles(rootDir, currentDir, searchKey string, result *[]FileI
 		match := strings.Contains(strings.ToLower(entry.Name()), strings.ToLower(searchKey))
 		if !match {
 			if entry.IsDir() {
-				recursiveSearchFiles(rootDir, entryAbsPath, searchKey, result)
+				recursiveSearchFiles(rootDir, entryAbsPath, searchKey, visible, result)
 			}
 			continue
 		}
@@ -156,6 +165,7 @@ func recursiveSearchFiles(rootDir, currentDir, searchKey string, result *[]FileI
 //	searchKey - 搜索关键词（空字符串表示不搜索，仅读取当前目录）
 //	pageStr - 页码字符串
 //	pageSizeStr - 每页条数字符串
+//	visible - 可见性过滤函数（按用户权限隐藏无权访问的文件/目录，nil表示不过滤）
 //
 // 返回值：
 //
@@ -163,7 +173,7 @@ func recursiveSearchFiles(rootDir, currentDir, searchKey string, result *[]FileI
 //	int - 符合条件的文件总数
 //	int - 总页数
 //	error - 错误信息（读取目录失败等）
-func GetFileList(dir string, searchKey, pageStr, pageSizeStr string) ([]FileInfo, int, int, error) {
+func GetFileList(dir string, searchKey, pageStr, pageSizeStr string, visible func(absPath string, isDir bool) bool) ([]FileInfo, int, int, error) {
 	var allFileList []FileInfo
 	// 解析分页参数：非法值重置为默认值（页码≥1，每页条数1-100）
 	page, err := strconv.Atoi(pageStr)
@@ -178,7 +188,7 @@ func GetFileList(dir string, searchKey, pageStr, pageSizeStr string) ([]FileInfo
 
 	// 搜索逻辑分支：有搜索关键词则递归遍历目标目录所有子目录，无则仅读取当前目录
 	if searchKey != "" {
-		recursiveSearchFiles(dir, dir, searchKey, &allFileList)
+		recursiveSearchFiles(dir, dir, searchKey, visible, &allFileList)
 	} else {
 		// 无搜索关键词时，仅读取当前目录下的文件/目录，不递归
 		entries, err := os.ReadDir(dir)
@@ -188,7 +198,10 @@ func GetFileList(dir string, searchKey, pageStr, pageSizeStr string) ([]FileInfo
 
 		// 遍历当前目录条目，构建FileInfo列表，过滤临时/隐藏文件
 		for _, entry := range entries {
-			if strings.HasSuffix(entry.Name(), ".part") || strings.HasPrefix(entry.Name(), ".") {
+			if hiddenUploadPath(entry.Name()) {
+				continue
+			}
+			if visible != nil && !visible(filepath.Join(dir, entry.Name()), entry.IsDir()) {
 				continue
 			}
 
@@ -254,7 +267,7 @@ func ExploreDir(c *gin.Context) {
 	// 获取根上传目录的绝对路径，作为目录访问权限校验的基准
 	dirAbs, err := filepath.Abs(GlobalConfig.UploadDir)
 	if err != nil {
-		Logger.Error("获取上传目录绝对路径失败", zap.Error(err))
+		Log(c).Error("获取上传目录绝对路径失败", zap.Error(err))
 		c.HTML(http.StatusInternalServerError, "error.html", gin.H{
 			"error": "无法获取上传目录位置：" + err.Error(),
 		})
@@ -264,10 +277,15 @@ func ExploreDir(c *gin.Context) {
 	// 拼接目标目录绝对路径并清理（去除../等非法路径），防止路径遍历漏洞
 	targetDir := filepath.Clean(filepath.Join(dirAbs, relativePath))
 	// 安全校验：确保目标目录在根上传目录范围内，禁止访问外部目录
-	if !strings.HasPrefix(targetDir, dirAbs) {
+	if !IsWithinDir(dirAbs, targetDir) {
 		c.String(http.StatusForbidden, "非法目录访问")
 		return
 	}
+	// 隐藏目录（上传会话元数据、隔离目录等）与文件列表一致，不提供浏览
+	if hiddenUploadPath(relativePath) {
+		c.String(http.StatusNotFound, "目录不存在")
+		return
+	}
 	// 校验目标路径是否存在且为目录
 	fileInfo, err := os.Stat(targetDir)
 	if err != nil {
@@ -296,7 +314,7 @@ func ExploreDir(c *gin.Context) {
 	}
 
 	// 调用GetFileList获取目标目录下的文件列表，传递目录路径、搜索关键词和分页参数
-	files, total, totalPage, err := GetFileList(targetDir, c.Query("search"), strconv.Itoa(page), strconv.Itoa(pageSize))
+	files, total, totalPage, err := GetFileList(targetDir, c.Query("search"), strconv.Itoa(page), strconv.Itoa(pageSize), listFilter(c))
 	if err != nil {
 		c.String(http.StatusInternalServerError, "读取目录失败：%v", err)
 		return
@@ -308,8 +326,10 @@ func ExploreDir(c *gin.Context) {
 		"dirRel":        relativePath, // 当前目录相对路径，用于面包屑导航
 		"Files":         files,        // 当前目录下的文件/目录列表
 		"Chunk_size":    GlobalConfig.ChunkSize,
-		"Username":      GlobalConfig.UserName,
-		"Max_file_size": GlobalConfig.MaxFileSize,
+		"Username":      currentUser(c),
+		"CanUpload":     Allowed(c, relativePath, store.PermUpload), // 是否展示上传区域
+		"CanDelete":     Allowed(c, relativePath, store.PermDelete), // 是否展示删除按钮
+		"Max_file_size": GlobalConfig.MaxUploadSize(),
 		"TotalPage":     totalPage,         // 总页数
 		"Total":         total,             // 符合条件的文件总数
 		"CurrentPage":   page,              // 当前页码
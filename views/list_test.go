@@ -0,0 +1,73 @@
+package views
+
+import (
+	. "SimpleHttpServer/config"
+	"SimpleHttpServer/internal/testutil"
+	"SimpleHttpServer/store"
+	"html/template"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 隔离目录、上传会话元数据和未完成的临时文件不能被浏览、预览或生成二维码，也不出现在文件列表中
+func TestHiddenUploadPaths(t *testing.T) {
+	dir := setupUploadDir(t, 1024)
+	GlobalConfig.FileToORMaxZize = 10 * 1024
+	testutil.SetupStores(t)
+	r := userEngine(addUser(t, "admin", store.RoleAdmin))
+	// 只渲染测试需要的内容：错误信息和文件名
+	tmpl := template.New("")
+	template.Must(tmpl.New("error.html").Parse(`{{.error}}`))
+	template.Must(tmpl.New("preview.html").Parse(`{{.content}}`))
+	template.Must(tmpl.New("index.html").Parse(`{{range .Files}}{{.Name}} {{end}}`))
+	r.SetHTMLTemplate(tmpl)
+	r.GET("/explore/*path", ExploreDir)
+	r.GET("/preview/*path", PreviewFile)
+	r.GET("/qrcode/*path", HandleFileToQR)
+
+	os.MkdirAll(filepath.Join(dir, ".quarantine"), 0755)
+	os.MkdirAll(filepath.Join(dir, "docs"), 0755)
+	for _, name := range []string{".quarantine/x.txt", "docs/a.txt", "docs/.a.txt.upload", "docs/b.txt.part"} {
+		os.WriteFile(filepath.Join(dir, name), []byte("hello"), 0644)
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		status int
+	}{
+		{"浏览隔离目录", "/explore/.quarantine", http.StatusNotFound},
+		{"预览隔离文件", "/preview/.quarantine/x.txt", http.StatusInternalServerError},
+		{"预览会话元数据", "/preview/docs/.a.txt.upload", http.StatusInternalServerError},
+		{"预览临时文件", "/preview/docs/b.txt.part", http.StatusInternalServerError},
+		{"隔离文件二维码", "/qrcode/.quarantine/x.txt", http.StatusNotFound},
+		{"会话元数据二维码", "/qrcode/docs/.a.txt.upload", http.StatusNotFound},
+		{"预览普通文件", "/preview/docs/a.txt", http.StatusOK},
+		{"普通文件二维码", "/qrcode/docs/a.txt", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := doRequest(r, http.MethodGet, tt.target, nil, nil)
+			if w.Code != tt.status {
+				t.Fatalf("%s返回%d，期望%d: %s", tt.target, w.Code, tt.status, w.Body.String())
+			}
+			if w.Code != http.StatusOK && strings.Contains(w.Body.String(), "hello") {
+				t.Fatalf("%s泄露了文件内容", tt.target)
+			}
+		})
+	}
+
+	for target, want := range map[string]string{"/explore/": "docs", "/explore/docs": "a.txt"} {
+		w := doRequest(r, http.MethodGet, target, nil, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s返回%d", target, w.Code)
+		}
+		names := strings.Fields(w.Body.String())
+		if len(names) != 1 || names[0] != want {
+			t.Fatalf("%s列出%v，期望只有%s", target, names, want)
+		}
+	}
+}
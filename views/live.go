@@ -0,0 +1,225 @@
+package views
+
+import (
+	. "SimpleHttpServer/config"
+	. "SimpleHttpServer/middleware"
+	"SimpleHttpServer/watcher"
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 目录实时更新：上传目录的文件变化（watcher监听）和分块上传进度按所在目录分发，
+// 通过 Server-Sent Events 推送给正在浏览该目录的页面
+
+// LiveProgress 上传进度事件类型，文件变化事件类型与watcher.Op一致（created、modified、deleted）
+const LiveProgress = "progress"
+
+// 上传进度状态
+const (
+	UploadStateUploading = "uploading" // 上传中
+	UploadStateCompleted = "completed" // 上传完成
+	UploadStateFailed    = "failed"    // 上传失败（校验失败、同名文件已存在等）
+	UploadStateAborted   = "aborted"   // 上传已取消
+)
+
+const (
+	liveHeartbeat      = 30 * time.Second // 心跳间隔，避免连接被代理或浏览器判定为空闲断开
+	liveBuffer         = 64               // 每个连接的事件缓冲，页面处理不过来时丢弃（文件变化由页面重新加载列表兜底）
+	liveProgressActive = time.Minute      // 连接时补发最近该时长内有进展的上传会话
+)
+
+// LiveEvent 推送给页面的目录变化或上传进度
+type LiveEvent struct {
+	Type    string `json:"type"`
+	Path    string `json:"path"` // 上传目录内的相对路径（/分隔）
+	Name    string `json:"name"`
+	IsDir   bool   `json:"is_dir"`
+	Size    int64  `json:"size"`
+	ModTime string `json:"mod_time,omitempty"`
+
+	// 上传进度（仅progress事件）
+	UploadID string `json:"upload_id,omitempty"`
+	User     string `json:"user,omitempty"`
+	Uploaded int64  `json:"uploaded,omitempty"`
+	State    string `json:"state,omitempty"`
+
+	dir string // 所在目录（上传目录内的相对路径，根目录为空），用于分发
+	abs string // 绝对路径，用于按订阅用户的权限过滤
+}
+
+// liveSubscriber 一个浏览目录的SSE连接
+type liveSubscriber struct {
+	dir    string
+	events chan LiveEvent
+}
+
+var (
+	liveMu          sync.Mutex
+	liveSubscribers = map[*liveSubscriber]struct{}{}
+	liveWatcher     *watcher.Watcher
+	liveDone        = make(chan struct{}) // 服务退出时关闭，结束所有SSE连接
+	liveStopOnce    sync.Once
+)
+
+// StartLiveEvents 监听上传目录的文件变化并分发给浏览对应目录的SSE连接（隐藏文件和.part临时文件不推送）
+func StartLiveEvents() error {
+	w, err := watcher.New(GlobalConfig.UploadDir, func(name string) bool {
+		return strings.HasPrefix(name, ".") || strings.HasSuffix(name, ".part")
+	})
+	if err != nil {
+		return err
+	}
+	liveWatcher = w
+	Logger.Info("目录实时更新已启动",
+		zap.String("uploadDir", GlobalConfig.UploadDir),
+		zap.String("mode", w.Mode()),
+	)
+	go func() {
+		for ev := range w.Events {
+			e := LiveEvent{Type: string(ev.Op), IsDir: ev.IsDir, abs: ev.Path}
+			if ev.Op != watcher.Deleted {
+				info, err := os.Stat(ev.Path)
+				if err != nil {
+					// 创建后立即被删除或移走，随后的删除事件会通知页面
+					continue
+				}
+				e.IsDir = info.IsDir()
+				e.ModTime = info.ModTime().Format(time.RFC3339)
+				if !e.IsDir {
+					e.Size = info.Size()
+				}
+			}
+			setLivePath(&e, ev.Path)
+			publishLive(e)
+		}
+	}()
+	return nil
+}
+
+// StopLiveEvents 停止监听并结束所有SSE连接（服务退出时在等待请求完成前调用，长连接不会拖住优雅关闭）
+func StopLiveEvents() {
+	liveStopOnce.Do(func() {
+		close(liveDone)
+		if liveWatcher != nil {
+			liveWatcher.Close()
+		}
+	})
+}
+
+// setLivePath 按绝对路径填充事件的相对路径、文件名和所在目录
+func setLivePath(e *LiveEvent, absPath string) {
+	rel := filepath.ToSlash(relUploadPath(absPath))
+	e.abs = absPath
+	e.Path = rel
+	e.Name = path.Base(rel)
+	e.dir = path.Dir(rel)
+	if e.dir == "." {
+		e.dir = ""
+	}
+}
+
+// publishLive 将事件分发给浏览该目录的连接，连接缓冲已满时丢弃
+func publishLive(e LiveEvent) {
+	liveMu.Lock()
+	defer liveMu.Unlock()
+	for sub := range liveSubscribers {
+		if sub.dir != e.dir {
+			continue
+		}
+		select {
+		case sub.events <- e:
+		default:
+		}
+	}
+}
+
+// uploadProgressEvent 上传会话的进度事件
+func uploadProgressEvent(status *UploadStatus, state string) LiveEvent {
+	e := LiveEvent{
+		Type:     LiveProgress,
+		Size:     status.TotalSize,
+		UploadID: status.ID,
+		User:     status.Owner,
+		Uploaded: sessionInfo(status).UploadedBytes,
+		State:    state,
+	}
+	setLivePath(&e, status.FilePath)
+	return e
+}
+
+// publishUploadProgress 推送分块上传会话的进度
+func publishUploadProgress(status *UploadStatus, state string) {
+	publishLive(uploadProgressEvent(status, state))
+}
+
+// LiveEventsHandler 目录实时更新（GET /events、/events/*path，Server-Sent Events）
+// 推送该目录下文件的created/modified/deleted事件及其他用户上传的progress事件，按当前用户的权限过滤
+func LiveEventsHandler(c *gin.Context) {
+	// ========== 1. 解析并校验目录 ==========
+	_, targetDir, ok := resolveUploadDir(c)
+	if !ok {
+		return
+	}
+	dir := filepath.ToSlash(relUploadPath(targetDir))
+	if dir == "." {
+		dir = ""
+	}
+
+	// ========== 2. 订阅目录事件 ==========
+	sub := &liveSubscriber{dir: dir, events: make(chan LiveEvent, liveBuffer)}
+	liveMu.Lock()
+	liveSubscribers[sub] = struct{}{}
+	liveMu.Unlock()
+	defer func() {
+		liveMu.Lock()
+		delete(liveSubscribers, sub)
+		liveMu.Unlock()
+	}()
+	Log(c).Info("目录实时更新连接已建立", zap.String("dir", dir))
+
+	// 补发该目录下正在进行的分块上传，页面打开时即可看到其他用户的上传进度
+	UploadStatusCache.Range(func(_, value any) bool {
+		status := value.(*UploadStatus)
+		if !status.Tus && !status.Multipart && filepath.Dir(status.FilePath) == targetDir &&
+			time.Since(status.LastActive()) < liveProgressActive {
+			select {
+			case sub.events <- uploadProgressEvent(status, UploadStateUploading):
+			default:
+			}
+		}
+		return true
+	})
+
+	// ========== 3. 推送事件 ==========
+	// 禁止反向代理（如nginx）缓冲响应，事件才能即时到达
+	c.Header("X-Accel-Buffering", "no")
+	visible := listFilter(c)
+	heartbeat := time.NewTicker(liveHeartbeat)
+	defer heartbeat.Stop()
+	c.Render(-1, sse.Event{Event: "ready", Data: gin.H{"dir": dir}})
+	c.Writer.Flush()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-liveDone:
+			return false
+		case <-heartbeat.C:
+			c.Render(-1, sse.Event{Event: "ping", Data: time.Now().Unix()})
+		case e := <-sub.events:
+			if visible(e.abs, e.IsDir) {
+				c.Render(-1, sse.Event{Event: e.Type, Data: e})
+			}
+		}
+		return true
+	})
+	Log(c).Info("目录实时更新连接已断开", zap.String("dir", dir))
+}
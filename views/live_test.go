@@ -0,0 +1,184 @@
+package views
+
+import (
+	"SimpleHttpServer/internal/testutil"
+	. "SimpleHttpServer/middleware"
+	"SimpleHttpServer/store"
+	"bufio"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sseEvent 从SSE连接读到的一个事件
+type sseEvent struct {
+	Type string
+	Data LiveEvent
+}
+
+// subscribeLive 以user身份订阅目录的实时更新，等待ready事件后返回读到的事件（连接断开时关闭）
+func subscribeLive(t *testing.T, r http.Handler, user store.User, dir string) <-chan sseEvent {
+	t.Helper()
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/events"+dir+"?user="+user.Username, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("订阅%s失败: %v", dir, err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		t.Fatalf("订阅%s返回%d %s", dir, resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	events := make(chan sseEvent, 64)
+	ready := make(chan struct{})
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(resp.Body)
+		var event sseEvent
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "event:"):
+				event.Type = strings.TrimPrefix(line, "event:")
+			case strings.HasPrefix(line, "data:"):
+				json.Unmarshal([]byte(strings.TrimPrefix(line, "data:")), &event.Data)
+			case line == "":
+				if event.Type == "ready" {
+					close(ready)
+				} else if event.Type != "ping" {
+					events <- event
+				}
+				event = sseEvent{}
+			}
+		}
+	}()
+	select {
+	case <-ready:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("订阅%s未收到ready事件", dir)
+	}
+	return events
+}
+
+// receiveUntil 读取事件直到收到名为last的文件的事件，返回期间收到的所有文件名（含last）
+func receiveUntil(t *testing.T, events <-chan sseEvent, last string) []string {
+	t.Helper()
+	var names []string
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				t.Fatalf("连接已断开，收到%v", names)
+			}
+			names = append(names, e.Data.Name)
+			if e.Data.Name == last {
+				return names
+			}
+		case <-timeout:
+			t.Fatalf("等待%s超时，收到%v", last, names)
+		}
+	}
+}
+
+// 同一目录的多个连接都收到磁盘上的文件变化，其他目录的连接收不到，推送内容按各自用户的权限过滤
+func TestLiveEventsFanOut(t *testing.T) {
+	dir := setupUploadDir(t, 1024)
+	testutil.SetupStores(t)
+	os.MkdirAll(filepath.Join(dir, "pub"), 0755)
+	os.MkdirAll(filepath.Join(dir, "other"), 0755)
+	for _, rule := range []store.ACLRule{
+		{Path: "/", Subject: "*", Access: store.AccessRead},
+		{Path: "/pub/private", Subject: "*", Access: store.AccessNone},
+		{Path: "/pub/team.txt", Subject: "*", Access: store.AccessNone},
+		{Path: "/pub/team.txt", Subject: "group:team", Access: store.AccessRead},
+	} {
+		if err := store.ACL.Set(rule); err != nil {
+			t.Fatalf("添加规则失败: %v", err)
+		}
+	}
+	users := map[string]store.User{
+		"alice": addUser(t, "alice", store.RoleViewer),
+		"bob":   addUser(t, "bob", store.RoleViewer, "team"),
+		"admin": addUser(t, "admin", store.RoleAdmin),
+	}
+
+	if err := StartLiveEvents(); err != nil {
+		t.Fatalf("启动目录实时更新失败: %v", err)
+	}
+	t.Cleanup(func() { liveWatcher.Close() })
+
+	// 按查询参数切换用户，多个连接共用同一个引擎
+	r := gin.New()
+	r.Use(func(c *gin.Context) { SetCurrentUser(c, users[c.Query("user")]) })
+	r.GET("/events", LiveEventsHandler)
+	r.GET("/events/*path", LiveEventsHandler)
+	alice := subscribeLive(t, r, users["alice"], "/pub")
+	bob := subscribeLive(t, r, users["bob"], "/pub")
+	admin := subscribeLive(t, r, users["admin"], "/pub")
+	root := subscribeLive(t, r, users["alice"], "")
+	other := subscribeLive(t, r, users["alice"], "/other")
+
+	for _, name := range []string{"pub/a.txt", "pub/team.txt", "pub/.hidden", "pub/b.txt.part"} {
+		os.WriteFile(filepath.Join(dir, name), []byte("x"), 0644)
+	}
+	os.Mkdir(filepath.Join(dir, "pub", "private"), 0755)
+	os.WriteFile(filepath.Join(dir, "pub", "private", "p.txt"), []byte("x"), 0644)
+	os.WriteFile(filepath.Join(dir, "pub", "z.txt"), []byte("x"), 0644)
+	os.WriteFile(filepath.Join(dir, "other", "o.txt"), []byte("x"), 0644)
+	os.WriteFile(filepath.Join(dir, "r.txt"), []byte("x"), 0644)
+
+	tests := []struct {
+		name     string
+		events   <-chan sseEvent
+		last     string
+		contains []string
+		excludes []string
+	}{
+		{"只读用户", alice, "z.txt", []string{"a.txt"}, []string{"team.txt", "private", "p.txt", ".hidden", "b.txt.part"}},
+		{"用户组可读", bob, "z.txt", []string{"a.txt", "team.txt"}, []string{"private", "p.txt"}},
+		{"管理员", admin, "z.txt", []string{"a.txt", "team.txt", "private"}, []string{"p.txt", ".hidden"}},
+		{"根目录", root, "r.txt", nil, []string{"a.txt", "z.txt", "o.txt"}},
+		{"其他目录", other, "o.txt", nil, []string{"a.txt", "z.txt", "r.txt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			names := strings.Join(receiveUntil(t, tt.events, tt.last), ",")
+			for _, s := range tt.contains {
+				if !strings.Contains(","+names+",", ","+s+",") {
+					t.Fatalf("应收到%s的事件，实际%s", s, names)
+				}
+			}
+			for _, s := range tt.excludes {
+				if strings.Contains(","+names+",", ","+s+",") {
+					t.Fatalf("不应收到%s的事件，实际%s", s, names)
+				}
+			}
+		})
+	}
+
+	// 删除事件同样分发给该目录的所有连接
+	os.Remove(filepath.Join(dir, "pub", "a.txt"))
+	for name, events := range map[string]<-chan sseEvent{"alice": alice, "bob": bob, "admin": admin} {
+		timeout := time.After(5 * time.Second)
+	wait:
+		for {
+			select {
+			case e := <-events:
+				if e.Type == "deleted" && e.Data.Path == "pub/a.txt" {
+					break wait
+				}
+			case <-timeout:
+				t.Fatalf("%s未收到删除事件", name)
+			}
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package views
+
+import (
+	. "SimpleHttpServer/config"
+	. "SimpleHttpServer/middleware"
+	"SimpleHttpServer/store"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	Logger = zap.NewNop()
+	os.Exit(m.Run())
+}
+
+// setupUploadDir 使用临时上传目录和较小的分块大小，并清空上传会话缓存
+func setupUploadDir(t *testing.T, chunkSize int64) string {
+	t.Helper()
+	dir := t.TempDir()
+	saved := *GlobalConfig
+	t.Cleanup(func() { *GlobalConfig = saved })
+	GlobalConfig.UploadDir = dir
+	GlobalConfig.ChunkSize = chunkSize
+	GlobalConfig.MaxFileSize = 1 << 30
+	clearUploadSessions()
+	t.Cleanup(clearUploadSessions)
+	return dir
+}
+
+// addUser 创建测试用户（密码与用户名相同）
+func addUser(t *testing.T, username string, role store.Role, groups ...string) store.User {
+	t.Helper()
+	user, err := store.Users.Add(username, username, role)
+	if err != nil {
+		t.Fatalf("创建用户%s失败: %v", username, err)
+	}
+	if len(groups) > 0 {
+		if err := store.Users.SetGroups(username, groups); err != nil {
+			t.Fatalf("设置用户组失败: %v", err)
+		}
+		user.Groups = groups
+	}
+	return user
+}
+
+// userEngine 返回以store中用户身份访问的gin引擎（与认证中间件一致写入当前用户，不含授权中间件）
+func userEngine(user store.User) *gin.Engine {
+	r := gin.New()
+	r.Use(func(c *gin.Context) { SetCurrentUser(c, user) })
+	return r
+}
+
+func clearUploadSessions() {
+	UploadStatusCache.Range(func(key, _ any) bool {
+		UploadStatusCache.Delete(key)
+		return true
+	})
+}
+
+// testEngine 返回以user身份访问的gin引擎（跳过登录认证和授权中间件）
+func testEngine(user string) *gin.Engine {
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("user", user) })
+	return r
+}
+
+// doRequest 发送请求并返回响应
+func doRequest(r http.Handler, method, target string, body io.Reader, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, body)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// postForm 以表单提交请求
+func postForm(r http.Handler, target string, form url.Values) *httptest.ResponseRecorder {
+	return doRequest(r, http.MethodPost, target, strings.NewReader(form.Encode()),
+		map[string]string{"Content-Type": "application/x-www-form-urlencoded"})
+}
+
+// decodeJSON 解析JSON响应体
+func decodeJSON(t *testing.T, w *httptest.ResponseRecorder, v any) {
+	t.Helper()
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("解析响应失败: %v，响应: %s", err, w.Body.String())
+	}
+}
@@ -0,0 +1,83 @@
+package views
+
+import (
+	. "SimpleHttpServer/config"
+	"SimpleHttpServer/metrics"
+	. "SimpleHttpServer/middleware"
+	"SimpleHttpServer/utils"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// uploadDirSizeTTL 上传目录占用空间的缓存时间（遍历大目录较慢，避免每次采集都遍历）
+const uploadDirSizeTTL = time.Minute
+
+var uploadDirSize struct {
+	mu         sync.Mutex
+	bytes      int64
+	computedAt time.Time
+}
+
+func init() {
+	metrics.NewGaugeFunc("shs_upload_sessions_active", "未完成的上传会话数", func() (float64, error) {
+		count := 0
+		UploadStatusCache.Range(func(_, _ any) bool {
+			count++
+			return true
+		})
+		return float64(count), nil
+	})
+	metrics.NewGaugeFunc("shs_upload_dir_size_bytes", "上传目录占用空间（字节，每分钟最多统计一次）", func() (float64, error) {
+		size, err := cachedUploadDirSize()
+		return float64(size), err
+	})
+	metrics.NewGaugeFunc("shs_upload_dir_fs_size_bytes", "上传目录所在文件系统的总空间（字节）", func() (float64, error) {
+		total, _, err := utils.DiskUsage(GlobalConfig.UploadDir)
+		return float64(total), err
+	})
+	metrics.NewGaugeFunc("shs_upload_dir_fs_free_bytes", "上传目录所在文件系统的可用空间（字节）", func() (float64, error) {
+		_, free, err := utils.DiskUsage(GlobalConfig.UploadDir)
+		return float64(free), err
+	})
+}
+
+// MetricsHandler 以Prometheus文本格式输出监控指标（GET /metrics，访问控制见MetricsAccessRequired）
+func MetricsHandler(c *gin.Context) {
+	c.Header("Content-Type", metrics.ContentType)
+	c.Status(http.StatusOK)
+	if err := metrics.WriteTo(c.Writer); err != nil {
+		Log(c).Warn("输出监控指标失败", zap.Error(err))
+	}
+}
+
+// cachedUploadDirSize 返回上传目录下所有文件（含未完成上传的临时文件）的总大小，结果缓存uploadDirSizeTTL
+func cachedUploadDirSize() (int64, error) {
+	uploadDirSize.mu.Lock()
+	defer uploadDirSize.mu.Unlock()
+	if time.Since(uploadDirSize.computedAt) < uploadDirSizeTTL {
+		return uploadDirSize.bytes, nil
+	}
+	var total int64
+	err := filepath.WalkDir(GlobalConfig.UploadDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// 遍历过程中被删除的文件忽略
+			return nil
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				total += info.Size()
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	uploadDirSize.bytes, uploadDirSize.computedAt = total, time.Now()
+	return total, nil
+}
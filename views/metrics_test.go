@@ -0,0 +1,73 @@
+package views
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// metricValue 通过/metrics接口读取指标取值（指标尚未出现时为0）
+func metricValue(t *testing.T, series string) float64 {
+	t.Helper()
+	r := testEngine("")
+	r.GET("/metrics", MetricsHandler)
+	w := doRequest(r, http.MethodGet, "/metrics", nil, nil)
+	for _, line := range strings.Split(w.Body.String(), "\n") {
+		if value, ok := strings.CutPrefix(line, series+" "); ok {
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				t.Fatalf("解析指标%s失败: %v", line, err)
+			}
+			return v
+		}
+	}
+	return 0
+}
+
+// 分块上传按protocol和reason标签统计完成、失败次数和接收字节数
+func TestUploadMetricsLabels(t *testing.T) {
+	setupUploadDir(t, 4)
+	r := uploadEngine("alice")
+	data := []byte("0123456789")
+	series := []string{
+		`shs_uploads_completed_total{protocol="chunk"}`,
+		`shs_upload_bytes_total{protocol="chunk"}`,
+		`shs_upload_chunks_total`,
+		`shs_uploads_failed_total{protocol="chunk",reason="checksum_mismatch"}`,
+		`shs_uploads_failed_total{protocol="chunk",reason="target_exists"}`,
+	}
+	before := map[string]float64{}
+	for _, s := range series {
+		before[s] = metricValue(t, s)
+	}
+
+	// 一次成功上传（3块10字节）
+	session := initUpload(t, r, "m.bin", len(data), "new")
+	if metricValue(t, "shs_upload_sessions_active") != 1 {
+		t.Fatalf("上传中的会话数应为1")
+	}
+	for index := 0; index < session.TotalChunks; index++ {
+		putChunk(t, r, session.UploadID, data, 4, index, nil)
+	}
+	// 一次整文件校验失败
+	w := postForm(r, "/uploads", url.Values{
+		"file_name":     {"bad.bin"},
+		"total_size":    {"4"},
+		"action":        {"new"},
+		"file_checksum": {sha256Checksum([]byte("other"))},
+	})
+	var bad uploadSession
+	decodeJSON(t, w, &bad)
+	putChunk(t, r, bad.UploadID, data[:4], 4, 0, nil)
+
+	for s, delta := range map[string]float64{series[0]: 1, series[1]: 14, series[2]: 4, series[3]: 1, series[4]: 0} {
+		if got := metricValue(t, s) - before[s]; got != delta {
+			t.Errorf("%s增加%v，期望%v", s, got, delta)
+		}
+	}
+	if metricValue(t, "shs_upload_sessions_active") != 0 {
+		t.Fatalf("上传结束后会话数应为0")
+	}
+}
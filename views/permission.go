@@ -0,0 +1,81 @@
+package views
+
+import (
+	. "SimpleHttpServer/config"
+	. "SimpleHttpServer/middleware"
+	"SimpleHttpServer/store"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"path"
+	"path/filepath"
+)
+
+// 授权中间件（middleware.RequirePermission）的目标路径解析函数，与对应处理函数解析路径的方式保持一致
+
+// UploadFormTarget 分块上传会话初始化的目标文件（/uploads/*path + 表单file_name）
+func UploadFormTarget(c *gin.Context) (string, bool) {
+	return path.Join(c.Param("path"), c.PostForm("file_name")), true
+}
+
+// UploadSessionTarget 上传会话（分块上传、tus）的目标文件（按会话ID查找）；
+// 每次访问会话都重新校验，撤销目录权限后进行中的上传随之停止
+func UploadSessionTarget(c *gin.Context) (string, bool) {
+	status := loadOwnedSession(c)
+	if status == nil {
+		return "", false
+	}
+	return relUploadPath(status.FilePath), true
+}
+
+// ResumeInfoTarget 续传信息查询的目标文件（/get_resume_info/*path + 查询参数file_name）
+func ResumeInfoTarget(c *gin.Context) (string, bool) {
+	return path.Join(c.Param("path"), c.Query("file_name")), true
+}
+
+// TusCreateTarget tus上传的目标文件（Upload-Metadata中的dir和filename）
+func TusCreateTarget(c *gin.Context) (string, bool) {
+	metadata, err := parseTusMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		respondUploadError(c, http.StatusBadRequest, err.Error(), zap.String("uploadMetadata", c.GetHeader("Upload-Metadata")))
+		return "", false
+	}
+	fileName := metadata["filename"]
+	if fileName == "" {
+		fileName = metadata["name"]
+	}
+	return path.Join(metadata["dir"], fileName), true
+}
+
+// DeleteTarget 删除接口的目标文件
+func DeleteTarget(c *gin.Context) (string, bool) {
+	return deletePathParam(c)
+}
+
+// DownloadTarget 下载接口的目标文件
+func DownloadTarget(c *gin.Context) (string, bool) {
+	return downloadPathParam(c)
+}
+
+// relUploadPath 将上传目录内的绝对路径转换为相对路径（用于权限校验）
+func relUploadPath(absPath string) string {
+	root, err := filepath.Abs(GlobalConfig.UploadDir)
+	if err != nil {
+		return absPath
+	}
+	rel, err := filepath.Rel(root, absPath)
+	if err != nil {
+		return absPath
+	}
+	return rel
+}
+
+// listFilter 返回文件列表的可见性过滤函数：目录需有浏览权限，文件需有读权限
+func listFilter(c *gin.Context) func(absPath string, isDir bool) bool {
+	return func(absPath string, isDir bool) bool {
+		if isDir {
+			return Allowed(c, relUploadPath(absPath), store.PermList)
+		}
+		return Allowed(c, relUploadPath(absPath), store.PermRead)
+	}
+}
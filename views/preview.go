@@ -28,14 +28,20 @@ func PreviewFile(c *gin.Context) {
 	}
 	// 去掉路径开头的/（避免拼接后出现//）
 	relPath = strings.TrimPrefix(relPath, "/")
+	middleware.SetAuditFile(c, relPath, 0, "")
 
 	// 2. 拼接文件绝对路径 + 路径安全校验（防止../../等路径遍历）
 	rootUploadDir := config.GlobalConfig.UploadDir // 你的根上传目录（确保是绝对路径）
 	absFilePath := filepath.Join(rootUploadDir, relPath)
 	// 关键：校验拼接后的路径是否在根上传目录内（防止路径遍历攻击）
-	if !strings.HasPrefix(filepath.Clean(absFilePath), filepath.Clean(rootUploadDir)) {
+	if !utils.IsWithinDir(rootUploadDir, absFilePath) {
 		renderError(c, "预览失败：非法文件路径（禁止访问上传目录外的文件）")
-		middleware.Logger.Warn("非法文件路径访问", zap.String("absFilePath", absFilePath), zap.String("rootDir", rootUploadDir))
+		middleware.Log(c).Warn("非法文件路径访问", zap.String("absFilePath", absFilePath), zap.String("rootDir", rootUploadDir))
+		return
+	}
+	// 未完成上传的临时文件（.part）和隐藏文件/目录（上传会话元数据、隔离目录等）与文件列表一致，不提供预览
+	if hiddenUploadPath(relPath) {
+		renderError(c, "预览失败：文件不存在")
 		return
 	}
 
@@ -47,7 +53,7 @@ func PreviewFile(c *gin.Context) {
 		} else {
 			renderError(c, "预览失败：获取文件信息失败："+err.Error())
 		}
-		middleware.Logger.Warn("文件基础校验失败", zap.String("filePath", absFilePath), zap.Error(err))
+		middleware.Log(c).Warn("文件基础校验失败", zap.String("filePath", absFilePath), zap.Error(err))
 		return
 	}
 	// 校验是否为普通文件（排除目录、设备文件等）
@@ -81,10 +87,12 @@ func PreviewFile(c *gin.Context) {
 	content, err := os.ReadFile(absFilePath)
 	if err != nil {
 		renderError(c, "预览失败：读取文件内容失败："+err.Error())
-		middleware.Logger.Warn("读取文件内容失败", zap.String("filePath", absFilePath), zap.Error(err))
+		middleware.Log(c).Warn("读取文件内容失败", zap.String("filePath", absFilePath), zap.Error(err))
 		return
 	}
 
+	middleware.SetAuditFile(c, relPath, fileSize, contentHash(content))
+
 	// 7. 渲染预览页面（传递文件名、路径、内容）
 	c.HTML(200, "preview.html", gin.H{
 		"fileName": filepath.Base(absFilePath), // 文件名（如a.txt）
@@ -0,0 +1,906 @@
+package views
+
+import (
+	. "SimpleHttpServer/config"
+	"SimpleHttpServer/hooks"
+	"SimpleHttpServer/metrics"
+	. "SimpleHttpServer/middleware"
+	"SimpleHttpServer/store"
+	. "SimpleHttpServer/utils"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3兼容接口（路径风格：http://host:<s3端口>/<bucket>/<key>）
+// 桶对应上传根目录下的一级目录，对象键对应桶目录内的相对路径；
+// 隐藏文件、.part临时文件与文件列表一致，不出现在列表中也不可访问。
+// 支持：ListBuckets、CreateBucket、DeleteBucket、HeadBucket、GetBucketLocation、
+// ListObjects(V1/V2)、GetObject(Range)、HeadObject、PutObject、CopyObject、DeleteObject、DeleteObjects，
+// 以及分段上传（见s3_multipart.go）。对象自定义元数据（x-amz-meta-*）不保存
+
+// s3MaxKeys ListObjects单次最多返回的条目数
+const s3MaxKeys = 1000
+
+// s3UnsupportedSubresources 不支持的子资源（ACL、标签、版本、策略等），请求时返回501
+var s3UnsupportedSubresources = []string{
+	"acl", "tagging", "policy", "cors", "lifecycle", "versioning", "versions", "website",
+	"encryption", "object-lock", "retention", "legal-hold", "replication", "notification", "logging",
+	"torrent", "restore", "select", "attributes",
+}
+
+// s3Error S3错误响应（XML格式，HTTP状态码+错误码+说明）
+type s3Error struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *s3Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+func newS3Error(status int, code, message string) *s3Error {
+	return &s3Error{Status: status, Code: code, Message: message}
+}
+
+// respondS3Error 记录错误日志并返回S3格式的XML错误响应
+func respondS3Error(c *gin.Context, s3Err *s3Error, fields ...zap.Field) {
+	fields = append(fields, zap.String("code", s3Err.Code), zap.Int("status", s3Err.Status))
+	Log(c).Error("S3请求失败: "+s3Err.Message, fields...)
+	c.XML(s3Err.Status, struct {
+		XMLName   xml.Name `xml:"Error"`
+		Code      string
+		Message   string
+		Resource  string
+		RequestId string
+	}{
+		Code:      s3Err.Code,
+		Message:   s3Err.Message,
+		Resource:  c.Request.URL.Path,
+		RequestId: c.GetString("s3RequestID"),
+	})
+}
+
+// respondS3WriteError 将写入对象时的错误转换为S3错误响应（请求体校验失败、长度不符、超出大小限制等）
+func respondS3WriteError(c *gin.Context, err error, fields ...zap.Field) {
+	var s3Err *s3Error
+	if !errors.As(err, &s3Err) {
+		s3Err = newS3Error(http.StatusInternalServerError, "InternalError", fmt.Sprintf("写入对象失败: %v", err))
+	}
+	respondS3Error(c, s3Err, append(fields, zap.Error(err))...)
+}
+
+// s3FailReason 写入对象失败时的监控指标原因（shs_uploads_failed_total的reason标签）
+func s3FailReason(err error) string {
+	var s3Err *s3Error
+	if errors.As(err, &s3Err) {
+		switch s3Err.Code {
+		case "EntityTooLarge":
+			return metrics.ReasonTooLarge
+		case "BadDigest":
+			return metrics.ReasonChecksumMismatch
+		}
+	}
+	return metrics.ReasonWriteError
+}
+
+// S3Handler S3接口统一入口（路由：/*path），按桶/对象、HTTP方法和子资源分发
+func S3Handler(c *gin.Context) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(c.Param("path"), "/"), "/")
+	query := c.Request.URL.Query()
+	for _, sub := range s3UnsupportedSubresources {
+		if query.Has(sub) {
+			respondS3Error(c, newS3Error(http.StatusNotImplemented, "NotImplemented", fmt.Sprintf("不支持的操作: ?%s", sub)))
+			return
+		}
+	}
+	method := c.Request.Method
+	// 记录审计日志（路径为/<桶>/<键>，处理函数可补充大小、摘要；批量删除按对象逐条记录）
+	if action := s3AuditAction(c, bucket, key); action != "" {
+		StartAudit(c, action, "s3")
+		defer FinishAudit(c)
+	}
+	// 按访问密钥关联用户的角色和目录访问规则授权（查询桶列表按桶过滤，批量删除按对象逐个校验）
+	if perm := s3Permission(c, bucket, key); perm != "" && !Allowed(c, path.Join(bucket, key), perm) {
+		respondS3Error(c, newS3Error(http.StatusForbidden, "AccessDenied", "没有权限执行该操作"),
+			zap.String("user", currentUser(c)),
+			zap.String("permission", string(perm)),
+		)
+		return
+	}
+
+	// ========== 1. 服务级操作 ==========
+	if bucket == "" {
+		if method == http.MethodGet {
+			s3ListBuckets(c)
+			return
+		}
+		respondS3Error(c, newS3Error(http.StatusMethodNotAllowed, "MethodNotAllowed", "不支持的操作"))
+		return
+	}
+
+	// ========== 2. 桶级操作 ==========
+	if key == "" {
+		if method == http.MethodPut {
+			s3CreateBucket(c, bucket)
+			return
+		}
+		bucketDir, ok := resolveS3Bucket(c, bucket)
+		if !ok {
+			return
+		}
+		switch {
+		case method == http.MethodGet && query.Has("location"):
+			c.XML(http.StatusOK, struct {
+				XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ LocationConstraint"`
+			}{})
+		case method == http.MethodGet && query.Has("uploads"):
+			s3ListMultipartUploads(c, bucket, bucketDir)
+		case method == http.MethodGet:
+			s3ListObjects(c, bucket, bucketDir)
+		case method == http.MethodHead:
+			c.Status(http.StatusOK)
+		case method == http.MethodDelete:
+			s3DeleteBucket(c, bucket, bucketDir)
+		case method == http.MethodPost && query.Has("delete"):
+			s3DeleteObjects(c, bucketDir)
+		default:
+			respondS3Error(c, newS3Error(http.StatusMethodNotAllowed, "MethodNotAllowed", "不支持的操作"))
+		}
+		return
+	}
+
+	// ========== 3. 对象级操作 ==========
+	bucketDir, ok := resolveS3Bucket(c, bucket)
+	if !ok {
+		return
+	}
+	uploadID := query.Get("uploadId")
+	switch {
+	case method == http.MethodPost && query.Has("uploads"):
+		s3CreateMultipartUpload(c, bucket, key, bucketDir)
+	case method == http.MethodPost && uploadID != "":
+		s3CompleteMultipartUpload(c, bucket, key, bucketDir, uploadID)
+	case method == http.MethodPut && uploadID != "":
+		s3UploadPart(c, key, bucketDir, uploadID)
+	case method == http.MethodPut && c.GetHeader("X-Amz-Copy-Source") != "":
+		s3CopyObject(c, key, bucketDir)
+	case method == http.MethodPut:
+		s3PutObject(c, key, bucketDir)
+	case method == http.MethodGet && uploadID != "":
+		s3ListParts(c, bucket, key, bucketDir, uploadID)
+	case method == http.MethodGet || method == http.MethodHead:
+		s3GetObject(c, key, bucketDir)
+	case method == http.MethodDelete && uploadID != "":
+		s3AbortMultipartUpload(c, key, bucketDir, uploadID)
+	case method == http.MethodDelete:
+		s3DeleteObject(c, key, bucketDir)
+	default:
+		respondS3Error(c, newS3Error(http.StatusMethodNotAllowed, "MethodNotAllowed", "不支持的操作"))
+	}
+}
+
+// s3AuditAction 返回S3请求对应的审计操作，不记录的请求（查询桶列表、HEAD、分段上传的各段等）返回空
+func s3AuditAction(c *gin.Context, bucket, key string) store.AuditAction {
+	query := c.Request.URL.Query()
+	uploadID := query.Get("uploadId")
+	switch method := c.Request.Method; {
+	case bucket == "":
+		return ""
+	case key == "" && method == http.MethodPut:
+		return store.AuditMkdir
+	case key == "" && method == http.MethodGet && !query.Has("location") && !query.Has("uploads"):
+		return store.AuditList
+	case key == "" && method == http.MethodDelete:
+		return store.AuditDelete
+	case key == "":
+		return ""
+	case method == http.MethodPost && uploadID != "":
+		return store.AuditUpload
+	case method == http.MethodPut && uploadID != "":
+		return ""
+	case method == http.MethodPut && c.GetHeader("X-Amz-Copy-Source") != "":
+		return store.AuditCopy
+	case method == http.MethodPut && strings.HasSuffix(key, "/"):
+		return store.AuditMkdir
+	case method == http.MethodPut:
+		return store.AuditUpload
+	case method == http.MethodGet && uploadID == "":
+		return store.AuditDownload
+	case method == http.MethodDelete && uploadID != "":
+		return store.AuditUpload
+	case method == http.MethodDelete:
+		return store.AuditDelete
+	}
+	return ""
+}
+
+// s3Permission 返回S3请求需要的权限（桶对应目录，对象对应文件），无需整体校验的请求（查询桶列表、批量删除）返回空
+func s3Permission(c *gin.Context, bucket, key string) store.Permission {
+	query := c.Request.URL.Query()
+	switch method := c.Request.Method; {
+	case bucket == "":
+		return ""
+	case key == "" && method == http.MethodPut:
+		return store.PermUpload
+	case key == "" && method == http.MethodDelete:
+		return store.PermDelete
+	case key == "" && method == http.MethodPost && query.Has("delete"):
+		return ""
+	case key == "":
+		return store.PermList
+	case (method == http.MethodGet || method == http.MethodHead) && !query.Has("uploadId"):
+		return store.PermRead
+	case method == http.MethodDelete && !query.Has("uploadId"):
+		return store.PermDelete
+	}
+	// 写入、复制及分段上传的各步骤
+	return store.PermUpload
+}
+
+// validS3BucketName 校验桶名：非空、不含路径分隔符，且不是隐藏目录或.part
+func validS3BucketName(bucket string) bool {
+	return bucket != "" && bucket != ".." && !strings.ContainsAny(bucket, `/\`) && !hiddenUploadPath(bucket)
+}
+
+// resolveS3Bucket 返回桶对应的目录（上传根目录下已存在的一级目录），校验失败时已写入错误响应
+func resolveS3Bucket(c *gin.Context, bucket string) (string, bool) {
+	root, err := filepath.Abs(GlobalConfig.UploadDir)
+	if err != nil {
+		respondS3Error(c, newS3Error(http.StatusInternalServerError, "InternalError", fmt.Sprintf("获取root目录绝对路径失败: %v", err)))
+		return "", false
+	}
+	if !validS3BucketName(bucket) {
+		respondS3Error(c, newS3Error(http.StatusBadRequest, "InvalidBucketName", fmt.Sprintf("桶名非法: %s", bucket)))
+		return "", false
+	}
+	bucketDir := filepath.Join(root, bucket)
+	if info, err := os.Stat(bucketDir); err != nil || !info.IsDir() {
+		respondS3Error(c, newS3Error(http.StatusNotFound, "NoSuchBucket", fmt.Sprintf("桶不存在: %s", bucket)),
+			zap.String("bucketDir", bucketDir),
+		)
+		return "", false
+	}
+	return bucketDir, true
+}
+
+// resolveS3Key 将对象键转换为桶目录内的文件路径
+// 键中含空路径段、.、..（规范化后会改变）或隐藏/.part路径段时返回false
+func resolveS3Key(bucketDir, key string) (string, bool) {
+	trimmed := strings.TrimSuffix(key, "/")
+	if trimmed == "" || path.Clean("/"+trimmed) != "/"+trimmed || hiddenUploadPath(trimmed) {
+		return "", false
+	}
+	target, err := SafeJoin(bucketDir, filepath.FromSlash(trimmed))
+	if err != nil {
+		return "", false
+	}
+	return target, true
+}
+
+// s3ObjectETag 对象ETag（与下载接口一致，按大小和修改时间生成，无需读取文件内容）
+func s3ObjectETag(info os.FileInfo) string {
+	return FileETag(info)
+}
+
+// s3ObjectSize 返回请求声明的对象大小（aws-chunked编码时为解码后的长度），未知时返回-1
+func s3ObjectSize(r *http.Request) int64 {
+	if decoded := r.Header.Get("X-Amz-Decoded-Content-Length"); decoded != "" {
+		if size, err := strconv.ParseInt(decoded, 10, 64); err == nil {
+			return size
+		}
+	}
+	return r.ContentLength
+}
+
+// writeS3File 将src写入target同目录的隐藏临时文件（.<文件名>.<随机串>.part），
+// 校验长度（size为-1时不校验）、大小限制和Content-MD5（Base64，为空时不校验）后落盘并原子重命名为target，
+// 返回内容MD5（十六进制）和写入字节数
+func writeS3File(target string, src io.Reader, size int64, contentMD5 string) (string, int64, error) {
+	maxSize := GlobalConfig.MaxUploadSize()
+	if size > maxSize {
+		return "", 0, newS3Error(http.StatusBadRequest, "EntityTooLarge", fmt.Sprintf("对象大小超过限制（最大支持%s）", FormatSize(maxSize)))
+	}
+	tmpPath := filepath.Join(filepath.Dir(target), "."+filepath.Base(target)+"."+NewUploadID()[:8]+".part")
+	f, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return "", 0, err
+	}
+	fail := func(err error) (string, int64, error) {
+		f.Close()
+		os.Remove(tmpPath)
+		return "", 0, err
+	}
+
+	h := md5.New()
+	written, err := io.Copy(io.MultiWriter(f, h), io.LimitReader(src, maxSize+1))
+	if err != nil {
+		return fail(err)
+	}
+	if written > maxSize {
+		return fail(newS3Error(http.StatusBadRequest, "EntityTooLarge", fmt.Sprintf("对象大小超过限制（最大支持%s）", FormatSize(maxSize))))
+	}
+	if size >= 0 && written != size {
+		return fail(newS3Error(http.StatusBadRequest, "IncompleteBody", fmt.Sprintf("数据不完整，应为%d字节，实际收到%d字节", size, written)))
+	}
+	sum := h.Sum(nil)
+	if contentMD5 != "" && contentMD5 != base64.StdEncoding.EncodeToString(sum) {
+		return fail(newS3Error(http.StatusBadRequest, "BadDigest", "数据MD5与Content-MD5不一致"))
+	}
+	if err := f.Sync(); err != nil {
+		return fail(err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmpPath)
+		return "", 0, err
+	}
+	if err := os.Rename(tmpPath, target); err != nil {
+		os.Remove(tmpPath)
+		return "", 0, err
+	}
+	if err := SyncDir(filepath.Dir(target)); err != nil {
+		Logger.Warn("同步上传目录失败", zap.String("filePath", target), zap.Error(err))
+	}
+	return hex.EncodeToString(sum), written, nil
+}
+
+// s3ListBuckets 列出所有桶（上传根目录下的一级目录）
+func s3ListBuckets(c *gin.Context) {
+	entries, err := os.ReadDir(GlobalConfig.UploadDir)
+	if err != nil {
+		respondS3Error(c, newS3Error(http.StatusInternalServerError, "InternalError", fmt.Sprintf("读取上传目录失败: %v", err)))
+		return
+	}
+	type bucketEntry struct {
+		Name         string
+		CreationDate string
+	}
+	buckets := []bucketEntry{}
+	for _, entry := range entries {
+		if !entry.IsDir() || !validS3BucketName(entry.Name()) || !Allowed(c, entry.Name(), store.PermList) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		buckets = append(buckets, bucketEntry{Name: entry.Name(), CreationDate: info.ModTime().UTC().Format(time.RFC3339)})
+	}
+	owner := currentUser(c)
+	c.XML(http.StatusOK, struct {
+		XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListAllMyBucketsResult"`
+		Owner   struct{ ID, DisplayName string }
+		Buckets []bucketEntry `xml:"Buckets>Bucket"`
+	}{
+		Owner:   struct{ ID, DisplayName string }{owner, owner},
+		Buckets: buckets,
+	})
+}
+
+// s3CreateBucket 创建桶（在上传根目录下创建一级目录）
+func s3CreateBucket(c *gin.Context, bucket string) {
+	if !validS3BucketName(bucket) {
+		respondS3Error(c, newS3Error(http.StatusBadRequest, "InvalidBucketName", fmt.Sprintf("桶名非法: %s", bucket)))
+		return
+	}
+	bucketDir := filepath.Join(GlobalConfig.UploadDir, bucket)
+	if err := os.Mkdir(bucketDir, 0755); err != nil {
+		if os.IsExist(err) {
+			respondS3Error(c, newS3Error(http.StatusConflict, "BucketAlreadyOwnedByYou", fmt.Sprintf("桶已存在: %s", bucket)))
+			return
+		}
+		respondS3Error(c, newS3Error(http.StatusInternalServerError, "InternalError", fmt.Sprintf("创建桶失败: %v", err)))
+		return
+	}
+	Log(c).Info("S3创建桶", zap.String("bucket", bucket), zap.String("user", currentUser(c)))
+	c.Header("Location", "/"+bucket)
+	c.Status(http.StatusOK)
+}
+
+// s3DeleteBucket 删除桶（仅允许删除空目录）
+func s3DeleteBucket(c *gin.Context, bucket, bucketDir string) {
+	if err := os.Remove(bucketDir); err != nil {
+		respondS3Error(c, newS3Error(http.StatusConflict, "BucketNotEmpty", fmt.Sprintf("桶不为空，无法删除: %s", bucket)), zap.Error(err))
+		return
+	}
+	Log(c).Info("S3删除桶", zap.String("bucket", bucket), zap.String("user", currentUser(c)))
+	c.Status(http.StatusNoContent)
+	fireHook(c, hooks.EventDelete, "s3", bucketDir, "", 0, "")
+}
+
+// s3ListEntry 列表中的一项：对象或公共前缀（按分隔符折叠的“目录”）
+type s3ListEntry struct {
+	key      string
+	info     os.FileInfo
+	isPrefix bool
+}
+
+// s3ObjectXML ListObjects响应中的对象
+type s3ObjectXML struct {
+	Key          string
+	LastModified string
+	ETag         string
+	Size         int64
+	StorageClass string
+}
+
+// s3PrefixXML ListObjects响应中的公共前缀
+type s3PrefixXML struct {
+	Prefix string
+}
+
+// s3ListObjects 列出桶内对象（GET /<bucket>，list-type=2为V2，否则为V1）
+func s3ListObjects(c *gin.Context, bucket, bucketDir string) {
+	query := c.Request.URL.Query()
+	prefix, delimiter := query.Get("prefix"), query.Get("delimiter")
+	v2 := query.Get("list-type") == "2"
+	maxKeys := s3MaxKeys
+	if value := query.Get("max-keys"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			respondS3Error(c, newS3Error(http.StatusBadRequest, "InvalidArgument", fmt.Sprintf("max-keys无效: %s", value)))
+			return
+		}
+		maxKeys = min(n, s3MaxKeys)
+	}
+	after := query.Get("marker")
+	if v2 {
+		after = query.Get("start-after")
+		if token := query.Get("continuation-token"); token != "" {
+			decoded, err := base64.RawURLEncoding.DecodeString(token)
+			if err != nil {
+				respondS3Error(c, newS3Error(http.StatusBadRequest, "InvalidArgument", "continuation-token无效"))
+				return
+			}
+			after = string(decoded)
+		}
+	}
+
+	entries, err := collectS3Entries(bucketDir, prefix, delimiter)
+	if err != nil {
+		respondS3Error(c, newS3Error(http.StatusInternalServerError, "InternalError", fmt.Sprintf("读取目录失败: %v", err)),
+			zap.String("bucket", bucket),
+			zap.String("prefix", prefix),
+		)
+		return
+	}
+	// 按权限过滤：公共前缀（目录）需要列出权限，对象需要读取权限
+	entries = slices.DeleteFunc(entries, func(entry s3ListEntry) bool {
+		if entry.isPrefix {
+			return !Allowed(c, path.Join(bucket, entry.key), store.PermList)
+		}
+		return !Allowed(c, path.Join(bucket, entry.key), store.PermRead)
+	})
+
+	// 跳过marker之前的条目（marker为公共前缀时跳过整个前缀下的内容），截取maxKeys条
+	start := sort.Search(len(entries), func(i int) bool { return entries[i].key > after })
+	for start < len(entries) && entries[start].isPrefix && after != "" && strings.HasPrefix(after, entries[start].key) {
+		start++
+	}
+	entries = entries[start:]
+	truncated := len(entries) > maxKeys
+	if truncated {
+		entries = entries[:maxKeys]
+	}
+
+	// encoding-type=url时对象键和前缀需URL编码（aws-cli默认使用）
+	encode := func(s string) string { return s }
+	if query.Get("encoding-type") == "url" {
+		encode = func(s string) string { return strings.ReplaceAll(url.QueryEscape(s), "%2F", "/") }
+	}
+	contents, prefixes := []s3ObjectXML{}, []s3PrefixXML{}
+	for _, entry := range entries {
+		if entry.isPrefix {
+			prefixes = append(prefixes, s3PrefixXML{Prefix: encode(entry.key)})
+			continue
+		}
+		contents = append(contents, s3ObjectXML{
+			Key:          encode(entry.key),
+			LastModified: entry.info.ModTime().UTC().Format("2006-01-02T15:04:05.000Z"),
+			ETag:         s3ObjectETag(entry.info),
+			Size:         entry.info.Size(),
+			StorageClass: "STANDARD",
+		})
+	}
+	nextMarker := ""
+	if truncated {
+		nextMarker = entries[len(entries)-1].key
+	}
+
+	type listResult struct {
+		XMLName               xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+		Name                  string
+		Prefix                string
+		Delimiter             string `xml:",omitempty"`
+		EncodingType          string `xml:",omitempty"`
+		Marker                string `xml:",omitempty"`
+		NextMarker            string `xml:",omitempty"`
+		StartAfter            string `xml:",omitempty"`
+		ContinuationToken     string `xml:",omitempty"`
+		NextContinuationToken string `xml:",omitempty"`
+		KeyCount              int    `xml:",omitempty"`
+		MaxKeys               int
+		IsTruncated           bool
+		Contents              []s3ObjectXML
+		CommonPrefixes        []s3PrefixXML
+	}
+	result := listResult{
+		Name:           bucket,
+		Prefix:         encode(prefix),
+		Delimiter:      encode(delimiter),
+		EncodingType:   query.Get("encoding-type"),
+		MaxKeys:        maxKeys,
+		IsTruncated:    truncated,
+		Contents:       contents,
+		CommonPrefixes: prefixes,
+	}
+	if v2 {
+		result.StartAfter = encode(query.Get("start-after"))
+		result.ContinuationToken = query.Get("continuation-token")
+		result.KeyCount = len(entries)
+		if truncated {
+			result.NextContinuationToken = base64.RawURLEncoding.EncodeToString([]byte(nextMarker))
+		}
+	} else {
+		result.Marker = encode(query.Get("marker"))
+		if truncated && delimiter != "" {
+			result.NextMarker = encode(nextMarker)
+		}
+	}
+	c.XML(http.StatusOK, result)
+}
+
+// collectS3Entries 收集桶内以prefix开头的对象（按键升序），有分隔符时将分隔符之后的部分折叠为公共前缀
+// 分隔符为/时只读取prefix所在的一级目录，子目录直接作为公共前缀，无需遍历整棵目录树
+func collectS3Entries(bucketDir, prefix, delimiter string) ([]s3ListEntry, error) {
+	baseRel := prefix[:strings.LastIndex(prefix, "/")+1]
+	baseDir, ok := resolveS3Key(bucketDir, baseRel)
+	if baseRel == "" {
+		baseDir, ok = bucketDir, true
+	}
+	if !ok {
+		return nil, nil
+	}
+
+	var entries []s3ListEntry
+	seen := map[string]bool{}
+	add := func(key string, info os.FileInfo) {
+		if !strings.HasPrefix(key, prefix) {
+			return
+		}
+		if delimiter != "" {
+			if index := strings.Index(key[len(prefix):], delimiter); index >= 0 {
+				commonPrefix := key[:len(prefix)+index+len(delimiter)]
+				if !seen[commonPrefix] {
+					seen[commonPrefix] = true
+					entries = append(entries, s3ListEntry{key: commonPrefix, isPrefix: true})
+				}
+				return
+			}
+		}
+		if info != nil {
+			entries = append(entries, s3ListEntry{key: key, info: info})
+		}
+	}
+
+	if delimiter == "/" {
+		dirEntries, err := os.ReadDir(baseDir)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		for _, entry := range dirEntries {
+			if hiddenUploadPath(entry.Name()) {
+				continue
+			}
+			if entry.IsDir() {
+				add(baseRel+entry.Name()+"/", nil)
+				continue
+			}
+			if info, err := entry.Info(); err == nil && info.Mode().IsRegular() {
+				add(baseRel+entry.Name(), info)
+			}
+		}
+	} else {
+		err := filepath.WalkDir(baseDir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if p != baseDir && hiddenUploadPath(d.Name()) {
+				if d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil || !info.Mode().IsRegular() {
+				return nil
+			}
+			rel, _ := filepath.Rel(bucketDir, p)
+			add(filepath.ToSlash(rel), info)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+	return entries, nil
+}
+
+// s3GetObject 下载对象（GET/HEAD /<bucket>/<key>），由ServeContent处理Range及条件请求
+func s3GetObject(c *gin.Context, key, bucketDir string) {
+	target, ok := resolveS3Key(bucketDir, key)
+	if !ok || strings.HasSuffix(key, "/") {
+		respondS3Error(c, newS3Error(http.StatusNotFound, "NoSuchKey", fmt.Sprintf("对象不存在: %s", key)))
+		return
+	}
+	f, err := os.Open(target)
+	if err != nil {
+		respondS3Error(c, newS3Error(http.StatusNotFound, "NoSuchKey", fmt.Sprintf("对象不存在: %s", key)), zap.Error(err))
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		respondS3Error(c, newS3Error(http.StatusNotFound, "NoSuchKey", fmt.Sprintf("对象不存在: %s", key)))
+		return
+	}
+
+	contentType := mime.TypeByExtension(filepath.Ext(target))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("ETag", s3ObjectETag(info))
+	c.Header("Accept-Ranges", "bytes")
+	// 预签名URL常用response-*参数覆盖响应头（如下载文件名）
+	for param, header := range map[string]string{
+		"response-content-type":        "Content-Type",
+		"response-content-disposition": "Content-Disposition",
+		"response-content-encoding":    "Content-Encoding",
+		"response-content-language":    "Content-Language",
+		"response-cache-control":       "Cache-Control",
+		"response-expires":             "Expires",
+	} {
+		if value := c.Query(param); value != "" {
+			c.Header(header, value)
+		}
+	}
+	SetAuditFile(c, target, info.Size(), "")
+	http.ServeContent(c.Writer, c.Request, info.Name(), info.ModTime(), f)
+	metrics.DownloadBytes.Add(float64(max(c.Writer.Size(), 0)), "s3")
+}
+
+// s3PutObject 上传对象（PUT /<bucket>/<key>），数据先写入临时文件，完整接收后原子替换目标文件；
+// 以/结尾的空对象视为创建目录
+func s3PutObject(c *gin.Context, key, bucketDir string) {
+	target, ok := resolveS3Key(bucketDir, key)
+	if !ok {
+		respondS3Error(c, newS3Error(http.StatusBadRequest, "InvalidArgument", fmt.Sprintf("对象名非法: %s", key)))
+		return
+	}
+	size := s3ObjectSize(c.Request)
+	if strings.HasSuffix(key, "/") {
+		if size > 0 {
+			respondS3Error(c, newS3Error(http.StatusBadRequest, "InvalidArgument", "以/结尾的对象（目录）内容必须为空"))
+			return
+		}
+		if err := os.MkdirAll(target, 0755); err != nil {
+			respondS3Error(c, newS3Error(http.StatusInternalServerError, "InternalError", fmt.Sprintf("创建目录失败: %v", err)))
+			return
+		}
+		c.Header("ETag", `"`+s3EmptyMD5+`"`)
+		c.Status(http.StatusOK)
+		return
+	}
+	if info, err := os.Stat(target); err == nil && info.IsDir() {
+		respondS3Error(c, newS3Error(http.StatusConflict, "InvalidArgument", fmt.Sprintf("同名目录已存在: %s", key)))
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		respondS3Error(c, newS3Error(http.StatusInternalServerError, "InternalError", fmt.Sprintf("创建目录失败: %v", err)))
+		return
+	}
+
+	md5Hex, written, err := writeS3File(target, c.Request.Body, size, c.GetHeader("Content-MD5"))
+	if err != nil {
+		metrics.UploadsFailed.Inc("s3", s3FailReason(err))
+		SetAuditFailure(c, s3FailReason(err))
+		respondS3WriteError(c, err, zap.String("filePath", target))
+		return
+	}
+	metrics.UploadBytes.Add(float64(written), "s3")
+	metrics.UploadsCompleted.Inc("s3")
+	SetAuditFile(c, target, written, "md5:"+md5Hex)
+	Log(c).Info("S3对象上传完成",
+		zap.String("user", currentUser(c)),
+		zap.String("filePath", target),
+		zap.String("size", FormatSize(written)),
+		zap.String("md5", md5Hex),
+	)
+	if info, err := os.Stat(target); err == nil {
+		c.Header("ETag", s3ObjectETag(info))
+	}
+	c.Status(http.StatusOK)
+	fireHook(c, hooks.EventUpload, "s3", target, "", written, "md5:"+md5Hex)
+}
+
+// s3EmptyMD5 空内容的MD5
+const s3EmptyMD5 = "d41d8cd98f00b204e9800998ecf8427e"
+
+// s3CopyObject 服务端复制对象（PUT /<bucket>/<key>，请求头X-Amz-Copy-Source: /<源桶>/<源键>）
+func s3CopyObject(c *gin.Context, key, bucketDir string) {
+	target, ok := resolveS3Key(bucketDir, key)
+	if !ok || strings.HasSuffix(key, "/") {
+		respondS3Error(c, newS3Error(http.StatusBadRequest, "InvalidArgument", fmt.Sprintf("对象名非法: %s", key)))
+		return
+	}
+	source, err := url.PathUnescape(c.GetHeader("X-Amz-Copy-Source"))
+	if err != nil {
+		respondS3Error(c, newS3Error(http.StatusBadRequest, "InvalidArgument", "X-Amz-Copy-Source无效"))
+		return
+	}
+	source, _, _ = strings.Cut(source, "?versionId=")
+	srcBucket, srcKey, _ := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+	if !Allowed(c, path.Join(srcBucket, srcKey), store.PermRead) {
+		respondS3Error(c, newS3Error(http.StatusForbidden, "AccessDenied", fmt.Sprintf("没有权限读取源对象: %s", source)),
+			zap.String("user", currentUser(c)),
+		)
+		return
+	}
+	srcBucketDir, ok := resolveS3Bucket(c, srcBucket)
+	if !ok {
+		return
+	}
+	srcPath, ok := resolveS3Key(srcBucketDir, srcKey)
+	if !ok {
+		respondS3Error(c, newS3Error(http.StatusNotFound, "NoSuchKey", fmt.Sprintf("源对象不存在: %s", source)))
+		return
+	}
+	src, err := os.Open(srcPath)
+	if err != nil {
+		respondS3Error(c, newS3Error(http.StatusNotFound, "NoSuchKey", fmt.Sprintf("源对象不存在: %s", source)), zap.Error(err))
+		return
+	}
+	defer src.Close()
+	srcInfo, err := src.Stat()
+	if err != nil || !srcInfo.Mode().IsRegular() {
+		respondS3Error(c, newS3Error(http.StatusNotFound, "NoSuchKey", fmt.Sprintf("源对象不存在: %s", source)))
+		return
+	}
+	SetAuditFile(c, srcPath, srcInfo.Size(), "")
+	SetAuditTarget(c, target)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		respondS3Error(c, newS3Error(http.StatusInternalServerError, "InternalError", fmt.Sprintf("创建目录失败: %v", err)))
+		return
+	}
+	if _, _, err := writeS3File(target, src, srcInfo.Size(), ""); err != nil {
+		respondS3WriteError(c, err, zap.String("source", srcPath), zap.String("filePath", target))
+		return
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		respondS3Error(c, newS3Error(http.StatusInternalServerError, "InternalError", fmt.Sprintf("获取文件信息失败: %v", err)))
+		return
+	}
+	Log(c).Info("S3对象复制完成",
+		zap.String("user", currentUser(c)),
+		zap.String("source", srcPath),
+		zap.String("filePath", target),
+		zap.String("size", FormatSize(info.Size())),
+	)
+	c.XML(http.StatusOK, struct {
+		XMLName      xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CopyObjectResult"`
+		LastModified string
+		ETag         string
+	}{
+		LastModified: info.ModTime().UTC().Format("2006-01-02T15:04:05.000Z"),
+		ETag:         s3ObjectETag(info),
+	})
+}
+
+// removeS3Object 删除对象对应的文件（以/结尾的键删除空目录），同时作废写入该文件的上传会话
+// 对象不存在不视为错误（与S3一致）
+func removeS3Object(bucketDir, key string) error {
+	target, ok := resolveS3Key(bucketDir, key)
+	if !ok {
+		return nil
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return nil
+	}
+	if info.IsDir() != strings.HasSuffix(key, "/") {
+		return nil
+	}
+	dropUploadSessions(target)
+	os.Remove(journalPath(target))
+	if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// s3DeleteObject 删除对象（DELETE /<bucket>/<key>）
+func s3DeleteObject(c *gin.Context, key, bucketDir string) {
+	if err := removeS3Object(bucketDir, key); err != nil {
+		respondS3Error(c, newS3Error(http.StatusInternalServerError, "InternalError", fmt.Sprintf("删除对象失败: %v", err)),
+			zap.String("key", key),
+		)
+		return
+	}
+	Log(c).Info("S3对象已删除", zap.String("user", currentUser(c)), zap.String("bucketDir", bucketDir), zap.String("key", key))
+	c.Status(http.StatusNoContent)
+	fireHook(c, hooks.EventDelete, "s3", filepath.Join(bucketDir, key), "", 0, "")
+}
+
+// s3DeleteObjects 批量删除对象（POST /<bucket>?delete）
+func s3DeleteObjects(c *gin.Context, bucketDir string) {
+	var request struct {
+		Quiet   bool
+		Objects []struct{ Key string } `xml:"Object"`
+	}
+	if err := xml.NewDecoder(io.LimitReader(c.Request.Body, 2<<20)).Decode(&request); err != nil {
+		respondS3Error(c, newS3Error(http.StatusBadRequest, "MalformedXML", fmt.Sprintf("请求体XML格式错误: %v", err)))
+		return
+	}
+	type deleted struct{ Key string }
+	type deleteError struct{ Key, Code, Message string }
+	var result struct {
+		XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ DeleteResult"`
+		Deleted []deleted
+		Error   []deleteError
+	}
+	for _, object := range request.Objects {
+		entry := store.AuditEntry{Action: store.AuditDelete, Path: AuditPath(filepath.Join(bucketDir, object.Key)), Protocol: "s3"}
+		if !Allowed(c, path.Join(filepath.Base(bucketDir), object.Key), store.PermDelete) {
+			result.Error = append(result.Error, deleteError{Key: object.Key, Code: "AccessDenied", Message: "没有权限删除该对象"})
+			entry.Result, entry.Detail = store.AuditFailure, "没有权限删除该对象"
+			RecordAudit(c, entry)
+			continue
+		}
+		if err := removeS3Object(bucketDir, object.Key); err != nil {
+			result.Error = append(result.Error, deleteError{Key: object.Key, Code: "InternalError", Message: err.Error()})
+			entry.Result, entry.Detail = store.AuditFailure, err.Error()
+			RecordAudit(c, entry)
+			continue
+		}
+		RecordAudit(c, entry)
+		fireHook(c, hooks.EventDelete, "s3", entry.Path, "", 0, "")
+		if !request.Quiet {
+			result.Deleted = append(result.Deleted, deleted{Key: object.Key})
+		}
+	}
+	Log(c).Info("S3批量删除对象",
+		zap.String("user", currentUser(c)),
+		zap.String("bucketDir", bucketDir),
+		zap.Int("objects", len(request.Objects)),
+		zap.Int("errors", len(result.Error)),
+	)
+	c.XML(http.StatusOK, result)
+}
@@ -0,0 +1,532 @@
+package views
+
+import (
+	. "SimpleHttpServer/config"
+	. "SimpleHttpServer/middleware"
+	"SimpleHttpServer/store"
+	. "SimpleHttpServer/utils"
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"hash"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AWS Signature Version 4 签名校验（https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-authenticating-requests.html）
+// 支持Authorization请求头签名和预签名URL（查询参数签名），请求体支持：
+//   - 十六进制SHA256：读取完毕时校验摘要
+//   - UNSIGNED-PAYLOAD：不校验
+//   - STREAMING-AWS4-HMAC-SHA256-PAYLOAD(-TRAILER)：aws-chunked分段编码，逐段校验签名
+//   - STREAMING-UNSIGNED-PAYLOAD-TRAILER：aws-chunked分段编码，不签名，按尾部校验和校验
+const (
+	s3Algorithm                = "AWS4-HMAC-SHA256"
+	s3UnsignedPayload          = "UNSIGNED-PAYLOAD"
+	s3StreamingPayload         = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	s3StreamingPayloadTrailer  = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD-TRAILER"
+	s3StreamingUnsignedTrailer = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+	s3EmptySHA256              = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	s3TimeFormat               = "20060102T150405Z"
+	// s3MaxClockSkew 请求签名时间与服务器时间允许的最大偏差
+	s3MaxClockSkew = 15 * time.Minute
+	// s3MaxPresignExpires 预签名URL最长有效期（7天）
+	s3MaxPresignExpires = 7 * 24 * time.Hour
+)
+
+// s3Now 校验签名时间使用的当前时间（测试中替换为固定时间以使用AWS文档中的示例请求）
+var s3Now = time.Now
+
+// s3Signature 从请求中解析出的签名信息
+type s3Signature struct {
+	accessKey     string
+	secretKey     string
+	user          string // 访问密钥关联的用户名
+	amzDate       string // 签名时间（20060102T150405Z）
+	scope         string // 凭证范围：日期/区域/服务/aws4_request
+	signedHeaders []string
+	signature     string
+	payloadHash   string
+	presigned     bool
+}
+
+// S3AuthRequired S3接口认证中间件：校验SigV4签名，成功后将访问密钥关联的用户写入上下文（与网页登录一致，由S3Handler按该用户授权），
+// 并按X-Amz-Content-Sha256替换请求体，使后续读取时校验内容摘要或解码aws-chunked分段
+func S3AuthRequired(c *gin.Context) {
+	requestID := strings.ToUpper(NewUploadID()[:16])
+	c.Set("s3RequestID", requestID)
+	c.Header("x-amz-request-id", requestID)
+
+	sig, s3Err := verifyS3Signature(c.Request)
+	if s3Err != nil {
+		respondS3Error(c, s3Err,
+			zap.String("method", c.Request.Method),
+			zap.String("path", c.Request.URL.Path),
+			zap.String("clientIP", c.ClientIP()),
+		)
+		c.Abort()
+		return
+	}
+	user, err := store.Users.GetByName(sig.user)
+	if err != nil || user.Disabled {
+		respondS3Error(c, newS3Error(http.StatusForbidden, "AccessDenied", "访问密钥关联的用户不存在或已禁用"),
+			zap.String("accessKey", sig.accessKey),
+			zap.String("username", sig.user),
+		)
+		c.Abort()
+		return
+	}
+	if s3Err := wrapS3Body(c.Request, sig); s3Err != nil {
+		respondS3Error(c, s3Err, zap.String("accessKey", sig.accessKey))
+		c.Abort()
+		return
+	}
+	SetCurrentUser(c, user)
+	c.Next()
+}
+
+// verifyS3Signature 解析并校验请求签名
+func verifyS3Signature(r *http.Request) (*s3Signature, *s3Error) {
+	var sig *s3Signature
+	var s3Err *s3Error
+	switch {
+	case strings.HasPrefix(r.Header.Get("Authorization"), s3Algorithm+" "):
+		sig, s3Err = parseS3AuthHeader(r)
+	case r.URL.Query().Get("X-Amz-Algorithm") != "":
+		sig, s3Err = parseS3Presigned(r)
+	case r.Header.Get("Authorization") != "":
+		return nil, newS3Error(http.StatusBadRequest, "InvalidRequest", "仅支持AWS4-HMAC-SHA256签名")
+	default:
+		return nil, newS3Error(http.StatusForbidden, "AccessDenied", "请求未签名")
+	}
+	if s3Err != nil {
+		return nil, s3Err
+	}
+
+	key, ok := GlobalConfig.S3Keys[sig.accessKey]
+	if !ok {
+		return nil, newS3Error(http.StatusForbidden, "InvalidAccessKeyId", fmt.Sprintf("AccessKey不存在: %s", sig.accessKey))
+	}
+	sig.secretKey, sig.user = key.SecretKey, key.User
+	expected := s3Sign(sig, s3StringToSign(sig, s3CanonicalRequest(r, sig)))
+	if !hmac.Equal([]byte(expected), []byte(sig.signature)) {
+		return nil, newS3Error(http.StatusForbidden, "SignatureDoesNotMatch", "签名校验失败，请检查SecretKey")
+	}
+	return sig, nil
+}
+
+// parseS3AuthHeader 解析Authorization请求头：
+// AWS4-HMAC-SHA256 Credential=<AK>/<日期>/<区域>/s3/aws4_request, SignedHeaders=host;x-amz-date, Signature=<签名>
+func parseS3AuthHeader(r *http.Request) (*s3Signature, *s3Error) {
+	fields := map[string]string{}
+	for _, field := range strings.Split(strings.TrimPrefix(r.Header.Get("Authorization"), s3Algorithm), ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+		fields[key] = value
+	}
+	sig := &s3Signature{
+		amzDate:     r.Header.Get("X-Amz-Date"),
+		signature:   fields["Signature"],
+		payloadHash: r.Header.Get("X-Amz-Content-Sha256"),
+	}
+	if sig.amzDate == "" {
+		if date, err := http.ParseTime(r.Header.Get("Date")); err == nil {
+			sig.amzDate = date.UTC().Format(s3TimeFormat)
+		}
+	}
+	if sig.payloadHash == "" {
+		return nil, newS3Error(http.StatusBadRequest, "InvalidRequest", "缺少请求头X-Amz-Content-Sha256")
+	}
+	if s3Err := sig.parseCredential(fields["Credential"], fields["SignedHeaders"]); s3Err != nil {
+		return nil, s3Err
+	}
+	signedAt, _ := time.Parse(s3TimeFormat, sig.amzDate)
+	if skew := s3Now().Sub(signedAt); skew > s3MaxClockSkew || skew < -s3MaxClockSkew {
+		return nil, newS3Error(http.StatusForbidden, "RequestTimeTooSkewed", fmt.Sprintf("请求时间%s与服务器时间相差过大", sig.amzDate))
+	}
+	return sig, nil
+}
+
+// parseS3Presigned 解析预签名URL的查询参数（X-Amz-Credential、X-Amz-Date、X-Amz-Expires、X-Amz-SignedHeaders、X-Amz-Signature）
+func parseS3Presigned(r *http.Request) (*s3Signature, *s3Error) {
+	query := r.URL.Query()
+	if query.Get("X-Amz-Algorithm") != s3Algorithm {
+		return nil, newS3Error(http.StatusBadRequest, "InvalidRequest", "仅支持AWS4-HMAC-SHA256签名")
+	}
+	sig := &s3Signature{
+		amzDate:     query.Get("X-Amz-Date"),
+		signature:   query.Get("X-Amz-Signature"),
+		payloadHash: query.Get("X-Amz-Content-Sha256"),
+		presigned:   true,
+	}
+	if sig.payloadHash == "" {
+		sig.payloadHash = s3UnsignedPayload
+	}
+	if s3Err := sig.parseCredential(query.Get("X-Amz-Credential"), query.Get("X-Amz-SignedHeaders")); s3Err != nil {
+		return nil, s3Err
+	}
+	expires, err := strconv.Atoi(query.Get("X-Amz-Expires"))
+	if err != nil || expires <= 0 || time.Duration(expires)*time.Second > s3MaxPresignExpires {
+		return nil, newS3Error(http.StatusBadRequest, "AuthorizationQueryParametersError", "X-Amz-Expires无效（需为1~604800秒）")
+	}
+	signedAt, _ := time.Parse(s3TimeFormat, sig.amzDate)
+	if s3Now().After(signedAt.Add(time.Duration(expires) * time.Second)) {
+		return nil, newS3Error(http.StatusForbidden, "AccessDenied", "预签名URL已过期")
+	}
+	if signedAt.Sub(s3Now()) > s3MaxClockSkew {
+		return nil, newS3Error(http.StatusForbidden, "AccessDenied", "预签名URL签名时间晚于服务器时间")
+	}
+	return sig, nil
+}
+
+// parseCredential 解析凭证（<AK>/<日期>/<区域>/s3/aws4_request）和签名请求头列表，并校验签名时间格式
+// 签名必须覆盖host请求头（SigV4规定），否则签名可被转发到其他主机重放
+func (sig *s3Signature) parseCredential(credential, signedHeaders string) *s3Error {
+	parts := strings.Split(credential, "/")
+	if len(parts) != 5 || parts[3] != "s3" || parts[4] != "aws4_request" {
+		return newS3Error(http.StatusBadRequest, "AuthorizationHeaderMalformed", fmt.Sprintf("凭证格式错误: %s", credential))
+	}
+	if _, err := time.Parse(s3TimeFormat, sig.amzDate); err != nil || sig.amzDate[:8] != parts[1] {
+		return newS3Error(http.StatusBadRequest, "AuthorizationHeaderMalformed", fmt.Sprintf("签名时间无效或与凭证日期不一致: %s", sig.amzDate))
+	}
+	if signedHeaders == "" || sig.signature == "" {
+		return newS3Error(http.StatusBadRequest, "AuthorizationHeaderMalformed", "缺少SignedHeaders或Signature")
+	}
+	sig.accessKey = parts[0]
+	sig.scope = strings.Join(parts[1:], "/")
+	sig.signedHeaders = strings.Split(signedHeaders, ";")
+	if !slices.Contains(sig.signedHeaders, "host") {
+		return newS3Error(http.StatusBadRequest, "AuthorizationHeaderMalformed", "SignedHeaders必须包含host")
+	}
+	return nil
+}
+
+// s3CanonicalRequest 构造规范请求：方法、URI、查询串、签名请求头、请求头列表、请求体摘要，以换行分隔
+func s3CanonicalRequest(r *http.Request, sig *s3Signature) string {
+	var headers strings.Builder
+	for _, name := range sig.signedHeaders {
+		var value string
+		switch name {
+		case "host":
+			value = r.Host
+		case "content-length":
+			value = strconv.FormatInt(r.ContentLength, 10)
+		case "transfer-encoding":
+			value = strings.Join(r.TransferEncoding, ",")
+		default:
+			values := r.Header.Values(name)
+			for i, v := range values {
+				values[i] = strings.Join(strings.Fields(v), " ")
+			}
+			value = strings.Join(values, ",")
+		}
+		headers.WriteString(name + ":" + value + "\n")
+	}
+
+	query := r.URL.Query()
+	if sig.presigned {
+		query.Del("X-Amz-Signature")
+	}
+	pairs := make([]string, 0, len(query))
+	for key, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, s3URIEncode(key, true)+"="+s3URIEncode(value, true))
+		}
+	}
+	sort.Strings(pairs)
+
+	return strings.Join([]string{
+		r.Method,
+		s3URIEncode(r.URL.Path, false),
+		strings.Join(pairs, "&"),
+		headers.String(),
+		strings.Join(sig.signedHeaders, ";"),
+		sig.payloadHash,
+	}, "\n")
+}
+
+// s3StringToSign 构造待签名字符串
+func s3StringToSign(sig *s3Signature, canonicalRequest string) string {
+	return strings.Join([]string{s3Algorithm, sig.amzDate, sig.scope, s3SHA256Hex([]byte(canonicalRequest))}, "\n")
+}
+
+// s3Sign 用SecretKey派生的签名密钥（依次对日期、区域、服务、aws4_request做HMAC）计算签名
+func s3Sign(sig *s3Signature, stringToSign string) string {
+	key := []byte("AWS4" + sig.secretKey)
+	for _, part := range strings.Split(sig.scope, "/") {
+		key = s3HMAC(key, part)
+	}
+	return hex.EncodeToString(s3HMAC(key, stringToSign))
+}
+
+func s3HMAC(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func s3SHA256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// s3URIEncode 按SigV4规则编码：除字母、数字和 -_.~ 外全部百分号编码，encodeSlash为false时保留路径中的/
+func s3URIEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if 'A' <= ch && ch <= 'Z' || 'a' <= ch && ch <= 'z' || '0' <= ch && ch <= '9' ||
+			ch == '-' || ch == '_' || ch == '.' || ch == '~' || ch == '/' && !encodeSlash {
+			b.WriteByte(ch)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", ch)
+	}
+	return b.String()
+}
+
+// wrapS3Body 按X-Amz-Content-Sha256替换请求体：十六进制摘要在读取完毕时校验，aws-chunked编码在读取时解码并校验
+func wrapS3Body(r *http.Request, sig *s3Signature) *s3Error {
+	switch sig.payloadHash {
+	case s3UnsignedPayload:
+		return nil
+	case s3StreamingPayload, s3StreamingPayloadTrailer, s3StreamingUnsignedTrailer:
+		if r.Header.Get("X-Amz-Decoded-Content-Length") == "" {
+			return newS3Error(http.StatusLengthRequired, "MissingContentLength", "aws-chunked编码请求缺少X-Amz-Decoded-Content-Length")
+		}
+		reader := &s3ChunkedReader{
+			body:      bufio.NewReader(r.Body),
+			signed:    sig.payloadHash != s3StreamingUnsignedTrailer,
+			trailer:   sig.payloadHash != s3StreamingPayload,
+			sig:       sig,
+			prevSig:   sig.signature,
+			chunkHash: sha256.New(),
+		}
+		if name := strings.ToLower(r.Header.Get("X-Amz-Trailer")); name != "" {
+			reader.checksumName = name
+			reader.checksum = s3TrailerChecksumHash(strings.TrimPrefix(name, "x-amz-checksum-"))
+		}
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{reader, r.Body}
+		return nil
+	default:
+		expected, err := hex.DecodeString(sig.payloadHash)
+		if err != nil || len(expected) != sha256.Size {
+			return newS3Error(http.StatusBadRequest, "InvalidArgument", fmt.Sprintf("X-Amz-Content-Sha256无效: %s", sig.payloadHash))
+		}
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{&s3HashReader{body: r.Body, hash: sha256.New(), expected: expected}, r.Body}
+		return nil
+	}
+}
+
+// s3TrailerChecksumHash 按尾部校验和算法名创建哈希计算器，不支持的算法（如crc64nvme）返回nil，不做校验
+func s3TrailerChecksumHash(algo string) hash.Hash {
+	if algo == "crc32" {
+		return crc32.NewIEEE()
+	}
+	h, err := NewChecksumHash(algo)
+	if err != nil {
+		return nil
+	}
+	return h
+}
+
+// s3HashReader 读取请求体的同时计算SHA256，读到EOF时与签名中声明的摘要比对
+type s3HashReader struct {
+	body     io.Reader
+	hash     hash.Hash
+	expected []byte
+}
+
+func (r *s3HashReader) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	r.hash.Write(p[:n])
+	if err == io.EOF && !bytes.Equal(r.hash.Sum(nil), r.expected) {
+		return n, newS3Error(http.StatusBadRequest, "XAmzContentSHA256Mismatch", "请求体SHA256与X-Amz-Content-Sha256不一致")
+	}
+	return n, err
+}
+
+// s3ChunkedReader 解码aws-chunked请求体：<十六进制长度>[;chunk-signature=<签名>]\r\n<数据>\r\n ... 0[;chunk-signature=<签名>]\r\n[尾部请求头]\r\n
+// 签名模式下每段签名以上一段签名为种子（首段为请求签名）；尾部请求头携带整体校验和（x-amz-checksum-*）及尾部签名
+type s3ChunkedReader struct {
+	body         *bufio.Reader
+	signed       bool // 各段是否带签名
+	trailer      bool // 结束段后是否有尾部请求头
+	sig          *s3Signature
+	prevSig      string
+	chunkHash    hash.Hash // 当前段数据的SHA256
+	chunkSig     string    // 当前段声明的签名
+	remaining    int64     // 当前段未读取的字节数
+	inChunk      bool
+	checksumName string    // X-Amz-Trailer声明的校验和请求头名称
+	checksum     hash.Hash // 整体数据校验和（不支持的算法为nil）
+	err          error
+}
+
+func (r *s3ChunkedReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	for r.remaining == 0 {
+		if r.inChunk {
+			// 段数据结束：校验\r\n和段签名
+			if r.err = r.finishChunk(); r.err != nil {
+				return 0, r.err
+			}
+		}
+		if r.err = r.readChunkHeader(); r.err != nil {
+			return 0, r.err
+		}
+		if !r.inChunk {
+			// 结束段
+			r.err = r.finish()
+			return 0, r.err
+		}
+	}
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n, err := r.body.Read(p)
+	r.remaining -= int64(n)
+	r.chunkHash.Write(p[:n])
+	if r.checksum != nil {
+		r.checksum.Write(p[:n])
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		r.err = err
+	}
+	return n, err
+}
+
+// readChunkHeader 读取段头（长度及段签名），长度为0时inChunk为false表示结束段
+func (r *s3ChunkedReader) readChunkHeader() error {
+	line, err := r.readLine()
+	if err != nil {
+		return err
+	}
+	sizeStr, ext, _ := strings.Cut(line, ";")
+	size, err := strconv.ParseInt(strings.TrimSpace(sizeStr), 16, 64)
+	if err != nil || size < 0 {
+		return newS3Error(http.StatusBadRequest, "IncompleteBody", fmt.Sprintf("aws-chunked段头格式错误: %q", line))
+	}
+	if r.signed {
+		var ok bool
+		if r.chunkSig, ok = strings.CutPrefix(ext, "chunk-signature="); !ok {
+			return newS3Error(http.StatusBadRequest, "SignatureDoesNotMatch", "aws-chunked段缺少签名")
+		}
+	}
+	r.remaining = size
+	r.inChunk = size > 0
+	r.chunkHash.Reset()
+	if !r.inChunk {
+		// 结束段没有数据，签名在此校验
+		return r.verifyChunk()
+	}
+	return nil
+}
+
+// finishChunk 读取段数据后的\r\n并校验段签名
+func (r *s3ChunkedReader) finishChunk() error {
+	if line, err := r.readLine(); err != nil || line != "" {
+		return newS3Error(http.StatusBadRequest, "IncompleteBody", "aws-chunked段数据长度与段头不一致")
+	}
+	r.inChunk = false
+	return r.verifyChunk()
+}
+
+// verifyChunk 校验当前段签名：AWS4-HMAC-SHA256-PAYLOAD、时间、范围、上一段签名、空串摘要、段数据摘要
+func (r *s3ChunkedReader) verifyChunk() error {
+	if !r.signed {
+		return nil
+	}
+	stringToSign := strings.Join([]string{
+		s3Algorithm + "-PAYLOAD", r.sig.amzDate, r.sig.scope, r.prevSig, s3EmptySHA256, hex.EncodeToString(r.chunkHash.Sum(nil)),
+	}, "\n")
+	expected := s3Sign(r.sig, stringToSign)
+	if !hmac.Equal([]byte(expected), []byte(r.chunkSig)) {
+		return newS3Error(http.StatusForbidden, "SignatureDoesNotMatch", "aws-chunked段签名校验失败")
+	}
+	r.prevSig = expected
+	return nil
+}
+
+// finish 处理结束段之后的尾部请求头（校验和及尾部签名），返回io.EOF表示数据已完整读取
+func (r *s3ChunkedReader) finish() error {
+	if !r.trailer {
+		if line, err := r.readLine(); err != nil || line != "" {
+			return newS3Error(http.StatusBadRequest, "IncompleteBody", "aws-chunked结束段格式错误")
+		}
+		return io.EOF
+	}
+	var trailers strings.Builder
+	var checksumValue, trailerSig string
+	for {
+		line, err := r.readLine()
+		if err != nil {
+			return err
+		}
+		if line == "" {
+			// 尾部以空行结束；部分客户端（如minio-go）在尾部签名前多写一个空行，签名模式下读到尾部签名才结束
+			if r.signed && trailerSig == "" || !r.signed && r.checksumName != "" && checksumValue == "" {
+				continue
+			}
+			break
+		}
+		name, value, _ := strings.Cut(line, ":")
+		name, value = strings.ToLower(strings.TrimSpace(name)), strings.TrimSpace(value)
+		if name == "x-amz-trailer-signature" {
+			trailerSig = value
+			continue
+		}
+		if name == r.checksumName {
+			checksumValue = value
+		}
+		trailers.WriteString(name + ":" + value + "\n")
+	}
+	if r.signed {
+		stringToSign := strings.Join([]string{
+			s3Algorithm + "-TRAILER", r.sig.amzDate, r.sig.scope, r.prevSig, s3SHA256Hex([]byte(trailers.String())),
+		}, "\n")
+		if expected := s3Sign(r.sig, stringToSign); !hmac.Equal([]byte(expected), []byte(trailerSig)) {
+			return newS3Error(http.StatusForbidden, "SignatureDoesNotMatch", "aws-chunked尾部签名校验失败")
+		}
+	}
+	if r.checksum != nil && checksumValue != base64.StdEncoding.EncodeToString(r.checksum.Sum(nil)) {
+		return newS3Error(http.StatusBadRequest, "BadDigest", fmt.Sprintf("请求体校验和与%s不一致", r.checksumName))
+	}
+	return io.EOF
+}
+
+// readLine 读取一行（去掉行尾\r\n），限制行长度防止恶意请求占用内存
+func (r *s3ChunkedReader) readLine() (string, error) {
+	line, err := r.body.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return "", newS3Error(http.StatusBadRequest, "IncompleteBody", "aws-chunked行过长")
+	}
+	if err == io.EOF {
+		return "", io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
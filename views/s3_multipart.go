@@ -0,0 +1,386 @@
+package views
+
+import (
+	. "SimpleHttpServer/config"
+	"SimpleHttpServer/hooks"
+	"SimpleHttpServer/metrics"
+	. "SimpleHttpServer/middleware"
+	. "SimpleHttpServer/utils"
+	"encoding/xml"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3分段上传与分块上传共用上传会话（UploadStatusCache、元数据持久化、过期清理）和完成时的落盘/重命名逻辑：
+// 会话以Multipart标记，各段大小不固定，先写入会话的分段暂存目录（以段号命名），
+// 完成时按客户端提交的段顺序拼接到.part临时文件，再由finalizeUpload原子替换目标文件
+
+// s3MaxPartNumber S3允许的最大段号
+const s3MaxPartNumber = 10000
+
+// s3PartXML 分段信息（CompleteMultipartUpload请求及ListParts响应）
+type s3PartXML struct {
+	PartNumber   int
+	LastModified string `xml:",omitempty"`
+	ETag         string
+	Size         int64 `xml:",omitempty"`
+}
+
+// loadS3Upload 加载当前用户写入target的S3分段上传会话，不存在（或不属于当前用户）时已写入NoSuchUpload错误响应
+func loadS3Upload(c *gin.Context, uploadID, target string) *UploadStatus {
+	statusInterface, ok := UploadStatusCache.Load(uploadID)
+	if ok {
+		status := statusInterface.(*UploadStatus)
+		if status.Multipart && status.FilePath == target && status.Owner == currentUser(c) {
+			return status
+		}
+	}
+	respondS3Error(c, newS3Error(http.StatusNotFound, "NoSuchUpload", fmt.Sprintf("分段上传不存在或已结束: %s", uploadID)),
+		zap.String("uploadID", uploadID),
+	)
+	return nil
+}
+
+// s3CreateMultipartUpload 创建分段上传（POST /<bucket>/<key>?uploads）
+func s3CreateMultipartUpload(c *gin.Context, bucket, key, bucketDir string) {
+	target, ok := resolveS3Key(bucketDir, key)
+	if !ok || strings.HasSuffix(key, "/") {
+		respondS3Error(c, newS3Error(http.StatusBadRequest, "InvalidArgument", fmt.Sprintf("对象名非法: %s", key)))
+		return
+	}
+	owner := currentUser(c)
+	if existing := findUploadSession(target, ""); existing != nil && existing.Owner != owner {
+		respondS3Error(c, newS3Error(http.StatusConflict, "OperationAborted", fmt.Sprintf("对象正在被用户%s上传，请稍后再试", existing.Owner)),
+			zap.String("filePath", target),
+			zap.String("uploadID", existing.ID),
+		)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		respondS3Error(c, newS3Error(http.StatusInternalServerError, "InternalError", fmt.Sprintf("创建目录失败: %v", err)))
+		return
+	}
+
+	// 与tus一致：同一文件只保留一个上传会话（S3语义下总是覆盖同名对象）
+	dropUploadSessions(target)
+	status := NewUploadStatus(NewUploadID(), owner, target, 0, GlobalConfig.ChunkSize)
+	status.Multipart = true
+	status.Overwrite = true
+	partFile, err := os.OpenFile(status.PartPath(), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err == nil {
+		partFile.Close()
+		err = os.Mkdir(status.PartsDir(), 0755)
+	}
+	if err != nil {
+		os.Remove(status.PartPath())
+		respondS3Error(c, newS3Error(http.StatusInternalServerError, "InternalError", fmt.Sprintf("创建临时文件失败: %v", err)),
+			zap.String("partPath", status.PartPath()),
+			zap.Error(err),
+		)
+		return
+	}
+	UploadStatusCache.Store(status.ID, status)
+	if err := saveUploadJournal(status); err != nil {
+		Log(c).Warn("保存上传会话元数据失败", zap.String("uploadID", status.ID), zap.Error(err))
+	}
+	Log(c).Info("创建S3分段上传会话",
+		zap.String("uploadID", status.ID),
+		zap.String("owner", owner),
+		zap.String("filePath", target),
+	)
+	c.XML(http.StatusOK, struct {
+		XMLName  xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ InitiateMultipartUploadResult"`
+		Bucket   string
+		Key      string
+		UploadId string
+	}{Bucket: bucket, Key: key, UploadId: status.ID})
+}
+
+// s3UploadPart 上传一段（PUT /<bucket>/<key>?partNumber=N&uploadId=ID），同一段号重复上传时覆盖
+func s3UploadPart(c *gin.Context, key, bucketDir, uploadID string) {
+	target, _ := resolveS3Key(bucketDir, key)
+	status := loadS3Upload(c, uploadID, target)
+	if status == nil {
+		return
+	}
+	if c.GetHeader("X-Amz-Copy-Source") != "" {
+		respondS3Error(c, newS3Error(http.StatusNotImplemented, "NotImplemented", "不支持UploadPartCopy"))
+		return
+	}
+	partNumberStr := c.Query("partNumber")
+	partNumber, err := strconv.Atoi(partNumberStr)
+	if err != nil || partNumber < 1 || partNumber > s3MaxPartNumber {
+		respondS3Error(c, newS3Error(http.StatusBadRequest, "InvalidArgument", fmt.Sprintf("段号无效: %s（需为1~%d）", partNumberStr, s3MaxPartNumber)))
+		return
+	}
+
+	partPath := filepath.Join(status.PartsDir(), strconv.Itoa(partNumber))
+	md5Hex, written, err := writeS3File(partPath, c.Request.Body, s3ObjectSize(c.Request), c.GetHeader("Content-MD5"))
+	if err != nil {
+		respondS3WriteError(c, err,
+			zap.String("uploadID", status.ID),
+			zap.Int("partNumber", partNumber),
+		)
+		return
+	}
+	status.SetPart(UploadPart{Number: partNumber, Size: written, ETag: md5Hex, LastModified: time.Now()})
+	metrics.UploadBytes.Add(float64(written), "s3")
+	if err := saveUploadJournal(status); err != nil {
+		Log(c).Warn("保存上传会话元数据失败", zap.String("uploadID", status.ID), zap.Int("partNumber", partNumber), zap.Error(err))
+	}
+	Log(c).Info("S3分段上传成功",
+		zap.String("uploadID", status.ID),
+		zap.String("fileName", status.FileName),
+		zap.Int("partNumber", partNumber),
+		zap.Int64("writtenBytes", written),
+	)
+	c.Header("ETag", `"`+md5Hex+`"`)
+	c.Status(http.StatusOK)
+}
+
+// s3CompleteMultipartUpload 完成分段上传（POST /<bucket>/<key>?uploadId=ID）
+// 按请求体中的段列表（段号升序，ETag需与上传时返回的一致）拼接到临时文件，再原子替换目标对象
+func s3CompleteMultipartUpload(c *gin.Context, bucket, key, bucketDir, uploadID string) {
+	// ========== 1. 加载会话并解析段列表 ==========
+	target, _ := resolveS3Key(bucketDir, key)
+	status := loadS3Upload(c, uploadID, target)
+	if status == nil {
+		return
+	}
+	var request struct {
+		Parts []s3PartXML `xml:"Part"`
+	}
+	if err := xml.NewDecoder(io.LimitReader(c.Request.Body, 2<<20)).Decode(&request); err != nil || len(request.Parts) == 0 {
+		respondS3Error(c, newS3Error(http.StatusBadRequest, "MalformedXML", "请求体XML格式错误或段列表为空"), zap.String("uploadID", uploadID))
+		return
+	}
+	received := map[int]UploadPart{}
+	for _, part := range status.PartList() {
+		received[part.Number] = part
+	}
+	var totalSize int64
+	for i, part := range request.Parts {
+		if i > 0 && part.PartNumber <= request.Parts[i-1].PartNumber {
+			respondS3Error(c, newS3Error(http.StatusBadRequest, "InvalidPartOrder", "段列表必须按段号升序排列"), zap.String("uploadID", uploadID))
+			return
+		}
+		uploaded, ok := received[part.PartNumber]
+		if !ok || strings.Trim(part.ETag, `"`) != uploaded.ETag {
+			respondS3Error(c, newS3Error(http.StatusBadRequest, "InvalidPart", fmt.Sprintf("第%d段不存在或ETag不一致", part.PartNumber)),
+				zap.String("uploadID", uploadID),
+				zap.Int("partNumber", part.PartNumber),
+			)
+			return
+		}
+		totalSize += uploaded.Size
+	}
+	if totalSize > GlobalConfig.MaxUploadSize() {
+		metrics.UploadsFailed.Inc("s3", metrics.ReasonTooLarge)
+		SetAuditFailure(c, metrics.ReasonTooLarge)
+		respondS3Error(c, newS3Error(http.StatusBadRequest, "EntityTooLarge", fmt.Sprintf("对象大小超过限制（最大支持%s）", FormatSize(GlobalConfig.MaxUploadSize()))),
+			zap.String("uploadID", uploadID),
+			zap.Int64("totalSize", totalSize),
+		)
+		return
+	}
+
+	// ========== 2. 认领会话（并发完成请求只处理一次） ==========
+	if _, claimed := UploadStatusCache.LoadAndDelete(status.ID); !claimed {
+		respondS3Error(c, newS3Error(http.StatusNotFound, "NoSuchUpload", fmt.Sprintf("分段上传不存在或已结束: %s", uploadID)))
+		return
+	}
+
+	// ========== 3. 拼接各段并完成上传 ==========
+	// 失败时保留会话和已上传的段，客户端可重试完成
+	restore := func(err error) {
+		status.TotalSize = 0
+		UploadStatusCache.Store(status.ID, status)
+		metrics.UploadsFailed.Inc("s3", metrics.ReasonWriteError)
+		SetAuditFailure(c, metrics.ReasonWriteError)
+		respondS3Error(c, newS3Error(http.StatusInternalServerError, "InternalError", fmt.Sprintf("合并上传文件失败: %v", err)),
+			zap.String("uploadID", status.ID),
+			zap.String("filePath", status.FilePath),
+			zap.Error(err),
+		)
+	}
+	if err := assembleS3Parts(status, request.Parts); err != nil {
+		restore(err)
+		return
+	}
+	status.TotalSize = totalSize
+	checksum, err := finalizeUpload(status)
+	if err != nil {
+		restore(err)
+		return
+	}
+	removeUploadJournal(status)
+	if err := os.RemoveAll(status.PartsDir()); err != nil {
+		Log(c).Warn("删除分段暂存目录失败", zap.String("uploadID", status.ID), zap.Error(err))
+	}
+	metrics.UploadsCompleted.Inc("s3")
+	SetAuditFile(c, status.FilePath, totalSize, checksum)
+	Log(c).Info("S3分段上传完成",
+		zap.String("uploadID", status.ID),
+		zap.String("filePath", status.FilePath),
+		zap.Int("parts", len(request.Parts)),
+		zap.String("totalSize", FormatSize(totalSize)),
+		zap.String("checksum", checksum),
+	)
+
+	etag := ""
+	if info, err := os.Stat(status.FilePath); err == nil {
+		etag = s3ObjectETag(info)
+	}
+	c.XML(http.StatusOK, struct {
+		XMLName  xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CompleteMultipartUploadResult"`
+		Location string
+		Bucket   string
+		Key      string
+		ETag     string
+	}{Location: "/" + bucket + "/" + key, Bucket: bucket, Key: key, ETag: etag})
+	fireHook(c, hooks.EventUpload, "s3", status.FilePath, "", totalSize, checksum)
+}
+
+// assembleS3Parts 按顺序将各段数据拼接写入会话的.part临时文件
+func assembleS3Parts(status *UploadStatus, parts []s3PartXML) error {
+	f, err := os.OpenFile(status.PartPath(), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	for _, part := range parts {
+		src, err := os.Open(filepath.Join(status.PartsDir(), strconv.Itoa(part.PartNumber)))
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(f, src)
+		src.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return f.Close()
+}
+
+// s3AbortMultipartUpload 取消分段上传（DELETE /<bucket>/<key>?uploadId=ID），删除已上传的段
+func s3AbortMultipartUpload(c *gin.Context, key, bucketDir, uploadID string) {
+	target, _ := resolveS3Key(bucketDir, key)
+	status := loadS3Upload(c, uploadID, target)
+	if status == nil {
+		return
+	}
+	if err := discardUpload(status); err != nil {
+		respondS3Error(c, newS3Error(http.StatusInternalServerError, "InternalError", fmt.Sprintf("删除未完成的临时文件失败: %v", err)),
+			zap.String("uploadID", status.ID),
+		)
+		return
+	}
+	metrics.UploadsFailed.Inc("s3", metrics.ReasonAborted)
+	SetAuditFailure(c, metrics.ReasonAborted)
+	Log(c).Info("S3分段上传已取消", zap.String("uploadID", status.ID), zap.String("filePath", status.FilePath))
+	c.Status(http.StatusNoContent)
+}
+
+// s3ListParts 列出已上传的段（GET /<bucket>/<key>?uploadId=ID），支持part-number-marker和max-parts分页
+func s3ListParts(c *gin.Context, bucket, key, bucketDir, uploadID string) {
+	target, _ := resolveS3Key(bucketDir, key)
+	status := loadS3Upload(c, uploadID, target)
+	if status == nil {
+		return
+	}
+	marker, _ := strconv.Atoi(c.Query("part-number-marker"))
+	maxParts := s3MaxKeys
+	if n, err := strconv.Atoi(c.Query("max-parts")); err == nil && n >= 0 && n < maxParts {
+		maxParts = n
+	}
+	parts := []s3PartXML{}
+	truncated := false
+	for _, part := range status.PartList() {
+		if part.Number <= marker {
+			continue
+		}
+		if len(parts) == maxParts {
+			truncated = true
+			break
+		}
+		parts = append(parts, s3PartXML{
+			PartNumber:   part.Number,
+			LastModified: part.LastModified.UTC().Format("2006-01-02T15:04:05.000Z"),
+			ETag:         `"` + part.ETag + `"`,
+			Size:         part.Size,
+		})
+	}
+	nextMarker := 0
+	if len(parts) > 0 {
+		nextMarker = parts[len(parts)-1].PartNumber
+	}
+	c.XML(http.StatusOK, struct {
+		XMLName              xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListPartsResult"`
+		Bucket               string
+		Key                  string
+		UploadId             string
+		StorageClass         string
+		PartNumberMarker     int
+		NextPartNumberMarker int
+		MaxParts             int
+		IsTruncated          bool
+		Parts                []s3PartXML `xml:"Part"`
+	}{
+		Bucket:               bucket,
+		Key:                  key,
+		UploadId:             uploadID,
+		StorageClass:         "STANDARD",
+		PartNumberMarker:     marker,
+		NextPartNumberMarker: nextMarker,
+		MaxParts:             maxParts,
+		IsTruncated:          truncated,
+		Parts:                parts,
+	})
+}
+
+// s3ListMultipartUploads 列出桶内当前用户未完成的分段上传（GET /<bucket>?uploads），支持prefix过滤
+func s3ListMultipartUploads(c *gin.Context, bucket, bucketDir string) {
+	type uploadXML struct {
+		Key          string
+		UploadId     string
+		Initiated    string
+		StorageClass string
+	}
+	prefix := c.Query("prefix")
+	owner := currentUser(c)
+	uploads := []uploadXML{}
+	UploadStatusCache.Range(func(_, value any) bool {
+		status := value.(*UploadStatus)
+		if !status.Multipart || status.Owner != owner || !IsWithinDir(bucketDir, status.FilePath) {
+			return true
+		}
+		rel, err := filepath.Rel(bucketDir, status.FilePath)
+		if key := filepath.ToSlash(rel); err == nil && strings.HasPrefix(key, prefix) {
+			uploads = append(uploads, uploadXML{
+				Key:          key,
+				UploadId:     status.ID,
+				Initiated:    status.CreatedAt.UTC().Format("2006-01-02T15:04:05.000Z"),
+				StorageClass: "STANDARD",
+			})
+		}
+		return true
+	})
+	sort.Slice(uploads, func(i, j int) bool { return uploads[i].Key < uploads[j].Key })
+	c.XML(http.StatusOK, struct {
+		XMLName     xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListMultipartUploadsResult"`
+		Bucket      string
+		Prefix      string
+		MaxUploads  int
+		IsTruncated bool
+		Uploads     []uploadXML `xml:"Upload"`
+	}{Bucket: bucket, Prefix: prefix, MaxUploads: s3MaxKeys, Uploads: uploads})
+}
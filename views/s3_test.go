@@ -0,0 +1,95 @@
+package views
+
+import (
+	"SimpleHttpServer/internal/testutil"
+	"SimpleHttpServer/store"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// S3请求按访问密钥关联用户的角色和目录访问规则授权
+func TestS3HandlerAuthorization(t *testing.T) {
+	dir := setupUploadDir(t, 1024)
+	testutil.SetupStores(t)
+	for name, content := range map[string]string{
+		"pub/a.txt":        "a",
+		"pub/w/b.txt":      "b",
+		"pub/hidden/c.txt": "c",
+		"secret/d.txt":     "d",
+	} {
+		os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755)
+		os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+	}
+	for _, rule := range []store.ACLRule{
+		{Path: "/pub", Subject: "*", Access: store.AccessRead},
+		{Path: "/pub/w", Subject: "group:team", Access: store.AccessWrite},
+		{Path: "/pub/hidden", Subject: "*", Access: store.AccessNone},
+	} {
+		if err := store.ACL.Set(rule); err != nil {
+			t.Fatalf("添加规则失败: %v", err)
+		}
+	}
+	viewer := addUser(t, "viewer", store.RoleViewer)
+	uploader := addUser(t, "uploader", store.RoleUploader, "team")
+	editor := addUser(t, "editor", store.RoleEditor, "team")
+
+	tests := []struct {
+		name     string
+		user     store.User
+		method   string
+		target   string
+		body     string
+		header   map[string]string
+		status   int
+		contains []string
+		excludes []string
+	}{
+		{"桶列表只含可列出的桶", viewer, "GET", "/", "", nil, http.StatusOK, []string{"<Name>pub</Name>"}, []string{"secret"}},
+		{"对象列表按权限过滤", viewer, "GET", "/pub?list-type=2", "", nil, http.StatusOK,
+			[]string{"<Key>a.txt</Key>", "<Key>w/b.txt</Key>"}, []string{"hidden"}},
+		{"没有规则的桶不可列出", viewer, "GET", "/secret", "", nil, http.StatusForbidden, []string{"AccessDenied"}, nil},
+		{"读取可读对象", viewer, "GET", "/pub/a.txt", "", nil, http.StatusOK, []string{"a"}, nil},
+		{"读取不可见对象", viewer, "GET", "/secret/d.txt", "", nil, http.StatusForbidden, []string{"AccessDenied"}, nil},
+		{"规则为none的目录", viewer, "HEAD", "/pub/hidden/c.txt", "", nil, http.StatusForbidden, nil, nil},
+		{"viewer角色不能上传", viewer, "PUT", "/pub/w/new.txt", "x", nil, http.StatusForbidden, []string{"AccessDenied"}, nil},
+		{"上传到可写目录", uploader, "PUT", "/pub/w/new.txt", "x", nil, http.StatusOK, nil, nil},
+		{"只读目录不能上传", uploader, "PUT", "/pub/new.txt", "x", nil, http.StatusForbidden, []string{"AccessDenied"}, nil},
+		{"不能创建没有规则的桶", uploader, "PUT", "/newbucket", "", nil, http.StatusForbidden, []string{"AccessDenied"}, nil},
+		{"复制需要源对象读取权限", uploader, "PUT", "/pub/w/copy.txt", "", map[string]string{"X-Amz-Copy-Source": "/secret/d.txt"},
+			http.StatusForbidden, []string{"AccessDenied"}, nil},
+		{"uploader角色不能删除", uploader, "DELETE", "/pub/w/b.txt", "", nil, http.StatusForbidden, []string{"AccessDenied"}, nil},
+		{"批量删除按对象校验", editor, "POST", "/pub?delete",
+			"<Delete><Object><Key>w/b.txt</Key></Object><Object><Key>a.txt</Key></Object></Delete>", nil, http.StatusOK,
+			[]string{"<Deleted><Key>w/b.txt</Key></Deleted>", "<Error><Key>a.txt</Key><Code>AccessDenied</Code>"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := userEngine(tt.user)
+			r.Any("/*path", S3Handler)
+			w := doRequest(r, tt.method, tt.target, strings.NewReader(tt.body), tt.header)
+			if w.Code != tt.status {
+				t.Fatalf("返回%d，期望%d: %s", w.Code, tt.status, w.Body.String())
+			}
+			for _, s := range tt.contains {
+				if !strings.Contains(w.Body.String(), s) {
+					t.Fatalf("响应缺少%q: %s", s, w.Body.String())
+				}
+			}
+			for _, s := range tt.excludes {
+				if strings.Contains(w.Body.String(), s) {
+					t.Fatalf("响应不应包含%q: %s", s, w.Body.String())
+				}
+			}
+		})
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "pub", "a.txt")); err != nil {
+		t.Fatalf("没有删除权限的对象不应被删除")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "pub", "w", "b.txt")); !os.IsNotExist(err) {
+		t.Fatalf("有删除权限的对象应被删除")
+	}
+}
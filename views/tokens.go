@@ -0,0 +1,82 @@
+package views
+
+import (
+	. "SimpleHttpServer/middleware"
+	"SimpleHttpServer/store"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TokensPage API令牌管理页（GET /tokens），列出当前用户的令牌
+func TokensPage(c *gin.Context) {
+	renderTokensPage(c, http.StatusOK, gin.H{})
+}
+
+// CreateTokenHandler 创建API令牌（POST /tokens）
+// 表单参数：name、scopes（可多选 read/upload/delete）、expires_days（有效天数，0或留空表示永不过期）
+// 明文令牌只在创建成功的页面中展示一次
+func CreateTokenHandler(c *gin.Context) {
+	user, _ := CurrentUser(c)
+	scopes, err := store.ParseTokenScopes(strings.Join(c.PostFormArray("scopes"), ","))
+	if err != nil {
+		renderTokensPage(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var ttl time.Duration
+	if daysStr := strings.TrimSpace(c.PostForm("expires_days")); daysStr != "" {
+		days, err := strconv.Atoi(daysStr)
+		if err != nil || days < 0 {
+			renderTokensPage(c, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("有效天数无效: %s（需为非负整数）", daysStr)})
+			return
+		}
+		ttl = time.Duration(days) * 24 * time.Hour
+	}
+
+	token, secret, err := store.Tokens.Create(user.ID, c.PostForm("name"), scopes, ttl)
+	if err != nil {
+		renderTokensPage(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	SetAuditDetail(c, "创建令牌 "+token.ID)
+	Log(c).Info("创建API令牌",
+		zap.String("tokenID", token.ID),
+		zap.String("username", user.Username),
+		zap.String("name", token.Name),
+		zap.Any("scopes", token.Scopes),
+		zap.Time("expiresAt", token.ExpiresAt),
+	)
+	renderTokensPage(c, http.StatusOK, gin.H{"NewToken": secret, "NewTokenName": token.Name})
+}
+
+// RevokeTokenHandler 吊销当前用户的API令牌（POST /tokens/:id/revoke）
+func RevokeTokenHandler(c *gin.Context) {
+	user, _ := CurrentUser(c)
+	tokenID := c.Param("id")
+	SetAuditDetail(c, "吊销令牌 "+tokenID)
+	if err := store.Tokens.Revoke(tokenID, user.ID); err != nil {
+		renderTokensPage(c, http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	Log(c).Info("吊销API令牌", zap.String("tokenID", tokenID), zap.String("username", user.Username))
+	c.Redirect(http.StatusFound, "/tokens")
+}
+
+// renderTokensPage 渲染令牌管理页，data中可附带error、NewToken等提示信息
+func renderTokensPage(c *gin.Context, httpStatus int, data gin.H) {
+	user, _ := CurrentUser(c)
+	tokens, err := store.Tokens.List(user.ID)
+	if err != nil {
+		Log(c).Error("读取API令牌失败", zap.String("username", user.Username), zap.Error(err))
+		c.HTML(http.StatusInternalServerError, "error.html", gin.H{"error": "读取API令牌失败：" + err.Error()})
+		return
+	}
+	data["Username"] = user.Username
+	data["Tokens"] = tokens
+	data["Scopes"] = store.TokenScopes
+	c.HTML(httpStatus, "tokens.html", data)
+}
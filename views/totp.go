@@ -0,0 +1,133 @@
+package views
+
+import (
+	. "SimpleHttpServer/middleware"
+	"SimpleHttpServer/store"
+	"encoding/base64"
+	"errors"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"github.com/skip2/go-qrcode"
+	"go.uber.org/zap"
+	"html/template"
+	"net/http"
+	"time"
+)
+
+// totpIssuer 身份验证器App中显示的服务名称
+const totpIssuer = "SimpleHttpServer"
+
+// totpSetupSecretKey 会话中保存待确认的两步验证密钥的键（确认验证码后才写入用户文件）
+const totpSetupSecretKey = "totp_setup_secret"
+
+// TOTPPage 两步验证设置页（GET /2fa）：未启用时展示二维码和密钥，已启用时可重新生成恢复码或关闭
+func TOTPPage(c *gin.Context) {
+	renderTOTPPage(c, http.StatusOK, gin.H{})
+}
+
+// EnableTOTPHandler 启用两步验证（POST /2fa/enable，表单参数 code：扫码后身份验证器显示的验证码）
+// 恢复码只在启用成功的页面中展示一次
+func EnableTOTPHandler(c *gin.Context) {
+	user, _ := CurrentUser(c)
+	SetAuditDetail(c, "启用两步验证")
+	session := sessions.Default(c)
+	secret, _ := session.Get(totpSetupSecretKey).(string)
+	if secret == "" {
+		renderTOTPPage(c, http.StatusBadRequest, gin.H{"error": "设置已过期，请重新扫描二维码"})
+		return
+	}
+	codes, err := store.Users.EnableTOTP(user.ID, secret, c.PostForm("code"))
+	if err != nil {
+		status := http.StatusBadRequest
+		if !errors.Is(err, store.ErrInvalidTOTP) && !errors.Is(err, store.ErrTOTPEnabled) {
+			status = http.StatusInternalServerError
+		}
+		renderTOTPPage(c, status, gin.H{"error": err.Error()})
+		return
+	}
+	// 启用后其他会话失效，当前会话更新登录时间以保持有效
+	session.Delete(totpSetupSecretKey)
+	session.Set(SessionLoginKey, time.Now().UnixNano())
+	session.Save()
+	Log(c).Info("用户启用两步验证", zap.String("username", user.Username), zap.String("client_ip", c.ClientIP()))
+	renderTOTPPage(c, http.StatusOK, gin.H{"RecoveryCodes": codes})
+}
+
+// RecoveryCodesHandler 重新生成恢复码（POST /2fa/recovery-codes，需输入当前验证码）
+func RecoveryCodesHandler(c *gin.Context) {
+	user, _ := CurrentUser(c)
+	SetAuditDetail(c, "重新生成恢复码")
+	if !verifyTOTPForm(c, user) {
+		return
+	}
+	codes, err := store.Users.RegenerateRecoveryCodes(user.ID)
+	if err != nil {
+		renderTOTPPage(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	Log(c).Info("用户重新生成恢复码", zap.String("username", user.Username), zap.String("client_ip", c.ClientIP()))
+	renderTOTPPage(c, http.StatusOK, gin.H{"RecoveryCodes": codes})
+}
+
+// DisableTOTPHandler 关闭两步验证（POST /2fa/disable，需输入当前验证码；角色要求两步验证时不可关闭）
+func DisableTOTPHandler(c *gin.Context) {
+	user, _ := CurrentUser(c)
+	SetAuditDetail(c, "关闭两步验证")
+	if TOTPRequired(user) {
+		renderTOTPPage(c, http.StatusForbidden, gin.H{"error": "管理员要求当前角色启用两步验证，不能关闭"})
+		return
+	}
+	if !verifyTOTPForm(c, user) {
+		return
+	}
+	if err := store.Users.DisableTOTP(user.Username); err != nil {
+		renderTOTPPage(c, http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	Log(c).Warn("用户关闭两步验证", zap.String("username", user.Username), zap.String("client_ip", c.ClientIP()))
+	c.Redirect(http.StatusFound, TOTPSetupPath)
+}
+
+// verifyTOTPForm 校验表单中的验证码（code），失败时渲染设置页并返回false
+func verifyTOTPForm(c *gin.Context, user store.User) bool {
+	if _, err := store.Users.VerifyTOTP(user.ID, c.PostForm("code")); err != nil {
+		Log(c).Warn("两步验证失败", zap.String("username", user.Username), zap.String("client_ip", c.ClientIP()), zap.Error(err))
+		renderTOTPPage(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+// renderTOTPPage 渲染两步验证设置页，data中可附带error、RecoveryCodes等提示信息；
+// 未启用时生成（或沿用会话中待确认的）密钥及二维码
+func renderTOTPPage(c *gin.Context, httpStatus int, data gin.H) {
+	current, _ := CurrentUser(c)
+	// 重新读取用户，页面展示本次操作后的状态
+	user, err := store.Users.Get(current.ID)
+	if err != nil {
+		Log(c).Error("读取用户失败", zap.String("userID", current.ID), zap.Error(err))
+		c.HTML(http.StatusInternalServerError, "error.html", gin.H{"error": "读取用户失败：" + err.Error()})
+		return
+	}
+	data["Username"] = user.Username
+	data["Enabled"] = user.TOTPEnabled()
+	data["Required"] = TOTPRequired(user)
+	data["RemainingCodes"] = len(user.RecoveryCodes)
+	if !user.TOTPEnabled() {
+		session := sessions.Default(c)
+		secret, _ := session.Get(totpSetupSecretKey).(string)
+		if secret == "" {
+			secret = store.NewTOTPSecret()
+			session.Set(totpSetupSecretKey, secret)
+			session.Save()
+		}
+		png, err := qrcode.Encode(store.TOTPURI(totpIssuer, user.Username, secret), qrcode.Medium, 220)
+		if err != nil {
+			Log(c).Error("生成两步验证二维码失败", zap.String("username", user.Username), zap.Error(err))
+		} else {
+			data["QRCode"] = template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(png))
+		}
+		data["Secret"] = secret
+	}
+	c.HTML(httpStatus, "totp.html", data)
+}
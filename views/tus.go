@@ -0,0 +1,468 @@
+package views
+
+import (
+	. "SimpleHttpServer/config"
+	"SimpleHttpServer/hooks"
+	"SimpleHttpServer/metrics"
+	. "SimpleHttpServer/middleware"
+	. "SimpleHttpServer/utils"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tus 1.0 断点续传协议（https://tus.io/protocols/resumable-upload）
+// 支持扩展：creation、termination、checksum、expiration。
+// tus上传与分块上传共用上传会话、.part临时文件、元数据持久化和完成时的校验/重命名逻辑，
+// 区别在于tus按偏移顺序追加写入（PATCH），偏移记录在会话的Offset中。
+//
+// Upload-Metadata 支持的键：
+//   - filename（或name）：文件名，必填
+//   - dir：上传根目录下的子目录（需已存在），默认根目录
+//   - overwrite：为 true/1 时允许覆盖同名文件，否则同名文件已存在时拒绝创建
+//   - checksum：整文件校验值（算法:十六进制摘要），同分块上传的 file_checksum
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,termination,checksum,expiration"
+	// tusChecksumAlgorithms checksum扩展支持的算法（Upload-Checksum: <算法> <Base64摘要>）
+	tusChecksumAlgorithms = "md5,sha1,sha256"
+	// tusStatusChecksumMismatch checksum扩展定义的校验失败状态码
+	tusStatusChecksumMismatch = 460
+)
+
+// tusActiveUploads 正在处理PATCH的会话ID，同一会话同一时刻只允许一个PATCH写入
+var tusActiveUploads sync.Map
+
+// TusResumableRequired tus路由分组中间件：所有响应带Tus-Resumable头，校验客户端协议版本
+func TusResumableRequired(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	if c.Request.Method != http.MethodOptions && c.GetHeader("Tus-Resumable") != tusVersion {
+		c.Header("Tus-Version", tusVersion)
+		c.AbortWithStatusJSON(http.StatusPreconditionFailed, gin.H{
+			"status":  "error",
+			"message": fmt.Sprintf("不支持的tus协议版本: %q（仅支持%s）", c.GetHeader("Tus-Resumable"), tusVersion),
+		})
+		return
+	}
+	c.Next()
+}
+
+// TusOptionsHandler 返回服务端支持的tus版本、扩展及限制（OPTIONS /files）
+func TusOptionsHandler(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", tusExtensions)
+	c.Header("Tus-Max-Size", strconv.FormatInt(GlobalConfig.MaxUploadSize(), 10))
+	c.Header("Tus-Checksum-Algorithm", tusChecksumAlgorithms)
+	c.Status(http.StatusNoContent)
+}
+
+// TusCreateHandler 创建tus上传（POST /files），返回201及Location: /files/<上传会话ID>
+func TusCreateHandler(c *gin.Context) {
+	// ========== 1. 日志：记录请求开始 ==========
+	Log(c).Info("开始处理tus上传创建请求",
+		zap.String("request_path", c.FullPath()),
+		zap.String("client_ip", c.ClientIP()),
+	)
+
+	// ========== 2. 校验文件大小 ==========
+	if c.GetHeader("Upload-Defer-Length") != "" {
+		respondUploadError(c, http.StatusBadRequest, "不支持延迟声明文件大小（Upload-Defer-Length），请提供Upload-Length")
+		return
+	}
+	lengthStr := c.GetHeader("Upload-Length")
+	totalSize, err := strconv.ParseInt(lengthStr, 10, 64)
+	if err != nil || totalSize < 0 {
+		respondUploadError(c, http.StatusBadRequest, fmt.Sprintf("Upload-Length无效: %s（需为非负整数）", lengthStr),
+			zap.String("uploadLength", lengthStr),
+		)
+		return
+	}
+	if totalSize > GlobalConfig.MaxUploadSize() {
+		metrics.UploadsFailed.Inc("tus", metrics.ReasonTooLarge)
+		respondUploadError(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("文件大小超过限制（最大支持%s，当前文件%s）",
+			FormatSize(GlobalConfig.MaxUploadSize()), FormatSize(totalSize)),
+			zap.Int64("totalSize", totalSize),
+			zap.Int64("maxFileSize", GlobalConfig.MaxUploadSize()),
+		)
+		auditUpload(c, "tus", "", totalSize, "", metrics.ReasonTooLarge)
+		return
+	}
+
+	// ========== 3. 解析Upload-Metadata ==========
+	metadata, err := parseTusMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		respondUploadError(c, http.StatusBadRequest, err.Error(), zap.String("uploadMetadata", c.GetHeader("Upload-Metadata")))
+		return
+	}
+	fileName := metadata["filename"]
+	if fileName == "" {
+		fileName = metadata["name"]
+	}
+	if fileName == "" || strings.ContainsAny(fileName, `/\`) {
+		respondUploadError(c, http.StatusBadRequest, fmt.Sprintf("Upload-Metadata中的文件名无效: %q", fileName))
+		return
+	}
+	fileChecksum := metadata["checksum"]
+	if fileChecksum != "" {
+		algo, digest, err := ParseChecksum(fileChecksum)
+		if err != nil {
+			respondUploadError(c, http.StatusBadRequest, err.Error(), zap.String("fileChecksum", fileChecksum))
+			return
+		}
+		fileChecksum = algo + ":" + digest
+	}
+	overwrite := metadata["overwrite"] == "true" || metadata["overwrite"] == "1"
+
+	// ========== 4. 校验目标路径 ==========
+	dirAbs, targetDir, ok := resolveUploadSubdir(c, metadata["dir"])
+	if !ok {
+		return
+	}
+	filePath := filepath.Join(targetDir, fileName)
+	if !IsWithinDir(dirAbs, filePath) {
+		respondUploadError(c, http.StatusForbidden, fmt.Sprintf("文件路径非法，禁止跨目录上传: %s", filePath),
+			zap.String("filePath", filePath),
+		)
+		return
+	}
+	owner := currentUser(c)
+	if existing := findUploadSession(filePath, ""); existing != nil && existing.Owner != owner {
+		respondUploadError(c, http.StatusConflict, fmt.Sprintf("文件正在被用户%s上传，请稍后再试", existing.Owner),
+			zap.String("filePath", filePath),
+			zap.String("uploadID", existing.ID),
+		)
+		return
+	}
+	if !overwrite {
+		if _, err := os.Stat(filePath); err == nil {
+			respondUploadError(c, http.StatusConflict, fmt.Sprintf("文件已存在: %s（如需覆盖请在Upload-Metadata中设置overwrite）", fileName),
+				zap.String("filePath", filePath),
+			)
+			return
+		}
+	}
+
+	// ========== 5. 创建上传会话 ==========
+	dropUploadSessions(filePath)
+	status := NewUploadStatus(NewUploadID(), owner, filePath, totalSize, int64(GlobalConfig.ChunkSize))
+	status.Tus = true
+	status.Overwrite = overwrite
+	status.FileChecksum = fileChecksum
+	partFile, err := os.OpenFile(status.PartPath(), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		respondUploadError(c, http.StatusInternalServerError, fmt.Sprintf("创建临时文件失败: %v", err),
+			zap.String("partPath", status.PartPath()),
+			zap.Error(err),
+		)
+		return
+	}
+	partFile.Close()
+	UploadStatusCache.Store(status.ID, status)
+	if err := saveUploadJournal(status); err != nil {
+		Log(c).Warn("保存上传会话元数据失败", zap.String("uploadID", status.ID), zap.Error(err))
+	}
+	Log(c).Info("创建tus上传会话",
+		zap.String("uploadID", status.ID),
+		zap.String("owner", owner),
+		zap.String("filePath", filePath),
+		zap.Int64("totalSize", totalSize),
+		zap.Bool("overwrite", overwrite),
+	)
+
+	// ========== 6. 返回上传地址 ==========
+	// 空文件无需PATCH，创建即完成
+	checksum := ""
+	if totalSize == 0 {
+		var ok bool
+		if checksum, ok = completeTusUpload(c, status); !ok {
+			return
+		}
+	}
+	setTusExpires(c, status)
+	c.Header("Location", "/files/"+status.ID)
+	c.Status(http.StatusCreated)
+	if checksum != "" {
+		auditUpload(c, "tus", status.FilePath, 0, checksum, "")
+		fireHook(c, hooks.EventUpload, "tus", status.FilePath, "", 0, checksum)
+	}
+}
+
+// TusHeadHandler 查询tus上传偏移（HEAD /files/:id）
+func TusHeadHandler(c *gin.Context) {
+	status := loadTusSession(c)
+	if status == nil {
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Header("Upload-Offset", strconv.FormatInt(status.CurrentOffset(), 10))
+	c.Header("Upload-Length", strconv.FormatInt(status.TotalSize, 10))
+	setTusExpires(c, status)
+	c.Status(http.StatusOK)
+}
+
+// TusPatchHandler 从Upload-Offset处追加写入数据（PATCH /files/:id）
+// 写满Upload-Length后按分块上传相同的流程完成：整文件校验、原子重命名为最终文件
+func TusPatchHandler(c *gin.Context) {
+	// ========== 1. 加载会话并校验请求头 ==========
+	status := loadTusSession(c)
+	if status == nil {
+		return
+	}
+	if c.ContentType() != "application/offset+octet-stream" {
+		respondUploadError(c, http.StatusUnsupportedMediaType, "Content-Type必须为application/offset+octet-stream",
+			zap.String("uploadID", status.ID),
+			zap.String("contentType", c.ContentType()),
+		)
+		return
+	}
+	if _, busy := tusActiveUploads.LoadOrStore(status.ID, struct{}{}); busy {
+		respondUploadError(c, http.StatusLocked, "该上传正在写入中，请稍后重试", zap.String("uploadID", status.ID))
+		return
+	}
+	defer tusActiveUploads.Delete(status.ID)
+
+	offset := status.CurrentOffset()
+	offsetStr := c.GetHeader("Upload-Offset")
+	if requestOffset, err := strconv.ParseInt(offsetStr, 10, 64); err != nil || requestOffset != offset {
+		respondUploadError(c, http.StatusConflict, fmt.Sprintf("Upload-Offset不匹配: %s（当前偏移%d）", offsetStr, offset),
+			zap.String("uploadID", status.ID),
+			zap.String("uploadOffset", offsetStr),
+			zap.Int64("offset", offset),
+		)
+		return
+	}
+
+	// 解析Upload-Checksum（可选，格式：<算法> <Base64摘要>）
+	var checksumHash hash.Hash
+	var checksumExpected string
+	if checksumHeader := c.GetHeader("Upload-Checksum"); checksumHeader != "" {
+		algo, encoded, _ := strings.Cut(checksumHeader, " ")
+		raw, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || !strings.Contains(","+tusChecksumAlgorithms+",", ","+algo+",") {
+			respondUploadError(c, http.StatusBadRequest, fmt.Sprintf("Upload-Checksum无效: %s（支持%s）", checksumHeader, tusChecksumAlgorithms),
+				zap.String("uploadID", status.ID),
+			)
+			return
+		}
+		checksumHash, _ = NewChecksumHash(algo)
+		checksumExpected = hex.EncodeToString(raw)
+	}
+
+	// ========== 2. 追加写入临时文件 ==========
+	f, err := os.OpenFile(status.PartPath(), os.O_WRONLY, 0644)
+	if err != nil {
+		respondUploadError(c, http.StatusInternalServerError, fmt.Sprintf("打开临时文件失败: %v", err),
+			zap.String("partPath", status.PartPath()),
+			zap.Error(err),
+		)
+		return
+	}
+	defer f.Close()
+
+	// 最多读取剩余长度+1字节，用于发现超出Upload-Length的数据
+	remaining := status.TotalSize - offset
+	var w io.Writer = io.NewOffsetWriter(f, offset)
+	if checksumHash != nil {
+		w = io.MultiWriter(w, checksumHash)
+	}
+	written, copyErr := io.Copy(w, io.LimitReader(c.Request.Body, remaining+1))
+	if written > remaining {
+		respondUploadError(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("写入数据超出Upload-Length（剩余%d字节）", remaining),
+			zap.String("uploadID", status.ID),
+		)
+		return
+	}
+	if checksumHash != nil {
+		// 校验失败或数据未完整接收时丢弃本次数据（不推进偏移，后续PATCH会覆盖）
+		if copyErr != nil {
+			respondUploadError(c, http.StatusBadRequest, fmt.Sprintf("接收数据失败: %v", copyErr), zap.String("uploadID", status.ID))
+			return
+		}
+		if actual := hex.EncodeToString(checksumHash.Sum(nil)); actual != checksumExpected {
+			respondUploadError(c, tusStatusChecksumMismatch, "数据校验失败（Checksum Mismatch），请重新发送",
+				zap.String("uploadID", status.ID),
+				zap.String("expected", checksumExpected),
+				zap.String("actual", actual),
+			)
+			return
+		}
+	}
+	// 连接中断时保留已收到的数据（tus允许客户端从新的偏移继续）
+	if err := f.Sync(); err != nil {
+		respondUploadError(c, http.StatusInternalServerError, fmt.Sprintf("数据落盘失败: %v", err),
+			zap.String("uploadID", status.ID),
+			zap.Error(err),
+		)
+		return
+	}
+
+	// ========== 3. 推进偏移并持久化 ==========
+	newOffset := status.AdvanceOffset(written)
+	metrics.UploadBytes.Add(float64(written), "tus")
+	if err := saveUploadJournal(status); err != nil {
+		Log(c).Warn("保存上传会话元数据失败", zap.String("uploadID", status.ID), zap.Error(err))
+	}
+	Log(c).Info("tus数据写入成功",
+		zap.String("uploadID", status.ID),
+		zap.String("fileName", status.FileName),
+		zap.Int64("offset", offset),
+		zap.Int64("writtenBytes", written),
+		zap.Int64("newOffset", newOffset),
+		zap.Int64("totalSize", status.TotalSize),
+		zap.NamedError("copyError", copyErr),
+	)
+	if copyErr != nil {
+		// 客户端已断开，无需再响应
+		return
+	}
+
+	// ========== 4. 写满后完成上传 ==========
+	checksum := ""
+	if newOffset == status.TotalSize {
+		var ok bool
+		if checksum, ok = completeTusUpload(c, status); !ok {
+			return
+		}
+		if checksum != "" {
+			c.Header("X-Upload-Checksum", checksum)
+		}
+	}
+
+	setTusExpires(c, status)
+	c.Header("Upload-Offset", strconv.FormatInt(newOffset, 10))
+	c.Status(http.StatusNoContent)
+	// 只有负责收尾的请求拿到摘要，避免并发请求重复记录
+	if checksum != "" {
+		auditUpload(c, "tus", status.FilePath, status.TotalSize, checksum, "")
+		fireHook(c, hooks.EventUpload, "tus", status.FilePath, "", status.TotalSize, checksum)
+	}
+}
+
+// completeTusUpload 数据写满后完成tus上传（整文件校验、原子重命名），返回服务端摘要
+// 并发请求中只有成功从缓存摘除会话的请求负责收尾，其他请求返回空摘要；失败时已写入错误响应，ok为false
+func completeTusUpload(c *gin.Context, status *UploadStatus) (checksum string, ok bool) {
+	if _, claimed := UploadStatusCache.LoadAndDelete(status.ID); !claimed {
+		return "", true
+	}
+	checksum, err := finalizeUpload(status)
+	if err != nil {
+		switch {
+		case errors.Is(err, errUploadChecksumMismatch):
+			quarantinePath, qErr := quarantineUpload(status)
+			metrics.UploadsFailed.Inc("tus", metrics.ReasonChecksumMismatch)
+			respondUploadError(c, http.StatusUnprocessableEntity, fmt.Sprintf("文件校验失败，已隔离: %v", err),
+				zap.String("uploadID", status.ID),
+				zap.String("quarantinePath", quarantinePath),
+				zap.NamedError("quarantineError", qErr),
+			)
+			auditUpload(c, "tus", status.FilePath, status.TotalSize, "", metrics.ReasonChecksumMismatch)
+		case errors.Is(err, errUploadTargetExists):
+			discardUpload(status)
+			metrics.UploadsFailed.Inc("tus", metrics.ReasonTargetExists)
+			respondUploadError(c, http.StatusConflict, fmt.Sprintf("文件已存在: %s", status.FileName),
+				zap.String("uploadID", status.ID),
+				zap.String("filePath", status.FilePath),
+			)
+			auditUpload(c, "tus", status.FilePath, status.TotalSize, "", metrics.ReasonTargetExists)
+		default:
+			// 保留会话，客户端重发空PATCH即可再次尝试完成
+			UploadStatusCache.Store(status.ID, status)
+			metrics.UploadsFailed.Inc("tus", metrics.ReasonWriteError)
+			respondUploadError(c, http.StatusInternalServerError, fmt.Sprintf("合并上传文件失败: %v", err),
+				zap.String("uploadID", status.ID),
+				zap.Error(err),
+			)
+			auditUpload(c, "tus", status.FilePath, status.TotalSize, "", metrics.ReasonWriteError)
+		}
+		return "", false
+	}
+	removeUploadJournal(status)
+	metrics.UploadsCompleted.Inc("tus")
+	Log(c).Info("tus文件上传完成",
+		zap.String("uploadID", status.ID),
+		zap.String("filePath", status.FilePath),
+		zap.String("totalSize", FormatSize(status.TotalSize)),
+		zap.String("checksum", checksum),
+	)
+	return checksum, true
+}
+
+// TusDeleteHandler 终止tus上传并删除临时文件（DELETE /files/:id，termination扩展）
+func TusDeleteHandler(c *gin.Context) {
+	status := loadTusSession(c)
+	if status == nil {
+		return
+	}
+	if err := discardUpload(status); err != nil {
+		respondUploadError(c, http.StatusInternalServerError, fmt.Sprintf("删除未完成的临时文件失败: %v", err),
+			zap.String("uploadID", status.ID),
+			zap.Error(err),
+		)
+		return
+	}
+	metrics.UploadsFailed.Inc("tus", metrics.ReasonAborted)
+	Log(c).Info("tus上传已终止", zap.String("uploadID", status.ID), zap.String("filePath", status.FilePath))
+	c.Status(http.StatusNoContent)
+	auditUpload(c, "tus", status.FilePath, status.TotalSize, "", metrics.ReasonAborted)
+}
+
+// loadTusSession 加载当前用户的tus上传会话；会话已过期时作废并返回410
+// 校验失败时已写入错误响应，返回nil
+func loadTusSession(c *gin.Context) *UploadStatus {
+	status := loadOwnedSession(c)
+	if status == nil {
+		return nil
+	}
+	if !status.Tus {
+		respondUploadError(c, http.StatusNotFound, "该上传会话不是tus上传", zap.String("uploadID", status.ID))
+		return nil
+	}
+	if GlobalConfig.UploadTTL > 0 && time.Since(status.LastActive()) > GlobalConfig.UploadTTL {
+		discardUpload(status)
+		metrics.UploadsFailed.Inc("tus", metrics.ReasonExpired)
+		respondUploadError(c, http.StatusGone, "上传已过期", zap.String("uploadID", status.ID))
+		auditUpload(c, "tus", status.FilePath, status.TotalSize, "", metrics.ReasonExpired)
+		return nil
+	}
+	return status
+}
+
+// setTusExpires 设置Upload-Expires响应头（expiration扩展），未配置会话超时时不设置
+func setTusExpires(c *gin.Context, status *UploadStatus) {
+	if GlobalConfig.UploadTTL > 0 {
+		c.Header("Upload-Expires", status.LastActive().Add(GlobalConfig.UploadTTL).UTC().Format(http.TimeFormat))
+	}
+}
+
+// parseTusMetadata 解析Upload-Metadata：逗号分隔的 "键 Base64值"，值可省略
+func parseTusMetadata(header string) (map[string]string, error) {
+	metadata := map[string]string{}
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, fmt.Errorf("Upload-Metadata格式错误: %s", header)
+		}
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("Upload-Metadata中%s的值不是有效的Base64: %v", key, err)
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
@@ -2,148 +2,53 @@ package views
 
 import (
 	. "SimpleHttpServer/config"
+	"SimpleHttpServer/hooks"
+	"SimpleHttpServer/metrics"
 	. "SimpleHttpServer/middleware" // 假设该包导出全局Zap Logger实例（Logger *zap.Logger）
 	. "SimpleHttpServer/utils"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap" // 导入zap包
+	"hash"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
-	"strings"
-	"time"
 )
 
-// UploadHandler 处理文件分片上传（优化版 + Zap日志）
-func UploadHandler(c *gin.Context) {
+// InitUploadHandler 初始化分块上传会话（POST /uploads/*path）
+// 表单参数：file_name、total_size、action(new/overwrite/resume)、file_checksum(可选，算法:十六进制摘要)
+// 返回上传会话ID及分块信息，后续分块上传、状态查询、取消上传均通过该ID进行
+func InitUploadHandler(c *gin.Context) {
 	// ========== 1. 日志：记录请求开始 ==========
-	Logger.Info("开始处理文件上传请求",
+	Log(c).Info("开始处理上传会话初始化请求",
 		zap.String("request_path", c.FullPath()),
 		zap.String("client_ip", c.ClientIP()),
 	)
 
-	// ========== 2. 解析路由路径参数（适配 /upload/*path） ==========
-	// 从路由获取子目录路径（/*path 匹配的部分）
-	dirPath := c.Param("path")
-	if dirPath == "" || dirPath == "/" || dirPath == "." {
-		dirPath = ""
-	}
-	// 拼接最终上传目录（绝对路径）
-	trimPath := filepath.Join(GlobalConfig.UploadDir, dirPath)
-
-	// 规范化路径（处理 ../ 等非法路径）
-	trimPath, err := filepath.Abs(trimPath)
-	if err != nil {
-		errMsg := fmt.Sprintf("规范化上传路径失败: %v", err)
-		Logger.Error(errMsg,
-			zap.String("trimPath", trimPath),
-			zap.Error(err), // 携带原始错误
-		)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "error",
-			"message": errMsg,
-		})
-		return
-	}
-	dirAbs, err := filepath.Abs(GlobalConfig.UploadDir)
-	if err != nil {
-		errMsg := fmt.Sprintf("获取root目录绝对路径失败: %v", err)
-		Logger.Error("获取root目录绝对路径失败", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "error",
-			"message": errMsg,
-		})
-		return
-	}
-	// ========== 3. 校验上传目录是否存在且为目录 ==========
-	uploadPathInfo, err := os.Stat(trimPath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			errMsg := fmt.Sprintf("上传目录不存在: %s", trimPath)
-			Logger.Error(errMsg, zap.String("trimPath", trimPath))
-			c.JSON(http.StatusBadRequest, gin.H{
-				"status":  "error",
-				"message": errMsg,
-			})
-			return
-		}
-		errMsg := fmt.Sprintf("获取上传目录信息失败: %v", err)
-		Logger.Error(errMsg,
-			zap.String("trimPath", trimPath),
-			zap.Error(err),
-		)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status":  "error",
-			"message": errMsg,
-		})
-		return
-	}
-	if !uploadPathInfo.IsDir() {
-		errMsg := fmt.Sprintf("路径%s不是目录", trimPath)
-		Logger.Error(errMsg, zap.String("trimPath", trimPath))
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "error",
-			"message": errMsg,
-		})
-		return
-	}
-
-	// ========== 4. 解析并校验表单参数 ==========
-	// 解析上传文件
-	file, err := c.FormFile("file")
-	if err != nil {
-		errMsg := fmt.Sprintf("解析上传文件失败: %v", err)
-		Logger.Error(errMsg, zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "error",
-			"message": errMsg,
-		})
+	// ========== 2. 解析并校验上传目录（适配 /uploads/*path） ==========
+	dirAbs, trimPath, ok := resolveUploadDir(c)
+	if !ok {
 		return
 	}
 
-	// 解析基础参数
+	// ========== 3. 解析并校验表单参数 ==========
 	fileName := c.PostForm("file_name")
 	if fileName == "" {
-		errMsg := "文件名称不能为空"
-		Logger.Error(errMsg)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "error",
-			"message": errMsg,
-		})
+		respondUploadError(c, http.StatusBadRequest, "文件名称不能为空")
 		return
 	}
 
-	// 解析分块索引（严格校验）
-	chunkIndexStr := c.PostForm("chunk_index")
-	chunkIndex, err := strconv.Atoi(chunkIndexStr)
-	if err != nil || chunkIndex < 0 {
-		errMsg := fmt.Sprintf("分块索引无效: %s（需为非负整数）", chunkIndexStr)
-		Logger.Error(errMsg,
-			zap.String("chunkIndexStr", chunkIndexStr),
+	totalSizeStr := c.PostForm("total_size")
+	totalSize, err := strconv.ParseInt(totalSizeStr, 10, 64)
+	if err != nil || totalSize < 0 {
+		respondUploadError(c, http.StatusBadRequest, fmt.Sprintf("文件大小无效: %s（需为非负整数）", totalSizeStr),
+			zap.String("totalSizeStr", totalSizeStr),
 			zap.Error(err),
 		)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "error",
-			"message": errMsg,
-		})
-		return
-	}
-
-	// 解析总分块数（严格校验）
-	totalChunksStr := c.PostForm("total_chunks")
-	totalChunks, err := strconv.Atoi(totalChunksStr)
-	if err != nil || totalChunks <= 0 {
-		errMsg := fmt.Sprintf("总分块数无效: %s（需为正整数）", totalChunksStr)
-		Logger.Error(errMsg,
-			zap.String("totalChunksStr", totalChunksStr),
-			zap.Error(err),
-		)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "error",
-			"message": errMsg,
-		})
 		return
 	}
 
@@ -151,357 +56,533 @@ func UploadHandler(c *gin.Context) {
 	action := c.PostForm("action")
 	validActions := map[string]bool{"new": true, "overwrite": true, "resume": true}
 	if !validActions[action] {
-		errMsg := fmt.Sprintf("操作类型无效: %s（仅支持new/overwrite/resume）", action)
-		Logger.Error(errMsg, zap.String("action", action))
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "error",
-			"message": errMsg,
-		})
+		respondUploadError(c, http.StatusBadRequest, fmt.Sprintf("操作类型无效: %s（仅支持new/overwrite/resume）", action),
+			zap.String("action", action),
+		)
 		return
 	}
 
-	// ========== 5. 校验文件大小限制 ==========
-	chunkSize := int64(GlobalConfig.ChunkSize)
-	if action == "new" || action == "overwrite" {
-		// 计算文件总大小（单个分块大小 * 总分块数，最后一块可能更小）
-		totalFileSize := file.Size * int64(totalChunks)
-		if totalFileSize > GlobalConfig.MaxFileSize {
-			errMsg := fmt.Sprintf("文件大小超过限制（最大支持%s，当前文件预估%s）",
-				FormatSize(GlobalConfig.MaxFileSize), FormatSize(totalFileSize))
-			Logger.Error(errMsg,
-				zap.String("fileName", fileName),
-				zap.Int64("totalFileSize", totalFileSize),
-				zap.Int64("maxFileSize", GlobalConfig.MaxFileSize),
-			)
-			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
-				"status":  "error",
-				"message": errMsg,
-			})
+	// 整文件校验值（可选）：上传完成时服务端计算摘要并比对，不一致的文件被隔离
+	fileChecksum := c.PostForm("file_checksum")
+	if fileChecksum != "" {
+		algo, digest, err := ParseChecksum(fileChecksum)
+		if err != nil {
+			respondUploadError(c, http.StatusBadRequest, err.Error(), zap.String("fileChecksum", fileChecksum))
 			return
 		}
+		fileChecksum = algo + ":" + digest
 	}
 
-	// ========== 6. 拼接最终文件路径（规范化） ==========
+	// ========== 4. 校验文件大小限制 ==========
+	if totalSize > GlobalConfig.MaxUploadSize() {
+		metrics.UploadsFailed.Inc("chunk", metrics.ReasonTooLarge)
+		errMsg := fmt.Sprintf("文件大小超过限制（最大支持%s，当前文件%s）",
+			FormatSize(GlobalConfig.MaxUploadSize()), FormatSize(totalSize))
+		respondUploadError(c, http.StatusRequestEntityTooLarge, errMsg,
+			zap.String("fileName", fileName),
+			zap.Int64("totalSize", totalSize),
+			zap.Int64("maxFileSize", GlobalConfig.MaxUploadSize()),
+		)
+		auditUpload(c, "chunk", filepath.Join(dirAbs, fileName), totalSize, "", metrics.ReasonTooLarge)
+		return
+	}
+
+	// ========== 5. 拼接最终文件路径（规范化） ==========
 	filePath := filepath.Join(trimPath, fileName)
 	// 确保文件路径在上传目录内（防止路径穿越）
-	if !strings.HasPrefix(filePath, dirAbs) {
-		errMsg := fmt.Sprintf("文件路径非法，禁止跨目录上传: %s", filePath)
-		Logger.Error(errMsg, zap.String("filePath", filePath))
-		c.JSON(http.StatusForbidden, gin.H{
-			"status":  "error",
-			"message": errMsg,
-		})
+	if !IsWithinDir(dirAbs, filePath) {
+		respondUploadError(c, http.StatusForbidden, fmt.Sprintf("文件路径非法，禁止跨目录上传: %s", filePath),
+			zap.String("filePath", filePath),
+		)
 		return
 	}
 
-	// ========== 7. 处理上传状态（初始化/恢复） ==========
+	// ========== 6. 创建/恢复上传会话 ==========
+	owner := currentUser(c)
+	chunkSize := int64(GlobalConfig.ChunkSize)
 	var status *UploadStatus
 	switch action {
 	case "new", "overwrite":
-		// 新文件/覆盖文件：初始化上传状态
-		if chunkIndex == 0 {
-			// 覆盖模式：先删除原有文件
-			if action == "overwrite" {
-				if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
-					Logger.Warn("删除原有文件失败", // 警告级别
-						zap.String("filePath", filePath),
-						zap.Error(err),
-					)
-				}
-			}
-			// 初始化上传状态缓存
-			status = &UploadStatus{
-				TotalChunks:    totalChunks,
-				ReceivedChunks: 0,
-				FilePath:       filePath,
-				LastUpdated:    time.Now(),
-			}
-			UploadStatusCache.Store(fileName, status)
-			Logger.Info("初始化上传状态",
-				zap.String("fileName", fileName),
-				zap.Int("totalChunks", totalChunks),
+		// 同一文件正在被其他用户上传时拒绝，避免互相覆盖数据
+		if existing := findUploadSession(filePath, ""); existing != nil && existing.Owner != owner {
+			respondUploadError(c, http.StatusConflict, fmt.Sprintf("文件正在被用户%s上传，请稍后再试", existing.Owner),
 				zap.String("filePath", filePath),
-				zap.String("action", action),
+				zap.String("uploadID", existing.ID),
 			)
+			return
+		}
+		// 覆盖模式不在此处删除原有文件，数据先写入.part临时文件，全部接收后才替换，上传失败不影响原文件
+		if action == "new" {
+			if _, err := os.Stat(filePath); err == nil {
+				respondUploadError(c, http.StatusConflict, fmt.Sprintf("文件已存在: %s（请选择覆盖或续传）", fileName),
+					zap.String("filePath", filePath),
+				)
+				return
+			}
+		}
+		// 当前用户对同一文件的旧会话作废，重新开始
+		dropUploadSessions(filePath)
+		status = NewUploadStatus(NewUploadID(), owner, filePath, totalSize, chunkSize)
+		status.Overwrite = action == "overwrite"
+		status.FileChecksum = fileChecksum
+		// 创建（清空）临时文件，分块上传只写入已存在的临时文件
+		partFile, err := os.OpenFile(status.PartPath(), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+		if err != nil {
+			respondUploadError(c, http.StatusInternalServerError, fmt.Sprintf("创建临时文件失败: %v", err),
+				zap.String("partPath", status.PartPath()),
+				zap.Error(err),
+			)
+			return
+		}
+		partFile.Close()
+		UploadStatusCache.Store(status.ID, status)
+		if err := saveUploadJournal(status); err != nil {
+			Log(c).Warn("保存上传会话元数据失败", zap.String("uploadID", status.ID), zap.Error(err))
 		}
+		Log(c).Info("创建上传会话",
+			zap.String("uploadID", status.ID),
+			zap.String("owner", owner),
+			zap.String("filePath", filePath),
+			zap.Int64("totalSize", totalSize),
+			zap.Int("totalChunks", status.TotalChunks),
+			zap.String("action", action),
+		)
 	case "resume":
-		// 续传：从缓存/磁盘恢复上传状态
-		statusInterface, loaded := UploadStatusCache.Load(fileName)
-		if !loaded {
-			// 尝试从磁盘恢复
-			fileStat, err := os.Stat(filePath)
-			if err != nil {
-				errMsg := fmt.Sprintf("无法恢复上传状态，文件不存在或无权限: %v", err)
-				Logger.Error(errMsg,
-					zap.String("fileName", fileName),
+		// 续传：优先复用当前用户未结束的会话
+		if existing := findUploadSession(filePath, ""); existing != nil {
+			if existing.Owner != owner {
+				respondUploadError(c, http.StatusConflict, fmt.Sprintf("文件正在被用户%s上传，请稍后再试", existing.Owner),
 					zap.String("filePath", filePath),
-					zap.Error(err),
+					zap.String("uploadID", existing.ID),
 				)
-				c.JSON(http.StatusBadRequest, gin.H{
-					"status":  "error",
-					"message": errMsg,
-				})
 				return
 			}
-			// 计算已上传分块数
-			uploadedChunks := int((fileStat.Size() + chunkSize - 1) / chunkSize)
-			status = &UploadStatus{
-				TotalChunks:    totalChunks,
-				ReceivedChunks: uploadedChunks,
-				FilePath:       filePath,
-				LastUpdated:    time.Now(),
+			if existing.Tus || existing.Multipart {
+				respondUploadError(c, http.StatusConflict, "文件正在通过tus或S3协议上传，请选择覆盖上传",
+					zap.String("uploadID", existing.ID),
+					zap.String("filePath", filePath),
+				)
+				return
 			}
-			UploadStatusCache.Store(fileName, status)
-			Logger.Info("从磁盘恢复上传状态",
+			if existing.TotalSize != totalSize {
+				respondUploadError(c, http.StatusConflict, "文件大小与未完成的上传会话不一致，请选择覆盖上传",
+					zap.String("uploadID", existing.ID),
+					zap.Int64("sessionTotalSize", existing.TotalSize),
+					zap.Int64("totalSize", totalSize),
+				)
+				return
+			}
+			if existing.FileChecksum != fileChecksum {
+				respondUploadError(c, http.StatusConflict, "整文件校验值与未完成的上传会话不一致，请选择覆盖上传",
+					zap.String("uploadID", existing.ID),
+					zap.String("sessionFileChecksum", existing.FileChecksum),
+					zap.String("fileChecksum", fileChecksum),
+				)
+				return
+			}
+			status = existing
+			Log(c).Info("复用上传会话", zap.String("uploadID", status.ID), zap.String("filePath", filePath))
+			break
+		}
+		// 无会话（也无元数据）时只有磁盘上的临时文件可供参考
+		if _, err := os.Stat(filePath + ".part"); err != nil {
+			respondUploadError(c, http.StatusBadRequest, fmt.Sprintf("无法恢复上传状态，未完成的临时文件不存在或无权限: %v", err),
 				zap.String("fileName", fileName),
-				zap.Int("uploadedChunks", uploadedChunks),
-				zap.Int("totalChunks", totalChunks),
+				zap.String("filePath", filePath),
+				zap.Error(err),
 			)
-		} else {
-			status = statusInterface.(*UploadStatus)
+			return
+		}
+		// 分块按偏移写入，临时文件是稀疏文件，其大小只反映写到的最大偏移，无法得知哪些分块完整到达；
+		// 没有元数据记录位图时不猜测，清空临时文件并以全部分块缺失重新开始。
+		// 续传的文件原本已存在说明用户此前选择的是覆盖（new模式在文件已存在时不会创建会话）
+		status = NewUploadStatus(NewUploadID(), owner, filePath, totalSize, chunkSize)
+		if _, err := os.Stat(filePath); err == nil {
+			status.Overwrite = true
+		}
+		status.FileChecksum = fileChecksum
+		if err := os.Truncate(status.PartPath(), 0); err != nil {
+			respondUploadError(c, http.StatusInternalServerError, fmt.Sprintf("清空临时文件失败: %v", err),
+				zap.String("partPath", status.PartPath()),
+				zap.Error(err),
+			)
+			return
+		}
+		UploadStatusCache.Store(status.ID, status)
+		if err := saveUploadJournal(status); err != nil {
+			Log(c).Warn("保存上传会话元数据失败", zap.String("uploadID", status.ID), zap.Error(err))
 		}
+		Log(c).Info("临时文件无上传会话元数据，重新开始上传",
+			zap.String("uploadID", status.ID),
+			zap.String("fileName", fileName),
+			zap.Int("totalChunks", status.TotalChunks),
+		)
 	}
 
-	// ========== 8. 从缓存加载上传状态（兜底校验） ==========
+	// ========== 7. 返回会话信息 ==========
+	info := sessionInfo(status)
+	c.JSON(http.StatusOK, gin.H{
+		"status":          "success",
+		"upload_id":       info.UploadID,
+		"chunk_size":      info.ChunkSize,
+		"total_chunks":    info.TotalChunks,
+		"uploaded_chunks": info.UploadedChunks,
+		"missing_chunks":  info.MissingChunks,
+	})
+}
+
+// UploadHandler 处理文件分片上传（PUT /uploads/:id/chunks/:index，请求体为分块原始数据）
+// 分块按 index * ChunkSize 的偏移写入，可乱序、并发上传，已接收分块记录在会话位图中；
+// 请求头 X-Chunk-Checksum（算法:十六进制摘要，可选）用于校验分块内容，不一致时拒绝该分块
+func UploadHandler(c *gin.Context) {
+	// ========== 1. 日志：记录请求开始 ==========
+	Log(c).Info("开始处理文件上传请求",
+		zap.String("request_path", c.FullPath()),
+		zap.String("client_ip", c.ClientIP()),
+	)
+
+	// ========== 2. 加载上传会话并校验归属 ==========
+	status := loadOwnedSession(c)
 	if status == nil {
-		statusInterface, ok := UploadStatusCache.Load(fileName)
-		if !ok {
-			errMsg := fmt.Sprintf("上传状态不存在，无法继续上传: %s", fileName)
-			Logger.Error(errMsg, zap.String("fileName", fileName))
-			c.JSON(http.StatusBadRequest, gin.H{
-				"status":  "error",
-				"message": errMsg,
-			})
-			return
-		}
-		status = statusInterface.(*UploadStatus)
+		return
+	}
+	if status.Tus {
+		respondUploadError(c, http.StatusConflict, "该上传会话使用tus协议，请通过 /files 接口上传", zap.String("uploadID", status.ID))
+		return
+	}
+	if status.Multipart {
+		respondUploadError(c, http.StatusConflict, "该上传会话为S3分段上传，请通过S3接口上传", zap.String("uploadID", status.ID))
+		return
 	}
 
-	// ========== 9. 校验分块索引（防止乱序上传） ==========
-	if chunkIndex != status.ReceivedChunks {
-		errMsg := fmt.Sprintf("分块索引错误，期望%d，收到%d", status.ReceivedChunks, chunkIndex)
-		Logger.Error(errMsg,
-			zap.String("fileName", fileName),
-			zap.Int("expectedChunkIndex", status.ReceivedChunks),
-			zap.Int("receivedChunkIndex", chunkIndex),
+	// ========== 3. 解析并校验分块索引（允许乱序，仅校验范围） ==========
+	chunkIndexStr := c.Param("index")
+	chunkIndex, err := strconv.Atoi(chunkIndexStr)
+	if err != nil || chunkIndex < 0 || chunkIndex >= status.TotalChunks {
+		respondUploadError(c, http.StatusBadRequest, fmt.Sprintf("分块索引无效: %s（需为0~%d）", chunkIndexStr, status.TotalChunks-1),
+			zap.String("uploadID", status.ID),
+			zap.String("chunkIndexStr", chunkIndexStr),
 		)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "error",
-			"message": errMsg,
-		})
 		return
 	}
 
-	// ========== 10. 写入分块数据到文件 ==========
-	// 打开文件（创建/追加写入）
-	f, err := os.OpenFile(status.FilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	// ========== 4. 校验分块大小 ==========
+	// 除最后一块外，每块大小必须等于分块大小，否则偏移写入会错位
+	expectedSize := status.ChunkLength(chunkIndex)
+	if c.Request.ContentLength >= 0 && c.Request.ContentLength != expectedSize {
+		respondUploadError(c, http.StatusBadRequest,
+			fmt.Sprintf("分块大小错误，第%d块应为%d字节，收到%d字节", chunkIndex, expectedSize, c.Request.ContentLength),
+			zap.String("uploadID", status.ID),
+			zap.Int("chunkIndex", chunkIndex),
+			zap.Int64("expectedSize", expectedSize),
+			zap.Int64("contentLength", c.Request.ContentLength),
+		)
+		return
+	}
+
+	// 解析分块校验值（可选）
+	var chunkHash hash.Hash
+	chunkAlgo, chunkDigest := "", ""
+	if checksumHeader := c.GetHeader("X-Chunk-Checksum"); checksumHeader != "" {
+		if chunkAlgo, chunkDigest, err = ParseChecksum(checksumHeader); err != nil {
+			respondUploadError(c, http.StatusBadRequest, err.Error(),
+				zap.String("uploadID", status.ID),
+				zap.Int("chunkIndex", chunkIndex),
+				zap.String("checksumHeader", checksumHeader),
+			)
+			return
+		}
+		chunkHash, _ = NewChecksumHash(chunkAlgo)
+	}
+
+	// ========== 5. 写入分块数据到临时文件 ==========
+	// 打开初始化会话时创建的.part文件（不使用O_APPEND，按分块偏移写入，支持并发；
+	// 不使用O_CREATE，会话已完成并重命名后迟到的重复分块不会再生成孤立的临时文件）
+	f, err := os.OpenFile(status.PartPath(), os.O_WRONLY, 0644)
 	if err != nil {
-		errMsg := fmt.Sprintf("打开文件失败: %v", err)
-		Logger.Error(errMsg,
-			zap.String("filePath", status.FilePath),
+		respondUploadError(c, http.StatusInternalServerError, fmt.Sprintf("打开临时文件失败: %v", err),
+			zap.String("partPath", status.PartPath()),
 			zap.Error(err),
 		)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status":  "error",
-			"message": errMsg,
-		})
 		return
 	}
 	defer f.Close() // 确保文件句柄释放
 
-	// 打开上传分块
-	src, err := file.Open()
+	// 写入分块数据（写到 index * ChunkSize 偏移处，最多读取期望大小+1字节用于发现超长分块），
+	// 同时计算记录到元数据的CRC32C及客户端指定算法的摘要
+	offset := int64(chunkIndex) * status.ChunkSize
+	crc, _ := NewChecksumHash("crc32c")
+	writers := []io.Writer{io.NewOffsetWriter(f, offset), crc}
+	if chunkHash != nil {
+		writers = append(writers, chunkHash)
+	}
+	written, err := io.Copy(io.MultiWriter(writers...), io.LimitReader(c.Request.Body, expectedSize+1))
 	if err != nil {
-		errMsg := fmt.Sprintf("读取上传分块失败: %v", err)
-		Logger.Error(errMsg,
-			zap.String("fileName", fileName),
+		respondUploadError(c, http.StatusInternalServerError, fmt.Sprintf("写入分块数据失败: %v", err),
+			zap.String("uploadID", status.ID),
 			zap.Int("chunkIndex", chunkIndex),
+			zap.Int64("writtenBytes", written),
 			zap.Error(err),
 		)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status":  "error",
-			"message": errMsg,
-		})
 		return
 	}
-	defer src.Close() // 确保分块句柄释放
-
-	// 写入分块数据
-	written, err := io.Copy(f, src)
-	if err != nil {
-		errMsg := fmt.Sprintf("写入分块数据失败: %v", err)
-		Logger.Error(errMsg,
-			zap.String("fileName", fileName),
+	if written != expectedSize {
+		respondUploadError(c, http.StatusBadRequest,
+			fmt.Sprintf("分块数据不完整，第%d块应为%d字节，实际收到%d字节", chunkIndex, expectedSize, written),
+			zap.String("uploadID", status.ID),
 			zap.Int("chunkIndex", chunkIndex),
 			zap.Int64("writtenBytes", written),
+		)
+		return
+	}
+
+	// 校验分块内容：不一致时不标记为已接收，客户端重传该分块即可覆盖错误数据
+	if chunkHash != nil {
+		if actual := hex.EncodeToString(chunkHash.Sum(nil)); actual != chunkDigest {
+			respondUploadError(c, http.StatusUnprocessableEntity,
+				fmt.Sprintf("第%d块校验失败（%s期望%s，实际%s），请重新上传该分块", chunkIndex, chunkAlgo, chunkDigest, actual),
+				zap.String("uploadID", status.ID),
+				zap.Int("chunkIndex", chunkIndex),
+				zap.String("algorithm", chunkAlgo),
+				zap.String("expected", chunkDigest),
+				zap.String("actual", actual),
+			)
+			return
+		}
+	}
+
+	// 分块数据落盘后再记录到元数据，保证元数据中标记为已接收的分块一定完整
+	if err := f.Sync(); err != nil {
+		respondUploadError(c, http.StatusInternalServerError, fmt.Sprintf("分块数据落盘失败: %v", err),
+			zap.String("uploadID", status.ID),
+			zap.Int("chunkIndex", chunkIndex),
 			zap.Error(err),
 		)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status":  "error",
-			"message": errMsg,
-		})
 		return
 	}
 
-	// ========== 11. 更新上传状态 ==========
-	status.ReceivedChunks++
-	status.LastUpdated = time.Now()
-	receivedChunks := status.ReceivedChunks
-	Logger.Info("分块上传成功",
-		zap.String("fileName", fileName),
+	// ========== 6. 更新上传状态 ==========
+	// 重复上传的分块只覆盖数据，不重复计数
+	receivedChunks, firstReceived := status.MarkChunk(chunkIndex, hex.EncodeToString(crc.Sum(nil)))
+	metrics.UploadBytes.Add(float64(written), "chunk")
+	metrics.UploadChunks.Inc()
+	if err := saveUploadJournal(status); err != nil {
+		Log(c).Warn("保存上传会话元数据失败",
+			zap.String("uploadID", status.ID),
+			zap.Int("chunkIndex", chunkIndex),
+			zap.Error(err),
+		)
+	}
+	Log(c).Info("分块上传成功",
+		zap.String("uploadID", status.ID),
+		zap.String("fileName", status.FileName),
 		zap.Int("chunkIndex", chunkIndex),
+		zap.Int64("offset", offset),
 		zap.Int("receivedChunks", receivedChunks),
-		zap.Int("totalChunks", totalChunks),
+		zap.Int("totalChunks", status.TotalChunks),
 		zap.Int64("writtenBytes", written),
+		zap.Bool("firstReceived", firstReceived),
 	)
+	publishUploadProgress(status, UploadStateUploading)
 
-	// ========== 12. 检查是否上传完成 ==========
-	if receivedChunks == totalChunks {
-		// 上传完成：清理缓存
-		UploadStatusCache.Delete(fileName)
-		Logger.Info("文件上传完成",
-			zap.String("fileName", fileName),
+	// ========== 7. 检查是否上传完成 ==========
+	// 只有成功从缓存中摘除会话的请求负责收尾，避免并发请求重复完成
+	if receivedChunks == status.TotalChunks {
+		if _, claimed := UploadStatusCache.LoadAndDelete(status.ID); !claimed {
+			c.JSON(http.StatusOK, gin.H{
+				"status":          "success",
+				"message":         "分块上传成功，文件正在合并",
+				"chunk_index":     chunkIndex,
+				"received_chunks": receivedChunks,
+				"complete":        false,
+			})
+			return
+		}
+		checksum, err := finalizeUpload(status)
+		if err != nil {
+			if errors.Is(err, errUploadChecksumMismatch) {
+				// 整文件校验失败：隔离临时文件供排查，作废本次上传
+				quarantinePath, qErr := quarantineUpload(status)
+				metrics.UploadsFailed.Inc("chunk", metrics.ReasonChecksumMismatch)
+				respondUploadError(c, http.StatusUnprocessableEntity, fmt.Sprintf("文件校验失败，已隔离: %v", err),
+					zap.String("uploadID", status.ID),
+					zap.String("filePath", status.FilePath),
+					zap.String("quarantinePath", quarantinePath),
+					zap.NamedError("quarantineError", qErr),
+					zap.Error(err),
+				)
+				auditUpload(c, "chunk", status.FilePath, status.TotalSize, "", metrics.ReasonChecksumMismatch)
+				publishUploadProgress(status, UploadStateFailed)
+				return
+			}
+			if errors.Is(err, errUploadTargetExists) {
+				// new模式上传期间同名文件已被其他途径创建，不覆盖，作废本次上传
+				discardUpload(status)
+				metrics.UploadsFailed.Inc("chunk", metrics.ReasonTargetExists)
+				respondUploadError(c, http.StatusConflict, fmt.Sprintf("文件已存在: %s（请选择覆盖上传）", status.FileName),
+					zap.String("uploadID", status.ID),
+					zap.String("filePath", status.FilePath),
+				)
+				auditUpload(c, "chunk", status.FilePath, status.TotalSize, "", metrics.ReasonTargetExists)
+				publishUploadProgress(status, UploadStateFailed)
+				return
+			}
+			// 其他错误保留会话，客户端重传任意分块即可再次尝试完成
+			UploadStatusCache.Store(status.ID, status)
+			metrics.UploadsFailed.Inc("chunk", metrics.ReasonWriteError)
+			respondUploadError(c, http.StatusInternalServerError, fmt.Sprintf("合并上传文件失败: %v", err),
+				zap.String("uploadID", status.ID),
+				zap.String("filePath", status.FilePath),
+				zap.Error(err),
+			)
+			auditUpload(c, "chunk", status.FilePath, status.TotalSize, "", metrics.ReasonWriteError)
+			return
+		}
+		removeUploadJournal(status)
+		metrics.UploadsCompleted.Inc("chunk")
+		Log(c).Info("文件上传完成",
+			zap.String("uploadID", status.ID),
+			zap.String("fileName", status.FileName),
 			zap.String("filePath", status.FilePath),
-			zap.String("totalSize", FormatSize(file.Size*int64(totalChunks))),
+			zap.String("totalSize", FormatSize(status.TotalSize)),
+			zap.String("checksum", checksum),
 		)
 		c.JSON(http.StatusOK, gin.H{
 			"status":   "success",
 			"message":  "文件上传完成",
-			"filename": fileName,
+			"filename": status.FileName,
 			"filePath": status.FilePath,
+			"checksum": checksum,
+			"complete": true,
 		})
+		auditUpload(c, "chunk", status.FilePath, status.TotalSize, checksum, "")
+		fireHook(c, hooks.EventUpload, "chunk", status.FilePath, "", status.TotalSize, checksum)
+		publishUploadProgress(status, UploadStateCompleted)
 		return
 	}
 
-	// ========== 13. 分块上传成功（未完成） ==========
+	// ========== 8. 分块上传成功（未完成） ==========
 	c.JSON(http.StatusOK, gin.H{
-		"status":     "success",
-		"message":    fmt.Sprintf("分块 %d/%d 上传成功", receivedChunks, totalChunks),
-		"next_chunk": receivedChunks,
+		"status":          "success",
+		"message":         fmt.Sprintf("分块 %d/%d 上传成功", receivedChunks, status.TotalChunks),
+		"chunk_index":     chunkIndex,
+		"received_chunks": receivedChunks,
+		"complete":        false,
 	})
 }
 
-// ResumeInfoHandler 获取续传信息（优化版 + Zap日志）
-func ResumeInfoHandler(c *gin.Context) {
-	// ========== 1. 日志：记录请求开始 ==========
-	Logger.Info("开始处理续传信息请求",
-		zap.String("client_ip", c.ClientIP()),
-		zap.String("request_path", c.FullPath()),
-	)
-
-	// ========== 2. 解析路由路径参数 ==========
-	dirPath := c.Param("path")
-	if dirPath == "" {
-		dirPath = "."
-	}
-	// 拼接并规范化目录路径
-	trimPath := filepath.Join(GlobalConfig.UploadDir, dirPath)
-	trimPath, err := filepath.Abs(trimPath)
-	if err != nil {
-		errMsg := fmt.Sprintf("规范化目录路径失败: %v", err)
-		Logger.Error(errMsg,
-			zap.String("dirPath", dirPath),
-			zap.Error(err),
-		)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "error",
-			"message": errMsg,
-		})
+// UploadStatusHandler 查询上传会话状态（GET /uploads/:id），返回缺失分块供客户端补传
+func UploadStatusHandler(c *gin.Context) {
+	status := loadOwnedSession(c)
+	if status == nil {
 		return
 	}
-	dirAbs, err := filepath.Abs(GlobalConfig.UploadDir)
-	if err != nil {
-		errMsg := fmt.Sprintf("获取root目录绝对路径失败: %v", err)
-		Logger.Error("获取root目录绝对路径失败", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "error",
-			"message": errMsg,
-		})
-		return
+	info := sessionInfo(status)
+	if _, err := os.Stat(status.FilePath); err == nil {
+		info.FileExists = true
 	}
+	c.JSON(http.StatusOK, info)
+}
 
-	// ========== 3. 校验目录是否存在且为目录 ==========
-	resumePathInfo, err := os.Stat(trimPath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			errMsg := fmt.Sprintf("目录不存在: %s", trimPath)
-			Logger.Error(errMsg, zap.String("trimPath", trimPath))
-			c.JSON(http.StatusBadRequest, gin.H{
-				"status":  "error",
-				"message": errMsg,
-			})
-			return
-		}
-		errMsg := fmt.Sprintf("获取目录信息失败: %v", err)
-		Logger.Error(errMsg,
-			zap.String("trimPath", trimPath),
+// AbortUploadHandler 取消上传会话（DELETE /uploads/:id），同时删除已写入的临时文件，已存在的同名文件不受影响
+func AbortUploadHandler(c *gin.Context) {
+	status := loadOwnedSession(c)
+	if status == nil {
+		return
+	}
+	if err := discardUpload(status); err != nil {
+		respondUploadError(c, http.StatusInternalServerError, fmt.Sprintf("删除未完成的临时文件失败: %v", err),
+			zap.String("uploadID", status.ID),
+			zap.String("partPath", status.PartPath()),
 			zap.Error(err),
 		)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status":  "error",
-			"message": errMsg,
-		})
 		return
 	}
-	if !resumePathInfo.IsDir() {
-		errMsg := fmt.Sprintf("路径%s不是目录", trimPath)
-		Logger.Error(errMsg, zap.String("trimPath", trimPath))
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "error",
-			"message": errMsg,
-		})
+	metrics.UploadsFailed.Inc("chunk", metrics.ReasonAborted)
+	Log(c).Info("上传会话已取消",
+		zap.String("uploadID", status.ID),
+		zap.String("owner", status.Owner),
+		zap.String("filePath", status.FilePath),
+	)
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "上传已取消",
+	})
+	auditUpload(c, "chunk", status.FilePath, status.TotalSize, "", metrics.ReasonAborted)
+	publishUploadProgress(status, UploadStateAborted)
+}
+
+// ResumeInfoHandler 获取续传信息（优化版 + Zap日志）
+// 存在当前用户未完成的上传会话时返回会话ID和缺失分块，客户端可直接续传
+func ResumeInfoHandler(c *gin.Context) {
+	// ========== 1. 日志：记录请求开始 ==========
+	Log(c).Info("开始处理续传信息请求",
+		zap.String("client_ip", c.ClientIP()),
+		zap.String("request_path", c.FullPath()),
+	)
+
+	// ========== 2. 解析并校验目录（适配 /get_resume_info/*path） ==========
+	dirAbs, trimPath, ok := resolveUploadDir(c)
+	if !ok {
 		return
 	}
 
-	// ========== 4. 解析并校验文件名 ==========
+	// ========== 3. 解析并校验文件名 ==========
 	fileName := c.Query("file_name")
 	if fileName == "" {
-		errMsg := "文件名称不能为空"
-		Logger.Error(errMsg)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "error",
-			"message": errMsg,
-		})
+		respondUploadError(c, http.StatusBadRequest, "文件名称不能为空")
 		return
 	}
 
-	// ========== 5. 拼接文件路径并检查是否存在 ==========
+	// ========== 4. 拼接文件路径并检查是否存在 ==========
 	filePath := filepath.Join(trimPath, fileName)
 	// 防止路径穿越
-	if !strings.HasPrefix(filePath, dirAbs) {
-		errMsg := fmt.Sprintf("文件路径非法: %s", filePath)
-		Logger.Error(errMsg, zap.String("filePath", filePath))
-		c.JSON(http.StatusForbidden, gin.H{
-			"status":  "error",
-			"message": errMsg,
-		})
+	if !IsWithinDir(dirAbs, filePath) {
+		respondUploadError(c, http.StatusForbidden, fmt.Sprintf("文件路径非法: %s", filePath), zap.String("filePath", filePath))
 		return
 	}
 
-	// ========== 6. 构建续传信息 ==========
+	// ========== 5. 构建续传信息 ==========
 	info := ResumeInfo{
-		FileName:   fileName,
-		FileExists: false,
+		FileName:      fileName,
+		FileExists:    false,
+		MissingChunks: []int{},
 	}
-	chunkSize := int64(GlobalConfig.ChunkSize)
 
-	fileStat, err := os.Stat(filePath)
-	if err == nil {
-		info.FileExists = true
-		info.UploadedBytes = fileStat.Size()
-		info.UploadedChunks = int((fileStat.Size() + chunkSize - 1) / chunkSize)
-		Logger.Info("获取续传信息成功",
+	_, err := os.Stat(filePath)
+	_, partErr := os.Stat(filePath + ".part")
+	if status := findUploadSession(filePath, currentUser(c)); status != nil {
+		// 存在上传会话：按位图返回缺失分块，客户端可并发补齐
+		info = sessionInfo(status)
+		info.FileExists = err == nil
+		Log(c).Info("获取续传信息成功",
+			zap.String("uploadID", status.ID),
 			zap.String("fileName", fileName),
 			zap.String("uploadedBytes", FormatSize(info.UploadedBytes)),
-			zap.Int("uploadedChunks", info.UploadedChunks),
+			zap.Int("missingChunks", len(info.MissingChunks)),
+		)
+	} else if partErr == nil {
+		// 无上传会话：临时文件是按偏移写入的稀疏文件，大小不能说明哪些分块已完整到达，
+		// 不按大小推算，客户端传入总分块数时全部报告为缺失
+		info.FileExists = err == nil
+		if totalChunks, err := strconv.Atoi(c.Query("total_chunks")); err == nil && totalChunks > 0 {
+			info.TotalChunks = totalChunks
+			for i := 0; i < totalChunks; i++ {
+				info.MissingChunks = append(info.MissingChunks, i)
+			}
+		}
+		Log(c).Info("临时文件无上传会话，需重新上传全部分块",
+			zap.String("fileName", fileName),
+			zap.Int("totalChunks", info.TotalChunks),
 		)
 	} else {
-		Logger.Info("文件不存在，无需续传",
+		info.FileExists = err == nil
+		Log(c).Info("无未完成的上传，无需续传",
 			zap.String("fileName", fileName),
 			zap.String("filePath", filePath),
 		)
 	}
 
-	// ========== 7. 返回续传信息 ==========
+	// ========== 6. 返回续传信息 ==========
 	c.JSON(http.StatusOK, info)
 }
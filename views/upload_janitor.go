@@ -0,0 +1,179 @@
+package views
+
+import (
+	. "SimpleHttpServer/config"
+	"SimpleHttpServer/metrics"
+	. "SimpleHttpServer/middleware"
+	. "SimpleHttpServer/utils"
+	"go.uber.org/zap"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// UploadSweepResult 一次过期上传清理的统计结果
+type UploadSweepResult struct {
+	Sessions  int   // 清理的上传会话数
+	PartFiles int   // 清理的无会话临时文件数
+	Bytes     int64 // 释放的磁盘空间(B)
+}
+
+// SweepStaleUploads 清理空闲超过ttl的上传会话及其临时文件、元数据，
+// 并删除没有对应会话且修改时间早于ttl的孤立.part文件及S3分段暂存目录（如旧版本遗留、元数据损坏）。
+// dryRun为true时只记录将被清理的内容，不做任何删除
+func SweepStaleUploads(ttl time.Duration, dryRun bool) (UploadSweepResult, error) {
+	var result UploadSweepResult
+	deadline := time.Now().Add(-ttl)
+
+	// ========== 1. 清理过期会话 ==========
+	UploadStatusCache.Range(func(_, value any) bool {
+		status := value.(*UploadStatus)
+		lastActive := status.LastActive()
+		if lastActive.After(deadline) {
+			return true
+		}
+		var partSize int64
+		if info, err := os.Stat(status.PartPath()); err == nil {
+			partSize = info.Size()
+		}
+		if !dryRun {
+			if err := discardUpload(status); err != nil {
+				Logger.Warn("删除过期上传的临时文件失败", zap.String("uploadID", status.ID), zap.Error(err))
+				return true
+			}
+			metrics.UploadsFailed.Inc(uploadProtocol(status), metrics.ReasonExpired)
+			auditExpiredUpload(status)
+		}
+		result.Sessions++
+		result.Bytes += partSize
+		Logger.Info("清理过期上传会话",
+			zap.String("uploadID", status.ID),
+			zap.String("owner", status.Owner),
+			zap.String("filePath", status.FilePath),
+			zap.Time("lastActive", lastActive),
+			zap.Int("receivedChunks", status.Received()),
+			zap.Int("totalChunks", status.TotalChunks),
+			zap.String("partSize", FormatSize(partSize)),
+			zap.Bool("dryRun", dryRun),
+		)
+		return true
+	})
+
+	// ========== 2. 清理孤立的临时文件 ==========
+	root, err := filepath.Abs(GlobalConfig.UploadDir)
+	if err != nil {
+		return result, err
+	}
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			Logger.Warn("扫描临时文件失败", zap.String("path", path), zap.Error(err))
+			return nil
+		}
+		if d.IsDir() {
+			return sweepOrphanPartsDir(path, d, deadline, dryRun, &result)
+		}
+		if !strings.HasSuffix(d.Name(), ".part") {
+			return nil
+		}
+		if findUploadSession(strings.TrimSuffix(path, ".part"), "") != nil {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || info.ModTime().After(deadline) {
+			return nil
+		}
+		if !dryRun {
+			if err := os.Remove(path); err != nil {
+				Logger.Warn("删除孤立临时文件失败", zap.String("path", path), zap.Error(err))
+				return nil
+			}
+		}
+		result.PartFiles++
+		result.Bytes += info.Size()
+		Logger.Info("清理孤立临时文件",
+			zap.String("path", path),
+			zap.Time("modTime", info.ModTime()),
+			zap.String("size", FormatSize(info.Size())),
+			zap.Bool("dryRun", dryRun),
+		)
+		return nil
+	})
+	return result, err
+}
+
+// sweepOrphanPartsDir 删除会话已不存在且修改时间早于deadline的S3分段暂存目录（.<文件名>.<会话ID>.parts）
+// 返回fs.SkipDir跳过已处理的暂存目录，非暂存目录返回nil继续遍历
+func sweepOrphanPartsDir(path string, d fs.DirEntry, deadline time.Time, dryRun bool, result *UploadSweepResult) error {
+	name := d.Name()
+	if !strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".parts") {
+		return nil
+	}
+	uploadID := filepath.Ext(strings.TrimSuffix(name, ".parts"))
+	if _, ok := UploadStatusCache.Load(strings.TrimPrefix(uploadID, ".")); ok {
+		return fs.SkipDir
+	}
+	info, err := d.Info()
+	if err != nil || info.ModTime().After(deadline) {
+		return fs.SkipDir
+	}
+	var size int64
+	filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() {
+			if info, err := entry.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	if !dryRun {
+		if err := os.RemoveAll(path); err != nil {
+			Logger.Warn("删除孤立分段暂存目录失败", zap.String("path", path), zap.Error(err))
+			return fs.SkipDir
+		}
+	}
+	result.PartFiles++
+	result.Bytes += size
+	Logger.Info("清理孤立分段暂存目录",
+		zap.String("path", path),
+		zap.Time("modTime", info.ModTime()),
+		zap.String("size", FormatSize(size)),
+		zap.Bool("dryRun", dryRun),
+	)
+	return fs.SkipDir
+}
+
+// RunUploadCleanup 单次清理（cleanup子命令使用）：先从元数据恢复上传会话，再按ttl清理
+func RunUploadCleanup(ttl time.Duration, dryRun bool) (UploadSweepResult, error) {
+	if _, err := restoreUploadSessions(!dryRun); err != nil {
+		return UploadSweepResult{}, err
+	}
+	return SweepStaleUploads(ttl, dryRun)
+}
+
+// StartUploadJanitor 启动后台清理协程，按ttl的1/10（介于1分钟和1小时之间）周期清理过期上传
+func StartUploadJanitor(ttl time.Duration) {
+	interval := min(max(ttl/10, time.Minute), time.Hour)
+	Logger.Info("过期上传清理任务已启动",
+		zap.Duration("ttl", ttl),
+		zap.Duration("interval", interval),
+	)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			result, err := SweepStaleUploads(ttl, false)
+			if err != nil {
+				Logger.Error("清理过期上传失败", zap.Error(err))
+			}
+			if result.Sessions > 0 || result.PartFiles > 0 {
+				Logger.Info("过期上传清理完成",
+					zap.Int("sessions", result.Sessions),
+					zap.Int("partFiles", result.PartFiles),
+					zap.String("freed", FormatSize(result.Bytes)),
+				)
+			}
+		}
+	}()
+}
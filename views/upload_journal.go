@@ -0,0 +1,165 @@
+package views
+
+import (
+	. "SimpleHttpServer/config"
+	. "SimpleHttpServer/middleware"
+	. "SimpleHttpServer/utils"
+	"encoding/json"
+	"fmt"
+	"go.uber.org/zap"
+	"io/fs"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// journalSuffix 上传会话元数据文件后缀
+// 元数据文件与数据文件（.part临时文件）位于同一目录，命名为 .<文件名>.upload，以.开头不会出现在文件列表中
+const journalSuffix = ".upload"
+
+// journalPath 返回数据文件对应的会话元数据文件路径
+func journalPath(filePath string) string {
+	return filepath.Join(filepath.Dir(filePath), "."+filepath.Base(filePath)+journalSuffix)
+}
+
+// saveUploadJournal 将会话状态（分块大小、总分块数、位图、各分块校验和）原子写入元数据文件
+func saveUploadJournal(status *UploadStatus) error {
+	path := journalPath(status.FilePath)
+	return status.WriteJournal(func(data []byte) error {
+		return WriteFileAtomic(path, data, 0644)
+	})
+}
+
+// removeUploadJournal 删除会话元数据文件（会话完成/取消/文件被删除时调用）
+func removeUploadJournal(status *UploadStatus) {
+	if err := os.Remove(journalPath(status.FilePath)); err != nil && !os.IsNotExist(err) {
+		Logger.Warn("删除上传会话元数据失败",
+			zap.String("uploadID", status.ID),
+			zap.String("filePath", status.FilePath),
+			zap.Error(err),
+		)
+	}
+}
+
+// FlushUploadSessions 将缓存中所有未完成的上传会话写入元数据文件（服务退出前调用），返回写入的会话数；
+// 临时文件已不存在的会话（已完成或已作废）跳过
+func FlushUploadSessions() int {
+	flushed := 0
+	UploadStatusCache.Range(func(_, value any) bool {
+		status := value.(*UploadStatus)
+		if _, err := os.Stat(status.PartPath()); err != nil {
+			return true
+		}
+		if err := saveUploadJournal(status); err != nil {
+			Logger.Error("保存上传会话元数据失败", zap.String("uploadID", status.ID), zap.Error(err))
+			return true
+		}
+		flushed++
+		return true
+	})
+	return flushed
+}
+
+// loadUploadJournal 读取并校验会话元数据文件
+// 数据文件路径以元数据文件所在目录为准（上传目录被整体迁移后仍可恢复）；
+// 临时文件长度不足以覆盖的分块视为未写完，取消其已接收标记
+func loadUploadJournal(path string) (*UploadStatus, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	status := &UploadStatus{}
+	if err := json.Unmarshal(data, status); err != nil {
+		return nil, fmt.Errorf("解析元数据失败: %w", err)
+	}
+	if status.ID == "" || status.ChunkSize <= 0 || status.TotalSize < 0 {
+		return nil, fmt.Errorf("元数据字段无效")
+	}
+	expected := NewUploadStatus(status.ID, status.Owner, status.FilePath, status.TotalSize, status.ChunkSize)
+	if status.TotalChunks != expected.TotalChunks || len(status.ChunkBitmap) != len(expected.ChunkBitmap) {
+		return nil, fmt.Errorf("元数据分块信息不一致")
+	}
+	if len(status.ChunkChecksums) != status.TotalChunks {
+		status.ChunkChecksums = make([]string, status.TotalChunks)
+	}
+	status.FilePath = filepath.Join(filepath.Dir(path), status.FileName)
+	if journalPath(status.FilePath) != path {
+		return nil, fmt.Errorf("元数据文件名与记录的文件名不一致")
+	}
+
+	// 以位图为准重新统计已接收分块数
+	status.ReceivedChunks = 0
+	for _, word := range status.ChunkBitmap {
+		status.ReceivedChunks += bits.OnesCount64(word)
+	}
+
+	partStat, err := os.Stat(status.PartPath())
+	if err != nil {
+		return nil, fmt.Errorf("临时文件不可用: %w", err)
+	}
+	if status.Offset > partStat.Size() {
+		status.Offset = partStat.Size()
+	}
+	for i := 0; i < status.TotalChunks; i++ {
+		if int64(i)*status.ChunkSize+status.ChunkLength(i) > partStat.Size() {
+			status.UnmarkChunk(i)
+		}
+	}
+	// S3分段上传：丢弃暂存文件缺失或大小不符的分段，客户端重传即可
+	if status.Multipart {
+		parts := status.Parts[:0]
+		for _, part := range status.Parts {
+			info, err := os.Stat(filepath.Join(status.PartsDir(), strconv.Itoa(part.Number)))
+			if err == nil && info.Size() == part.Size {
+				parts = append(parts, part)
+			}
+		}
+		status.Parts = parts
+	}
+	return status, nil
+}
+
+// RestoreUploadSessions 扫描上传目录中的会话元数据文件，将未完成的上传会话恢复到缓存
+// 服务启动时调用；无法恢复的元数据（临时文件已不存在、内容损坏）会被删除
+func RestoreUploadSessions() (int, error) {
+	return restoreUploadSessions(true)
+}
+
+// restoreUploadSessions 恢复上传会话，removeInvalid为false时只记录无法恢复的元数据而不删除（用于试运行）
+func restoreUploadSessions(removeInvalid bool) (int, error) {
+	root, err := filepath.Abs(GlobalConfig.UploadDir)
+	if err != nil {
+		return 0, err
+	}
+	restored := 0
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			Logger.Warn("扫描上传会话元数据失败", zap.String("path", path), zap.Error(err))
+			return nil
+		}
+		if d.IsDir() || !strings.HasPrefix(d.Name(), ".") || !strings.HasSuffix(d.Name(), journalSuffix) {
+			return nil
+		}
+		status, err := loadUploadJournal(path)
+		if err != nil {
+			Logger.Warn("无法恢复上传会话，删除元数据", zap.String("journal", path), zap.Bool("dryRun", !removeInvalid), zap.Error(err))
+			if removeInvalid {
+				os.Remove(path)
+			}
+			return nil
+		}
+		UploadStatusCache.Store(status.ID, status)
+		restored++
+		Logger.Info("恢复上传会话",
+			zap.String("uploadID", status.ID),
+			zap.String("owner", status.Owner),
+			zap.String("filePath", status.FilePath),
+			zap.Int("receivedChunks", status.ReceivedChunks),
+			zap.Int("totalChunks", status.TotalChunks),
+		)
+		return nil
+	})
+	return restored, err
+}
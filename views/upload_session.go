@@ -0,0 +1,263 @@
+package views
+
+import (
+	. "SimpleHttpServer/config"
+	. "SimpleHttpServer/middleware"
+	. "SimpleHttpServer/utils"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// respondUploadError 记录错误日志并返回统一格式的上传错误响应
+func respondUploadError(c *gin.Context, httpStatus int, errMsg string, fields ...zap.Field) {
+	Log(c).Error(errMsg, fields...)
+	c.JSON(httpStatus, gin.H{
+		"status":  "error",
+		"message": errMsg,
+	})
+}
+
+// resolveUploadDir 解析路由中的 /*path 子目录，校验其位于上传根目录内且为已存在的目录
+// 返回上传根目录绝对路径和目标目录绝对路径；校验失败时已写入错误响应，ok为false
+func resolveUploadDir(c *gin.Context) (dirAbs, targetDir string, ok bool) {
+	return resolveUploadSubdir(c, c.Param("path"))
+}
+
+// resolveUploadSubdir 校验上传根目录下的相对子目录dirPath（为空表示根目录），规则同resolveUploadDir
+func resolveUploadSubdir(c *gin.Context, dirPath string) (dirAbs, targetDir string, ok bool) {
+	if dirPath == "" || dirPath == "/" || dirPath == "." {
+		dirPath = ""
+	}
+	dirAbs, err := filepath.Abs(GlobalConfig.UploadDir)
+	if err != nil {
+		respondUploadError(c, http.StatusBadRequest, fmt.Sprintf("获取root目录绝对路径失败: %v", err), zap.Error(err))
+		return "", "", false
+	}
+	// 拼接并规范化目录路径（处理 ../ 等非法路径）
+	targetDir = filepath.Clean(filepath.Join(dirAbs, dirPath))
+	if !IsWithinDir(dirAbs, targetDir) {
+		respondUploadError(c, http.StatusForbidden, fmt.Sprintf("目录路径非法: %s", dirPath), zap.String("dirPath", dirPath))
+		return "", "", false
+	}
+
+	info, err := os.Stat(targetDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			respondUploadError(c, http.StatusBadRequest, fmt.Sprintf("上传目录不存在: %s", targetDir), zap.String("targetDir", targetDir))
+			return "", "", false
+		}
+		respondUploadError(c, http.StatusInternalServerError, fmt.Sprintf("获取上传目录信息失败: %v", err),
+			zap.String("targetDir", targetDir),
+			zap.Error(err),
+		)
+		return "", "", false
+	}
+	if !info.IsDir() {
+		respondUploadError(c, http.StatusBadRequest, fmt.Sprintf("路径%s不是目录", targetDir), zap.String("targetDir", targetDir))
+		return "", "", false
+	}
+	return dirAbs, targetDir, true
+}
+
+// findUploadSession 查找写入指定文件路径的上传会话，owner为空时不限制所属用户
+func findUploadSession(filePath, owner string) *UploadStatus {
+	var found *UploadStatus
+	UploadStatusCache.Range(func(_, value any) bool {
+		status := value.(*UploadStatus)
+		if status.FilePath == filePath && (owner == "" || status.Owner == owner) {
+			found = status
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// dropUploadSessions 删除写入指定文件路径的所有上传会话及其元数据、临时文件，返回删除的会话数
+func dropUploadSessions(filePath string) int {
+	dropped := 0
+	UploadStatusCache.Range(func(_, value any) bool {
+		if status := value.(*UploadStatus); status.FilePath == filePath {
+			if err := discardUpload(status); err != nil {
+				Logger.Warn("删除未完成的临时文件失败", zap.String("uploadID", status.ID), zap.Error(err))
+			}
+			dropped++
+		}
+		return true
+	})
+	return dropped
+}
+
+// dropUploadSessionsUnder 删除目标路径为path或位于path目录内的所有上传会话（目录被删除/移动时调用）
+func dropUploadSessionsUnder(path string) int {
+	dropped := 0
+	UploadStatusCache.Range(func(_, value any) bool {
+		if status := value.(*UploadStatus); IsWithinDir(path, status.FilePath) {
+			if err := discardUpload(status); err != nil {
+				Logger.Warn("删除未完成的临时文件失败", zap.String("uploadID", status.ID), zap.Error(err))
+			}
+			dropped++
+		}
+		return true
+	})
+	return dropped
+}
+
+// discardUpload 作废上传会话：从缓存移除，删除元数据和临时文件（S3分段上传还包括暂存的各段），不触碰最终文件
+func discardUpload(status *UploadStatus) error {
+	UploadStatusCache.Delete(status.ID)
+	removeUploadJournal(status)
+	if status.Multipart {
+		if err := os.RemoveAll(status.PartsDir()); err != nil {
+			return err
+		}
+	}
+	if err := os.Remove(status.PartPath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// uploadProtocol 上传会话所属的上传方式（监控指标的protocol标签）
+func uploadProtocol(status *UploadStatus) string {
+	switch {
+	case status.Tus:
+		return "tus"
+	case status.Multipart:
+		return "s3"
+	}
+	return "chunk"
+}
+
+var (
+	// errUploadTargetExists new模式完成上传时目标文件已存在
+	errUploadTargetExists = errors.New("目标文件已存在")
+	// errUploadChecksumMismatch 完成上传时整文件摘要与客户端声明的校验值不一致
+	errUploadChecksumMismatch = errors.New("整文件校验值不一致")
+)
+
+// defaultChecksumAlgo 客户端未声明整文件校验值时，完成上传后计算并返回的摘要算法
+const defaultChecksumAlgo = "sha256"
+
+// finalizeUpload 完成上传：将临时文件截断到文件总大小并落盘，计算整文件摘要并与客户端声明的校验值比对，
+// 再原子重命名为最终文件名，返回 "算法:摘要" 格式的服务端摘要。
+// 覆盖模式下原文件直到此刻才被替换；new模式下目标文件已存在时返回errUploadTargetExists；
+// 摘要不一致时返回errUploadChecksumMismatch，临时文件保持原样
+func finalizeUpload(status *UploadStatus) (string, error) {
+	partPath := status.PartPath()
+	f, err := os.OpenFile(partPath, os.O_WRONLY, 0644)
+	if err != nil {
+		return "", err
+	}
+	// 截断可能残留的多余数据（如旧临时文件比本次文件更大）
+	if err := f.Truncate(status.TotalSize); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+
+	algo, expected := defaultChecksumAlgo, ""
+	if status.FileChecksum != "" {
+		algo, expected, _ = strings.Cut(status.FileChecksum, ":")
+	}
+	digest, err := FileChecksum(partPath, algo)
+	if err != nil {
+		return "", fmt.Errorf("计算文件摘要失败: %w", err)
+	}
+	checksum := algo + ":" + digest
+	if expected != "" && digest != expected {
+		return checksum, fmt.Errorf("%w（期望%s，实际%s）", errUploadChecksumMismatch, status.FileChecksum, checksum)
+	}
+
+	if !status.Overwrite {
+		if _, err := os.Stat(status.FilePath); err == nil {
+			return checksum, errUploadTargetExists
+		}
+	}
+	if err := os.Rename(partPath, status.FilePath); err != nil {
+		return checksum, err
+	}
+	// 重命名落盘失败不影响本次结果，仅记录警告
+	if err := SyncDir(filepath.Dir(status.FilePath)); err != nil {
+		Logger.Warn("同步上传目录失败", zap.String("filePath", status.FilePath), zap.Error(err))
+	}
+	return checksum, nil
+}
+
+// quarantineDir 校验失败的上传文件隔离目录（位于上传根目录下，以.开头不会出现在文件列表中）
+const quarantineDir = ".quarantine"
+
+// quarantineUpload 将校验失败的临时文件移动到隔离目录（命名为 <会话ID>-<文件名>），并作废上传会话
+// 返回隔离后的文件路径；移动失败时删除临时文件
+func quarantineUpload(status *UploadStatus) (string, error) {
+	UploadStatusCache.Delete(status.ID)
+	removeUploadJournal(status)
+
+	root, err := filepath.Abs(GlobalConfig.UploadDir)
+	if err != nil {
+		os.Remove(status.PartPath())
+		return "", err
+	}
+	dir := filepath.Join(root, quarantineDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		os.Remove(status.PartPath())
+		return "", err
+	}
+	dest := filepath.Join(dir, status.ID+"-"+status.FileName)
+	if err := os.Rename(status.PartPath(), dest); err != nil {
+		os.Remove(status.PartPath())
+		return "", err
+	}
+	return dest, nil
+}
+
+// loadOwnedSession 按路由参数 :id 加载上传会话，并校验会话属于当前登录用户
+// 校验失败时已写入错误响应，返回nil
+func loadOwnedSession(c *gin.Context) *UploadStatus {
+	uploadID := c.Param("id")
+	statusInterface, ok := UploadStatusCache.Load(uploadID)
+	if !ok {
+		respondUploadError(c, http.StatusNotFound, fmt.Sprintf("上传会话不存在或已结束: %s", uploadID), zap.String("uploadID", uploadID))
+		return nil
+	}
+	status := statusInterface.(*UploadStatus)
+	if user := currentUser(c); status.Owner != user {
+		respondUploadError(c, http.StatusForbidden, "无权操作其他用户的上传会话",
+			zap.String("uploadID", uploadID),
+			zap.String("owner", status.Owner),
+			zap.String("user", user),
+		)
+		return nil
+	}
+	return status
+}
+
+// sessionInfo 将上传会话转换为续传信息
+func sessionInfo(status *UploadStatus) ResumeInfo {
+	missing := status.MissingChunks()
+	uploadedBytes := status.TotalSize
+	for _, index := range missing {
+		uploadedBytes -= status.ChunkLength(index)
+	}
+	return ResumeInfo{
+		FileName:       status.FileName,
+		UploadedBytes:  uploadedBytes,
+		UploadedChunks: status.TotalChunks - len(missing),
+		TotalChunks:    status.TotalChunks,
+		MissingChunks:  missing,
+		UploadID:       status.ID,
+		ChunkSize:      status.ChunkSize,
+	}
+}
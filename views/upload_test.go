@@ -0,0 +1,350 @@
+package views
+
+import (
+	. "SimpleHttpServer/config"
+	"SimpleHttpServer/internal/testutil"
+	. "SimpleHttpServer/middleware"
+	"SimpleHttpServer/store"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// uploadEngine 注册分块上传相关路由（与serverRouter一致，不含授权中间件）
+func uploadEngine(user string) *gin.Engine {
+	r := testEngine(user)
+	r.POST("/uploads", InitUploadHandler)
+	r.POST("/uploads/*path", InitUploadHandler)
+	r.PUT("/uploads/:id/chunks/:index", UploadHandler)
+	r.GET("/get_resume_info", ResumeInfoHandler)
+	return r
+}
+
+// uploadSession 初始化上传会话的响应
+type uploadSession struct {
+	Status         string `json:"status"`
+	Message        string `json:"message"`
+	UploadID       string `json:"upload_id"`
+	TotalChunks    int    `json:"total_chunks"`
+	UploadedChunks int    `json:"uploaded_chunks"`
+	MissingChunks  []int  `json:"missing_chunks"`
+}
+
+// chunkResult 上传分块的响应
+type chunkResult struct {
+	Status         string `json:"status"`
+	Message        string `json:"message"`
+	ReceivedChunks int    `json:"received_chunks"`
+	Complete       bool   `json:"complete"`
+	Checksum       string `json:"checksum"`
+}
+
+func initUpload(t *testing.T, r http.Handler, fileName string, size int, action string) uploadSession {
+	t.Helper()
+	w := postForm(r, "/uploads", url.Values{
+		"file_name":  {fileName},
+		"total_size": {strconv.Itoa(size)},
+		"action":     {action},
+	})
+	var session uploadSession
+	decodeJSON(t, w, &session)
+	if w.Code != http.StatusOK {
+		t.Fatalf("初始化上传会话返回%d: %s", w.Code, session.Message)
+	}
+	return session
+}
+
+func putChunk(t *testing.T, r http.Handler, uploadID string, data []byte, chunkSize, index int, header map[string]string) (int, chunkResult) {
+	t.Helper()
+	end := min((index+1)*chunkSize, len(data))
+	w := doRequest(r, http.MethodPut, fmt.Sprintf("/uploads/%s/chunks/%d", uploadID, index),
+		bytes.NewReader(data[index*chunkSize:end]), header)
+	var result chunkResult
+	decodeJSON(t, w, &result)
+	return w.Code, result
+}
+
+func sha256Checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// 乱序上传、重复分块、分块校验失败、重启后按元数据续传，最后合并并比对整文件摘要
+func TestChunkedUploadOutOfOrderResume(t *testing.T) {
+	dir := setupUploadDir(t, 4)
+	r := uploadEngine("alice")
+	data := []byte("0123456789abcdefghij!") // 21字节，6块，最后一块1字节
+
+	session := initUpload(t, r, "a.bin", len(data), "new")
+	if session.TotalChunks != 6 || len(session.MissingChunks) != 6 {
+		t.Fatalf("会话分块信息错误: %+v", session)
+	}
+
+	// 乱序上传第5、2、0块，重复上传第2块不重复计数
+	for _, index := range []int{5, 2, 0} {
+		if code, result := putChunk(t, r, session.UploadID, data, 4, index, nil); code != http.StatusOK || result.Complete {
+			t.Fatalf("第%d块返回%d: %+v", index, code, result)
+		}
+	}
+	if code, result := putChunk(t, r, session.UploadID, data, 4, 2, nil); code != http.StatusOK || result.ReceivedChunks != 3 {
+		t.Fatalf("重复分块返回%d，已接收%d，期望3", code, result.ReceivedChunks)
+	}
+
+	// 分块大小错误、分块校验值不一致均拒绝且不计数
+	w := doRequest(r, http.MethodPut, "/uploads/"+session.UploadID+"/chunks/1", bytes.NewReader([]byte("xyz")), nil)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("分块大小错误返回%d，期望400", w.Code)
+	}
+	badChecksum := map[string]string{"X-Chunk-Checksum": sha256Checksum([]byte("nope"))}
+	if code, _ := putChunk(t, r, session.UploadID, data, 4, 1, badChecksum); code != http.StatusUnprocessableEntity {
+		t.Fatalf("分块校验失败返回%d，期望422", code)
+	}
+
+	// 模拟重启：清空缓存后从元数据恢复，续传只需补齐缺失分块
+	clearUploadSessions()
+	if restored, err := RestoreUploadSessions(); err != nil || restored != 1 {
+		t.Fatalf("恢复上传会话 restored=%d err=%v", restored, err)
+	}
+	resumed := initUpload(t, r, "a.bin", len(data), "resume")
+	if resumed.UploadID != session.UploadID || !slices.Equal(resumed.MissingChunks, []int{1, 3, 4}) {
+		t.Fatalf("续传会话错误: %+v", resumed)
+	}
+
+	for i, index := range []int{4, 1, 3} {
+		chunk := data[index*4 : min(index*4+4, len(data))]
+		header := map[string]string{"X-Chunk-Checksum": sha256Checksum(chunk)}
+		code, result := putChunk(t, r, session.UploadID, data, 4, index, header)
+		if code != http.StatusOK || result.Complete != (i == 2) {
+			t.Fatalf("第%d块返回%d: %+v", index, code, result)
+		}
+		if result.Complete && result.Checksum != sha256Checksum(data) {
+			t.Fatalf("整文件摘要=%s，期望%s", result.Checksum, sha256Checksum(data))
+		}
+	}
+
+	got, err := os.ReadFile(filepath.Join(dir, "a.bin"))
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("合并后的文件内容不一致: %q err=%v", got, err)
+	}
+	for _, leftover := range []string{"a.bin.part", ".a.bin.upload"} {
+		if _, err := os.Stat(filepath.Join(dir, leftover)); !os.IsNotExist(err) {
+			t.Fatalf("上传完成后%s应被删除", leftover)
+		}
+	}
+	if _, ok := UploadStatusCache.Load(session.UploadID); ok {
+		t.Fatalf("上传完成后会话应从缓存删除")
+	}
+}
+
+// 并发上传全部分块，只有一个请求负责合并
+func TestChunkedUploadParallel(t *testing.T) {
+	dir := setupUploadDir(t, 1024)
+	r := uploadEngine("alice")
+	data := bytes.Repeat([]byte("parallel upload "), 4000) // 64000字节，63块
+
+	session := initUpload(t, r, "p.bin", len(data), "new")
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	completed := 0
+	for index := 0; index < session.TotalChunks; index++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			end := min((index+1)*1024, len(data))
+			w := doRequest(r, http.MethodPut, fmt.Sprintf("/uploads/%s/chunks/%d", session.UploadID, index),
+				bytes.NewReader(data[index*1024:end]), nil)
+			if w.Code != http.StatusOK {
+				t.Errorf("第%d块返回%d: %s", index, w.Code, w.Body.String())
+				return
+			}
+			var result chunkResult
+			if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+				t.Errorf("解析第%d块响应失败: %v", index, err)
+				return
+			}
+			if result.Complete {
+				mu.Lock()
+				completed++
+				mu.Unlock()
+				if result.Checksum != sha256Checksum(data) {
+					t.Errorf("整文件摘要=%s，期望%s", result.Checksum, sha256Checksum(data))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if completed != 1 {
+		t.Fatalf("完成上传的请求数=%d，期望1", completed)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "p.bin"))
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("并发上传后文件内容不一致 err=%v", err)
+	}
+}
+
+// 整文件校验值不一致时隔离临时文件，不生成目标文件
+func TestChunkedUploadFileChecksumMismatch(t *testing.T) {
+	dir := setupUploadDir(t, 8)
+	r := uploadEngine("alice")
+	data := []byte("checksum mismatch")
+
+	w := postForm(r, "/uploads", url.Values{
+		"file_name":     {"c.bin"},
+		"total_size":    {strconv.Itoa(len(data))},
+		"action":        {"new"},
+		"file_checksum": {sha256Checksum([]byte("other"))},
+	})
+	var session uploadSession
+	decodeJSON(t, w, &session)
+	var code int
+	for index := 0; index < session.TotalChunks; index++ {
+		code, _ = putChunk(t, r, session.UploadID, data, 8, index, nil)
+	}
+	if code != http.StatusUnprocessableEntity {
+		t.Fatalf("整文件校验失败返回%d，期望422", code)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "c.bin")); !os.IsNotExist(err) {
+		t.Fatalf("校验失败不应生成目标文件")
+	}
+	quarantined, _ := filepath.Glob(filepath.Join(dir, quarantineDir, "*c.bin"))
+	if len(quarantined) != 1 {
+		t.Fatalf("校验失败的临时文件应被隔离，实际%v", quarantined)
+	}
+}
+
+// 没有会话元数据时，稀疏的临时文件不能说明哪些分块已到达：续传信息报告全部缺失，续传重新开始
+func TestResumeWithoutJournalRestartsUpload(t *testing.T) {
+	dir := setupUploadDir(t, 4)
+	r := uploadEngine("alice")
+	data := []byte("0123456789ab") // 3块
+
+	// 只写入最后一块后丢失元数据：临时文件长度为12字节，但前两块是空洞
+	session := initUpload(t, r, "s.bin", len(data), "new")
+	putChunk(t, r, session.UploadID, data, 4, 2, nil)
+	clearUploadSessions()
+	os.Remove(filepath.Join(dir, ".s.bin.upload"))
+
+	w := doRequest(r, http.MethodGet, "/get_resume_info?file_name=s.bin&total_chunks=3", nil, nil)
+	var info ResumeInfo
+	decodeJSON(t, w, &info)
+	if info.UploadedBytes != 0 || info.UploadedChunks != 0 || !slices.Equal(info.MissingChunks, []int{0, 1, 2}) {
+		t.Fatalf("无元数据的续传信息应报告全部缺失: %+v", info)
+	}
+
+	resumed := initUpload(t, r, "s.bin", len(data), "resume")
+	if resumed.UploadedChunks != 0 || !slices.Equal(resumed.MissingChunks, []int{0, 1, 2}) {
+		t.Fatalf("无元数据续传应重新开始: %+v", resumed)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "s.bin.part")); err != nil || info.Size() != 0 {
+		t.Fatalf("无元数据续传应清空临时文件")
+	}
+	for index := 0; index < 3; index++ {
+		putChunk(t, r, resumed.UploadID, data, 4, index, nil)
+	}
+	if got, err := os.ReadFile(filepath.Join(dir, "s.bin")); err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("重新上传后文件内容不一致: %q err=%v", got, err)
+	}
+}
+
+// 撤销目录上传权限后，续传信息查询和已有上传会话（分块上传、tus）的后续操作都被拒绝
+func TestUploadSessionPermissionRevoked(t *testing.T) {
+	dir := setupUploadDir(t, 4)
+	testutil.SetupStores(t)
+	os.Mkdir(filepath.Join(dir, "docs"), 0755)
+	rule := store.ACLRule{Path: "/docs", Subject: "alice", Access: store.AccessWrite}
+	if err := store.ACL.Set(rule); err != nil {
+		t.Fatalf("添加规则失败: %v", err)
+	}
+	r := userEngine(addUser(t, "alice", store.RoleUploader))
+	// 与serverRouter一致挂载授权中间件
+	canUploadForm := RequirePermission(store.PermUpload, UploadFormTarget)
+	canUploadSession := RequirePermission(store.PermUpload, UploadSessionTarget)
+	canResume := RequirePermission(store.PermUpload, ResumeInfoTarget)
+	r.POST("/uploads/*path", canUploadForm, InitUploadHandler)
+	r.PUT("/uploads/:id/chunks/:index", canUploadSession, UploadHandler)
+	r.GET("/uploads/:id", canUploadSession, UploadStatusHandler)
+	r.DELETE("/uploads/:id", canUploadSession, AbortUploadHandler)
+	r.GET("/get_resume_info/*path", canResume, ResumeInfoHandler)
+	tus := r.Group("/files", TusResumableRequired)
+	tus.POST("", RequirePermission(store.PermUpload, TusCreateTarget), TusCreateHandler)
+	tus.HEAD("/:id", canUploadSession, TusHeadHandler)
+	tus.PATCH("/:id", canUploadSession, TusPatchHandler)
+	tus.DELETE("/:id", canUploadSession, TusDeleteHandler)
+
+	data := []byte("0123456789ab")
+	w := postForm(r, "/uploads/docs", url.Values{"file_name": {"a.bin"}, "total_size": {strconv.Itoa(len(data))}, "action": {"new"}})
+	var session uploadSession
+	decodeJSON(t, w, &session)
+	if w.Code != http.StatusOK {
+		t.Fatalf("初始化上传会话返回%d: %s", w.Code, session.Message)
+	}
+	if code, _ := putChunk(t, r, session.UploadID, data, 4, 0, nil); code != http.StatusOK {
+		t.Fatalf("撤销权限前上传分块返回%d", code)
+	}
+	tusHeader := map[string]string{
+		"Tus-Resumable":   "1.0.0",
+		"Upload-Length":   "3",
+		"Upload-Metadata": "filename " + base64.StdEncoding.EncodeToString([]byte("t.bin")) + ",dir " + base64.StdEncoding.EncodeToString([]byte("docs")),
+	}
+	w = doRequest(r, http.MethodPost, "/files", nil, tusHeader)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("创建tus上传返回%d: %s", w.Code, w.Body.String())
+	}
+	tusURL := w.Header().Get("Location")
+
+	before := []struct {
+		method, target string
+		header         map[string]string
+	}{
+		{http.MethodGet, "/uploads/" + session.UploadID, nil},
+		{http.MethodGet, "/get_resume_info/docs?file_name=a.bin", nil},
+		{http.MethodHead, tusURL, map[string]string{"Tus-Resumable": "1.0.0"}},
+	}
+	for _, req := range before {
+		if w := doRequest(r, req.method, req.target, nil, req.header); w.Code != http.StatusOK {
+			t.Fatalf("撤销权限前%s %s返回%d", req.method, req.target, w.Code)
+		}
+	}
+
+	rule.Access = store.AccessRead
+	if err := store.ACL.Set(rule); err != nil {
+		t.Fatalf("修改规则失败: %v", err)
+	}
+	patch := map[string]string{"Tus-Resumable": "1.0.0", "Upload-Offset": "0", "Content-Type": "application/offset+octet-stream"}
+	tests := []struct {
+		name           string
+		method, target string
+		body           []byte
+		header         map[string]string
+	}{
+		{"上传分块", http.MethodPut, "/uploads/" + session.UploadID + "/chunks/1", data[4:8], nil},
+		{"查询会话状态", http.MethodGet, "/uploads/" + session.UploadID, nil, nil},
+		{"取消会话", http.MethodDelete, "/uploads/" + session.UploadID, nil, nil},
+		{"续传信息", http.MethodGet, "/get_resume_info/docs?file_name=a.bin", nil, nil},
+		{"tus查询偏移", http.MethodHead, tusURL, nil, map[string]string{"Tus-Resumable": "1.0.0"}},
+		{"tus追加数据", http.MethodPatch, tusURL, []byte("abc"), patch},
+		{"tus终止上传", http.MethodDelete, tusURL, nil, map[string]string{"Tus-Resumable": "1.0.0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := doRequest(r, tt.method, tt.target, bytes.NewReader(tt.body), tt.header); w.Code != http.StatusForbidden {
+				t.Fatalf("撤销权限后%s %s返回%d，期望403", tt.method, tt.target, w.Code)
+			}
+		})
+	}
+	if _, err := os.Stat(filepath.Join(dir, "docs", "t.bin")); !os.IsNotExist(err) {
+		t.Fatalf("撤销权限后tus上传不应完成")
+	}
+}
@@ -0,0 +1,392 @@
+package views
+
+import (
+	. "SimpleHttpServer/config"
+	"SimpleHttpServer/hooks"
+	"SimpleHttpServer/metrics"
+	. "SimpleHttpServer/middleware"
+	"SimpleHttpServer/store"
+	. "SimpleHttpServer/utils"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"golang.org/x/net/webdav"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// WebDAVPrefix WebDAV挂载路径，文件管理器（Nautilus、Windows资源管理器、Finder、rclone）挂载 http://host:port/webdav/
+const WebDAVPrefix = "/webdav"
+
+// WebDAVMethods WebDAV处理的HTTP方法（路由需逐个注册，gin的Any不包含WebDAV扩展方法）
+var WebDAVMethods = []string{
+	http.MethodOptions, http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete,
+	"PROPFIND", "PROPPATCH", "MKCOL", "COPY", "MOVE", "LOCK", "UNLOCK",
+}
+
+// webdavContentLengthKey 请求上下文中保存PUT请求Content-Length的键，用于判断上传数据是否完整
+type webdavContentLengthKey struct{}
+
+// webdavAuthorizeKey 请求上下文中保存权限校验函数的键，文件系统操作据此校验角色、目录访问规则及API令牌授权范围
+type webdavAuthorizeKey struct{}
+
+// webdavUploadKey 请求上下文中保存PUT写入结果（*webdavUploadResult）的键，临时文件关闭时填写，用于审计日志
+type webdavUploadKey struct{}
+
+// webdavUploadResult PUT写入的结果
+type webdavUploadResult struct {
+	size       int64
+	hash       string // 整文件SHA-256摘要（sha256:十六进制），写入失败时为空
+	failReason string // 失败原因（监控指标的reason标签），成功时为空
+	readErr    error  // 读取请求体的错误（客户端断开、分块传输编码不完整），临时文件关闭时据此丢弃数据
+}
+
+// webdavBody PUT请求体，记录读取错误：分块传输编码没有声明长度，数据是否完整只能由读取是否出错判断
+type webdavBody struct {
+	io.ReadCloser
+	result *webdavUploadResult
+}
+
+func (b webdavBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF && b.result.readErr == nil {
+		b.result.readErr = err
+	}
+	return n, err
+}
+
+// webdavAuditActions 记录审计日志的WebDAV方法及对应操作（PROPFIND、LOCK等不记录）
+var webdavAuditActions = map[string]store.AuditAction{
+	http.MethodGet:    store.AuditDownload,
+	http.MethodPut:    store.AuditUpload,
+	http.MethodDelete: store.AuditDelete,
+	"MKCOL":           store.AuditMkdir,
+	"MOVE":            store.AuditMove,
+	"COPY":            store.AuditCopy,
+}
+
+var webdavHandler = &webdav.Handler{
+	Prefix:     WebDAVPrefix,
+	FileSystem: uploadFileSystem{},
+	LockSystem: webdav.NewMemLS(),
+	Logger: func(r *http.Request, err error) {
+		fields := []zap.Field{
+			zap.String("method", r.Method),
+			zap.String("path", r.URL.Path),
+		}
+		if destination := r.Header.Get("Destination"); destination != "" {
+			fields = append(fields, zap.String("destination", destination))
+		}
+		if err != nil {
+			LogContext(r.Context()).Warn("WebDAV请求失败", append(fields, zap.Error(err))...)
+			return
+		}
+		LogContext(r.Context()).Debug("WebDAV请求", fields...)
+	},
+}
+
+// WebDAVHandler 以WebDAV协议访问上传目录（路由：/webdav/*path，需登录或HTTP Basic认证）
+func WebDAVHandler(c *gin.Context) {
+	// 下载、上传、删除、创建目录、移动、复制记录审计日志，结果按响应状态码判断
+	if action, ok := webdavAuditActions[c.Request.Method]; ok {
+		StartAudit(c, action, "webdav")
+		SetAuditFile(c, webdavPath(c.Request.URL.Path), 0, "")
+		if destination, err := url.Parse(c.GetHeader("Destination")); err == nil && destination.Path != "" {
+			SetAuditTarget(c, webdavPath(destination.Path))
+		}
+		defer FinishAudit(c)
+	}
+	// 单文件大小限制与网页上传一致
+	if c.Request.Method == http.MethodPut && c.Request.ContentLength > GlobalConfig.MaxUploadSize() {
+		metrics.UploadsFailed.Inc("webdav", metrics.ReasonTooLarge)
+		SetAuditFailure(c, metrics.ReasonTooLarge)
+		Log(c).Error("WebDAV上传文件超过大小限制",
+			zap.String("path", c.Request.URL.Path),
+			zap.Int64("contentLength", c.Request.ContentLength),
+			zap.Int64("maxFileSize", GlobalConfig.MaxUploadSize()),
+		)
+		c.Status(http.StatusRequestEntityTooLarge)
+		return
+	}
+	authorize := func(name string, perm store.Permission) bool { return Allowed(c, name, perm) }
+	ctx := context.WithValue(c.Request.Context(), webdavAuthorizeKey{}, authorize)
+	// PUT请求记录声明的数据长度，写入临时文件关闭时据此判断数据是否完整（COPY、LOCK创建文件时不校验）
+	upload := &webdavUploadResult{}
+	if c.Request.Method == http.MethodPut {
+		ctx = context.WithValue(ctx, webdavContentLengthKey{}, c.Request.ContentLength)
+		ctx = context.WithValue(ctx, webdavUploadKey{}, upload)
+		c.Request.Body = webdavBody{ReadCloser: c.Request.Body, result: upload}
+	}
+	c.Request = c.Request.WithContext(ctx)
+	webdavHandler.ServeHTTP(c.Writer, c.Request)
+	succeeded := c.Writer.Status() < http.StatusBadRequest
+	switch c.Request.Method {
+	case http.MethodGet:
+		metrics.DownloadBytes.Add(float64(max(c.Writer.Size(), 0)), "webdav")
+		SetAuditFile(c, webdavPath(c.Request.URL.Path), int64(max(c.Writer.Size(), 0)), "")
+	case http.MethodPut:
+		SetAuditFile(c, webdavPath(c.Request.URL.Path), upload.size, upload.hash)
+		if upload.failReason != "" {
+			SetAuditFailure(c, upload.failReason)
+		} else if succeeded && upload.hash != "" {
+			fireHook(c, hooks.EventUpload, "webdav", webdavPath(c.Request.URL.Path), "", upload.size, upload.hash)
+		}
+	case http.MethodDelete:
+		if succeeded {
+			fireHook(c, hooks.EventDelete, "webdav", webdavPath(c.Request.URL.Path), "", 0, "")
+		}
+	case "MOVE":
+		if destination, err := url.Parse(c.GetHeader("Destination")); err == nil && succeeded {
+			fireHook(c, hooks.EventRename, "webdav", webdavPath(c.Request.URL.Path), webdavPath(destination.Path), 0, "")
+		}
+	}
+}
+
+// webdavPath 将WebDAV请求路径转换为上传目录内的路径（去掉挂载前缀）
+func webdavPath(urlPath string) string {
+	return "/" + strings.TrimPrefix(strings.TrimPrefix(urlPath, WebDAVPrefix), "/")
+}
+
+// uploadFileSystem 将上传目录映射为WebDAV文件系统
+// 路径校验与下载、删除、目录浏览接口一致（SafeJoin），隐藏文件和.part临时文件不可见也不可操作；
+// 权限与网页一致：按用户角色和目录访问规则校验，无权访问的文件和目录不可见；
+// PUT写入同目录的隐藏临时文件，数据完整接收后才原子重命名为目标文件
+type uploadFileSystem struct{}
+
+// resolve 将WebDAV路径（/分隔）转换为上传目录内的绝对路径，并校验当前用户对该路径的操作权限
+// perm为空时按目标类型校验读取权限（目录需可浏览，文件需可读）
+func (uploadFileSystem) resolve(ctx context.Context, name string, perm store.Permission) (string, error) {
+	if hiddenUploadPath(name) {
+		return "", os.ErrNotExist
+	}
+	root, err := filepath.Abs(GlobalConfig.UploadDir)
+	if err != nil {
+		return "", err
+	}
+	target, err := SafeJoin(root, filepath.FromSlash(name))
+	if err != nil {
+		return "", os.ErrPermission
+	}
+	if perm == "" {
+		perm = store.PermRead
+		if info, err := os.Stat(target); err == nil && info.IsDir() {
+			perm = store.PermList
+		}
+	}
+	if !webdavAllowed(ctx, name, perm) {
+		return "", os.ErrPermission
+	}
+	return target, nil
+}
+
+// webdavAllowed 判断请求用户能否对WebDAV路径执行操作
+func webdavAllowed(ctx context.Context, name string, perm store.Permission) bool {
+	authorize, ok := ctx.Value(webdavAuthorizeKey{}).(func(string, store.Permission) bool)
+	return ok && authorize(name, perm)
+}
+
+func (fs uploadFileSystem) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	target, err := fs.resolve(ctx, name, store.PermUpload)
+	if err != nil {
+		return err
+	}
+	return os.Mkdir(target, perm)
+}
+
+func (fs uploadFileSystem) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	// 写入、创建（PUT、COPY目标、LOCK空文件）需上传权限，读取按目标类型校验
+	var required store.Permission
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE) != 0 {
+		required = store.PermUpload
+	}
+	target, err := fs.resolve(ctx, name, required)
+	if err != nil {
+		return nil, err
+	}
+	if flag&(os.O_WRONLY|os.O_RDWR) != 0 && flag&os.O_TRUNC != 0 {
+		// PUT：写入临时文件（.<文件名>.<随机串>.part，隐藏且会被过期清理任务回收）
+		if info, err := os.Stat(target); err == nil && info.IsDir() {
+			return nil, fmt.Errorf("目标路径是目录: %s", name)
+		}
+		tmpPath := filepath.Join(filepath.Dir(target), "."+filepath.Base(target)+"."+NewUploadID()[:8]+".part")
+		f, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_EXCL, perm)
+		if err != nil {
+			return nil, err
+		}
+		expected, ok := ctx.Value(webdavContentLengthKey{}).(int64)
+		if !ok {
+			expected = -1
+		}
+		result, _ := ctx.Value(webdavUploadKey{}).(*webdavUploadResult)
+		return &webdavUploadFile{file: f, target: target, expected: expected, hash: sha256.New(), result: result}, nil
+	}
+	f, err := os.OpenFile(target, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return webdavFile{File: f, ctx: ctx, name: name}, nil
+}
+
+func (fs uploadFileSystem) RemoveAll(ctx context.Context, name string) error {
+	target, err := fs.resolve(ctx, name, store.PermDelete)
+	if err != nil {
+		return err
+	}
+	if root, _ := filepath.Abs(GlobalConfig.UploadDir); target == root {
+		return os.ErrPermission
+	}
+	// 删除文件/目录时一并作废其中的上传会话
+	dropUploadSessionsUnder(target)
+	return os.RemoveAll(target)
+}
+
+func (fs uploadFileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	// 移动相当于删除原路径并在新路径上传
+	oldPath, err := fs.resolve(ctx, oldName, store.PermDelete)
+	if err != nil {
+		return err
+	}
+	newPath, err := fs.resolve(ctx, newName, store.PermUpload)
+	if err != nil {
+		return err
+	}
+	if root, _ := filepath.Abs(GlobalConfig.UploadDir); oldPath == root || newPath == root {
+		return os.ErrPermission
+	}
+	// 会话记录的是原路径，移动后无法继续写入，直接作废
+	dropUploadSessionsUnder(oldPath)
+	return os.Rename(oldPath, newPath)
+}
+
+func (fs uploadFileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	target, err := fs.resolve(ctx, name, "")
+	if err != nil {
+		return nil, err
+	}
+	return os.Stat(target)
+}
+
+// webdavFile 读取用文件，目录列表中过滤隐藏文件、.part临时文件及当前用户无权访问的条目
+type webdavFile struct {
+	*os.File
+	ctx  context.Context
+	name string // WebDAV路径
+}
+
+func (f webdavFile) Readdir(count int) ([]os.FileInfo, error) {
+	infos, err := f.File.Readdir(count)
+	visible := infos[:0]
+	for _, info := range infos {
+		if hiddenUploadPath(info.Name()) {
+			continue
+		}
+		perm := store.PermRead
+		if info.IsDir() {
+			perm = store.PermList
+		}
+		if webdavAllowed(f.ctx, path.Join(f.name, info.Name()), perm) {
+			visible = append(visible, info)
+		}
+	}
+	return visible, err
+}
+
+// webdavUploadFile PUT写入的临时文件，Close时校验数据完整后落盘并重命名为目标文件
+// 不内嵌*os.File，避免io.Copy走os.File的ReadFrom绕过写入计数
+type webdavUploadFile struct {
+	file     *os.File
+	target   string
+	expected int64 // 请求声明的Content-Length，-1表示未知（分块传输编码）
+	written  int64
+	hash     hash.Hash           // 写入数据的SHA-256摘要
+	result   *webdavUploadResult // PUT写入结果（COPY、LOCK创建文件时为nil）
+	err      error               // 写入失败的错误（超过大小限制、写入临时文件失败），Close时据此丢弃临时文件
+	failed   bool                // 已记录失败（每次写入只统计一次）
+}
+
+func (f *webdavUploadFile) Write(p []byte) (int, error) {
+	if f.err != nil {
+		return 0, f.err
+	}
+	if f.written+int64(len(p)) > GlobalConfig.MaxUploadSize() {
+		f.err = fmt.Errorf("文件大小超过限制（最大支持%s）", FormatSize(GlobalConfig.MaxUploadSize()))
+		f.fail(metrics.ReasonTooLarge)
+		return 0, f.err
+	}
+	n, err := f.file.Write(p)
+	f.hash.Write(p[:n])
+	f.written += int64(n)
+	metrics.UploadBytes.Add(float64(n), "webdav")
+	if err != nil {
+		f.err = err
+		f.fail(metrics.ReasonWriteError)
+	}
+	return n, err
+}
+
+func (f *webdavUploadFile) Read(p []byte) (int, error) { return f.file.Read(p) }
+
+func (f *webdavUploadFile) Seek(offset int64, whence int) (int64, error) {
+	return f.file.Seek(offset, whence)
+}
+
+func (f *webdavUploadFile) Readdir(count int) ([]os.FileInfo, error) { return nil, os.ErrInvalid }
+
+func (f *webdavUploadFile) Stat() (os.FileInfo, error) { return f.file.Stat() }
+
+func (f *webdavUploadFile) Close() error {
+	tmpPath := f.file.Name()
+	// 写入出错、读取请求体出错或数据长度与声明不一致时丢弃临时文件，不覆盖目标文件
+	err := f.err
+	if err == nil && f.result != nil && f.result.readErr != nil {
+		err = fmt.Errorf("读取上传数据失败: %w", f.result.readErr)
+	}
+	if err == nil && f.expected >= 0 && f.written != f.expected {
+		err = fmt.Errorf("上传数据不完整，应为%d字节，实际收到%d字节", f.expected, f.written)
+	}
+	if err != nil {
+		f.file.Close()
+		os.Remove(tmpPath)
+		f.fail(metrics.ReasonAborted)
+		return err
+	}
+	err = f.file.Sync()
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, f.target)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		f.fail(metrics.ReasonWriteError)
+		return err
+	}
+	metrics.UploadsCompleted.Inc("webdav")
+	if f.result != nil {
+		f.result.size, f.result.hash = f.written, "sha256:"+hex.EncodeToString(f.hash.Sum(nil))
+	}
+	Logger.Info("WebDAV文件写入完成", zap.String("filePath", f.target), zap.String("size", FormatSize(f.written)))
+	return nil
+}
+
+// fail 统计并记录写入失败的原因（以最先发生的为准，同一次写入只统计一次）
+func (f *webdavUploadFile) fail(reason string) {
+	if f.failed {
+		return
+	}
+	f.failed = true
+	metrics.UploadsFailed.Inc("webdav", reason)
+	if f.result != nil {
+		f.result.size, f.result.failReason = f.written, reason
+	}
+}
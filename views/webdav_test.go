@@ -0,0 +1,88 @@
+package views
+
+import (
+	. "SimpleHttpServer/config"
+	"SimpleHttpServer/internal/testutil"
+	"SimpleHttpServer/store"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// truncatedBody 读出data后返回err，模拟客户端中途断开
+type truncatedBody struct {
+	data *bytes.Reader
+	err  error
+}
+
+func (b *truncatedBody) Read(p []byte) (int, error) {
+	if b.data.Len() == 0 {
+		return 0, b.err
+	}
+	return b.data.Read(p)
+}
+
+// putChunked 以分块传输编码（不声明Content-Length）PUT文件
+func putChunked(r http.Handler, target string, body io.Reader) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPut, target, body)
+	req.ContentLength = -1
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// 分块传输编码的PUT超过大小限制或中途断开时丢弃临时文件，不覆盖已有文件，失败只统计一次
+func TestWebDAVChunkedPutIncomplete(t *testing.T) {
+	dir := setupUploadDir(t, 1024)
+	GlobalConfig.MaxFileSize = 16
+	testutil.SetupStores(t)
+	r := userEngine(addUser(t, "admin", store.RoleAdmin))
+	r.Handle(http.MethodPut, "/webdav/*path", WebDAVHandler)
+	target := filepath.Join(dir, "a.txt")
+	original := []byte("original")
+	os.WriteFile(target, original, 0644)
+
+	tooLarge := `shs_uploads_failed_total{protocol="webdav",reason="too_large"}`
+	aborted := `shs_uploads_failed_total{protocol="webdav",reason="aborted"}`
+
+	tests := []struct {
+		name    string
+		body    io.Reader
+		failure string // 期望增加的失败指标
+	}{
+		{"超过大小限制", bytes.NewReader(bytes.Repeat([]byte("x"), 64*1024)), tooLarge},
+		{"客户端中途断开", &truncatedBody{data: bytes.NewReader([]byte("trunc")), err: io.ErrUnexpectedEOF}, aborted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tooLargeBefore, abortedBefore := metricValue(t, tooLarge), metricValue(t, aborted)
+			w := putChunked(r, "/webdav/a.txt", tt.body)
+			if w.Code < http.StatusBadRequest {
+				t.Fatalf("不完整的上传返回%d，期望失败", w.Code)
+			}
+			if got, _ := os.ReadFile(target); !bytes.Equal(got, original) {
+				t.Fatalf("已有文件被覆盖: %q", got)
+			}
+			if leftovers, _ := filepath.Glob(filepath.Join(dir, ".a.txt.*.part")); len(leftovers) != 0 {
+				t.Fatalf("临时文件未删除: %v", leftovers)
+			}
+			tooLargeDelta := metricValue(t, tooLarge) - tooLargeBefore
+			abortedDelta := metricValue(t, aborted) - abortedBefore
+			if tooLargeDelta+abortedDelta != 1 || (tt.failure == tooLarge) != (tooLargeDelta == 1) {
+				t.Fatalf("失败统计 too_large+%v aborted+%v，期望只有%s加1", tooLargeDelta, abortedDelta, tt.failure)
+			}
+		})
+	}
+
+	// 完整的分块传输编码上传正常替换文件
+	if w := putChunked(r, "/webdav/a.txt", bytes.NewReader([]byte("replaced"))); w.Code >= http.StatusBadRequest {
+		t.Fatalf("完整上传返回%d", w.Code)
+	}
+	if got, _ := os.ReadFile(target); string(got) != "replaced" {
+		t.Fatalf("完整上传后文件内容为%q", got)
+	}
+}
@@ -0,0 +1,148 @@
+package watcher
+
+import (
+	"errors"
+	"github.com/fsnotify/fsnotify"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// writeSettle 文件在该时长内没有再写入时才产生修改事件，避免大文件写入期间每次写入都产生事件
+var writeSettle = 500 * time.Millisecond
+
+// nativeWatcher fsnotify实例（Linux inotify、BSD/macOS kqueue、Windows ReadDirectoryChangesW）。
+// fsnotify只监听单个目录，目录树需逐个添加子目录
+type nativeWatcher struct {
+	fs   *fsnotify.Watcher
+	dirs map[string]bool // 已监听的目录：删除或移出时据此判断是否为目录，并移除其子目录的监听
+}
+
+// startNative 以fsnotify监听目录树，平台不支持或目录数超出系统限制等原因失败时返回错误
+func (w *Watcher) startNative() error {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	n := &nativeWatcher{fs: fw, dirs: map[string]bool{}}
+	if err := n.addTree(w.root, w.ignore); err != nil {
+		fw.Close()
+		return err
+	}
+	w.mode = "fsnotify"
+	w.stop = func() { fw.Close() }
+	go w.readNative(n)
+	return nil
+}
+
+// addTree 监听目录及其所有子目录（跳过忽略的目录）
+func (n *nativeWatcher) addTree(root string, ignore func(string) bool) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// 遍历期间被删除的目录跳过
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != root && ignore(d.Name()) {
+			return filepath.SkipDir
+		}
+		if err := n.fs.Add(path); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		n.dirs[path] = true
+		return nil
+	})
+}
+
+// removeTree 移除已删除或移出的目录及其子目录的监听（监听可能已被系统移除，忽略错误）；
+// 移出的子目录若继续监听，其中的变化会以原路径报告
+func (n *nativeWatcher) removeTree(dir string) {
+	prefix := dir + string(filepath.Separator)
+	for path := range n.dirs {
+		if path == dir || strings.HasPrefix(path, prefix) {
+			n.fs.Remove(path)
+			delete(n.dirs, path)
+		}
+	}
+}
+
+// readNative 读取并转换fsnotify事件，同一文件连续的写入在停止writeSettle后合并为一次修改事件
+func (w *Watcher) readNative(n *nativeWatcher) {
+	defer close(w.Events)
+	writing := map[string]time.Time{} // 写入中的文件 → 最后一次写入的时间
+	var flush <-chan time.Time
+	for {
+		select {
+		case ev, ok := <-n.fs.Events:
+			if !ok {
+				return
+			}
+			if !w.handleNative(n, ev, writing) {
+				return
+			}
+			if len(writing) > 0 && flush == nil {
+				flush = time.After(writeSettle)
+			}
+		case _, ok := <-n.fs.Errors:
+			// 事件队列溢出等错误只会丢失部分事件，继续监听
+			if !ok {
+				return
+			}
+		case <-flush:
+			flush = nil
+			next := writeSettle
+			for path, last := range writing {
+				if wait := writeSettle - time.Since(last); wait > 0 {
+					next = min(next, wait)
+					continue
+				}
+				delete(writing, path)
+				if !w.emit(Event{Op: Modified, Path: path}) {
+					return
+				}
+			}
+			if len(writing) > 0 {
+				flush = time.After(next)
+			}
+		}
+	}
+}
+
+// handleNative 转换一个fsnotify事件，新建或移入的目录加入监听；已关闭时返回false
+func (w *Watcher) handleNative(n *nativeWatcher, ev fsnotify.Event, writing map[string]time.Time) bool {
+	path := filepath.Clean(ev.Name)
+	if path == w.root || w.ignore(filepath.Base(path)) {
+		return true
+	}
+	switch {
+	case ev.Has(fsnotify.Create):
+		info, err := os.Lstat(path)
+		isDir := err == nil && info.IsDir()
+		if isDir {
+			// 达到监听数上限时新目录内的变化收不到，不影响已有目录
+			n.addTree(path, w.ignore)
+		}
+		return w.emit(Event{Op: Created, Path: path, IsDir: isDir})
+	case ev.Has(fsnotify.Write):
+		writing[path] = time.Now()
+		return true
+	case ev.Has(fsnotify.Remove), ev.Has(fsnotify.Rename):
+		delete(writing, path)
+		isDir := n.dirs[path]
+		if isDir {
+			n.removeTree(path)
+		}
+		return w.emit(Event{Op: Deleted, Path: path, IsDir: isDir})
+	}
+	return true
+}
@@ -0,0 +1,161 @@
+package watcher
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 递归监听目录树中文件和目录的创建、修改、删除：使用fsnotify（系统的文件变化通知），平台不支持或
+// 监听失败（如超出 fs.inotify.max_user_watches）时定期扫描比对
+
+// Op 变化类型
+type Op string
+
+const (
+	Created  Op = "created"  // 创建或移入
+	Modified Op = "modified" // 写入完成（停止写入一段时间后）
+	Deleted  Op = "deleted"  // 删除或移出
+)
+
+// Event 一次变化
+type Event struct {
+	Op    Op
+	Path  string // 绝对路径
+	IsDir bool
+}
+
+// PollInterval 扫描比对方式的扫描间隔
+var PollInterval = 2 * time.Second
+
+// Watcher 目录树监听器，变化通过Events读取，不再使用时调用Close
+type Watcher struct {
+	Events chan Event
+
+	root   string
+	ignore func(name string) bool // 忽略的文件名（不监听该目录，也不产生事件）
+	mode   string
+	done   chan struct{}
+	once   sync.Once
+	stop   func() // 停止底层监听
+}
+
+// New 开始监听root目录树，ignore为nil时不忽略任何文件
+func New(root string, ignore func(name string) bool) (*Watcher, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(root); err != nil {
+		return nil, err
+	}
+	if ignore == nil {
+		ignore = func(string) bool { return false }
+	}
+	w := &Watcher{Events: make(chan Event, 256), root: root, ignore: ignore, done: make(chan struct{})}
+	if err := w.startNative(); err != nil {
+		w.startPoll()
+	}
+	return w, nil
+}
+
+// Mode 返回监听方式：fsnotify 或 poll
+func (w *Watcher) Mode() string {
+	return w.mode
+}
+
+// Close 停止监听并关闭Events
+func (w *Watcher) Close() error {
+	w.once.Do(func() {
+		close(w.done)
+		if w.stop != nil {
+			w.stop()
+		}
+	})
+	return nil
+}
+
+// emit 发送事件，已关闭时返回false
+func (w *Watcher) emit(e Event) bool {
+	if w.ignore(filepath.Base(e.Path)) {
+		return true
+	}
+	select {
+	case w.Events <- e:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+// fileState 扫描比对时记录的文件状态
+type fileState struct {
+	size    int64
+	modTime time.Time
+	isDir   bool
+}
+
+// startPoll 以定期扫描比对的方式监听
+func (w *Watcher) startPoll() {
+	w.mode = "poll"
+	go func() {
+		defer close(w.Events)
+		previous := w.scan()
+		ticker := time.NewTicker(PollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.done:
+				return
+			case <-ticker.C:
+			}
+			current := w.scan()
+			for path, state := range current {
+				old, ok := previous[path]
+				switch {
+				case !ok:
+					if !w.emit(Event{Op: Created, Path: path, IsDir: state.isDir}) {
+						return
+					}
+				case !state.isDir && (old.size != state.size || !old.modTime.Equal(state.modTime)):
+					if !w.emit(Event{Op: Modified, Path: path}) {
+						return
+					}
+				}
+			}
+			for path, state := range previous {
+				if _, ok := current[path]; !ok {
+					if !w.emit(Event{Op: Deleted, Path: path, IsDir: state.isDir}) {
+						return
+					}
+				}
+			}
+			previous = current
+		}
+	}()
+}
+
+// scan 记录目录树中所有文件和目录的状态（跳过忽略的条目）
+func (w *Watcher) scan() map[string]fileState {
+	states := map[string]fileState{}
+	filepath.WalkDir(w.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == w.root {
+			return nil
+		}
+		if w.ignore(d.Name()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		states[path] = fileState{size: info.Size(), modTime: info.ModTime(), isDir: d.IsDir()}
+		return nil
+	})
+	return states
+}
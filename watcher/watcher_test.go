@@ -0,0 +1,167 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	PollInterval = 50 * time.Millisecond
+	writeSettle = 100 * time.Millisecond
+	os.Exit(m.Run())
+}
+
+// ignoreHidden 忽略隐藏文件和目录
+func ignoreHidden(name string) bool {
+	return strings.HasPrefix(name, ".")
+}
+
+// newPollWatcher 以扫描比对方式监听（与New相同，但不尝试fsnotify）
+func newPollWatcher(t *testing.T, root string) *Watcher {
+	t.Helper()
+	w := &Watcher{Events: make(chan Event, 256), root: root, ignore: ignoreHidden, done: make(chan struct{})}
+	w.startPoll()
+	return w
+}
+
+// expectEvents 等待want中的事件全部到达（顺序不限），返回期间收到的所有事件
+func expectEvents(t *testing.T, w *Watcher, want ...Event) []Event {
+	t.Helper()
+	var got []Event
+	missing := map[Event]bool{}
+	for _, e := range want {
+		missing[e] = true
+	}
+	timeout := time.After(5 * time.Second)
+	for len(missing) > 0 {
+		select {
+		case e, ok := <-w.Events:
+			if !ok {
+				t.Fatalf("Events已关闭，仍缺少%v", missing)
+			}
+			got = append(got, e)
+			delete(missing, e)
+		case <-timeout:
+			t.Fatalf("等待事件超时，缺少%v，收到%v", missing, got)
+		}
+	}
+	return got
+}
+
+// 创建、写入、重命名、删除文件和目录，新建的子目录自动加入监听，忽略的文件不产生事件
+func TestWatcherTree(t *testing.T) {
+	for _, mode := range []string{"fsnotify", "poll"} {
+		t.Run(mode, func(t *testing.T) {
+			root := t.TempDir()
+			os.Mkdir(filepath.Join(root, "old"), 0755)
+			var w *Watcher
+			if mode == "poll" {
+				w = newPollWatcher(t, root)
+			} else {
+				var err error
+				if w, err = New(root, ignoreHidden); err != nil {
+					t.Fatalf("创建监听失败: %v", err)
+				}
+				if w.Mode() != "fsnotify" {
+					t.Fatalf("Mode=%s，期望fsnotify", w.Mode())
+				}
+			}
+			defer w.Close()
+			if mode == "poll" {
+				// 等待首次扫描完成，之后的变化才能比对出来
+				time.Sleep(2 * PollInterval)
+			}
+			join := func(names ...string) string { return filepath.Join(append([]string{root}, names...)...) }
+
+			os.Mkdir(join("a"), 0755)
+			expectEvents(t, w, Event{Op: Created, Path: join("a"), IsDir: true})
+
+			os.Mkdir(join("a", "b"), 0755)
+			expectEvents(t, w, Event{Op: Created, Path: join("a", "b"), IsDir: true})
+
+			os.WriteFile(join("a", "b", "f.txt"), []byte("hello"), 0644)
+			os.WriteFile(join("a", "b", ".f.txt.upload"), []byte("{}"), 0644)
+			os.Mkdir(join(".hidden"), 0755)
+			// 扫描比对方式在一次扫描间隔内创建并写完的文件只产生创建事件
+			want := []Event{{Op: Created, Path: join("a", "b", "f.txt")}}
+			if mode == "fsnotify" {
+				want = append(want, Event{Op: Modified, Path: join("a", "b", "f.txt")})
+			}
+			got := expectEvents(t, w, want...)
+
+			os.Rename(join("a", "b", "f.txt"), join("a", "g.txt"))
+			got = append(got, expectEvents(t, w,
+				Event{Op: Deleted, Path: join("a", "b", "f.txt")},
+				Event{Op: Created, Path: join("a", "g.txt")},
+			)...)
+
+			// 移动目录后，新位置下的子目录继续监听
+			os.Rename(join("a"), join("c"))
+			got = append(got, expectEvents(t, w,
+				Event{Op: Deleted, Path: join("a"), IsDir: true},
+				Event{Op: Created, Path: join("c"), IsDir: true},
+			)...)
+			os.WriteFile(join("c", "b", "h.txt"), []byte("x"), 0644)
+			got = append(got, expectEvents(t, w, Event{Op: Created, Path: join("c", "b", "h.txt")})...)
+
+			os.RemoveAll(join("c"))
+			os.Remove(join("old"))
+			got = append(got, expectEvents(t, w,
+				Event{Op: Deleted, Path: join("c"), IsDir: true},
+				Event{Op: Deleted, Path: join("old"), IsDir: true},
+			)...)
+
+			// 关闭后读出剩余的事件：每个删除只应报告一次
+			time.Sleep(4 * PollInterval)
+			w.Close()
+			for e := range w.Events {
+				got = append(got, e)
+			}
+			deleted := map[string]int{}
+			for _, e := range got {
+				if strings.Contains(e.Path, string(filepath.Separator)+".") {
+					t.Fatalf("忽略的文件不应产生事件: %+v", e)
+				}
+				if e.Op == Deleted {
+					deleted[e.Path]++
+				}
+			}
+			for path, count := range deleted {
+				if count != 1 {
+					t.Fatalf("%s的删除事件%d次，期望1次", path, count)
+				}
+			}
+		})
+	}
+}
+
+// 连续写入只在停止写入后产生一次修改事件
+func TestWatcherWriteSettle(t *testing.T) {
+	root := t.TempDir()
+	w, err := New(root, nil)
+	if err != nil {
+		t.Fatalf("创建监听失败: %v", err)
+	}
+	defer w.Close()
+
+	path := filepath.Join(root, "big.bin")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("创建文件失败: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		f.Write([]byte("0123456789"))
+		time.Sleep(writeSettle / 10)
+	}
+	f.Close()
+	expectEvents(t, w, Event{Op: Created, Path: path}, Event{Op: Modified, Path: path})
+
+	select {
+	case e := <-w.Events:
+		t.Fatalf("停止写入后只应有一次修改事件，又收到%+v", e)
+	case <-time.After(3 * writeSettle):
+	}
+}